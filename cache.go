@@ -0,0 +1,14 @@
+package getter
+
+// Cache is an interface that knows how to store downloaded files, so that
+// downloading the same source with the same checksum again is served from
+// local storage. See Client.Cache.
+type Cache interface {
+	// Get copies the file cached for the key to dst, and returns whether
+	// there was one.
+	Get(key, dst string) (bool, error)
+
+	// Put stores a copy of the file at src for the key, replacing the file
+	// cached for it, if any.
+	Put(key, src string) error
+}
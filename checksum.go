@@ -0,0 +1,84 @@
+package getter
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+)
+
+// Checksummers is the mapping of checksum types, the prefix of the
+// "checksum" query parameter such as "sha256", to the constructor of the
+// hash that computes that checksum. Additional algorithms, such as BLAKE2 or
+// SHA3, can be registered here before downloading.
+var Checksummers map[string]func() hash.Hash
+
+func init() {
+	Checksummers = map[string]func() hash.Hash{
+		"md5":    md5.New,
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+	}
+}
+
+// checksumSidecar is the record of a checksum cache sidecar, written next to
+// a downloaded file as "<file>.checksum". It holds the verified checksum,
+// Ex., 'sha256:66a045b4...', with the size and modification time of the
+// file when it was verified, so that an unchanged file can be trusted
+// without hashing it again.
+type checksumSidecar struct {
+	Checksum string
+	Size     int64
+	ModTime  int64
+}
+
+// checksumSidecarPath returns the path of the checksum sidecar of path.
+func checksumSidecarPath(path string) string {
+	return path + ".checksum"
+}
+
+// readChecksumSidecar reads the checksum sidecar of path.
+func readChecksumSidecar(path string) (*checksumSidecar, error) {
+	b, err := ioutil.ReadFile(checksumSidecarPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var s checksumSidecar
+	if _, err := fmt.Sscanf(string(b), "%s %d %d", &s.Checksum, &s.Size, &s.ModTime); err != nil {
+		return nil, fmt.Errorf("invalid checksum sidecar of %s: %s", path, err)
+	}
+	return &s, nil
+}
+
+// writeChecksumSidecar records that the file at path, as it is now, has
+// the given checksum.
+func writeChecksumSidecar(path, checksum string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(checksumSidecarPath(path), []byte(fmt.Sprintf(
+		"%s %d %d\n", checksum, fi.Size(), fi.ModTime().UnixNano())), 0644)
+}
+
+// matchesChecksumSidecar returns whether the file at path is unchanged since
+// its checksum sidecar recorded it had the given checksum.
+func matchesChecksumSidecar(path, checksum string) bool {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+
+	s, err := readChecksumSidecar(path)
+	if err != nil {
+		return false
+	}
+	return s.Checksum == checksum && s.Size == fi.Size() && s.ModTime == fi.ModTime().UnixNano()
+}
@@ -2,19 +2,18 @@ package getter
 
 import (
 	"bytes"
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
+	"context"
 	"encoding/hex"
 	"fmt"
 	"hash"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	urlhelper "github.com/hashicorp/go-getter/helper/url"
 )
@@ -50,10 +49,187 @@ type Client struct {
 	// If this is nil, then the default value is the Decompressors global.
 	Decompressors map[string]Decompressor
 
+	// RecursiveDecompress is the number of times an archive found inside
+	// an archive is extracted as well, e.g. for a release that is a
+	// .tar.gz containing a single .zip. After an archive is extracted into
+	// a directory, if the directory holds nothing but another archive, that
+	// archive replaces it with its contents. An error is returned if an
+	// archive is still left once RecursiveDecompress archives were
+	// extracted. If this is zero, nested archives are kept as is.
+	RecursiveDecompress int
+
+	// NoDecompress, if true, disables decompression entirely, so that
+	// archives are saved verbatim even if their extension or "archive"
+	// query parameter is recognized.
+	NoDecompress bool
+
+	// DecompressStream, if true, makes GetStream decompress gzip, bzip2,
+	// xz and lz4 sources while they are read, based on their extension or
+	// "archive" query parameter.
+	DecompressStream bool
+
+	// StreamArchives, if true, extracts tar archives downloaded into a
+	// directory while they are downloaded, instead of saving the archive to
+	// a temporary file first, which halves the disk space needed for large
+	// archives. This is supported by getters implementing Streamer, such as
+	// the HTTP getter, and by the tar based Decompressors unless
+	// CheckDiskSpace is set. Archives that are checksummed, kept, extracted
+	// with archive_entry, or have mirrors are still saved first. If the
+	// download fails, Dst may be left partially extracted.
+	StreamArchives bool
+
 	// Getters is the map of protocols supported by this client. If this
 	// is nil, then the default Getters variable will be used.
 	Getters map[string]Getter
 
+	// Checksummers is the map of checksum types supported by this client.
+	// If this is nil, then the default Checksummers variable will be used.
+	Checksummers map[string]func() hash.Hash
+
+	// Logger is used for logging by the client and its getters. If this is
+	// nil, nothing is logged.
+	Logger Logger
+
+	// Hooks, if set, are called at key stages of the download, e.g. to
+	// trace how the source is fetched.
+	Hooks *Hooks
+
+	// ProgressTracker, if set, is notified of the progress of the files
+	// downloaded by getters supporting it, such as the HTTP and Maven
+	// getters.
+	ProgressTracker ProgressTracker
+
+	// Ctx, if set, is the context the source is downloaded with. When it is
+	// cancelled or its deadline expires, the requests and commands of the
+	// getters supporting it, such as the HTTP, Maven, git and hg getters,
+	// are aborted and Get returns an error.
+	Ctx context.Context
+
+	// URLRewrite, if set, is called with the URL of the source after it
+	// was detected, before a getter is selected for it, and its result is
+	// downloaded instead. This lets integrators redirect public URLs to
+	// internal mirrors, or add tokens to them, in a single place. It is also
+	// called for mirrors, and for the URLs the MvnGetter derives from the
+	// source, such as those of the artifact and its metadata, so it should
+	// return the URLs it already rewrote as is. The URL can be modified in
+	// place. If it returns an error, the download is aborted with it.
+	URLRewrite func(*url.URL) (*url.URL, error)
+
+	// Retries is the number of times a download failing with a transient
+	// error is retried, waiting RetryBackoff, 1s by default, before the
+	// first retry and doubling the wait before each next one, up to a
+	// minute. Retryable classifies the errors that are retried. If it is
+	// nil, network errors, 5xx and 429 responses of the HTTP and Maven
+	// getters, and S3 throttling are retried. Downloads are not retried
+	// once Ctx is done.
+	Retries      int
+	RetryBackoff time.Duration
+	Retryable    func(error) bool
+
+	// Timeout, if non-zero, is the time the getter has to download the
+	// source, after which it is aborted like when Ctx is cancelled, and a
+	// *TimeoutError naming the scheme of the getter is returned. Timeouts
+	// overrides it for the getters of specific schemes, keyed like Getters,
+	// Ex., a longer timeout for "git" clones than for "http" files. A zero
+	// duration in Timeouts means no timeout for that scheme. Only getters
+	// supporting Ctx can be aborted.
+	Timeout  time.Duration
+	Timeouts map[string]time.Duration
+
+	// KeepArchive, if true, keeps the downloaded archive after it was
+	// decompressed, at KeepArchivePath. If KeepArchivePath is empty, the
+	// archive is kept next to Dst, as Dst with the archive type appended,
+	// e.g. "foo.tar.gz".
+	KeepArchive     bool
+	KeepArchivePath string
+
+	// Username and Password are the credentials used for basic auth by
+	// getters supporting it, such as the HTTP and Maven getters, so that
+	// they don't have to be put in the URL.
+	Username string
+	Password string
+
+	// RateLimit, if non-zero, caps the rate sources are downloaded at by
+	// getters supporting it, the HTTP, Maven, S3, GCS, Azure, OCI and SFTP
+	// getters, in bytes per second, so that large downloads don't starve
+	// others of bandwidth.
+	RateLimit int64
+
+	// Mirrors is a list of alternative sources for the file being
+	// downloaded. If downloading the file from Src fails, or its checksum
+	// doesn't match, each mirror is tried in order. Mirrors can also be
+	// given with the repeatable "mirror" query parameter of Src, which are
+	// tried first. Mirrors are only used in file mode.
+	Mirrors []string
+
+	// ChecksumCache, if true, writes a "<Dst>.checksum" sidecar next to
+	// files downloaded in file mode with a checksum, recording the checksum
+	// with the size and modification time of the file. Later downloads with
+	// the same checksum skip the download and trust the file if it is
+	// unchanged since. If VerifyStrict is set as well, the checksum of an
+	// existing file is always computed again instead of trusting the
+	// sidecar. Archives that are decompressed aren't cached, and neither
+	// are Atomic downloads.
+	ChecksumCache bool
+	VerifyStrict  bool
+
+	// Cache, if set, stores the files downloaded in file mode with a
+	// checksum, including archives before they are unarchived, keyed by
+	// their URL and checksum. Downloading the same source with the same
+	// checksum again copies the cached file instead, after verifying it
+	// against the checksum. Files that don't match are downloaded again.
+	// BypassCache, if true, doesn't use the cached files, downloading the
+	// source again and replacing its cached file.
+	Cache       Cache
+	BypassCache bool
+
+	// Atomic, if true, downloads into a temporary path next to Dst and
+	// only moves the result into place once everything succeeded, so that
+	// Dst is never left half-written. Anything already at Dst is replaced
+	// as a whole.
+	Atomic bool
+
+	// TempDir is the directory temporary files and directories are created
+	// in by the client and its getters. If this is empty, the system's
+	// temporary directory is used, which honors TMPDIR.
+	TempDir string
+
+	// TempPattern, if set, prefixes the names of the temporary files and
+	// directories created by the client and the Maven getter, Ex., "myapp-",
+	// so that they are easy to tell apart.
+	//
+	// KeepTempOnError, if true, keeps them when the download fails, such as
+	// the downloaded archive or the Maven metadata, for post-mortem analysis.
+	// Their paths are added to the error. They are still removed when the
+	// download succeeds.
+	TempPattern     string
+	KeepTempOnError bool
+
+	// OutputChecksum, if set, is the type of checksum, such as "sha256", to
+	// compute of the file downloaded in file mode, or of the archive before
+	// it is unarchived, to record what was downloaded. The checksum is
+	// returned in the Result of GetResult. Getters supporting it, such as
+	// HTTP, compute it while downloading, so the file isn't read again.
+	OutputChecksum string
+
+	// result is the Result of the download in progress, if any, and
+	// outputHash the hash computing its output checksum.
+	result     *Result
+	outputHash *outputHash
+
+	// timeoutCtx is Ctx bounded by the timeout of the getter downloading
+	// the source, if any.
+	timeoutCtx context.Context
+
+	// keptTemp are the temporary paths kept by KeepTempOnError until the
+	// download in progress finished.
+	keptTemp []string
+
+	// finalDst is the Dst of the Client downloading atomically into the
+	// temporary Dst of this one, which the default KeepArchivePath is next
+	// to.
+	finalDst string
+
 	// Dir, if true, tells the Client it is downloading a directory (versus
 	// a single file). This distinction is necessary since filenames and
 	// directory names follow the same format so disambiguating is impossible
@@ -63,8 +239,83 @@ type Client struct {
 	Dir bool
 }
 
+// Result describes a completed download.
+type Result struct {
+	// Checksum is the checksum of the downloaded file computed for
+	// OutputChecksum, formatted like the "checksum" query parameter, e.g.
+	// "sha256:<hex>". It is empty if OutputChecksum isn't set, or nothing
+	// was downloaded in file mode.
+	Checksum string
+
+	// Mode is what the source was downloaded as, ClientModeFile or
+	// ClientModeDir. With ClientModeAny, it is the mode given by the
+	// getter, or ClientModeDir for archives that are unarchived.
+	Mode ClientMode
+}
+
+// recordMode records the mode the source is downloaded as on the Result, if
+// any.
+func (c *Client) recordMode(mode ClientMode) {
+	if c.result != nil {
+		c.result.Mode = mode
+	}
+}
+
+// GetResult downloads the configured source to the destination like Get,
+// and returns the Result of the download.
+func (c *Client) GetResult() (*Result, error) {
+	c.result = new(Result)
+	defer func() { c.result = nil }()
+
+	if err := c.Get(); err != nil {
+		return nil, err
+	}
+	return c.result, nil
+}
+
 // Get downloads the configured source to the destination.
 func (c *Client) Get() error {
+	if c.Ctx != nil {
+		if err := c.Ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	c.keptTemp = nil
+	err := c.getOnce()
+	for retry := 1; err != nil && retry <= c.Retries && c.retryable(err); retry++ {
+		backoff := c.retryBackoff(retry)
+		c.logger().Printf("error downloading '%s', retrying in %s (%d/%d): %s", redactURL(c.Src), backoff, retry, c.Retries, err)
+		if sleepErr := c.sleep(backoff); sleepErr != nil {
+			break
+		}
+		err = c.getOnce()
+	}
+	return c.cleanTemp(err)
+}
+
+// getOnce downloads the configured source to the destination, atomically if
+// Atomic is set.
+func (c *Client) getOnce() error {
+	if c.Atomic {
+		return c.getAtomic()
+	}
+	return c.get()
+}
+
+// GetWithContext downloads the configured source to the destination like
+// Get, with ctx as the Ctx of the download, so that servers embedding the
+// Client can cancel it or give it a deadline. The download is made by a
+// copy of the Client with ctx, which the getter is bound to, so that c is
+// left as is and concurrent calls don't cancel each other's downloads.
+func (c *Client) GetWithContext(ctx context.Context) error {
+	cc := *c
+	cc.Ctx = ctx
+	return cc.Get()
+}
+
+// get downloads the configured source to the destination, see Get.
+func (c *Client) get() (err error) {
 	// Store this locally since there are cases we swap this
 	mode := c.Mode
 	if mode == ClientModeInvalid {
@@ -75,6 +326,12 @@ func (c *Client) Get() error {
 		}
 	}
 
+	// Fail early, before downloading anything, if we can't write to the
+	// destination
+	if err := checkWritable(c.Dst); err != nil {
+		return err
+	}
+
 	// Default decompressor value
 	decompressors := c.Decompressors
 	if decompressors == nil {
@@ -90,6 +347,10 @@ func (c *Client) Get() error {
 	if err != nil {
 		return err
 	}
+	if src != c.Src {
+		c.logger().Printf("detected '%s' as '%s'", redactURL(c.Src), redactURL(src))
+	}
+	c.Hooks.detected(c.Src, src)
 
 	// Determine if we have a forced protocol, i.e. "git::http://..."
 	force, src := getForcedGetter(src)
@@ -100,14 +361,14 @@ func (c *Client) Get() error {
 	dst := c.Dst
 	src, subDir := SourceDirSubdir(src)
 	if subDir != "" {
-		tmpDir, err := ioutil.TempDir("", "tf")
+		tmpDir, err := ioutil.TempDir(c.TempDir, c.TempPattern+"tf")
 		if err != nil {
 			return err
 		}
 		if err := os.RemoveAll(tmpDir); err != nil {
 			return err
 		}
-		defer os.RemoveAll(tmpDir)
+		defer c.removeTemp(tmpDir)
 
 		realDst = dst
 		dst = tmpDir
@@ -117,6 +378,9 @@ func (c *Client) Get() error {
 	if err != nil {
 		return err
 	}
+	if u, err = c.rewriteURL(u); err != nil {
+		return err
+	}
 	if force == "" {
 		force = u.Scheme
 	}
@@ -132,6 +396,29 @@ func (c *Client) Get() error {
 			"download not supported for scheme '%s'", force)
 	}
 
+	c.logger().Printf("using %s getter for '%s://%s%s'", force, u.Scheme, u.Host, u.Path)
+	c.Hooks.getterSelected(force, g)
+
+	// Bound the download by the timeout of the getter, if any
+	if timeout := c.timeout(force); timeout > 0 {
+		stopTimeout := c.startTimeout(force, timeout)
+		defer func() { err = stopTimeout(err) }()
+	}
+
+	// Give the getter access to the client, e.g. for logging
+	g = bindGetter(g, c)
+
+	// Determine if we have a checksum
+	checksumType := strings.SplitN(u.Query().Get("checksum"), ":", 2)[0]
+	checksumHash, checksumValue, err := c.checksumParam(u)
+	if err != nil {
+		return err
+	}
+	newOutputHash, err := c.outputChecksummer()
+	if err != nil {
+		return err
+	}
+
 	// We have magic query parameters that we use to signal different features
 	q := u.Query()
 
@@ -149,15 +436,37 @@ func (c *Client) Get() error {
 			archiveV = "-"
 		}
 	}
+	if c.NoDecompress {
+		archiveV = "-"
+	}
+
+	// Determine if we only extract a single entry of the archive
+	archiveEntry := q.Get("archive_entry")
+	if archiveEntry != "" {
+		q.Del("archive_entry")
+		u.RawQuery = q.Encode()
+
+		if mode != ClientModeFile {
+			return fmt.Errorf("archive_entry can only be used in file mode")
+		}
+		if containsDotDot(archiveEntry) {
+			return fmt.Errorf("archive_entry cannot contain '..': %s", archiveEntry)
+		}
+	}
+	// Determine if we have a custom file name, used instead of the one of
+	// the source in "any" mode
+	filename := q.Get("filename")
+	if filename != "" {
+		q.Del("filename")
+		u.RawQuery = q.Encode()
+
+		if strings.ContainsAny(filename, `/\`) || filename == "." || filename == ".." {
+			return fmt.Errorf("filename must be a file name, without directories: %s", filename)
+		}
+	}
 	if archiveV == "" {
 		// We don't appear to... but is it part of the filename?
-		matchingLen := 0
-		for k, _ := range decompressors {
-			if strings.HasSuffix(u.Path, "."+k) && len(k) > matchingLen {
-				archiveV = k
-				matchingLen = len(k)
-			}
-		}
+		archiveV = matchDecompressor(u.Path, decompressors)
 	}
 
 	// If we have a decompressor, then we need to change the destination
@@ -166,15 +475,56 @@ func (c *Client) Get() error {
 	var decompressDst string
 	var decompressDir bool
 	decompressor := decompressors[archiveV]
-	if decompressor != nil {
+	if archiveEntry != "" && decompressor == nil {
+		return fmt.Errorf("archive_entry can only be used with an archive")
+	}
+	// Determine if we have mirrors to fall back to
+	var mirrors []string
+	if vs, ok := q["mirror"]; ok {
+		// Delete the query parameter if we have it.
+		q.Del("mirror")
+		u.RawQuery = q.Encode()
+
+		mirrors = append(mirrors, vs...)
+	}
+	mirrors = append(mirrors, c.Mirrors...)
+
+	// Extract the archive while it is downloaded if we can, and nothing
+	// needs the archive itself
+	var streamer Streamer
+	if c.StreamArchives && mode != ClientModeFile && archiveEntry == "" && checksumHash == nil &&
+		newOutputHash == nil && !c.KeepArchive && len(mirrors) == 0 && asReaderDecompressor(decompressor) != nil {
+		streamer, _ = g.(Streamer)
+	}
+
+	if streamer != nil {
+		c.logger().Printf("using %s decompressor, extracting while downloading", archiveV)
+		if err := c.streamArchive(streamer, asReaderDecompressor(decompressor), archiveV, dst, u); err != nil {
+			return err
+		}
+
+		if c.RecursiveDecompress > 0 {
+			if err := c.decompressNested(dst, decompressors); err != nil {
+				return err
+			}
+		}
+		if c.Logger != nil {
+			c.logExtracted(dst)
+		}
+
+		mode = ClientModeDir
+		c.recordMode(mode)
+	} else if decompressor != nil {
+		c.logger().Printf("using %s decompressor", archiveV)
+
 		// Create a temporary directory to store our archive. We delete
 		// this at the end of everything.
-		td, err := ioutil.TempDir("", "getter")
+		td, err := ioutil.TempDir(c.TempDir, c.TempPattern+"getter")
 		if err != nil {
 			return fmt.Errorf(
 				"Error creating temporary directory for archive: %s", err)
 		}
-		defer os.RemoveAll(td)
+		defer c.removeTemp(td)
 
 		// Swap the download directory to be our temporary path and
 		// store the old values.
@@ -184,44 +534,6 @@ func (c *Client) Get() error {
 		mode = ClientModeFile
 	}
 
-	// Determine if we have a checksum
-	var checksumHash hash.Hash
-	var checksumValue []byte
-	if v := q.Get("checksum"); v != "" {
-		// Delete the query parameter if we have it.
-		q.Del("checksum")
-		u.RawQuery = q.Encode()
-
-		// Determine the checksum hash type
-		checksumType := ""
-		idx := strings.Index(v, ":")
-		if idx > -1 {
-			checksumType = v[:idx]
-		}
-		switch checksumType {
-		case "md5":
-			checksumHash = md5.New()
-		case "sha1":
-			checksumHash = sha1.New()
-		case "sha256":
-			checksumHash = sha256.New()
-		case "sha512":
-			checksumHash = sha512.New()
-		default:
-			return fmt.Errorf(
-				"unsupported checksum type: %s", checksumType)
-		}
-
-		// Get the remainder of the value and parse it into bytes
-		b, err := hex.DecodeString(v[idx+1:])
-		if err != nil {
-			return fmt.Errorf("invalid checksum: %s", err)
-		}
-
-		// Set our value
-		checksumValue = b
-	}
-
 	if mode == ClientModeAny {
 		// Ask the getter which client mode to use
 		mode, err = g.ClientMode(u)
@@ -232,56 +544,150 @@ func (c *Client) Get() error {
 		// Destination is the base name of the URL path in "any" mode when
 		// a file source is detected.
 		if mode == ClientModeFile {
-			filename, err := g.GetFilename(u)
-			if err != nil {
-				return err
+			if filename == "" {
+				filename, err = g.GetFilename(u)
+				if err != nil {
+					return err
+				}
 			}
-
 			if filename == "" {
 				filename = filepath.Base(u.Path)
-
-				// Determine if we have a custom file name
-				if v := q.Get("filename"); v != "" {
-					// Delete the query parameter if we have it.
-					q.Del("filename")
-					u.RawQuery = q.Encode()
-
-					filename = v
-				}
 			}
 
 			dst = filepath.Join(dst, filename)
 		}
 	}
+	if decompressor == nil {
+		c.recordMode(mode)
+	}
 
 	// If we're not downloading a directory, then just download the file
 	// and return.
 	if mode == ClientModeFile {
-		err := g.GetFile(dst, u)
-		if err != nil {
-			return err
+		// The checksum to cache next to the file, Ex., 'sha256:66a045b4...'
+		var cacheChecksum string
+		if c.ChecksumCache && checksumHash != nil && decompressor == nil {
+			cacheChecksum = checksumType + ":" + hex.EncodeToString(checksumValue)
+
+			if c.cachedChecksum(dst, cacheChecksum, checksumHash, checksumValue) {
+				c.logger().Printf("'%s' has checksum %s, skipping download", dst, cacheChecksum)
+				if newOutputHash != nil {
+					c.outputHash = &outputHash{Hash: newOutputHash(), path: dst}
+					defer func() { c.outputHash = nil }()
+					return c.recordOutputChecksum(dst)
+				}
+				return nil
+			}
 		}
 
-		if checksumHash != nil {
-			if err := checksum(dst, checksumHash, checksumValue); err != nil {
-				return err
+		getFile := func(g Getter, u *url.URL) error {
+			if newOutputHash != nil {
+				c.outputHash = &outputHash{Hash: newOutputHash(), path: dst}
+				defer func() { c.outputHash = nil }()
+			}
+
+			// Files with a checksum are served from the Cache if it has them
+			var cacheKey string
+			if c.Cache != nil && checksumHash != nil {
+				cacheKey = redactURL(u.String()) + " " + checksumType + ":" + hex.EncodeToString(checksumValue)
+			}
+			cached := false
+			if cacheKey != "" && !c.BypassCache {
+				var err error
+				cached, err = c.getCached(cacheKey, dst, checksumHash, checksumValue)
+				if err != nil {
+					return err
+				}
+			}
+
+			if !cached {
+				if err := g.GetFile(dst, u); err != nil {
+					return err
+				}
+
+				if checksumHash != nil {
+					checksumHash.Reset()
+					if err := checksum(dst, checksumHash, checksumValue); err != nil {
+						return err
+					}
+				}
+				if cacheKey != "" {
+					if err := c.Cache.Put(cacheKey, dst); err != nil {
+						c.logger().Printf("error caching '%s': %s", redactURL(u.String()), err)
+					}
+				}
+			}
+			if cacheChecksum != "" {
+				if err := writeChecksumSidecar(dst, cacheChecksum); err != nil {
+					return err
+				}
 			}
+
+			if c.outputHash != nil {
+				return c.recordOutputChecksum(dst)
+			}
+
+			return nil
+		}
+
+		err := getFile(g, u)
+		for _, mirror := range mirrors {
+			if err == nil {
+				break
+			}
+			c.logger().Printf("error downloading '%s', trying mirror '%s': %s", redactURL(src), redactURL(mirror), err)
+
+			var mg Getter
+			var mu *url.URL
+			mg, mu, err = c.mirrorGetter(mirror, detectors, getters)
+			if err == nil {
+				err = getFile(mg, mu)
+			}
+		}
+		if err != nil {
+			return err
 		}
 
 		if decompressor != nil {
 			// We have a decompressor, so decompress the current destination
-			// into the final destination with the proper mode.
-			err := decompressor.Decompress(decompressDst, dst, decompressDir)
+			// into the final destination with the proper mode. The archive
+			// passed its checksum above, so a tampered archive is never
+			// extracted.
+			var err error
+			c.Hooks.decompressStarted(archiveV, decompressDst)
+			if archiveEntry != "" {
+				err = c.extractEntry(decompressor, decompressDst, dst, archiveEntry)
+			} else {
+				err = decompressor.Decompress(decompressDst, dst, decompressDir)
+			}
+			c.Hooks.decompressFinished(archiveV, decompressDst, err)
 			if err != nil {
 				return err
 			}
 
+			if decompressDir && c.RecursiveDecompress > 0 {
+				if err := c.decompressNested(decompressDst, decompressors); err != nil {
+					return err
+				}
+			}
+
+			if c.KeepArchive {
+				if err := c.keepArchive(dst, archiveV); err != nil {
+					return err
+				}
+			}
+			if c.Logger != nil {
+				c.logExtracted(decompressDst)
+			}
+
 			// Swap the information back
 			dst = decompressDst
 			if decompressDir {
 				mode = ClientModeAny
+				c.recordMode(ClientModeDir)
 			} else {
 				mode = ClientModeFile
+				c.recordMode(ClientModeFile)
 			}
 		}
 
@@ -307,7 +713,12 @@ func (c *Client) Get() error {
 
 		// We're downloading a directory, which might require a bit more work
 		// if we're specifying a subdir.
-		err := g.Get(dst, u)
+		var err error
+		if sg, ok := g.(subdirGetter); ok && subDir != "" {
+			err = sg.GetSubdir(dst, u, subDir)
+		} else {
+			err = g.Get(dst, u)
+		}
 		if err != nil {
 			err = fmt.Errorf("error downloading '%s': %s", src, err)
 			return err
@@ -335,6 +746,574 @@ func (c *Client) Get() error {
 	return nil
 }
 
+// streamArchive extracts the archive at u into the directory dst while it
+// is downloaded with s.
+func (c *Client) streamArchive(s Streamer, d readerDecompressor, archiveV, dst string, u *url.URL) error {
+	r, err := s.GetReader(u)
+	if err != nil {
+		return fmt.Errorf("error downloading '%s': %s", redactURL(u.String()), err)
+	}
+	defer r.Close()
+
+	c.Hooks.decompressStarted(archiveV, dst)
+	err = d.decompressReader(dst, c.progressReader(u, r, -1), redactURL(u.String()), true)
+	c.Hooks.decompressFinished(archiveV, dst, err)
+	return err
+}
+
+// getCached copies the file cached for the key to dst, and returns whether
+// there was one matching the checksum. A cached file that doesn't match is
+// downloaded again, replacing it in the cache.
+func (c *Client) getCached(key, dst string, h hash.Hash, v []byte) (bool, error) {
+	ok, err := c.Cache.Get(key, dst)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	h.Reset()
+	if err := checksum(dst, h, v); err != nil {
+		c.logger().Printf("cached file of %s doesn't match, downloading it again: %s", key, err)
+		return false, nil
+	}
+	c.logger().Printf("using the cached file of %s", key)
+	return true, nil
+}
+
+// rewriteURL returns u rewritten by the URLRewrite hook, or u itself if
+// there is none.
+func (c *Client) rewriteURL(u *url.URL) (*url.URL, error) {
+	if c.URLRewrite == nil {
+		return u, nil
+	}
+
+	u2 := *u
+	rewritten, err := c.URLRewrite(&u2)
+	if err != nil {
+		return nil, fmt.Errorf("error rewriting '%s': %s", redactURL(u.String()), err)
+	}
+	if rewritten == nil {
+		return nil, fmt.Errorf("error rewriting '%s': no URL returned", redactURL(u.String()))
+	}
+	if rewritten.String() != u.String() {
+		// Log the URLs without their query, which the rewrite may have
+		// added credentials to, such as tokens or signatures
+		from, to := *u, *rewritten
+		from.RawQuery, to.RawQuery = "", ""
+		c.logger().Printf("rewrote '%s' to '%s'", redactURL(from.String()), redactURL(to.String()))
+	}
+	return rewritten, nil
+}
+
+// checkWritable checks that files can be created at the destination dst,
+// by creating and removing a temporary file in it if it's a directory, or
+// else in its closest existing parent directory.
+func checkWritable(dst string) error {
+	dir := filepath.Clean(dst)
+	if fi, err := os.Lstat(dir); err != nil || !fi.IsDir() {
+		dir = filepath.Dir(dir)
+		for {
+			if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+				break
+			}
+			dir = filepath.Dir(dir)
+		}
+	}
+
+	f, err := ioutil.TempFile(dir, ".getter-write-check")
+	if err != nil {
+		return fmt.Errorf("destination %s is not writable: %s", dst, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// removeTemp removes the temporary file or directory at path, or with
+// KeepTempOnError, keeps it until the download finished.
+func (c *Client) removeTemp(path string) {
+	if c.KeepTempOnError {
+		c.keptTemp = append(c.keptTemp, path)
+		return
+	}
+	os.RemoveAll(path)
+}
+
+// cleanTemp removes the temporary paths kept by KeepTempOnError if the
+// download succeeded, or adds them to the error err of the download.
+func (c *Client) cleanTemp(err error) error {
+	kept := c.keptTemp
+	c.keptTemp = nil
+	if err == nil {
+		for _, path := range kept {
+			os.RemoveAll(path)
+		}
+		return nil
+	}
+
+	if len(kept) == 0 {
+		return err
+	}
+	return fmt.Errorf("%s (kept temporary files: %s)", err, strings.Join(kept, ", "))
+}
+
+// getAtomic downloads the source into a temporary path alongside Dst, and
+// moves it into place once the download succeeded.
+func (c *Client) getAtomic() error {
+	dst := filepath.Clean(c.Dst)
+	parent := filepath.Dir(dst)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+
+	// The temporary path is on the same filesystem as Dst, so the result
+	// can be renamed into place
+	td, err := ioutil.TempDir(parent, "."+filepath.Base(dst)+"-getter")
+	if err != nil {
+		return err
+	}
+	defer c.removeTemp(td)
+
+	tmp := *c
+	tmp.Atomic = false
+	tmp.ChecksumCache = false
+	tmp.Retries = 0
+	tmp.Dst = filepath.Join(td, "dst")
+	tmp.finalDst = dst
+	if err := tmp.Get(); err != nil {
+		return err
+	}
+
+	return replacePath(dst, tmp.Dst, filepath.Join(td, "old"))
+}
+
+// replacePath moves src to dst, replacing anything at dst. The previous
+// contents of dst are moved to old first, and restored if the move fails.
+// If src can't be renamed, e.g. across filesystems, it is copied instead.
+func replacePath(dst, src, old string) error {
+	_, err := os.Lstat(dst)
+	exists := err == nil
+	if exists {
+		if err := os.Rename(dst, old); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		if err := copyPath(dst, src); err != nil {
+			os.RemoveAll(dst)
+			if exists {
+				os.Rename(old, dst)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyPath copies the file or directory src to dst.
+func copyPath(dst, src string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return copyFile(dst, src)
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	return copyDir(dst, src, false)
+}
+
+// matchDecompressor returns the key of the Decompressor for the longest
+// extension path ends with, or an empty string if there is none.
+func matchDecompressor(path string, decompressors map[string]Decompressor) string {
+	archiveV := ""
+	for k := range decompressors {
+		if strings.HasSuffix(path, "."+k) && len(k) > len(archiveV) {
+			archiveV = k
+		}
+	}
+	return archiveV
+}
+
+// decompressNested extracts the archive that is the only file in the
+// directory dst into it, as long as there is one, up to RecursiveDecompress
+// times. Single compressed files, such as "foo.gz", are decompressed in
+// place instead.
+func (c *Client) decompressNested(dst string, decompressors map[string]Decompressor) error {
+	for depth := 0; ; depth++ {
+		entries, err := ioutil.ReadDir(dst)
+		if err != nil {
+			return err
+		}
+		if len(entries) != 1 || !entries[0].Mode().IsRegular() {
+			return nil
+		}
+		name := entries[0].Name()
+		archiveV := matchDecompressor(name, decompressors)
+		if archiveV == "" {
+			return nil
+		}
+		if depth == c.RecursiveDecompress {
+			return fmt.Errorf(
+				"%s is an archive nested more than %d levels deep", name, c.RecursiveDecompress)
+		}
+
+		c.logger().Printf("extracting nested %s archive %s", archiveV, name)
+		if err := c.decompressFile(dst, name, decompressors[archiveV], archiveV); err != nil {
+			return err
+		}
+	}
+}
+
+// decompressFile moves the archive name out of the directory dst and
+// extracts it into dst.
+func (c *Client) decompressFile(dst, name string, d Decompressor, archiveV string) error {
+	td, err := ioutil.TempDir(c.TempDir, c.TempPattern+"getter")
+	if err != nil {
+		return err
+	}
+	defer c.removeTemp(td)
+
+	archive := filepath.Join(td, name)
+	if err := copyFile(archive, filepath.Join(dst, name)); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dst, name)); err != nil {
+		return err
+	}
+
+	dir := true
+	if _, ok := streamDecompressors[archiveV]; ok {
+		dir = false
+		dst = filepath.Join(dst, strings.TrimSuffix(name, "."+archiveV))
+	}
+
+	c.Hooks.decompressStarted(archiveV, dst)
+	err = d.Decompress(dst, archive, dir)
+	c.Hooks.decompressFinished(archiveV, dst, err)
+	return err
+}
+
+// extractEntry decompresses the archive at src into a temporary directory,
+// and copies its file at the slash-separated path entry to dst.
+func (c *Client) extractEntry(d Decompressor, dst, src, entry string) error {
+	td, err := ioutil.TempDir(c.TempDir, c.TempPattern+"getter")
+	if err != nil {
+		return err
+	}
+	defer c.removeTemp(td)
+
+	if err := d.Decompress(td, src, true); err != nil {
+		return err
+	}
+
+	path := filepath.Join(td, filepath.FromSlash(entry))
+	if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+		return fmt.Errorf("entry '%s' not found in archive", entry)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return copyFile(dst, path)
+}
+
+// keepArchive moves the downloaded archive at path to KeepArchivePath.
+func (c *Client) keepArchive(path, archiveV string) error {
+	keepPath := c.KeepArchivePath
+	if keepPath == "" {
+		dst := c.Dst
+		if c.finalDst != "" {
+			dst = c.finalDst
+		}
+		keepPath = filepath.Clean(dst) + "." + archiveV
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keepPath), 0755); err != nil {
+		return err
+	}
+
+	// The archive is in a temporary directory, which may be on another
+	// filesystem, so fall back to copying it
+	if err := os.Rename(path, keepPath); err != nil {
+		if err := copyFile(keepPath, path); err != nil {
+			return fmt.Errorf("error keeping archive: %s", err)
+		}
+	}
+
+	c.logger().Printf("kept archive at %s", keepPath)
+	return nil
+}
+
+// mirrorGetter detects the given mirror source and returns the Getter and
+// URL to download it with.
+func (c *Client) mirrorGetter(src string, detectors []Detector, getters map[string]Getter) (Getter, *url.URL, error) {
+	src, err := Detect(src, c.Pwd, detectors)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	force, src := getForcedGetter(src)
+	u, err := urlhelper.Parse(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u, err = c.rewriteURL(u); err != nil {
+		return nil, nil, err
+	}
+	if force == "" {
+		force = u.Scheme
+	}
+
+	g, ok := getters[force]
+	if !ok {
+		return nil, nil, fmt.Errorf(
+			"download not supported for scheme '%s'", force)
+	}
+	g = bindGetter(g, c)
+
+	return g, u, nil
+}
+
+// logExtracted logs every file extracted into dst.
+func (c *Client) logExtracted(dst string) {
+	filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			c.logger().Printf("extracted %s", path)
+		}
+		return nil
+	})
+}
+
+// redactedParams are the query parameters of sources carrying credentials,
+// which are redacted from logged URLs.
+var redactedParams = []string{
+	"access_token",
+	"account_key",
+	"apikey",
+	"aws_access_key_id",
+	"aws_access_key_secret",
+	"aws_access_token",
+	"aws_secret_access_key",
+	"aws_session_token",
+	"passphrase",
+	"password",
+	"sas",
+	"sig",
+	"sshkey",
+	"token",
+}
+
+// redactURL returns the source URL with any password and credentials in its
+// query replaced, so it can be logged.
+func redactURL(src string) string {
+	force, rawURL := getForcedGetter(src)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return src
+	}
+
+	redacted := false
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "redacted")
+			redacted = true
+		}
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for _, k := range redactedParams {
+			if _, ok := q[k]; ok {
+				q.Set(k, "redacted")
+				redacted = true
+			}
+		}
+		if redacted {
+			u.RawQuery = q.Encode()
+		}
+	}
+	if !redacted {
+		return src
+	}
+
+	if force != "" {
+		return force + "::" + u.String()
+	}
+	return u.String()
+}
+
+// logger returns the Logger of the client, or a Logger that discards
+// everything if none is set.
+func (c *Client) logger() Logger {
+	if c.Logger == nil {
+		return discardLogger{}
+	}
+	return c.Logger
+}
+
+// resolveGetter detects the source and returns the Getter for it, the key
+// it is registered under and the URL to pass to it. The Getter is given
+// access to the client. A subdirectory of the source is ignored.
+func (c *Client) resolveGetter() (Getter, string, *url.URL, error) {
+	// Detect the URL. This is safe if it is already detected.
+	detectors := c.Detectors
+	if detectors == nil {
+		detectors = Detectors
+	}
+	src, err := Detect(c.Src, c.Pwd, detectors)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	c.Hooks.detected(c.Src, src)
+
+	// Determine if we have a forced protocol, i.e. "git::http://..."
+	force, src := getForcedGetter(src)
+	src, _ = SourceDirSubdir(src)
+
+	u, err := urlhelper.Parse(src)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if u, err = c.rewriteURL(u); err != nil {
+		return nil, "", nil, err
+	}
+	if force == "" {
+		force = u.Scheme
+	}
+
+	getters := c.Getters
+	if getters == nil {
+		getters = Getters
+	}
+
+	g, ok := getters[force]
+	if !ok {
+		return nil, "", nil, fmt.Errorf(
+			"download not supported for scheme '%s'", force)
+	}
+
+	c.Hooks.getterSelected(force, g)
+
+	// Give the getter access to the client, e.g. for logging
+	g = bindGetter(g, c)
+
+	return g, force, u, nil
+}
+
+// checksumParam parses the "checksum" query parameter of u, if any, into
+// the hash computing the checksum and the expected value. The parameter is
+// removed from u.
+func (c *Client) checksumParam(u *url.URL) (hash.Hash, []byte, error) {
+	q := u.Query()
+	v := q.Get("checksum")
+	if v == "" {
+		return nil, nil, nil
+	}
+
+	// Delete the query parameter if we have it.
+	q.Del("checksum")
+	u.RawQuery = q.Encode()
+
+	// Determine the checksum hash type
+	checksumType := ""
+	idx := strings.Index(v, ":")
+	if idx > -1 {
+		checksumType = v[:idx]
+	}
+	checksummers := c.Checksummers
+	if checksummers == nil {
+		checksummers = Checksummers
+	}
+	newHash, ok := checksummers[checksumType]
+	if !ok {
+		return nil, nil, fmt.Errorf(
+			"unsupported checksum type: %s", checksumType)
+	}
+
+	// Get the remainder of the value and parse it into bytes
+	b, err := hex.DecodeString(v[idx+1:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid checksum: %s", err)
+	}
+
+	return newHash(), b, nil
+}
+
+// cachedChecksum returns whether the file at dst already has the checksum,
+// given as 'type:value', according to its checksum sidecar, or by computing
+// it with h if VerifyStrict is set. The sidecar is rewritten after computing
+// a matching checksum.
+func (c *Client) cachedChecksum(dst, v string, h hash.Hash, value []byte) bool {
+	if !c.VerifyStrict {
+		return matchesChecksumSidecar(dst, v)
+	}
+
+	if fi, err := os.Stat(dst); err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+	h.Reset()
+	if err := checksum(dst, h, value); err != nil {
+		return false
+	}
+	return writeChecksumSidecar(dst, v) == nil
+}
+
+// outputHash is the hash computing the output checksum of the file
+// downloaded to path. Getters writing the file to it while downloading set
+// streamed, so that the file doesn't need to be read again.
+type outputHash struct {
+	hash.Hash
+	path     string
+	streamed bool
+}
+
+// outputChecksummer returns the constructor of the hash for OutputChecksum,
+// or nil if it isn't set.
+func (c *Client) outputChecksummer() (func() hash.Hash, error) {
+	if c.OutputChecksum == "" {
+		return nil, nil
+	}
+
+	checksummers := c.Checksummers
+	if checksummers == nil {
+		checksummers = Checksummers
+	}
+	newHash, ok := checksummers[c.OutputChecksum]
+	if !ok {
+		return nil, fmt.Errorf(
+			"unsupported output checksum type: %s", c.OutputChecksum)
+	}
+	return newHash, nil
+}
+
+// recordOutputChecksum records the output checksum of the file downloaded
+// to path in the Result, hashing the file unless the getter did while
+// downloading it.
+func (c *Client) recordOutputChecksum(path string) error {
+	h := c.outputHash
+	if !h.streamed {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("Failed to open file for checksum: %s", err)
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(h, f); err != nil {
+			return fmt.Errorf("Failed to hash: %s", err)
+		}
+	}
+
+	sum := c.OutputChecksum + ":" + hex.EncodeToString(h.Sum(nil))
+	c.logger().Printf("%s checksum of %s is %s", c.OutputChecksum, path, sum)
+	if c.result != nil {
+		c.result.Checksum = sum
+	}
+	return nil
+}
+
 // checksum is a simple method to compute the checksum of a source file
 // and compare it to the given expected value.
 func checksum(source string, h hash.Hash, v []byte) error {
@@ -1,19 +1,38 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/hashicorp/go-getter"
 )
 
 var version = "DEV"
 
+// exitTimeout is the exit code when the -timeout deadline expired, the same
+// as timeout(1).
+const exitTimeout = 124
+
 func main() {
 	modeRaw := flag.String("mode", "any", "get mode (any, file, dir)")
+	fromRaw := flag.String("from", "", "read 'URL<TAB>dst' lines from a file, or - for stdin")
+	parallel := flag.Int("parallel", 1, "number of concurrent downloads with -from")
+	verbose := flag.Bool("verbose", false, "log how sources are resolved, fetched and extracted")
+	quiet := flag.Bool("quiet", false, "only print errors")
+	printChecksum := flag.String("print-checksum", "", "print the checksum of the given type, e.g. sha256, of downloaded files")
+	timeout := flag.Duration("timeout", 0, "abort downloading after the given duration, e.g. 10m")
+	insecure := flag.Bool("insecure", false, "skip verifying the TLS certificates of HTTP and Maven servers")
 	verPtr := flag.Bool("version", false, "print version")
+	var mirrors stringsFlag
+	flag.Var(&mirrors, "mirror", "alternative source of the file to fall back to, can be repeated")
 	flag.Parse()
 
 	if *verPtr {
@@ -21,9 +40,34 @@ func main() {
 		os.Exit(0)
 	}
 	args := flag.Args()
-	if len(args) < 2 {
+	if *fromRaw == "" && len(args) < 2 {
 		log.Fatalf("Expected two args: URL and dst")
 	}
+	if *verbose && *quiet {
+		log.Fatalf("Only one of -verbose and -quiet can be set")
+	}
+	if *fromRaw != "" && len(mirrors) > 0 {
+		log.Fatalf("-mirror can't be used with -from, give mirrors in the manifest URLs instead")
+	}
+
+	// Identify the CLI to the servers we download from
+	getter.DefaultUserAgent = "go-getter/" + version
+
+	// Only log the details of getting the sources if verbose
+	var logger getter.Logger
+	if *verbose {
+		logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	// Trust any certificate of HTTP and Maven servers, such as internal
+	// repositories with self-signed certificates during development. The
+	// getters are shared by the concurrent downloads of -from, which is safe
+	// as clients download with copies of them.
+	getters := getter.Getters
+	if *insecure {
+		log.Printf("WARNING: -insecure is set, TLS certificates of HTTP and Maven servers are not verified")
+		getters = insecureGetters()
+	}
 
 	// Get the mode
 	var mode getter.ClientMode
@@ -44,17 +88,178 @@ func main() {
 		log.Fatalf("Error getting wd: %s", err)
 	}
 
+	// Bound the whole run, including all the downloads with -from
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	if *fromRaw != "" {
+		code := getFrom(ctx, *fromRaw, *parallel, pwd, mode, getters, logger, *quiet, *printChecksum)
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("Download timed out after %s", *timeout)
+			code = exitTimeout
+		}
+		os.Exit(code)
+	}
+
+	result, err := get(ctx, args[0], args[1], pwd, mode, getters, logger, *printChecksum, mirrors)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("Download timed out after %s", *timeout)
+			os.Exit(exitTimeout)
+		}
+		log.Fatalf("Error downloading: %s", err)
+	}
+	if result.Checksum != "" {
+		fmt.Println(result.Checksum)
+	}
+
+	if !*quiet {
+		log.Println("Success!")
+	}
+}
+
+// get downloads a single source into dst, falling back to the given mirrors,
+// computing the checksum of the downloaded file of the given type if any.
+func get(ctx context.Context, src, dst, pwd string, mode getter.ClientMode, getters map[string]getter.Getter, logger getter.Logger, checksum string, mirrors []string) (*getter.Result, error) {
 	// Build the client
 	client := &getter.Client{
-		Src:  args[0],
-		Dst:  args[1],
-		Pwd:  pwd,
-		Mode: mode,
+		Ctx:            ctx,
+		Src:            src,
+		Dst:            dst,
+		Pwd:            pwd,
+		Mode:           mode,
+		Getters:        getters,
+		Logger:         logger,
+		OutputChecksum: checksum,
+		Mirrors:        mirrors,
 	}
 
-	if err := client.Get(); err != nil {
-		log.Fatalf("Error downloading: %s", err)
+	return client.GetResult()
+}
+
+// stringsFlag is a flag that can be given multiple times, collecting all
+// its values.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// insecureGetters returns the default getters, with a new HTTP getter
+// skipping the verification of TLS certificates. The getters of package
+// registries, such as Maven, download with it too.
+func insecureGetters() map[string]getter.Getter {
+	httpGetter := &getter.HttpGetter{
+		Netrc:           true,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+
+	getters := make(map[string]getter.Getter, len(getter.Getters))
+	for k, g := range getter.Getters {
+		getters[k] = g
+	}
+	getters["http"] = httpGetter
+	getters["https"] = httpGetter
+	return getters
+}
+
+// manifestEntry is a single source to download, read from a manifest.
+type manifestEntry struct {
+	Src string
+	Dst string
+}
+
+// getFrom downloads every entry of the manifest at path, using up to
+// parallel concurrent downloads, and returns the exit code.
+func getFrom(ctx context.Context, path string, parallel int, pwd string, mode getter.ClientMode, getters map[string]getter.Getter, logger getter.Logger, quiet bool, checksum string) int {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Error opening manifest: %s", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	entries, err := readManifest(r)
+	if err != nil {
+		log.Fatalf("Error reading manifest: %s", err)
+	}
+
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	sem := make(chan struct{}, parallel)
+	failed := 0
+	for _, e := range entries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(e manifestEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result, err := get(ctx, e.Src, e.Dst, pwd, mode, getters, logger, checksum, nil)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				failed++
+				log.Printf("Error downloading %s: %s", e.Src, err)
+				return
+			}
+			if result.Checksum != "" {
+				fmt.Printf("%s\t%s\n", result.Checksum, e.Src)
+			}
+			if !quiet {
+				log.Printf("Downloaded %s to %s", e.Src, e.Dst)
+			}
+		}(e)
+	}
+	wg.Wait()
+
+	if !quiet || failed > 0 {
+		log.Printf("%d succeeded, %d failed", len(entries)-failed, failed)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readManifest reads the entries of a manifest, one 'URL<TAB>dst' per line.
+// Blank lines and lines starting with '#' are ignored.
+func readManifest(r io.Reader) ([]manifestEntry, error) {
+	var entries []manifestEntry
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, "\t")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected 'URL<TAB>dst', got %q", n, line)
+		}
+
+		entries = append(entries, manifestEntry{
+			Src: strings.TrimSpace(parts[0]),
+			Dst: strings.TrimSpace(parts[1]),
+		})
 	}
 
-	log.Println("Success!")
+	return entries, scanner.Err()
 }
@@ -8,7 +8,8 @@ import (
 )
 
 // copyDir copies the src directory contents into dst. Both directories
-// should already exist.
+// should already exist. The modes and modification times of the copied
+// files and directories are kept.
 //
 // If ignoreDot is set to true, then dot-prefixed files/folders are ignored.
 func copyDir(dst string, src string, ignoreDot bool) error {
@@ -17,6 +18,11 @@ func copyDir(dst string, src string, ignoreDot bool) error {
 		return err
 	}
 
+	// Directories are recorded so that we may set their attributes after
+	// all files have been copied, since adding files changes their mtime
+	var dirs []string
+	var dirInfos []os.FileInfo
+
 	walkFn := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -50,6 +56,8 @@ func copyDir(dst string, src string, ignoreDot bool) error {
 				return err
 			}
 
+			dirs = append(dirs, dstPath)
+			dirInfos = append(dirInfos, info)
 			return nil
 		}
 
@@ -71,8 +79,51 @@ func copyDir(dst string, src string, ignoreDot bool) error {
 		}
 
 		// Chmod it
-		return os.Chmod(dstPath, info.Mode())
+		if err := os.Chmod(dstPath, info.Mode()); err != nil {
+			return err
+		}
+
+		return os.Chtimes(dstPath, info.ModTime(), info.ModTime())
+	}
+
+	if err := filepath.Walk(src, walkFn); err != nil {
+		return err
+	}
+
+	for i, dir := range dirs {
+		if err := os.Chmod(dir, dirInfos[i].Mode()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dir, dirInfos[i].ModTime(), dirInfos[i].ModTime()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyFile copies the src file to dst, keeping its mode.
+func copyFile(dst, src string) error {
+	srcF, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcF.Close()
+
+	fi, err := srcF.Stat()
+	if err != nil {
+		return err
+	}
+
+	dstF, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstF.Close()
+
+	if _, err := io.Copy(dstF, srcF); err != nil {
+		return err
 	}
 
-	return filepath.Walk(src, walkFn)
+	return os.Chmod(dst, fi.Mode())
 }
@@ -1,5 +1,10 @@
 package getter
 
+import (
+	"io"
+	"strings"
+)
+
 // Decompressor defines the interface that must be implemented to add
 // support for decompressing a type.
 type Decompressor interface {
@@ -9,25 +14,96 @@ type Decompressor interface {
 	Decompress(dst, src string, dir bool) error
 }
 
+// readerDecompressor is implemented by Decompressors that can decompress an
+// archive while it is read, such as the tar based ones, so that it doesn't
+// have to be saved to a file first. Formats that need to seek, like zip,
+// can't.
+type readerDecompressor interface {
+	// decompressReader is like Decompress, with the archive read from r.
+	// src names the archive in errors.
+	decompressReader(dst string, r io.Reader, src string, dir bool) error
+
+	// readsOnce returns whether the archive is only read once, as options
+	// of the Decompressor may require reading it again.
+	readsOnce() bool
+}
+
+// asReaderDecompressor returns d if it can decompress an archive while it
+// is read, or nil otherwise.
+func asReaderDecompressor(d Decompressor) readerDecompressor {
+	if rd, ok := d.(readerDecompressor); ok && rd.readsOnce() {
+		return rd
+	}
+	return nil
+}
+
 // Decompressors is the mapping of extension to the Decompressor implementation
 // that will decompress that extension/type.
+//
+// When the archive type isn't given with the "archive" query parameter, the
+// longest extension matching the end of the source path is used, so that
+// "foo.tar.gz" is unpacked by the "tar.gz" Decompressor rather than "gz".
 var Decompressors map[string]Decompressor
 
+// RegisterDecompressor registers d as the Decompressor for the extension
+// ext, such as "zst" or "tar.br", replacing any Decompressor registered for
+// it already, including the built-in ones. A leading "." is ignored.
+//
+// This modifies the Decompressors global, so it should be called before
+// downloading, e.g. in an init function. To only change the Decompressors of
+// a single Client, set its Decompressors field instead.
+func RegisterDecompressor(ext string, d Decompressor) {
+	Decompressors[strings.TrimPrefix(ext, ".")] = d
+}
+
 func init() {
 	tbzDecompressor := new(TarBzip2Decompressor)
 	tgzDecompressor := new(TarGzipDecompressor)
+	tlz4Decompressor := new(TarLz4Decompressor)
 	txzDecompressor := new(TarXzDecompressor)
 
 	Decompressors = map[string]Decompressor{
 		"bz2":     new(Bzip2Decompressor),
 		"gz":      new(GzipDecompressor),
+		"lz4":     new(Lz4Decompressor),
 		"xz":      new(XzDecompressor),
 		"tar.bz2": tbzDecompressor,
 		"tar.gz":  tgzDecompressor,
+		"tar.lz4": tlz4Decompressor,
 		"tar.xz":  txzDecompressor,
 		"tbz2":    tbzDecompressor,
 		"tgz":     tgzDecompressor,
+		"tlz4":    tlz4Decompressor,
 		"txz":     txzDecompressor,
 		"zip":     new(ZipDecompressor),
 	}
 }
+
+// containsDotDot checks if the filepath value v contains a ".." entry.
+// This will check filepath components by splitting along / or \. This
+// function is copied directly from the Go net/http implementation.
+func containsDotDot(v string) bool {
+	if !strings.Contains(v, "..") {
+		return false
+	}
+	for _, ent := range strings.FieldsFunc(v, isSlashRune) {
+		if ent == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+func isSlashRune(r rune) bool { return r == '/' || r == '\\' }
+
+// relativeEntryName returns the name of an archive entry made relative, so
+// that absolute names like "/etc/passwd" or "C:/Windows/win.ini" are
+// extracted below the destination instead of being joined to it as is. A
+// drive letter and leading separators are stripped.
+func relativeEntryName(name string) string {
+	if len(name) >= 2 && name[1] == ':' &&
+		('a' <= name[0] && name[0] <= 'z' || 'A' <= name[0] && name[0] <= 'Z') {
+		name = name[2:]
+	}
+	return strings.TrimLeft(name, "/\\")
+}
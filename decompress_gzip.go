@@ -30,7 +30,8 @@ func (d *GzipDecompressor) Decompress(dst, src string, dir bool) error {
 	}
 	defer f.Close()
 
-	// gzip compression is second
+	// gzip compression is second. The reader is in multistream mode, so
+	// files of concatenated gzip members are read whole
 	gzipR, err := gzip.NewReader(f)
 	if err != nil {
 		return err
@@ -16,6 +16,16 @@ func TestGzipDecompressor(t *testing.T) {
 			nil,
 		},
 
+		// Concatenated gzip members are all read, "fo" and "o\n"
+		{
+			"multistream.gz",
+			false,
+			false,
+			nil,
+			"d3b07384d113edec49eaa6238ad5ff00",
+			nil,
+		},
+
 		{
 			"single.gz",
 			true,
@@ -0,0 +1,52 @@
+package getter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pierrec/lz4"
+)
+
+// Lz4Decompressor is an implementation of Decompressor that can
+// decompress lz4 files.
+type Lz4Decompressor struct{}
+
+func (d *Lz4Decompressor) Decompress(dst, src string, dir bool) error {
+	// Directory isn't supported at all
+	if dir {
+		return fmt.Errorf("lz4-compressed files can only unarchive to a single file")
+	}
+
+	// If we're going into a directory we should make that first
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// File first
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// lz4 compression is second
+	lz4R := lz4.NewReader(f)
+
+	// Copy it out
+	dstF, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstF.Close()
+
+	// Don't leave a partial file behind when the stream is truncated or
+	// corrupt
+	if _, err := io.Copy(dstF, lz4R); err != nil {
+		dstF.Close()
+		os.Remove(dst)
+		return fmt.Errorf("Error decompressing lz4 file %s: %s", src, err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package getter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLz4Decompressor(t *testing.T) {
+	cases := []TestDecompressCase{
+		{
+			"single.lz4",
+			false,
+			false,
+			nil,
+			"d3b07384d113edec49eaa6238ad5ff00",
+			nil,
+		},
+
+		{
+			"single.lz4",
+			true,
+			true,
+			nil,
+			"",
+			nil,
+		},
+
+		{
+			"truncated.lz4",
+			false,
+			true,
+			nil,
+			"",
+			nil,
+		},
+	}
+
+	for i, tc := range cases {
+		cases[i].Input = filepath.Join("./test-fixtures", "decompress-lz4", tc.Input)
+	}
+
+	TestDecompressor(t, new(Lz4Decompressor), cases)
+}
+
+func TestLz4Decompressor_truncated(t *testing.T) {
+	src := filepath.Join("./test-fixtures", "decompress-lz4", "truncated.lz4")
+
+	dst := filepath.Join(tempDir(t), "single")
+	err := new(Lz4Decompressor).Decompress(dst, src, false)
+	if err == nil || !strings.Contains(err.Error(), "Error decompressing lz4 file") {
+		t.Fatalf("expected decompression error, got: %v", err)
+	}
+
+	// The partially decompressed file is removed
+	if _, err := os.Stat(dst); err == nil {
+		t.Fatal("should not leave a partial file")
+	}
+}
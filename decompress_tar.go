@@ -5,19 +5,23 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 // untar is a shared helper for untarring an archive. The reader should provide
 // an uncompressed view of the tar archive.
-func untar(input io.Reader, dst, src string, dir bool) error {
+func untar(input io.Reader, dst, src string, dir bool, opts TarOptions) error {
 	tarR := tar.NewReader(input)
 	done := false
+	filtered := false
 	dirHdrs := []*tar.Header{}
 	for {
 		hdr, err := tarR.Next()
 		if err == io.EOF {
-			if !done {
+			if !done && !filtered && !(dir && opts.AllowEmptyArchive) {
 				// Empty archive
 				return fmt.Errorf("empty archive: %s", src)
 			}
@@ -35,6 +39,19 @@ func untar(input io.Reader, dst, src string, dir bool) error {
 
 		path := dst
 		if dir {
+			// Prevent the archive from escaping the destination, absolute
+			// entries are extracted relative to it
+			hdr.Name = relativeEntryName(hdr.Name)
+			if containsDotDot(hdr.Name) {
+				return fmt.Errorf("entry contains '..': %s", hdr.Name)
+			}
+
+			// Skip the entries filtered out by the include and exclude patterns
+			if !opts.match(hdr.Name) {
+				filtered = true
+				continue
+			}
+
 			path = filepath.Join(path, hdr.Name)
 		}
 
@@ -44,7 +61,7 @@ func untar(input io.Reader, dst, src string, dir bool) error {
 			}
 
 			// A directory, just make the directory and continue unarchiving...
-			if err := os.MkdirAll(path, 0755); err != nil {
+			if err := os.MkdirAll(path, opts.dirMode()); err != nil {
 				return err
 			}
 
@@ -60,7 +77,7 @@ func untar(input io.Reader, dst, src string, dir bool) error {
 
 			// Check that the directory exists, otherwise create it
 			if _, err := os.Stat(dstPath); os.IsNotExist(err) {
-				if err := os.MkdirAll(dstPath, 0755); err != nil {
+				if err := os.MkdirAll(dstPath, opts.dirMode()); err != nil {
 					return err
 				}
 			}
@@ -86,21 +103,33 @@ func untar(input io.Reader, dst, src string, dir bool) error {
 		}
 
 		// Chmod the file
-		if err := os.Chmod(path, hdr.FileInfo().Mode()); err != nil {
+		if err := os.Chmod(path, opts.fileMode(hdr.FileInfo().Mode())); err != nil {
 			return err
 		}
 
 		// Set the access and modification time
-		if err := os.Chtimes(path, hdr.AccessTime, hdr.ModTime); err != nil {
+		atime, mtime := opts.times(hdr)
+		if err := os.Chtimes(path, atime, mtime); err != nil {
 			return err
 		}
 	}
 
 	// Adding a file or subdirectory changes the mtime of a directory
-	// We therefore wait until we've extracted everything and then set the mtime and atime attributes
+	// We therefore wait until we've extracted everything and then set the
+	// mode, mtime and atime attributes. Setting the mode last also lets files
+	// be extracted into directories the archive records as read-only.
 	for _, dirHdr := range dirHdrs {
 		path := filepath.Join(dst, dirHdr.Name)
-		if err := os.Chtimes(path, dirHdr.AccessTime, dirHdr.ModTime); err != nil {
+		if opts.archiveDirMode() {
+			// Archives without permissions for directories record them as 0
+			if mode := dirHdr.FileInfo().Mode().Perm(); mode != 0 {
+				if err := os.Chmod(path, opts.umask(mode)); err != nil {
+					return err
+				}
+			}
+		}
+		atime, mtime := opts.times(dirHdr)
+		if err := os.Chtimes(path, atime, mtime); err != nil {
 			return err
 		}
 	}
@@ -108,20 +137,213 @@ func untar(input io.Reader, dst, src string, dir bool) error {
 	return nil
 }
 
-// tarDecompressor is an implementation of Decompressor that can
-// unpack tar files.
-type tarDecompressor struct{}
+// TarOptions are the options shared by the tar based Decompressors.
+type TarOptions struct {
+	// CheckDiskSpace, if true, sums the sizes of the files in the archive
+	// before extracting it and errors early if the filesystem of the
+	// destination doesn't have enough space available. This reads the
+	// archive twice and is only supported on platforms with statfs.
+	CheckDiskSpace bool
 
-func (d *tarDecompressor) Decompress(dst, src string, dir bool) error {
-	// If we're going into a directory we should make that first
+	// ApplyUmask, if true, clears the bits of Umask from the modes of
+	// extracted files, so that sloppy or untrusted archives can't create
+	// world-writable files. Umask defaults to 0022 if unset.
+	ApplyUmask bool
+	Umask      os.FileMode
+
+	// FileMode, if non-zero, is the mode given to all extracted files
+	// instead of the mode recorded in the archive.
+	FileMode os.FileMode
+
+	// The directories of the archive are given the mode recorded in the
+	// archive, with ApplyUmask applying to it like for files. Directories
+	// only implied by the paths of files are created with mode 0755 before
+	// the umask of the process is applied.
+	//
+	// DirMode, if non-zero, is the mode all directories are created with
+	// instead, before the umask of the process is applied. If
+	// PreserveDirMode is true, it is only used for implied directories, and
+	// the directories of the archive are still given their recorded mode.
+	DirMode         os.FileMode
+	PreserveDirMode bool
+
+	// Include and Exclude are glob patterns selecting the entries of the
+	// archive to extract into a directory. Entries whose name doesn't match
+	// any Include pattern, or matches an Exclude pattern, are skipped. An
+	// empty Include extracts all entries. Patterns use path.Match semantics
+	// for each path segment, and a "**" segment matches any number of
+	// segments, e.g. "**/*.tf" or "vendor/**".
+	Include []string
+	Exclude []string
+
+	// AllowEmptyArchive, if true, extracts an archive without files into
+	// an empty directory instead of returning an error, as archives of an
+	// empty directory are legitimate in some workflows. Archives are always
+	// required to have a file when extracting a single file.
+	AllowEmptyArchive bool
+
+	// NormalizeMtime, if set, is the access and modification time given to
+	// all extracted files and directories instead of the times recorded in
+	// the archive, so that the extracted tree is the same on every
+	// extraction, Ex., for hashing its contents. Directories only implied by
+	// the paths of files keep the time they were created at.
+	NormalizeMtime *time.Time
+}
+
+// times returns the access and modification time to give to the extracted
+// entry of the archive with the header hdr.
+func (o *TarOptions) times(hdr *tar.Header) (time.Time, time.Time) {
+	if o.NormalizeMtime != nil {
+		return *o.NormalizeMtime, *o.NormalizeMtime
+	}
+	return hdr.AccessTime, hdr.ModTime
+}
+
+// readsOnce returns whether archives are read only once when extracted,
+// which isn't the case if CheckDiskSpace is set.
+func (o *TarOptions) readsOnce() bool {
+	return !o.CheckDiskSpace
+}
+
+// match returns whether the entry of the archive with the given name
+// passes the Include and Exclude patterns.
+func (o *TarOptions) match(name string) bool {
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "./"), "/")
+
+	if len(o.Include) > 0 && !matchAny(o.Include, name) {
+		return false
+	}
+	return !matchAny(o.Exclude, name)
+}
+
+// matchAny returns whether name matches any of the glob patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(strings.Split(pattern, "/"), strings.Split(name, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches the segments of a path against the segments of a glob
+// pattern, where a "**" segment matches zero or more path segments.
+func matchGlob(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlob(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// fileMode returns the mode to give an extracted file whose mode in the
+// archive is mode.
+func (o *TarOptions) fileMode(mode os.FileMode) os.FileMode {
+	if o.FileMode != 0 {
+		return o.FileMode
+	}
+
+	return o.umask(mode)
+}
+
+// umask returns mode with the bits of Umask cleared if ApplyUmask is set.
+func (o *TarOptions) umask(mode os.FileMode) os.FileMode {
+	if o.ApplyUmask {
+		umask := o.Umask
+		if umask == 0 {
+			umask = 0022
+		}
+		mode &^= umask
+	}
+
+	return mode
+}
+
+// dirMode returns the mode to create directories with.
+func (o *TarOptions) dirMode() os.FileMode {
+	if o.DirMode != 0 {
+		return o.DirMode
+	}
+	return 0755
+}
+
+// archiveDirMode returns whether the directories of the archive are given
+// the mode recorded in the archive.
+func (o *TarOptions) archiveDirMode() bool {
+	return o.DirMode == 0 || o.PreserveDirMode
+}
+
+// tarReaderFunc returns an uncompressed view of the tar archive read from r.
+type tarReaderFunc func(r io.Reader) (io.Reader, error)
+
+// untarFile untars the archive at src into dst, using newReader to
+// uncompress it.
+func untarFile(dst, src string, dir bool, opts TarOptions, newReader tarReaderFunc) error {
+	mkdir, err := mkdirTarDst(dst, dir, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.CheckDiskSpace {
+		err := readTar(src, newReader, func(r io.Reader) error {
+			return checkTarDiskSpace(r, mkdir, src)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return readTar(src, newReader, func(r io.Reader) error {
+		return untar(r, dst, src, dir, opts)
+	})
+}
+
+// untarReader untars the archive read from r into dst, using newReader to
+// uncompress it. src names the archive in errors.
+func untarReader(dst string, r io.Reader, src string, dir bool, opts TarOptions, newReader tarReaderFunc) error {
+	if _, err := mkdirTarDst(dst, dir, opts); err != nil {
+		return err
+	}
+
+	tarR, err := newReader(r)
+	if err != nil {
+		return err
+	}
+	if c, ok := tarR.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	return untar(tarR, dst, src, dir, opts)
+}
+
+// mkdirTarDst creates the directory an archive is extracted into, dst
+// itself or its parent directory if extracting a single file, and returns
+// it.
+func mkdirTarDst(dst string, dir bool, opts TarOptions) (string, error) {
 	mkdir := dst
 	if !dir {
 		mkdir = filepath.Dir(dst)
 	}
-	if err := os.MkdirAll(mkdir, 0755); err != nil {
-		return err
-	}
+	return mkdir, os.MkdirAll(mkdir, opts.dirMode())
+}
 
+// readTar opens the archive at src and calls fn with its uncompressed view.
+func readTar(src string, newReader tarReaderFunc, fn func(io.Reader) error) error {
 	// File first
 	f, err := os.Open(src)
 	if err != nil {
@@ -129,5 +351,69 @@ func (d *tarDecompressor) Decompress(dst, src string, dir bool) error {
 	}
 	defer f.Close()
 
-	return untar(f, dst, src, dir)
+	// Compression is second
+	r, err := newReader(f)
+	if err != nil {
+		return err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	return fn(r)
+}
+
+// diskSpaceFunc returns the available disk space in bytes on the
+// filesystem containing path. It is a variable so tests can replace it.
+var diskSpaceFunc = diskSpace
+
+// checkTarDiskSpace sums the sizes of the files in the tar archive and
+// errors if the filesystem containing dst doesn't have that much space.
+func checkTarDiskSpace(input io.Reader, dst, src string) error {
+	tarR := tar.NewReader(input)
+	var total uint64
+	for {
+		hdr, err := tarR.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA {
+			total += uint64(hdr.Size)
+		}
+	}
+
+	available, err := diskSpaceFunc(dst)
+	if err != nil {
+		return fmt.Errorf("error checking disk space for %s: %s", dst, err)
+	}
+	if total > available {
+		return fmt.Errorf(
+			"insufficient disk space to extract %s: need %d bytes, %d available",
+			src, total, available)
+	}
+
+	return nil
+}
+
+// tarDecompressor is an implementation of Decompressor that can
+// unpack tar files.
+type tarDecompressor struct {
+	TarOptions
+}
+
+func (d *tarDecompressor) Decompress(dst, src string, dir bool) error {
+	return untarFile(dst, src, dir, d.TarOptions, plainTarReader)
+}
+
+func (d *tarDecompressor) decompressReader(dst string, r io.Reader, src string, dir bool) error {
+	return untarReader(dst, r, src, dir, d.TarOptions, plainTarReader)
+}
+
+// plainTarReader returns r as is, for uncompressed tar archives.
+func plainTarReader(r io.Reader) (io.Reader, error) {
+	return r, nil
 }
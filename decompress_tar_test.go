@@ -1,6 +1,8 @@
 package getter
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -41,3 +43,139 @@ func TestTar(t *testing.T) {
 
 	TestDecompressor(t, new(tarDecompressor), cases)
 }
+
+func TestTar_absolute(t *testing.T) {
+	// Absolute entries are extracted relative to the destination, but still
+	// can't escape it
+	cases := []TestDecompressCase{
+		{
+			"absolute.tar",
+			true,
+			false,
+			[]string{"abs/", "abs/file", "drive/", "drive/file"},
+			"",
+			nil,
+		},
+		{
+			"absolute_dotdot.tar",
+			true,
+			true,
+			nil,
+			"",
+			nil,
+		},
+	}
+
+	for i, tc := range cases {
+		cases[i].Input = filepath.Join("./test-fixtures", "decompress-tar", tc.Input)
+	}
+
+	TestDecompressor(t, new(tarDecompressor), cases)
+}
+
+func TestTar_umask(t *testing.T) {
+	src := filepath.Join("./test-fixtures", "decompress-tar", "mode_0777.tar")
+
+	cases := []struct {
+		Options TarOptions
+		Mode    os.FileMode
+	}{
+		{TarOptions{}, 0777},
+		{TarOptions{ApplyUmask: true}, 0755},
+		{TarOptions{ApplyUmask: true, Umask: 0027}, 0750},
+		{TarOptions{ApplyUmask: true, FileMode: 0600}, 0600},
+	}
+
+	for _, tc := range cases {
+		dst := tempDir(t)
+		d := &tarDecompressor{TarOptions: tc.Options}
+		if err := d.Decompress(dst, src, true); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		fi, err := os.Stat(filepath.Join(dst, "file"))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if fi.Mode().Perm() != tc.Mode {
+			t.Fatalf("%#v: bad mode: %o", tc.Options, fi.Mode().Perm())
+		}
+	}
+}
+
+func TestTar_normalizeMtime(t *testing.T) {
+	mtime := time.Unix(946684800, 0)
+	cases := []TestDecompressCase{
+		{
+			filepath.Join("./test-fixtures", "decompress-tar", "unix_time_0.tar"),
+			true,
+			false,
+			[]string{"directory/", "directory/sub/", "directory/sub/a", "directory/sub/b"},
+			"",
+			&mtime,
+		},
+	}
+
+	TestDecompressor(t, &tarDecompressor{TarOptions: TarOptions{NormalizeMtime: &mtime}}, cases)
+}
+
+func TestTar_allowEmptyArchive(t *testing.T) {
+	src := filepath.Join("./test-fixtures", "decompress-tar", "empty.tar")
+
+	// Rejected by default
+	dst := tempDir(t)
+	if err := new(tarDecompressor).Decompress(dst, src, true); err == nil {
+		t.Fatal("should error")
+	}
+
+	// Allowed, resulting in an empty directory
+	d := &tarDecompressor{TarOptions: TarOptions{AllowEmptyArchive: true}}
+	if err := d.Decompress(dst, src, true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	fis, err := ioutil.ReadDir(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(fis) != 0 {
+		t.Fatalf("expected empty directory, got %d entries", len(fis))
+	}
+
+	// A single file can't be extracted from it either way
+	if err := d.Decompress(filepath.Join(tempDir(t), "file"), src, false); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestTar_dirMode(t *testing.T) {
+	src := filepath.Join("./test-fixtures", "decompress-tar", "dir_modes.tar")
+
+	cases := []struct {
+		Options TarOptions
+		Private os.FileMode
+		Implied os.FileMode
+	}{
+		{TarOptions{}, 0700, 0755},
+		{TarOptions{DirMode: 0700}, 0700, 0700},
+		{TarOptions{DirMode: 0750}, 0750, 0750},
+		{TarOptions{DirMode: 0750, PreserveDirMode: true}, 0700, 0750},
+	}
+
+	for _, tc := range cases {
+		dst := tempDir(t)
+		d := &tarDecompressor{TarOptions: tc.Options}
+		if err := d.Decompress(dst, src, true); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		for dir, mode := range map[string]os.FileMode{"private": tc.Private, "implied": tc.Implied} {
+			fi, err := os.Stat(filepath.Join(dst, dir))
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if fi.Mode().Perm() != mode {
+				t.Fatalf("%#v: %s: bad mode: %o", tc.Options, dir, fi.Mode().Perm())
+			}
+		}
+	}
+}
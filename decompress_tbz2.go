@@ -2,32 +2,25 @@ package getter
 
 import (
 	"compress/bzip2"
-	"os"
-	"path/filepath"
+	"io"
 )
 
 // TarBzip2Decompressor is an implementation of Decompressor that can
 // decompress tar.bz2 files.
-type TarBzip2Decompressor struct{}
+type TarBzip2Decompressor struct {
+	TarOptions
+}
 
 func (d *TarBzip2Decompressor) Decompress(dst, src string, dir bool) error {
-	// If we're going into a directory we should make that first
-	mkdir := dst
-	if !dir {
-		mkdir = filepath.Dir(dst)
-	}
-	if err := os.MkdirAll(mkdir, 0755); err != nil {
-		return err
-	}
+	return untarFile(dst, src, dir, d.TarOptions, bzip2TarReader)
+}
 
-	// File first
-	f, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+func (d *TarBzip2Decompressor) decompressReader(dst string, r io.Reader, src string, dir bool) error {
+	return untarReader(dst, r, src, dir, d.TarOptions, bzip2TarReader)
+}
 
+// bzip2TarReader uncompresses bzip2 compressed archives.
+func bzip2TarReader(f io.Reader) (io.Reader, error) {
 	// Bzip2 compression is second
-	bzipR := bzip2.NewReader(f)
-	return untar(bzipR, dst, src, dir)
+	return bzip2.NewReader(f), nil
 }
@@ -3,37 +3,32 @@ package getter
 import (
 	"compress/gzip"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 )
 
 // TarGzipDecompressor is an implementation of Decompressor that can
 // decompress tar.gzip files.
-type TarGzipDecompressor struct{}
+type TarGzipDecompressor struct {
+	TarOptions
+}
 
 func (d *TarGzipDecompressor) Decompress(dst, src string, dir bool) error {
-	// If we're going into a directory we should make that first
-	mkdir := dst
-	if !dir {
-		mkdir = filepath.Dir(dst)
-	}
-	if err := os.MkdirAll(mkdir, 0755); err != nil {
-		return err
-	}
+	return untarFile(dst, src, dir, d.TarOptions, gzipTarReader(src))
+}
 
-	// File first
-	f, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+func (d *TarGzipDecompressor) decompressReader(dst string, r io.Reader, src string, dir bool) error {
+	return untarReader(dst, r, src, dir, d.TarOptions, gzipTarReader(src))
+}
 
-	// Gzip compression is second
-	gzipR, err := gzip.NewReader(f)
-	if err != nil {
-		return fmt.Errorf("Error opening a gzip reader for %s: %s", src, err)
+// gzipTarReader returns a tarReaderFunc uncompressing the gzip compressed
+// archive src.
+func gzipTarReader(src string) tarReaderFunc {
+	return func(f io.Reader) (io.Reader, error) {
+		// Gzip compression is second
+		gzipR, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("Error opening a gzip reader for %s: %s", src, err)
+		}
+		return gzipR, nil
 	}
-	defer gzipR.Close()
-
-	return untar(gzipR, dst, src, dir)
 }
@@ -1,7 +1,11 @@
 package getter
 
 import (
+	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -74,6 +78,26 @@ func TestTarGzipDecompressor(t *testing.T) {
 			"",
 			nil,
 		},
+
+		// The archive is split into two concatenated gzip members, with
+		// file2 only in the second one
+		{
+			"multistream.tar.gz",
+			true,
+			false,
+			[]string{"file1", "file2"},
+			"",
+			nil,
+		},
+
+		{
+			"traversal.tar.gz",
+			true,
+			true,
+			nil,
+			"",
+			nil,
+		},
 	}
 
 	for i, tc := range cases {
@@ -82,3 +106,85 @@ func TestTarGzipDecompressor(t *testing.T) {
 
 	TestDecompressor(t, new(TarGzipDecompressor), cases)
 }
+
+func TestTarGzipDecompressor_checkDiskSpace(t *testing.T) {
+	d := &TarGzipDecompressor{TarOptions: TarOptions{CheckDiskSpace: true}}
+	src := filepath.Join("./test-fixtures", "decompress-tgz", "multiple.tar.gz")
+
+	// Enough space
+	dst := tempDir(t)
+	if err := d.Decompress(dst, src, true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Not enough space
+	old := diskSpaceFunc
+	defer func() { diskSpaceFunc = old }()
+	diskSpaceFunc = func(string) (uint64, error) { return 0, nil }
+
+	dst = tempDir(t)
+	err := d.Decompress(dst, src, true)
+	if err == nil || !strings.Contains(err.Error(), "insufficient disk space") {
+		t.Fatalf("expected insufficient disk space error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "test1")); err == nil {
+		t.Fatal("should not extract any files")
+	}
+}
+
+func TestTarGzipDecompressor_filter(t *testing.T) {
+	src := filepath.Join("./test-fixtures", "decompress-tgz", "filter.tar.gz")
+
+	cases := []struct {
+		Include []string
+		Exclude []string
+		Files   []string
+	}{
+		{
+			nil,
+			nil,
+			[]string{"README.md", "main.tf", "modules/a/a.tf", "modules/a/notes.txt", "vendor/x/x.tf"},
+		},
+		{
+			[]string{"**/*.tf"},
+			nil,
+			[]string{"main.tf", "modules/a/a.tf", "vendor/x/x.tf"},
+		},
+		{
+			[]string{"**/*.tf"},
+			[]string{"vendor/**"},
+			[]string{"main.tf", "modules/a/a.tf"},
+		},
+		{
+			[]string{"*.tf"},
+			nil,
+			[]string{"main.tf"},
+		},
+		{
+			nil,
+			[]string{"**/*.txt", "*.md"},
+			[]string{"main.tf", "modules/a/a.tf", "vendor/x/x.tf"},
+		},
+	}
+
+	for _, tc := range cases {
+		dst := tempDir(t)
+		d := &TarGzipDecompressor{TarOptions: TarOptions{Include: tc.Include, Exclude: tc.Exclude}}
+		if err := d.Decompress(dst, src, true); err != nil {
+			t.Fatalf("%v %v: err: %s", tc.Include, tc.Exclude, err)
+		}
+
+		var files []string
+		filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				rel, _ := filepath.Rel(dst, path)
+				files = append(files, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		sort.Strings(files)
+		if !reflect.DeepEqual(files, tc.Files) {
+			t.Fatalf("%v %v: bad files: %v", tc.Include, tc.Exclude, files)
+		}
+	}
+}
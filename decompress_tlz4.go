@@ -0,0 +1,34 @@
+package getter
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/pierrec/lz4"
+)
+
+// TarLz4Decompressor is an implementation of Decompressor that can
+// decompress tar.lz4 files.
+type TarLz4Decompressor struct {
+	TarOptions
+}
+
+func (d *TarLz4Decompressor) Decompress(dst, src string, dir bool) error {
+	newReader := func(f io.Reader) (io.Reader, error) {
+		// lz4 compression is second
+		return lz4.NewReader(f), nil
+	}
+
+	// lz4 is fast to decompress, so the whole stream is checked first, so
+	// that a truncated or corrupt archive isn't partially extracted
+	err := readTar(src, newReader, func(r io.Reader) error {
+		_, err := io.Copy(ioutil.Discard, r)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Error decompressing lz4 archive %s: %s", src, err)
+	}
+
+	return untarFile(dst, src, dir, d.TarOptions, newReader)
+}
@@ -0,0 +1,119 @@
+package getter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTarLz4Decompressor(t *testing.T) {
+
+	multiplePaths := []string{"dir/", "dir/test2", "test1"}
+	orderingPaths := []string{"workers/", "workers/mq/", "workers/mq/__init__.py"}
+
+	cases := []TestDecompressCase{
+		{
+			"empty.tar.lz4",
+			false,
+			true,
+			nil,
+			"",
+			nil,
+		},
+
+		{
+			"single.tar.lz4",
+			false,
+			false,
+			nil,
+			"d3b07384d113edec49eaa6238ad5ff00",
+			nil,
+		},
+
+		{
+			"single.tar.lz4",
+			true,
+			false,
+			[]string{"file"},
+			"",
+			nil,
+		},
+
+		{
+			"multiple.tar.lz4",
+			true,
+			false,
+			[]string{"file1", "file2"},
+			"",
+			nil,
+		},
+
+		{
+			"multiple.tar.lz4",
+			false,
+			true,
+			nil,
+			"",
+			nil,
+		},
+
+		{
+			"multiple_dir.tar.lz4",
+			true,
+			false,
+			multiplePaths,
+			"",
+			nil,
+		},
+
+		// Tests when the file is listed before the parent folder
+		{
+			"ordering.tar.lz4",
+			true,
+			false,
+			orderingPaths,
+			"",
+			nil,
+		},
+
+		{
+			"traversal.tar.lz4",
+			true,
+			true,
+			nil,
+			"",
+			nil,
+		},
+
+		{
+			"truncated.tar.lz4",
+			true,
+			true,
+			nil,
+			"",
+			nil,
+		},
+	}
+
+	for i, tc := range cases {
+		cases[i].Input = filepath.Join("./test-fixtures", "decompress-tlz4", tc.Input)
+	}
+
+	TestDecompressor(t, new(TarLz4Decompressor), cases)
+}
+
+func TestTarLz4Decompressor_truncated(t *testing.T) {
+	src := filepath.Join("./test-fixtures", "decompress-tlz4", "truncated.tar.lz4")
+
+	dst := tempDir(t)
+	err := new(TarLz4Decompressor).Decompress(dst, src, true)
+	if err == nil || !strings.Contains(err.Error(), "Error decompressing lz4 archive") {
+		t.Fatalf("expected decompression error, got: %v", err)
+	}
+
+	// Nothing is extracted from the truncated archive
+	if _, err := os.Stat(filepath.Join(dst, "file1")); err == nil {
+		t.Fatal("should not extract any files")
+	}
+}
@@ -2,38 +2,34 @@ package getter
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 
 	"github.com/ulikunitz/xz"
 )
 
 // TarXzDecompressor is an implementation of Decompressor that can
 // decompress tar.xz files.
-type TarXzDecompressor struct{}
+type TarXzDecompressor struct {
+	TarOptions
+}
 
 func (d *TarXzDecompressor) Decompress(dst, src string, dir bool) error {
-	// If we're going into a directory we should make that first
-	mkdir := dst
-	if !dir {
-		mkdir = filepath.Dir(dst)
-	}
-	if err := os.MkdirAll(mkdir, 0755); err != nil {
-		return err
-	}
+	return untarFile(dst, src, dir, d.TarOptions, xzTarReader(src))
+}
 
-	// File first
-	f, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+func (d *TarXzDecompressor) decompressReader(dst string, r io.Reader, src string, dir bool) error {
+	return untarReader(dst, r, src, dir, d.TarOptions, xzTarReader(src))
+}
 
-	// xz compression is second
-	txzR, err := xz.NewReader(f)
-	if err != nil {
-		return fmt.Errorf("Error opening an xz reader for %s: %s", src, err)
+// xzTarReader returns a tarReaderFunc uncompressing the xz compressed
+// archive src.
+func xzTarReader(src string) tarReaderFunc {
+	return func(f io.Reader) (io.Reader, error) {
+		// xz compression is second
+		txzR, err := xz.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("Error opening an xz reader for %s: %s", src, err)
+		}
+		return txzR, nil
 	}
-
-	return untar(txzR, dst, src, dir)
 }
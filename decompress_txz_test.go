@@ -74,6 +74,15 @@ func TestTarXzDecompressor(t *testing.T) {
 			"",
 			nil,
 		},
+
+		{
+			"traversal.tar.xz",
+			true,
+			true,
+			nil,
+			"",
+			nil,
+		},
 	}
 
 	for i, tc := range cases {
@@ -78,6 +78,15 @@ func TestZipDecompressor(t *testing.T) {
 			"",
 			nil,
 		},
+
+		{
+			"traversal.zip",
+			true,
+			true,
+			nil,
+			"",
+			nil,
+		},
 	}
 
 	for i, tc := range cases {
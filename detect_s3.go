@@ -32,8 +32,12 @@ func (d *S3Detector) detectHTTP(src string) (string, bool, error) {
 	hostParts := strings.Split(parts[0], ".")
 	if len(hostParts) == 3 {
 		return d.detectPathStyle(hostParts[0], parts[1:])
+	} else if len(hostParts) == 4 && hostParts[0] == "s3" {
+		return d.detectPathStyle(hostParts[0]+"."+hostParts[1], parts[1:])
 	} else if len(hostParts) == 4 {
 		return d.detectVhostStyle(hostParts[1], hostParts[0], parts[1:])
+	} else if len(hostParts) == 5 && hostParts[1] == "s3" {
+		return d.detectVhostStyle(hostParts[1]+"."+hostParts[2], hostParts[0], parts[1:])
 	} else {
 		return "", false, fmt.Errorf(
 			"URL is not a valid S3 URL")
@@ -34,6 +34,10 @@ func TestS3Detector(t *testing.T) {
 			"bucket.s3-eu-west-1.amazonaws.com/foo/bar.baz",
 			"s3::https://s3-eu-west-1.amazonaws.com/bucket/foo/bar.baz",
 		},
+		{
+			"bucket.s3.eu-west-1.amazonaws.com/foo/bar.baz",
+			"s3::https://s3.eu-west-1.amazonaws.com/bucket/foo/bar.baz",
+		},
 		// Path style
 		{
 			"s3.amazonaws.com/bucket/foo",
@@ -59,6 +63,10 @@ func TestS3Detector(t *testing.T) {
 			"s3-eu-west-1.amazonaws.com/bucket/foo/bar.baz",
 			"s3::https://s3-eu-west-1.amazonaws.com/bucket/foo/bar.baz",
 		},
+		{
+			"s3.eu-west-1.amazonaws.com/bucket/foo/bar.baz",
+			"s3::https://s3.eu-west-1.amazonaws.com/bucket/foo/bar.baz",
+		},
 		// Misc tests
 		{
 			"s3-eu-west-1.amazonaws.com/bucket/foo/bar.baz?version=1234",
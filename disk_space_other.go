@@ -0,0 +1,14 @@
+// +build !linux,!darwin,!freebsd
+
+package getter
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// diskSpace is not supported on this platform, so CheckDiskSpace can't
+// be used.
+func diskSpace(path string) (uint64, error) {
+	return 0, fmt.Errorf("checking disk space is not supported on %s", runtime.GOOS)
+}
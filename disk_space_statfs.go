@@ -0,0 +1,17 @@
+// +build linux darwin freebsd
+
+package getter
+
+import (
+	"syscall"
+)
+
+// diskSpace returns the disk space in bytes available to unprivileged
+// users on the filesystem containing path.
+func diskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,63 @@
+package getter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FolderCache is an implementation of the Cache interface that stores files
+// on the disk.
+type FolderCache struct {
+	// CacheDir is the directory where the files will be stored. It is
+	// created if it doesn't exist.
+	CacheDir string
+}
+
+// Get implements Cache.Get
+func (c *FolderCache) Get(key, dst string) (bool, error) {
+	path := c.path(key)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return false, err
+	}
+	if err := copyFile(dst, path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Put implements Cache.Put
+func (c *FolderCache) Put(key, src string) error {
+	if err := os.MkdirAll(c.CacheDir, 0755); err != nil {
+		return err
+	}
+
+	// Copy to a temporary file first, so that a partial file is never
+	// served from the cache
+	f, err := ioutil.TempFile(c.CacheDir, ".put")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	if err := copyFile(f.Name(), src); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.path(key))
+}
+
+// path returns the path of the file cached for the key.
+func (c *FolderCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.CacheDir, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,49 @@
+package getter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFolderCache_impl(t *testing.T) {
+	var _ Cache = new(FolderCache)
+}
+
+func TestFolderCache(t *testing.T) {
+	c := &FolderCache{CacheDir: tempDir(t)}
+	dst := filepath.Join(tempDir(t), "foo")
+
+	// Nothing is cached at first...
+	ok, err := c.Get("foo", dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ok {
+		t.Fatal("should not be cached")
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// We can put a file
+	src := filepath.Join(fixtureDir, "basic-file", "foo.txt")
+	if err := c.Put("foo", src); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Now it is cached
+	ok, err = c.Get("foo", dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !ok {
+		t.Fatal("should be cached")
+	}
+	expected, err := ioutil.ReadFile(src)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, string(expected))
+}
@@ -14,12 +14,11 @@ package getter
 import (
 	"bytes"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os/exec"
 	"regexp"
 	"syscall"
-
-	cleanhttp "github.com/hashicorp/go-cleanhttp"
 )
 
 // Getter defines the interface that schemes must implement to download
@@ -46,6 +45,14 @@ type Getter interface {
 	GetFilename(*url.URL) (string, error)
 }
 
+// subdirGetter is implemented by Getters that can limit a directory download
+// to a subdirectory of the source, to avoid transferring what isn't needed.
+// The Client still copies the subdirectory out of dst afterwards, so dst may
+// contain more than just the subdirectory.
+type subdirGetter interface {
+	GetSubdir(dst string, u *url.URL, subDir string) error
+}
+
 // Getters is the mapping of scheme to the Getter implementation that will
 // be used to get a dependency.
 var Getters map[string]Getter
@@ -54,25 +61,57 @@ var Getters map[string]Getter
 // syntax is schema::url, example: git::https://foo.com
 var forcedRegexp = regexp.MustCompile(`^([A-Za-z0-9]+)::(.+)$`)
 
-// httpClient is the default client to be used by HttpGetters.
-var httpClient = cleanhttp.DefaultClient()
+// httpClient is the default client to be used by HttpGetters. It keeps
+// connections alive, so that they are reused across requests to the same
+// host, and uses HTTP/2 with servers supporting it.
+var httpClient = &http.Client{Transport: newHttpTransport(nil)}
 
 func init() {
 	httpGetter := &HttpGetter{
 		Netrc: true,
 	}
 
+	azureGetter := new(AzureBlobGetter)
+	sftpGetter := new(SftpGetter)
+	ftpGetter := new(FtpGetter)
+	webDAVGetter := new(WebDAVGetter)
+
 	Getters = map[string]Getter{
-		"file":  new(FileGetter),
-		"git":   new(GitGetter),
-		"hg":    new(HgGetter),
-		"s3":    new(S3Getter),
-		"sftp":  new(SftpGetter),
-		"http":  httpGetter,
-		"https": httpGetter,
-		"mvn": &MvnGetter{
-			HttpGet: *httpGetter,
-		},
+		"abs":         azureGetter,
+		"azblob":      azureGetter,
+		"azure":       azureGetter,
+		"dav":         webDAVGetter,
+		"davs":        webDAVGetter,
+		"file":        new(FileGetter),
+		"ftp":         ftpGetter,
+		"ftps":        ftpGetter,
+		"gcs":         new(GCSGetter),
+		"ghrelease":   new(GitHubReleaseGetter),
+		"git":         new(GitGetter),
+		"gitlab":      new(GitLabGetter),
+		"hg":          new(HgGetter),
+		"ipfs":        new(IpfsGetter),
+		"oci":         new(OCIGetter),
+		"p4":          new(P4Getter),
+		"rsync":       new(RsyncGetter),
+		"s3":          new(S3Getter),
+		"sftp":        sftpGetter,
+		"smb":         new(SmbGetter),
+		"ssh":         sftpGetter,
+		"svn":         new(SvnGetter),
+		"swift":       new(SwiftGetter),
+		"http":        httpGetter,
+		"https":       httpGetter,
+		"webdav":      webDAVGetter,
+		"mvn":         new(MvnGetter),
+		"helm":        new(HelmGetter),
+		"npm":         new(NpmGetter),
+		"pypi":        new(PypiGetter),
+		"nuget":       new(NugetGetter),
+		"gomod":       new(GoModGetter),
+		"apt":         new(AptGetter),
+		"yum":         new(YumGetter),
+		"artifactory": new(ArtifactoryGetter),
 	}
 }
 
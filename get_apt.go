@@ -0,0 +1,496 @@
+package getter
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// AptGetter is a Getter implementation that will download a .deb package
+// from an APT repository, resolving the package by name, version and
+// architecture from the Release and Packages indexes of the repository
+// rather than from the path in its pool.
+//
+// URLs take the form apt::http://deb.debian.org/debian?dist=bookworm&package=name,
+// where the URL is that of the repository. The Packages indexes are
+// verified with the hashes in the Release file, and the package with the
+// hash in the Packages index. The signature of the Release file isn't
+// verified.
+//
+// Query parameters:
+//   - dist: the distribution, Ex., 'bookworm' or 'stable'
+//   - package: the name of the package
+//   - version: the version, which is matched exactly, or constraints on it,
+//     Ex., '>= 7.88, << 8', default as the newest version
+//   - arch: the architecture, default as that of the running system
+//   - component: the components to search, Ex., 'main,contrib', default as
+//     those listed by the Release file
+type AptGetter struct {
+	httpBackedGetter
+}
+
+// aptPackage is a package listed by a Packages index.
+type aptPackage struct {
+	Package      string
+	Version      string
+	Architecture string
+	Filename     string
+	SHA256       string
+}
+
+// aptQueryParams are the query parameters of the AptGetter, which aren't
+// part of the URL of the repository.
+var aptQueryParams = []string{"dist", "package", "version", "arch", "component"}
+
+// aptArchs maps the GOARCH of the running system to the name of its
+// architecture in Debian.
+var aptArchs = map[string]string{
+	"386":      "i386",
+	"arm":      "armhf",
+	"mips64le": "mips64el",
+	"mipsle":   "mipsel",
+	"ppc64le":  "ppc64el",
+}
+
+func (g *AptGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	return ClientModeFile, nil
+}
+
+// GetFilename returns the name of the .deb of the resolved version, Ex.,
+// 'curl_7.88.1-10_amd64.deb'.
+func (g *AptGetter) GetFilename(u *url.URL) (string, error) {
+	_, p, err := g.resolve(u)
+	if err != nil {
+		return "", err
+	}
+	return path.Base(p.Filename), nil
+}
+
+// Get downloads the .deb into the directory dst.
+func (g *AptGetter) Get(dst string, u *url.URL) error {
+	repo, p, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+	return g.getPackage(filepath.Join(dst, path.Base(p.Filename)), repo, p)
+}
+
+func (g *AptGetter) GetFile(dst string, u *url.URL) error {
+	repo, p, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+	return g.getPackage(dst, repo, p)
+}
+
+// getPackage downloads the .deb of p from the repository to dst and
+// verifies its hash.
+func (g *AptGetter) getPackage(dst string, repo *url.URL, p *aptPackage) error {
+	if p.Filename == "" || containsDotDot(p.Filename) {
+		return fmt.Errorf("invalid file name of package %s %s: %q", p.Package, p.Version, p.Filename)
+	}
+	debURL, err := repo.Parse(strings.TrimPrefix(p.Filename, "/"))
+	if err != nil {
+		return err
+	}
+	return g.getVerified(dst, debURL, p.SHA256)
+}
+
+// getVerified downloads u to dst and verifies its sha256 hash, if any.
+func (g *AptGetter) getVerified(dst string, u *url.URL, sha string) error {
+	if err := g.http().GetFile(dst, u); err != nil {
+		return err
+	}
+	if sha == "" {
+		return nil
+	}
+
+	f, err := os.Open(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != strings.ToLower(sha) {
+		return fmt.Errorf("sha256 of %s doesn't match, expected %s, got %s", path.Base(u.Path), sha, actual)
+	}
+	return nil
+}
+
+// resolve returns the URL of the repository of u, with a trailing slash,
+// and the newest version of the package of u matching the version, arch
+// and component query parameters.
+func (g *AptGetter) resolve(u *url.URL) (*url.URL, *aptPackage, error) {
+	q := u.Query()
+	dist, name := q.Get("dist"), q.Get("package")
+	if dist == "" || name == "" {
+		return nil, nil, fmt.Errorf("query parameters 'dist' and 'package' are required")
+	}
+	if containsDotDot(dist) {
+		return nil, nil, fmt.Errorf("invalid dist: %s", dist)
+	}
+	constraints, err := parseDebConstraints(q.Get("version"))
+	if err != nil {
+		return nil, nil, err
+	}
+	arch := q.Get("arch")
+	if arch == "" {
+		if arch = aptArchs[runtime.GOARCH]; arch == "" {
+			arch = runtime.GOARCH
+		}
+	}
+	var components []string
+	if c := q.Get("component"); c != "" {
+		components = strings.Split(c, ",")
+	}
+
+	repo := *u
+	for _, k := range aptQueryParams {
+		q.Del(k)
+	}
+	repo.RawQuery = q.Encode()
+	repo.Path = strings.TrimSuffix(repo.Path, "/") + "/"
+	repo.RawPath = ""
+
+	td, err := ioutil.TempDir(g.tempDir(), g.tempPattern("apt"))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer g.removeTemp(td)
+
+	// The Release file lists the components and the hashes of the indexes
+	distURL, err := repo.Parse("dists/" + strings.Trim(dist, "/") + "/")
+	if err != nil {
+		return nil, nil, err
+	}
+	releaseURL, _ := distURL.Parse("Release")
+	releasePath := filepath.Join(td, "Release")
+	if err := g.http().GetFile(releasePath, releaseURL); err != nil {
+		return nil, nil, err
+	}
+	release, err := parseAptRelease(releasePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing %s: %s", redactURL(releaseURL.String()), err)
+	}
+	if components == nil {
+		components = strings.Fields(release.Fields["Components"])
+	}
+	if components == nil {
+		components = []string{"main"}
+	}
+
+	var latest *aptPackage
+	found := false
+	for _, component := range components {
+		component = strings.TrimSpace(component)
+		if component == "" || containsDotDot(component) {
+			return nil, nil, fmt.Errorf("invalid component: %q", component)
+		}
+
+		// The index may be compressed, and only some forms exist
+		index := ""
+		for _, ext := range []string{".gz", "", ".xz"} {
+			if _, ok := release.SHA256[component+"/binary-"+arch+"/Packages"+ext]; ok {
+				index = component + "/binary-" + arch + "/Packages" + ext
+				break
+			}
+		}
+		if index == "" {
+			continue
+		}
+		found = true
+
+		indexURL, err := distURL.Parse(index)
+		if err != nil {
+			return nil, nil, err
+		}
+		indexPath := filepath.Join(td, "Packages"+path.Ext(index))
+		if err := g.getVerified(indexPath, indexURL, release.SHA256[index]); err != nil {
+			return nil, nil, err
+		}
+		if ext := strings.TrimPrefix(path.Ext(index), "."); ext != "" {
+			packagesPath := filepath.Join(td, "Packages")
+			if err := g.decompressors()[ext].Decompress(packagesPath, indexPath, false); err != nil {
+				return nil, nil, err
+			}
+			indexPath = packagesPath
+		}
+
+		packages, err := parseAptPackages(indexPath, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing %s: %s", redactURL(indexURL.String()), err)
+		}
+		for i, p := range packages {
+			if p.Architecture != arch && p.Architecture != "all" {
+				continue
+			}
+			if !constraints.Check(p.Version) {
+				continue
+			}
+			if latest == nil || compareDebVersions(p.Version, latest.Version) > 0 {
+				latest = &packages[i]
+			}
+		}
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("%s has no Packages index for %s in %s",
+			redactURL(releaseURL.String()), arch, strings.Join(components, ", "))
+	}
+	if latest == nil {
+		return nil, nil, fmt.Errorf("no version of package %s for %s matches %q", name, arch, u.Query().Get("version"))
+	}
+	return &repo, latest, nil
+}
+
+// aptRelease is a Release file of a distribution.
+type aptRelease struct {
+	// Fields are the single line fields, Ex., Components
+	Fields map[string]string
+
+	// SHA256 are the hashes of the indexes, by path relative to the
+	// distribution
+	SHA256 map[string]string
+}
+
+// parseAptRelease parses the Release file at path.
+func parseAptRelease(releasePath string) (*aptRelease, error) {
+	f, err := os.Open(releasePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	release := &aptRelease{Fields: map[string]string{}, SHA256: map[string]string{}}
+	field := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, " ") {
+			// A line of the hashes, Ex., " <sha256> <size> main/binary-amd64/Packages"
+			if fields := strings.Fields(line); field == "SHA256" && len(fields) == 3 {
+				release.SHA256[fields[2]] = fields[0]
+			}
+			continue
+		}
+		if i := strings.Index(line, ":"); i > 0 {
+			field = line[:i]
+			release.Fields[field] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(release.SHA256) == 0 {
+		return nil, fmt.Errorf("no SHA256 hashes")
+	}
+	return release, nil
+}
+
+// parseAptPackages returns the packages named name in the Packages index
+// at path.
+func parseAptPackages(packagesPath, name string) ([]aptPackage, error) {
+	f, err := os.Open(packagesPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var packages []aptPackage
+	var p aptPackage
+	flush := func() {
+		if p.Package == name {
+			packages = append(packages, p)
+		}
+		p = aptPackage{}
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		value := strings.TrimSpace(line[i+1:])
+		switch line[:i] {
+		case "Package":
+			p.Package = value
+		case "Version":
+			p.Version = value
+		case "Architecture":
+			p.Architecture = value
+		case "Filename":
+			p.Filename = value
+		case "SHA256":
+			p.SHA256 = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return packages, nil
+}
+
+// debConstraints are constraints on Debian versions, which all have to
+// match.
+type debConstraints []struct {
+	op, version string
+}
+
+// parseDebConstraints parses the comma-separated constraints in expr, with
+// the relations of Debian, Ex., '>= 7.88, << 8'. A version without a
+// relation is matched exactly, and '<' and '>' are strict.
+func parseDebConstraints(expr string) (debConstraints, error) {
+	var constraints debConstraints
+	for _, c := range strings.Split(expr, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		version := strings.TrimSpace(strings.TrimLeft(c, "<>="))
+		op := strings.TrimSpace(c[:len(c)-len(strings.TrimLeft(c, "<>="))])
+		switch op {
+		case "":
+			op = "="
+		case "<":
+			op = "<<"
+		case ">":
+			op = ">>"
+		case "<<", "<=", "=", ">=", ">>":
+		default:
+			return nil, fmt.Errorf("invalid version constraint %q", c)
+		}
+		if version == "" || version[0] < '0' || version[0] > '9' {
+			return nil, fmt.Errorf("invalid version constraint %q", c)
+		}
+		constraints = append(constraints, struct{ op, version string }{op, version})
+	}
+	return constraints, nil
+}
+
+// Check returns whether the version v matches the constraints.
+func (cs debConstraints) Check(v string) bool {
+	for _, c := range cs {
+		cmp := compareDebVersions(v, c.version)
+		var ok bool
+		switch c.op {
+		case "<<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case "=":
+			ok = cmp == 0
+		case ">=":
+			ok = cmp >= 0
+		case ">>":
+			ok = cmp > 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compareDebVersions compares the Debian versions a and b, of the form
+// '[epoch:]upstream[-revision]', as dpkg does, returning a negative number
+// if a is older, zero if they're equal, and a positive number if a is newer.
+func compareDebVersions(a, b string) int {
+	splitVersion := func(v string) (int, string, string) {
+		epoch := 0
+		if i := strings.Index(v, ":"); i >= 0 {
+			epoch, _ = strconv.Atoi(v[:i])
+			v = v[i+1:]
+		}
+		revision := ""
+		if i := strings.LastIndex(v, "-"); i >= 0 {
+			v, revision = v[:i], v[i+1:]
+		}
+		return epoch, v, revision
+	}
+	aEpoch, aUpstream, aRevision := splitVersion(a)
+	bEpoch, bUpstream, bRevision := splitVersion(b)
+	if aEpoch != bEpoch {
+		return aEpoch - bEpoch
+	}
+	if cmp := compareDebParts(aUpstream, bUpstream); cmp != 0 {
+		return cmp
+	}
+	return compareDebParts(aRevision, bRevision)
+}
+
+// compareDebParts compares the upstream versions or revisions a and b,
+// alternating between non-digits, compared with letters before other
+// characters and '~' before anything, even the end, and numbers.
+func compareDebParts(a, b string) int {
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	order := func(s string, i int) int {
+		switch {
+		case i >= len(s), isDigit(s[i]):
+			return 0
+		case s[i] == '~':
+			return -1
+		case s[i] >= 'A' && s[i] <= 'Z', s[i] >= 'a' && s[i] <= 'z':
+			return int(s[i])
+		}
+		return int(s[i]) + 256
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			if ac, bc := order(a, i), order(b, j); ac != bc {
+				return ac - bc
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		firstDiff := 0
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+	return 0
+}
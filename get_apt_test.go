@@ -0,0 +1,238 @@
+package getter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAptGetter_impl(t *testing.T) {
+	var _ Getter = new(AptGetter)
+}
+
+func TestCompareDebVersions(t *testing.T) {
+	cases := []struct {
+		A, B string
+		Cmp  int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.00", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"7.88.1-10", "7.88.1-10+deb12u5", -1},
+		{"1:7.0-1", "8.5.0-2", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0a", "1.0", 1},
+		{"1.0a", "1.0+", -1},
+		{"2.30-1", "2.30-1ubuntu1", -1},
+		{"1.2.3-1", "1.2.3-1", 0},
+	}
+	for _, tc := range cases {
+		cmp := compareDebVersions(tc.A, tc.B)
+		if (cmp < 0 && tc.Cmp >= 0) || (cmp > 0 && tc.Cmp <= 0) || (cmp == 0 && tc.Cmp != 0) {
+			t.Fatalf("%s %s: bad comparison: %d", tc.A, tc.B, cmp)
+		}
+	}
+}
+
+func TestParseDebConstraints(t *testing.T) {
+	cases := []struct {
+		Expr    string
+		Version string
+		Match   bool
+	}{
+		{"", "1.0", true},
+		{"1.0-1", "1.0-1", true},
+		{"= 1.0-1", "1.0-2", false},
+		{">= 7.88, << 8", "7.88.1-10", true},
+		{">=7.88,<<8", "8.0", false},
+		{"> 7.88.1-10", "7.88.1-10", false},
+		{"<= 7.88.1-10", "7.88.1-10", true},
+	}
+	for _, tc := range cases {
+		c, err := parseDebConstraints(tc.Expr)
+		if err != nil {
+			t.Fatalf("%q: err: %s", tc.Expr, err)
+		}
+		if c.Check(tc.Version) != tc.Match {
+			t.Fatalf("%q %s: bad match", tc.Expr, tc.Version)
+		}
+	}
+
+	for _, expr := range []string{">=", "=> 1.0", "~1.0", "!= 1.0"} {
+		if _, err := parseDebConstraints(expr); err == nil {
+			t.Fatalf("%q: should error", expr)
+		}
+	}
+}
+
+func TestAptGetter(t *testing.T) {
+	ln := testAptServer(t)
+	defer ln.Close()
+
+	g := new(AptGetter)
+	cases := []struct {
+		Query    string
+		Filename string
+	}{
+		{"package=curl", "curl_9.0-1_amd64.deb"},
+		{"package=curl&component=main", "curl_8.5.0-2_amd64.deb"},
+		{"package=curl&version=>=%207.88,%20<<%208", "curl_7.88.1-10+deb12u5_amd64.deb"},
+		{"package=curl&version=7.88.1-10", "curl_7.88.1-10_amd64.deb"},
+		{"package=curl&arch=arm64", "curl_7.88.1-10_arm64.deb"},
+		{"package=hello", "hello_2.10-3_all.deb"},
+	}
+	for _, tc := range cases {
+		u := testURL(fmt.Sprintf("http://%s/debian?dist=stable&arch=amd64&%s", ln.Addr(), tc.Query))
+		if strings.Contains(tc.Query, "arch=") {
+			u = testURL(fmt.Sprintf("http://%s/debian/?dist=stable&%s", ln.Addr(), tc.Query))
+		}
+
+		mode, err := g.ClientMode(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		if mode != ClientModeFile {
+			t.Fatalf("%s: bad mode: %d", tc.Query, mode)
+		}
+
+		filename, err := g.GetFilename(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		if filename != tc.Filename {
+			t.Fatalf("%s: bad filename: %s", tc.Query, filename)
+		}
+
+		dst := tempFile(t)
+		if err := g.GetFile(dst, u); err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		assertContents(t, dst, tc.Filename)
+	}
+}
+
+func TestAptGetter_bad(t *testing.T) {
+	ln := testAptServer(t)
+	defer ln.Close()
+
+	g := new(AptGetter)
+	for _, query := range []string{
+		"package=curl",
+		"dist=stable",
+		"dist=missing&package=curl",
+		"dist=stable&package=missing",
+		"dist=stable&package=curl&version=>>%2010",
+		"dist=stable&package=curl&version=abc",
+		"dist=stable&package=curl&arch=riscv64",
+		"dist=stable&package=corrupt",
+		"dist=stable&package=curl&component=../main",
+	} {
+		u := testURL(fmt.Sprintf("http://%s/debian?%s", ln.Addr(), query))
+		if err := g.GetFile(tempFile(t), u); err == nil {
+			t.Fatalf("%s: should error", query)
+		}
+	}
+}
+
+func TestAptGetter_client(t *testing.T) {
+	ln := testAptServer(t)
+	defer ln.Close()
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:  fmt.Sprintf("apt::http://%s/debian?dist=stable&package=curl&arch=arm64", ln.Addr()),
+		Dst:  filepath.Join(dst, "curl.deb"),
+		Mode: ClientModeFile,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "curl.deb"), "curl_7.88.1-10_arm64.deb")
+}
+
+// testAptServer starts a repository at /debian with the distribution
+// stable. Its main component has the versions 7.88.1-10, 7.88.1-10+deb12u5
+// and 8.5.0-2 of curl for amd64 in a gzipped index, 7.88.1-10 for arm64 in
+// an uncompressed one, hello for all architectures and corrupt, whose hash
+// doesn't match. Its contrib component has curl 9.0-1 for amd64. The
+// content of each .deb is its name.
+func testAptServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	files := map[string][]byte{}
+	indexes := map[string]*bytes.Buffer{}
+	add := func(component, arch, name, version, debArch string) {
+		filename := fmt.Sprintf("pool/%s/%c/%s/%s_%s_%s.deb", component, name[0], name, name, version, debArch)
+		content := []byte(filepath.Base(filename))
+		files[filename] = content
+		sum := sha256.Sum256(content)
+		sha := hex.EncodeToString(sum[:])
+		if name == "corrupt" {
+			sha = strings.Repeat("0", 64)
+		}
+
+		key := component + "/binary-" + arch
+		if indexes[key] == nil {
+			indexes[key] = new(bytes.Buffer)
+		}
+		fmt.Fprintf(indexes[key], "Package: %s\nVersion: %s\nArchitecture: %s\n"+
+			"Description: a package\n with a long description\n .\n over lines\n"+
+			"Filename: %s\nSize: %d\nSHA256: %s\n\n",
+			name, version, debArch, filename, len(content), sha)
+	}
+	add("main", "amd64", "curl", "7.88.1-10", "amd64")
+	add("main", "amd64", "curl", "8.5.0-2", "amd64")
+	add("main", "amd64", "curl", "7.88.1-10+deb12u5", "amd64")
+	add("main", "amd64", "hello", "2.10-3", "all")
+	add("main", "amd64", "corrupt", "1.0-1", "amd64")
+	add("main", "arm64", "curl", "7.88.1-10", "arm64")
+	add("contrib", "amd64", "curl", "9.0-1", "amd64")
+
+	// The index of main for amd64 is gzipped, the others aren't
+	var release bytes.Buffer
+	fmt.Fprint(&release, "Origin: Test\nSuite: stable\nArchitectures: amd64 arm64\n"+
+		"Components: main contrib\nDescription: Test\nMD5Sum:\n 00000000000000000000000000000000 0 main/binary-amd64/Packages\nSHA256:\n")
+	for key, index := range indexes {
+		name := "dists/stable/" + key + "/Packages"
+		content := index.Bytes()
+		if key == "main/binary-amd64" {
+			var buf bytes.Buffer
+			gzipW := gzip.NewWriter(&buf)
+			gzipW.Write(content)
+			gzipW.Close()
+			name, content = name+".gz", buf.Bytes()
+		}
+		files[name] = content
+		sum := sha256.Sum256(content)
+		fmt.Fprintf(&release, " %s %d %s\n", hex.EncodeToString(sum[:]), len(content), strings.TrimPrefix(name, "dists/stable/"))
+	}
+	files["dists/stable/Release"] = release.Bytes()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debian/", func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[strings.TrimPrefix(r.URL.Path, "/debian/")]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write(content)
+	})
+
+	var server http.Server
+	server.Handler = mux
+	go server.Serve(ln)
+
+	return ln
+}
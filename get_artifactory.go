@@ -0,0 +1,337 @@
+package getter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ArtifactoryGetter is a Getter implementation that will download an
+// artifact from a JFrog Artifactory repository, resolving the latest one
+// with the REST API, and verify it with its SHA-256 checksum in Artifactory.
+//
+// URLs take the form artifactory::https://host/artifactory/repo/path, where
+// the path is the artifact, or the folder to resolve the latest artifact in.
+// The URL must contain the context path, /artifactory, the API is below.
+// Requests authenticate with basic auth, given in the URL, or with an API
+// key from the apikey query parameter, APIKey or ARTIFACTORY_API_KEY, which
+// is only sent to the host of the URL.
+//
+// Query parameters:
+//   - property: a property the artifact must have, Ex., 'release=true',
+//     can be repeated. The most recently created artifact with the
+//     properties below the path, which can be a glob, is resolved with AQL.
+//   - name: a glob of the name of the artifact, resolved like properties
+//   - version: 'latest' to resolve the latest release of the path as the
+//     group and artifact of a Maven layout, Ex., 'org/acme/app', or a
+//     snapshot version, Ex., '1.0-SNAPSHOT', to resolve its latest build
+//   - ext, classifier: the extension, default as 'jar', and classifier of
+//     the artifact resolved by version
+//   - apikey: the API key to authenticate with
+type ArtifactoryGetter struct {
+	httpBackedGetter
+
+	// APIKey is the API key to authenticate with, if the URL has none.
+	// This defaults to the ARTIFACTORY_API_KEY environment variable.
+	APIKey string
+}
+
+// artifactoryQueryParams are the query parameters of the ArtifactoryGetter,
+// which aren't part of the URL of the artifact.
+var artifactoryQueryParams = []string{"property", "name", "version", "ext", "classifier", "apikey"}
+
+func (g *ArtifactoryGetter) ClientMode(_ *url.URL) (ClientMode, error) {
+	return ClientModeFile, nil
+}
+
+// GetFilename returns the name of the resolved artifact.
+func (g *ArtifactoryGetter) GetFilename(u *url.URL) (string, error) {
+	_, repoPath, err := g.resolve(u)
+	if err != nil {
+		return "", err
+	}
+	return path.Base(repoPath), nil
+}
+
+func (g *ArtifactoryGetter) Get(dst string, u *url.URL) error {
+	base, repoPath, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+	return g.getArtifact(filepath.Join(dst, path.Base(repoPath)), u, base, repoPath)
+}
+
+func (g *ArtifactoryGetter) GetFile(dst string, u *url.URL) error {
+	base, repoPath, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+	return g.getArtifact(dst, u, base, repoPath)
+}
+
+// getArtifact downloads the artifact at repoPath, Ex., 'repo/path/a.jar',
+// to dst and verifies it with the SHA-256 checksum of its file info.
+func (g *ArtifactoryGetter) getArtifact(dst string, u, base *url.URL, repoPath string) error {
+	var info struct {
+		DownloadURI string `json:"downloadUri"`
+		Checksums   struct {
+			SHA256 string `json:"sha256"`
+		} `json:"checksums"`
+	}
+	if err := g.api(u, "GET", g.apiURL(base, "storage/"+repoPath, nil), "", &info); err != nil {
+		return err
+	}
+	if info.Checksums.SHA256 == "" {
+		return fmt.Errorf("artifact %s has no SHA-256 checksum", repoPath)
+	}
+
+	artifactURL := *base
+	artifactURL.Path = base.Path + "/" + repoPath
+	if info.DownloadURI != "" {
+		downloadURL, err := base.Parse(info.DownloadURI)
+		if err != nil {
+			return fmt.Errorf("invalid download URI of %s: %s", repoPath, err)
+		}
+		artifactURL = *downloadURL
+	}
+	// Artifacts on the same host keep its credentials
+	if artifactURL.Host == u.Host && artifactURL.User == nil {
+		artifactURL.User = u.User
+	}
+
+	httpGet := g.authHttpGetter(u, &artifactURL)
+	if err := httpGet.GetFile(dst, &artifactURL); err != nil {
+		return err
+	}
+
+	f, err := os.Open(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != strings.ToLower(info.Checksums.SHA256) {
+		return fmt.Errorf("sha256 of %s doesn't match Artifactory, expected %s, got %s",
+			path.Base(repoPath), info.Checksums.SHA256, actual)
+	}
+	return nil
+}
+
+// resolve returns the URL of Artifactory, with the context path, and the
+// path of the artifact of u in it, with the repository, Ex.,
+// 'repo/path/a.jar'.
+func (g *ArtifactoryGetter) resolve(u *url.URL) (*url.URL, string, error) {
+	i := strings.Index(u.Path+"/", "/artifactory/")
+	if i < 0 {
+		return nil, "", fmt.Errorf("URL must contain the /artifactory context path: %s", redactURL(u.String()))
+	}
+	base := *u
+	base.Path = u.Path[:i+len("/artifactory")]
+	base.RawPath = ""
+	q := base.Query()
+	for _, k := range artifactoryQueryParams {
+		q.Del(k)
+	}
+	base.RawQuery = q.Encode()
+
+	repoPath := strings.Trim(u.Path[i+len("/artifactory"):], "/")
+	if repoPath == "" || containsDotDot(repoPath) {
+		return nil, "", fmt.Errorf("invalid repository path: %q", repoPath)
+	}
+	repo, folder := repoPath, ""
+	if j := strings.Index(repoPath, "/"); j >= 0 {
+		repo, folder = repoPath[:j], repoPath[j+1:]
+	}
+
+	q = u.Query()
+	switch {
+	case len(q["property"]) > 0 || q.Get("name") != "":
+		p, err := g.search(u, &base, repo, folder, q["property"], q.Get("name"))
+		return &base, p, err
+	case q.Get("version") != "":
+		p, err := g.latestVersion(u, &base, repo, folder, q.Get("version"), q.Get("ext"), q.Get("classifier"))
+		return &base, p, err
+	}
+	return &base, repoPath, nil
+}
+
+// search returns the path of the most recently created artifact in repo
+// below folder, a glob, with the properties, Ex., 'release=true', and name,
+// a glob, with AQL.
+func (g *ArtifactoryGetter) search(u, base *url.URL, repo, folder string, properties []string, name string) (string, error) {
+	criteria := map[string]interface{}{"repo": repo, "type": "file"}
+	if folder != "" {
+		criteria["path"] = map[string]string{"$match": folder}
+	}
+	if name != "" {
+		criteria["name"] = map[string]string{"$match": name}
+	}
+	for _, p := range properties {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return "", fmt.Errorf("invalid property, expected 'key=value': %s", p)
+		}
+		criteria["@"+kv[0]] = kv[1]
+	}
+	b, err := json.Marshal(criteria)
+	if err != nil {
+		return "", err
+	}
+	aql := fmt.Sprintf(`items.find(%s).include("repo","path","name","created").sort({"$desc":["created"]}).limit(1)`, b)
+
+	var result struct {
+		Results []struct {
+			Repo string `json:"repo"`
+			Path string `json:"path"`
+			Name string `json:"name"`
+		} `json:"results"`
+	}
+	if err := g.api(u, "POST", g.apiURL(base, "search/aql", nil), aql, &result); err != nil {
+		return "", err
+	}
+	if len(result.Results) == 0 {
+		return "", fmt.Errorf("no artifact in %s matches the name and properties", path.Join(repo, folder))
+	}
+
+	r := result.Results[0]
+	repoPath := path.Join(r.Repo, r.Path, r.Name)
+	if r.Name == "" || containsDotDot(repoPath) {
+		return "", fmt.Errorf("invalid artifact path: %q", repoPath)
+	}
+	return repoPath, nil
+}
+
+// latestVersion returns the path of the artifact of the latest version of
+// folder in repo, as the group and artifact of a Maven layout, with the
+// layout-based latest version search. A snapshot version resolves its
+// latest build.
+func (g *ArtifactoryGetter) latestVersion(u, base *url.URL, repo, folder, version, ext, classifier string) (string, error) {
+	i := strings.LastIndex(folder, "/")
+	if i <= 0 {
+		return "", fmt.Errorf("path must be the group and artifact, Ex., 'org/acme/app': %q", folder)
+	}
+	group, artifact := strings.Replace(folder[:i], "/", ".", -1), folder[i+1:]
+
+	params := url.Values{"g": {group}, "a": {artifact}, "repos": {repo}}
+	switch {
+	case version == "latest":
+	case strings.HasSuffix(version, "-SNAPSHOT"):
+		params.Set("v", version)
+	default:
+		return "", fmt.Errorf("version must be 'latest' or a snapshot version: %s", version)
+	}
+
+	var b []byte
+	if err := g.api(u, "GET", g.apiURL(base, "search/latestVersion", params), "", &b); err != nil {
+		return "", err
+	}
+	resolved := strings.TrimSpace(string(b))
+	if resolved == "" || strings.ContainsAny(resolved, "/\\") || containsDotDot(resolved) {
+		return "", fmt.Errorf("invalid latest version of %s: %q", folder, resolved)
+	}
+
+	dir := resolved
+	if version != "latest" {
+		// Builds of a snapshot are in the folder of the snapshot version
+		dir = version
+	}
+	if ext == "" {
+		ext = "jar"
+	}
+	name := artifact + "-" + resolved
+	if classifier != "" {
+		name += "-" + classifier
+	}
+	return path.Join(repo, folder, dir, name+"."+ext), nil
+}
+
+// apiURL returns the URL of the API endpoint of Artifactory at base, Ex.,
+// 'storage/repo/path', with the query params.
+func (g *ArtifactoryGetter) apiURL(base *url.URL, endpoint string, params url.Values) *url.URL {
+	apiURL := *base
+	apiURL.Path = base.Path + "/api/" + endpoint
+	if params != nil {
+		apiURL.RawQuery = params.Encode()
+	}
+	return &apiURL
+}
+
+// api sends a request, with the body, if any, to the API at apiURL and
+// decodes the JSON response into v, or reads it into v, if it's a *[]byte.
+func (g *ArtifactoryGetter) api(u *url.URL, method string, apiURL *url.URL, body string, v interface{}) error {
+	reqURL := *apiURL
+	if g.http().Netrc {
+		if err := addAuthFromNetrc(&reqURL); err != nil {
+			return err
+		}
+	}
+
+	httpGet := g.authHttpGetter(u, apiURL)
+	httpGet.initClient()
+	req, err := httpGet.newRequest(method, &reqURL)
+	if err != nil {
+		return err
+	}
+	if body != "" {
+		req.Body = ioutil.NopCloser(strings.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.Header.Set("Content-Type", "text/plain")
+	}
+
+	resp, err := httpGet.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad response code getting %s: %d", redactURL(apiURL.String()), resp.StatusCode)
+	}
+
+	if b, ok := v.(*[]byte); ok {
+		*b, err = ioutil.ReadAll(g.rateLimitReader(resp.Body))
+		return err
+	}
+	if err := json.NewDecoder(g.rateLimitReader(resp.Body)).Decode(v); err != nil {
+		return fmt.Errorf("error parsing %s: %s", redactURL(apiURL.String()), err)
+	}
+	return nil
+}
+
+// authHttpGetter returns HttpGet sending the API key, if any, with
+// requests to target, if it's on the host of u.
+func (g *ArtifactoryGetter) authHttpGetter(u, target *url.URL) HttpGetter {
+	hg := g.http()
+	httpGet := *hg
+	if target.Host != u.Host {
+		return httpGet
+	}
+
+	apiKey := u.Query().Get("apikey")
+	if apiKey == "" {
+		apiKey = g.APIKey
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv("ARTIFACTORY_API_KEY")
+	}
+	if apiKey != "" {
+		httpGet.Header = make(map[string][]string)
+		for k, v := range hg.Header {
+			httpGet.Header[k] = v
+		}
+		httpGet.Header.Set("X-JFrog-Art-Api", apiKey)
+	}
+	return httpGet
+}
@@ -0,0 +1,218 @@
+package getter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArtifactoryGetter_impl(t *testing.T) {
+	var _ Getter = new(ArtifactoryGetter)
+}
+
+func TestArtifactoryGetter(t *testing.T) {
+	ln := testArtifactoryServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Path     string
+		Filename string
+	}{
+		{"libs-release/org/acme/app/1.0/app-1.0.jar", "app-1.0.jar"},
+		{"libs-release/org/acme/app?version=latest", "app-1.1.jar"},
+		{"libs-release/org/acme/app?version=latest&ext=pom", "app-1.1.pom"},
+		{"libs-release/org/acme/app?version=latest&classifier=sources", "app-1.1-sources.jar"},
+		{"libs-snapshot/org/acme/app?version=1.2-SNAPSHOT", "app-1.2-20240101.120000-2.jar"},
+		{"generic-local/tools/*?property=release=true", "tool-2.0.tar.gz"},
+		{"generic-local?name=tool-*.tar.gz", "tool-3.0.tar.gz"},
+	}
+	for _, tc := range cases {
+		g := new(ArtifactoryGetter)
+		u := testURL(fmt.Sprintf("http://%s/artifactory/%s", ln.Addr(), tc.Path))
+		if !strings.Contains(tc.Path, "?") {
+			u.RawQuery = "apikey=KEY"
+		} else {
+			u.RawQuery += "&apikey=KEY"
+		}
+
+		mode, err := g.ClientMode(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Path, err)
+		}
+		if mode != ClientModeFile {
+			t.Fatalf("%s: bad mode: %d", tc.Path, mode)
+		}
+
+		filename, err := g.GetFilename(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Path, err)
+		}
+		if filename != tc.Filename {
+			t.Fatalf("%s: bad filename: %s", tc.Path, filename)
+		}
+
+		dst := tempFile(t)
+		if err := g.GetFile(dst, u); err != nil {
+			t.Fatalf("%s: err: %s", tc.Path, err)
+		}
+		assertContents(t, dst, tc.Filename)
+	}
+
+	// The API key can be given to the getter
+	g := &ArtifactoryGetter{APIKey: "KEY"}
+	dst := tempDir(t)
+	u := testURL(fmt.Sprintf("http://%s/artifactory/libs-release/org/acme/app?version=latest", ln.Addr()))
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "app-1.1.jar"), "app-1.1.jar")
+}
+
+func TestArtifactoryGetter_bad(t *testing.T) {
+	ln := testArtifactoryServer(t)
+	defer ln.Close()
+
+	g := new(ArtifactoryGetter)
+	for _, p := range []string{
+		"libs-release/org/acme/app/1.0/app-1.0.jar",
+		"libs-release/org/acme/app/1.0/missing.jar?apikey=KEY",
+		"libs-release/org/acme/app/1.0/corrupt.jar?apikey=KEY",
+		"libs-release/org/acme/app/1.0/unsummed.jar?apikey=KEY",
+		"libs-release/app?version=latest&apikey=KEY",
+		"libs-release/org/acme/app?version=1.0&apikey=KEY",
+		"generic-local?property=release&apikey=KEY",
+		"generic-local?property=missing=true&apikey=KEY",
+		"?apikey=KEY",
+	} {
+		u := testURL(fmt.Sprintf("http://%s/artifactory/%s", ln.Addr(), p))
+		if err := g.GetFile(tempFile(t), u); err == nil {
+			t.Fatalf("%s: should error", p)
+		}
+	}
+
+	u := testURL(fmt.Sprintf("http://%s/libs-release/app.jar?apikey=KEY", ln.Addr()))
+	if err := g.GetFile(tempFile(t), u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestArtifactoryGetter_client(t *testing.T) {
+	ln := testArtifactoryServer(t)
+	defer ln.Close()
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:  fmt.Sprintf("artifactory::http://%s/artifactory/libs-release/org/acme/app?version=latest&apikey=KEY", ln.Addr()),
+		Dst:  filepath.Join(dst, "app.jar"),
+		Mode: ClientModeFile,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "app.jar"), "app-1.1.jar")
+}
+
+// testArtifactoryServer starts an Artifactory at /artifactory, requiring
+// the API key KEY. libs-release has the versions 1.0 and 1.1 of
+// org.acme:app, libs-snapshot builds of 1.2-SNAPSHOT, and generic-local
+// tools with properties, of which tool-3.0.tar.gz is the most recent. The
+// content of each artifact is its name. corrupt.jar has a checksum that
+// doesn't match, and unsummed.jar none.
+func testArtifactoryServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	files := map[string]bool{}
+	for _, f := range []string{
+		"libs-release/org/acme/app/1.0/app-1.0.jar",
+		"libs-release/org/acme/app/1.0/corrupt.jar",
+		"libs-release/org/acme/app/1.0/unsummed.jar",
+		"libs-release/org/acme/app/1.1/app-1.1.jar",
+		"libs-release/org/acme/app/1.1/app-1.1.pom",
+		"libs-release/org/acme/app/1.1/app-1.1-sources.jar",
+		"libs-snapshot/org/acme/app/1.2-SNAPSHOT/app-1.2-20240101.120000-2.jar",
+		"generic-local/tools/stable/tool-2.0.tar.gz",
+		"generic-local/tool-3.0.tar.gz",
+	} {
+		files[f] = true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/artifactory/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-JFrog-Art-Api") != "KEY" {
+			w.WriteHeader(401)
+			return
+		}
+
+		p := strings.TrimPrefix(r.URL.Path, "/artifactory/")
+		switch {
+		case strings.HasPrefix(p, "api/storage/"):
+			name := strings.TrimPrefix(p, "api/storage/")
+			if !files[name] {
+				w.WriteHeader(404)
+				return
+			}
+			sum := sha256.Sum256([]byte(filepath.Base(name)))
+			sha := hex.EncodeToString(sum[:])
+			switch filepath.Base(name) {
+			case "corrupt.jar":
+				sha = strings.Repeat("0", 64)
+			case "unsummed.jar":
+				sha = ""
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"repo":        strings.SplitN(name, "/", 2)[0],
+				"downloadUri": fmt.Sprintf("http://%s/artifactory/%s", r.Host, name),
+				"checksums":   map[string]string{"sha256": sha},
+			})
+		case p == "api/search/latestVersion":
+			q := r.URL.Query()
+			if q.Get("g") != "org.acme" || q.Get("a") != "app" {
+				w.WriteHeader(404)
+				return
+			}
+			switch {
+			case q.Get("repos") == "libs-release" && q.Get("v") == "":
+				fmt.Fprint(w, "1.1")
+			case q.Get("repos") == "libs-snapshot" && q.Get("v") == "1.2-SNAPSHOT":
+				fmt.Fprint(w, "1.2-20240101.120000-2")
+			default:
+				w.WriteHeader(404)
+			}
+		case p == "api/search/aql" && r.Method == "POST":
+			body, _ := ioutil.ReadAll(r.Body)
+			aql := string(body)
+			var results []map[string]string
+			switch {
+			case strings.Contains(aql, `"@release":"true"`) && strings.Contains(aql, `"path":{"$match":"tools/*"}`):
+				results = append(results, map[string]string{"repo": "generic-local", "path": "tools/stable", "name": "tool-2.0.tar.gz"})
+			case strings.Contains(aql, `"name":{"$match":"tool-*.tar.gz"}`) && !strings.Contains(aql, `"path":{`):
+				results = append(results, map[string]string{"repo": "generic-local", "path": ".", "name": "tool-3.0.tar.gz"})
+			}
+			if !strings.HasPrefix(aql, "items.find(") || !strings.Contains(aql, `"repo":"generic-local"`) ||
+				!strings.HasSuffix(aql, `.sort({"$desc":["created"]}).limit(1)`) {
+				results = nil
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+		case files[p]:
+			fmt.Fprint(w, filepath.Base(p))
+		default:
+			w.WriteHeader(404)
+		}
+	})
+
+	var server http.Server
+	server.Handler = mux
+	go server.Serve(ln)
+
+	return ln
+}
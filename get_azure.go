@@ -0,0 +1,480 @@
+package getter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AzureBlobGetter is a Getter implementation that will download a blob, or
+// all the blobs below a virtual directory prefix, from Azure Blob Storage.
+//
+// URLs take the form azure::https://account.blob.core.windows.net/container/path,
+// abs:: and azblob:// are accepted as well. Other hosts, such as the Azurite emulator, are
+// addressed in path style, with the account as the first path segment:
+// azure::http://127.0.0.1:10000/account/container/path.
+//
+// Requests are authorized with the first of, if any:
+//   - a SAS token, given URL encoded in the sas query parameter, or as the
+//     query parameters of the token themselves
+//   - the account key, from the account_key query parameter or the
+//     AZURE_STORAGE_KEY environment variable
+//   - an Azure AD access token, from the access_token query parameter, the
+//     AZURE_STORAGE_ACCESS_TOKEN environment variable or Token
+//   - the managed identity of the VM or App Service, if the managed_identity
+//     query parameter is true, or the user-assigned identity of the client_id
+//     query parameter
+//
+// Otherwise the container is accessed anonymously, which works for
+// containers allowing public read access.
+type AzureBlobGetter struct {
+	getter
+
+	// Client is the http.Client to use for requests to the storage account.
+	// This defaults to the client shared with the HttpGetter if left unset.
+	Client *http.Client
+
+	// Token is an Azure AD access token for the storage account, used if no
+	// other credentials are given.
+	Token string
+}
+
+// azureAPIVersion is the version of the Blob service REST API requested.
+const azureAPIVersion = "2020-04-08"
+
+// azureIMDSEndpoint is the token endpoint of the Azure Instance Metadata
+// Service, which issues the access tokens of managed identities.
+var azureIMDSEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// azureBlob is a parsed reference to a blob, or a prefix of blobs, in a
+// container.
+type azureBlob struct {
+	// Base is the URL of the container, Ex.,
+	// 'https://account.blob.core.windows.net/container'
+	Base *url.URL
+
+	Account string
+	Path    string
+
+	// Query are the query parameters to add to every request, i.e. the SAS
+	// token.
+	Query      url.Values
+	AccountKey string
+	Token      string
+}
+
+// azureBlobList is the response of the List Blobs operation.
+type azureBlobList struct {
+	Blobs []struct {
+		Name string `xml:"Name"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (g *AzureBlobGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	blob, err := g.parseUrl(u)
+	if err != nil {
+		return 0, err
+	}
+	if blob.Path == "" || strings.HasSuffix(blob.Path, "/") {
+		return ClientModeDir, nil
+	}
+
+	// A blob exists at exactly the path for single files, otherwise the
+	// path is a virtual directory.
+	resp, err := g.request(blob, "HEAD", blob.Path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case 200:
+		return ClientModeFile, nil
+	case 404:
+		return ClientModeDir, nil
+	default:
+		return 0, fmt.Errorf("bad response code checking blob %s: %d", blob.Path, resp.StatusCode)
+	}
+}
+
+func (g *AzureBlobGetter) GetFilename(u *url.URL) (string, error) {
+	return "", nil
+}
+
+func (g *AzureBlobGetter) Get(dst string, u *url.URL) error {
+	blob, err := g.parseUrl(u)
+	if err != nil {
+		return err
+	}
+
+	prefix := blob.Path
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// List the blobs below the prefix, keep listing until there is no marker
+	// of a next page
+	found := false
+	marker := ""
+	for {
+		list, err := g.list(blob, prefix, marker)
+		if err != nil {
+			return err
+		}
+
+		for _, b := range list.Blobs {
+			// Directory markers of accounts with a hierarchical namespace
+			// end with a slash, skip them
+			if strings.HasSuffix(b.Name, "/") {
+				continue
+			}
+
+			rel := strings.TrimPrefix(b.Name, prefix)
+			if containsDotDot(rel) {
+				return fmt.Errorf("blob name contains '..': %s", b.Name)
+			}
+
+			found = true
+			if err := g.getBlob(filepath.Join(dst, filepath.FromSlash(rel)), blob, b.Name); err != nil {
+				return err
+			}
+		}
+
+		if list.NextMarker == "" {
+			break
+		}
+		marker = list.NextMarker
+	}
+
+	if !found {
+		return fmt.Errorf("no blobs found below %s", u)
+	}
+
+	return nil
+}
+
+func (g *AzureBlobGetter) GetFile(dst string, u *url.URL) error {
+	blob, err := g.parseUrl(u)
+	if err != nil {
+		return err
+	}
+
+	return g.getBlob(dst, blob, blob.Path)
+}
+
+// getBlob downloads the blob with the given name to dst.
+func (g *AzureBlobGetter) getBlob(dst string, blob *azureBlob, name string) error {
+	resp, err := g.request(blob, "GET", name, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("bad response code downloading blob %s: %d", name, resp.StatusCode)
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if h := g.outputHash(dst); h != nil {
+		w = io.MultiWriter(f, h)
+	}
+
+	_, err = io.Copy(w, g.rateLimitReader(resp.Body))
+	return err
+}
+
+// list returns the page of blobs below prefix starting at marker.
+func (g *AzureBlobGetter) list(blob *azureBlob, prefix, marker string) (*azureBlobList, error) {
+	q := url.Values{}
+	q.Set("restype", "container")
+	q.Set("comp", "list")
+	if prefix != "" {
+		q.Set("prefix", prefix)
+	}
+	if marker != "" {
+		q.Set("marker", marker)
+	}
+
+	resp, err := g.request(blob, "GET", "", q)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("bad response code listing blobs of %s: %d", blob.Base, resp.StatusCode)
+	}
+
+	var list azureBlobList
+	if err := xml.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("error parsing blob list of %s: %s", blob.Base, err)
+	}
+
+	return &list, nil
+}
+
+// request issues a request for the blob with the given name, or for the
+// container if name is empty, authorized with the credentials of blob.
+func (g *AzureBlobGetter) request(blob *azureBlob, method, name string, query url.Values) (*http.Response, error) {
+	if g.Client == nil {
+		g.Client = httpClient
+	}
+
+	u := *blob.Base
+	if name != "" {
+		u.Path += "/" + name
+	}
+	q := url.Values{}
+	for k, v := range blob.Query {
+		q[k] = v
+	}
+	for k, v := range query {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(g.ctx())
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureAPIVersion)
+
+	switch {
+	case len(blob.Query) > 0:
+		// The SAS token in the query authorizes the request
+	case blob.AccountKey != "":
+		if err := azureSignSharedKey(req, blob.Account, blob.AccountKey); err != nil {
+			return nil, err
+		}
+	case blob.Token != "":
+		req.Header.Set("Authorization", "Bearer "+blob.Token)
+	}
+
+	return g.Client.Do(req)
+}
+
+func (g *AzureBlobGetter) parseUrl(u *url.URL) (*azureBlob, error) {
+	switch u.Scheme {
+	case "http", "https":
+	case "azure", "abs", "azblob":
+		u2 := *u
+		u2.Scheme = "https"
+		u = &u2
+	default:
+		return nil, fmt.Errorf("unsupported scheme for Azure blob: %s", u.Scheme)
+	}
+
+	// Hosts of the public cloud, and the sovereign ones, name the account
+	// as their first label. Other hosts are addressed in path style.
+	var account, path string
+	if idx := strings.Index(u.Host, ".blob."); idx > 0 {
+		account = u.Host[:idx]
+		path = strings.TrimPrefix(u.Path, "/")
+	} else {
+		parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+		account = parts[0]
+		path = strings.TrimPrefix(u.Path, "/"+account)
+		path = strings.TrimPrefix(path, "/")
+	}
+
+	parts := strings.SplitN(path, "/", 2)
+	container := parts[0]
+	if account == "" || container == "" {
+		return nil, fmt.Errorf("URL is not a valid Azure blob URL: %s", u)
+	}
+	blob := &azureBlob{
+		Base: &url.URL{
+			Scheme: u.Scheme,
+			Host:   u.Host,
+			Path:   strings.TrimSuffix(u.Path, path) + container,
+		},
+		Account: account,
+		Query:   url.Values{},
+	}
+	if len(parts) > 1 {
+		blob.Path = parts[1]
+	}
+
+	q := u.Query()
+	blob.AccountKey = q.Get("account_key")
+	if blob.AccountKey == "" {
+		blob.AccountKey = os.Getenv("AZURE_STORAGE_KEY")
+	}
+	blob.Token = q.Get("access_token")
+	if blob.Token == "" {
+		blob.Token = os.Getenv("AZURE_STORAGE_ACCESS_TOKEN")
+	}
+	if blob.Token == "" {
+		blob.Token = g.Token
+	}
+
+	if sas := q.Get("sas"); sas != "" {
+		sasQuery, err := url.ParseQuery(strings.TrimPrefix(sas, "?"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SAS token: %s", err)
+		}
+		blob.Query = sasQuery
+	}
+
+	managedIdentity, _ := strconv.ParseBool(q.Get("managed_identity"))
+	clientID := q.Get("client_id")
+
+	// Any other query parameters are those of a SAS token given as is
+	for _, k := range []string{"sas", "account_key", "access_token", "managed_identity", "client_id"} {
+		q.Del(k)
+	}
+	for k, v := range q {
+		blob.Query[k] = v
+	}
+
+	if (managedIdentity || clientID != "") && len(blob.Query) == 0 && blob.AccountKey == "" && blob.Token == "" {
+		token, err := g.managedIdentityToken(clientID)
+		if err != nil {
+			return nil, err
+		}
+		blob.Token = token
+	}
+
+	return blob, nil
+}
+
+// managedIdentityToken requests an access token for Azure Storage of the
+// managed identity of the VM or App Service, or of the user-assigned
+// identity with the client ID if not empty.
+func (g *AzureBlobGetter) managedIdentityToken(clientID string) (string, error) {
+	if g.Client == nil {
+		g.Client = httpClient
+	}
+
+	endpoint, apiVersion, header, secret := azureIMDSEndpoint, "2018-02-01", "Metadata", "true"
+	// App Service and Functions have an endpoint of their own
+	if e, h := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER"); e != "" && h != "" {
+		endpoint, apiVersion, header, secret = e, "2019-08-01", "X-IDENTITY-HEADER", h
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("api-version", apiVersion)
+	q.Set("resource", "https://storage.azure.com/")
+	if clientID != "" {
+		q.Set("client_id", clientID)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(g.ctx())
+	req.Header.Set(header, secret)
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting a managed identity token: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("bad response code requesting a managed identity token: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("error parsing managed identity token: %s", err)
+	}
+	if body.AccessToken == "" {
+		return "", fmt.Errorf("no managed identity token returned by %s", endpoint)
+	}
+	return body.AccessToken, nil
+}
+
+// azureSignSharedKey authorizes req with the Shared Key of the account.
+func azureSignSharedKey(req *http.Request, account, key string) error {
+	decodedKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return fmt.Errorf("invalid Azure storage account key: %s", err)
+	}
+
+	// Content headers are all empty for the GET and HEAD requests we make,
+	// only the x-ms- headers and resource are signed
+	var headers []string
+	for k := range req.Header {
+		if k := strings.ToLower(k); strings.HasPrefix(k, "x-ms-") {
+			headers = append(headers, k)
+		}
+	}
+	sort.Strings(headers)
+
+	var sts []string
+	sts = append(sts, req.Method)
+	sts = append(sts, make([]string, 11)...)
+	for _, k := range headers {
+		sts = append(sts, k+":"+req.Header.Get(k))
+	}
+
+	resource := "/" + account + req.URL.EscapedPath()
+	q := req.URL.Query()
+	var keys []string
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		values := q[k]
+		sort.Strings(values)
+		resource += "\n" + strings.ToLower(k) + ":" + strings.Join(values, ",")
+	}
+	sts = append(sts, resource)
+
+	mac := hmac.New(sha256.New, decodedKey)
+	mac.Write([]byte(strings.Join(sts, "\n")))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	req.Header.Set("Authorization", fmt.Sprintf("SharedKey %s:%s", account, signature))
+	return nil
+}
@@ -0,0 +1,339 @@
+package getter
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testAzureKey is the account key of the test storage account.
+var testAzureKey = base64.StdEncoding.EncodeToString([]byte("secret"))
+
+func TestAzureBlobGetter_impl(t *testing.T) {
+	var _ Getter = new(AzureBlobGetter)
+}
+
+func TestAzureBlobGetter_parseUrl(t *testing.T) {
+	cases := []struct {
+		Input   string
+		Base    string
+		Account string
+		Path    string
+		Err     bool
+	}{
+		{
+			"https://account.blob.core.windows.net/container/dir/file.txt",
+			"https://account.blob.core.windows.net/container", "account", "dir/file.txt", false,
+		},
+		{
+			"azure://account.blob.core.windows.net/container/dir",
+			"https://account.blob.core.windows.net/container", "account", "dir", false,
+		},
+		{
+			"azblob://account.blob.core.windows.net/container/dir",
+			"https://account.blob.core.windows.net/container", "account", "dir", false,
+		},
+		{
+			"http://127.0.0.1:10000/devstoreaccount1/container/file.txt",
+			"http://127.0.0.1:10000/devstoreaccount1/container", "devstoreaccount1", "file.txt", false,
+		},
+		{
+			"https://account.blob.core.windows.net/container",
+			"https://account.blob.core.windows.net/container", "account", "", false,
+		},
+		{"https://account.blob.core.windows.net/", "", "", "", true},
+		{"ftp://account.blob.core.windows.net/container/file.txt", "", "", "", true},
+	}
+
+	g := new(AzureBlobGetter)
+	for _, tc := range cases {
+		blob, err := g.parseUrl(testURL(tc.Input))
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %s", tc.Input, err)
+		}
+		if tc.Err {
+			continue
+		}
+		if blob.Base.String() != tc.Base || blob.Account != tc.Account || blob.Path != tc.Path {
+			t.Fatalf("%s: bad: %s %s %s", tc.Input, blob.Base, blob.Account, blob.Path)
+		}
+	}
+}
+
+func TestAzureBlobGetter_parseUrlCredentials(t *testing.T) {
+	g := &AzureBlobGetter{Token: "token"}
+	blob, err := g.parseUrl(testURL(
+		"https://account.blob.core.windows.net/container/file.txt?sas=" +
+			url.QueryEscape("sv=2020-04-08&sig=abc") + "&account_key=key"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if blob.Query.Get("sig") != "abc" || blob.Query.Get("sv") != "2020-04-08" {
+		t.Fatalf("bad SAS: %v", blob.Query)
+	}
+	if blob.AccountKey != "key" || blob.Token != "token" {
+		t.Fatalf("bad credentials: %#v", blob)
+	}
+
+	// A SAS token can also be given as is
+	blob, err = g.parseUrl(testURL(
+		"https://account.blob.core.windows.net/container/file.txt?sv=2020-04-08&sig=abc"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if blob.Query.Get("sig") != "abc" {
+		t.Fatalf("bad SAS: %v", blob.Query)
+	}
+}
+
+func TestAzureBlobGetter_file(t *testing.T) {
+	ln := testAzureServer(t)
+	defer ln.Close()
+
+	g := new(AzureBlobGetter)
+	u := testAzureURL(ln, "public/file.txt")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeFile {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempFile(t)
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestAzureBlobGetter_dir(t *testing.T) {
+	ln := testAzureServer(t)
+	defer ln.Close()
+
+	g := new(AzureBlobGetter)
+	u := testAzureURL(ln, "public/module")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempDir(t)
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "# Hello\n")
+	assertContents(t, filepath.Join(dst, "sub", "foo.txt"), "foo\n")
+}
+
+func TestAzureBlobGetter_dirEmpty(t *testing.T) {
+	ln := testAzureServer(t)
+	defer ln.Close()
+
+	g := new(AzureBlobGetter)
+	dst := tempDir(t)
+	if err := g.Get(dst, testAzureURL(ln, "public/missing")); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestAzureBlobGetter_auth(t *testing.T) {
+	ln := testAzureServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Name  string
+		Query string
+		Token string
+		Err   bool
+	}{
+		{"anonymous", "", "", true},
+		{"sas", "?sas=" + url.QueryEscape("sv=2020-04-08&sig=secret"), "", false},
+		{"sas as is", "?sv=2020-04-08&sig=secret", "", false},
+		{"bad sas", "?sig=wrong", "", true},
+		{"account key", "?account_key=" + url.QueryEscape(testAzureKey), "", false},
+		{"bad account key", "?account_key=" + url.QueryEscape(base64.StdEncoding.EncodeToString([]byte("wrong"))), "", true},
+		{"access token", "?access_token=token", "", false},
+		{"token field", "", "token", false},
+		{"managed identity", "?managed_identity=true", "", false},
+		{"user-assigned identity", "?client_id=client", "", false},
+		{"bad user-assigned identity", "?client_id=wrong", "", true},
+	}
+
+	old := azureIMDSEndpoint
+	azureIMDSEndpoint = fmt.Sprintf("http://%s/metadata/identity/oauth2/token", ln.Addr().String())
+	defer func() { azureIMDSEndpoint = old }()
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			g := &AzureBlobGetter{Token: tc.Token}
+			u := testURL(testAzureURL(ln, "private/module").String() + tc.Query)
+
+			dst := tempDir(t)
+			err := g.Get(dst, u)
+			if (err != nil) != tc.Err {
+				t.Fatalf("err: %v", err)
+			}
+			if !tc.Err {
+				assertContents(t, filepath.Join(dst, "main.tf"), "# Hello\n")
+			}
+		})
+	}
+}
+
+func TestAzureBlobGetter_client(t *testing.T) {
+	ln := testAzureServer(t)
+	defer ln.Close()
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:  "azure::" + testAzureURL(ln, "public/module").String(),
+		Dst:  dst,
+		Mode: ClientModeAny,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "# Hello\n")
+}
+
+func TestAzureBlobGetter_rateLimit(t *testing.T) {
+	ln := testAzureServer(t)
+	defer ln.Close()
+
+	// The first 4 bytes are a burst, the last 2 take half a second
+	g := new(AzureBlobGetter)
+	g.SetClient(&Client{RateLimit: 4})
+	dst := tempFile(t)
+	start := time.Now()
+	if err := g.GetFile(dst, testAzureURL(ln, "public/file.txt")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("download wasn't throttled: %s", elapsed)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func testAzureURL(ln net.Listener, path string) *url.URL {
+	return testURL(fmt.Sprintf("http://%s/devstoreaccount1/%s", ln.Addr().String(), path))
+}
+
+// testAzureServer starts a minimal Blob service, addressed in path style,
+// for the account devstoreaccount1. Its container public can be read
+// anonymously, its container private requires the SAS token sig=secret, the
+// account key testAzureKey or the access token "token", which is also issued
+// by its managed identity endpoint for the client IDs "" and "client". Blobs
+// are listed one per page, to test listing with markers.
+func testAzureServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	blobs := map[string]string{
+		"file.txt":           "Hello\n",
+		"module/main.tf":     "# Hello\n",
+		"module/sub/":        "",
+		"module/sub/foo.txt": "foo\n",
+		"other/bar.txt":      "bar\n",
+	}
+	var names []string
+	for name := range blobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	authorized := func(r *http.Request) bool {
+		switch auth := r.Header.Get("Authorization"); {
+		case r.URL.Query().Get("sig") == "secret":
+			return true
+		case auth == "Bearer token":
+			return true
+		case strings.HasPrefix(auth, "SharedKey "):
+			r2 := *r
+			r2.Header = http.Header{}
+			for k, v := range r.Header {
+				if strings.HasPrefix(strings.ToLower(k), "x-ms-") {
+					r2.Header[k] = v
+				}
+			}
+			if err := azureSignSharedKey(&r2, "devstoreaccount1", testAzureKey); err != nil {
+				return false
+			}
+			return r2.Header.Get("Authorization") == auth
+		}
+		return false
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metadata/identity/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.Header.Get("Metadata") != "true" || q.Get("resource") != "https://storage.azure.com/" {
+			w.WriteHeader(400)
+			return
+		}
+		if id := q.Get("client_id"); id != "" && id != "client" {
+			w.WriteHeader(400)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "token", "token_type": "Bearer"}`)
+	})
+	mux.HandleFunc("/devstoreaccount1/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/devstoreaccount1/"), "/", 2)
+		if parts[0] != "public" && !(parts[0] == "private" && authorized(r)) {
+			w.WriteHeader(403)
+			return
+		}
+
+		q := r.URL.Query()
+		if len(parts) == 1 && q.Get("comp") == "list" {
+			var matches []string
+			for _, name := range names {
+				if strings.HasPrefix(name, q.Get("prefix")) && name > q.Get("marker") {
+					matches = append(matches, name)
+				}
+			}
+
+			fmt.Fprint(w, "<EnumerationResults><Blobs>")
+			if len(matches) > 0 {
+				fmt.Fprintf(w, "<Blob><Name>%s</Name></Blob>", matches[0])
+			}
+			fmt.Fprint(w, "</Blobs><NextMarker>")
+			if len(matches) > 1 {
+				fmt.Fprint(w, matches[0])
+			}
+			fmt.Fprint(w, "</NextMarker></EnumerationResults>")
+			return
+		}
+
+		content, ok := blobs[parts[len(parts)-1]]
+		if len(parts) != 2 || !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if r.Method != "HEAD" {
+			fmt.Fprint(w, content)
+		}
+	})
+
+	var server http.Server
+	server.Handler = mux
+	go server.Serve(ln)
+
+	return ln
+}
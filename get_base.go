@@ -0,0 +1,217 @@
+package getter
+
+import (
+	"context"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+)
+
+// getter is our base getter; it regroups fields all getters have in common.
+type getter struct {
+	client *Client
+}
+
+// SetClient sets the Client the getter is used by. The Client calls this
+// before downloading so the getter can access its configuration.
+func (g *getter) SetClient(c *Client) { g.client = c }
+
+// logger returns the Logger of the getter's client. If the getter isn't
+// used by a client, or the client has no Logger, nothing is logged.
+func (g *getter) logger() Logger {
+	if g == nil || g.client == nil {
+		return discardLogger{}
+	}
+	return g.client.logger()
+}
+
+// tempDir returns the directory temporary files should be created in. An
+// empty string means the system's temporary directory.
+func (g *getter) tempDir() string {
+	if g == nil || g.client == nil {
+		return ""
+	}
+	return g.client.TempDir
+}
+
+// tempPattern returns pattern, the prefix of the name of a temporary file or
+// directory, prefixed with the TempPattern of the getter's client.
+func (g *getter) tempPattern(pattern string) string {
+	if g == nil || g.client == nil {
+		return pattern
+	}
+	return g.client.TempPattern + pattern
+}
+
+// removeTemp removes the temporary file or directory at path, unless the
+// getter's client keeps it with KeepTempOnError.
+func (g *getter) removeTemp(path string) {
+	if g == nil || g.client == nil {
+		os.RemoveAll(path)
+		return
+	}
+	g.client.removeTemp(path)
+}
+
+// ctx returns the context of the getter's client, bounded by the timeout of
+// the getter, which getters pass on to the requests and commands they make
+// so that they are aborted with it.
+func (g *getter) ctx() context.Context {
+	if g == nil || g.client == nil {
+		return context.Background()
+	}
+	if g.client.timeoutCtx != nil {
+		return g.client.timeoutCtx
+	}
+	if g.client.Ctx == nil {
+		return context.Background()
+	}
+	return g.client.Ctx
+}
+
+// hooks returns the Hooks of the getter's client, which may be nil.
+func (g *getter) hooks() *Hooks {
+	if g == nil || g.client == nil {
+		return nil
+	}
+	return g.client.Hooks
+}
+
+// rewriteURL returns u rewritten by the URLRewrite hook of the getter's
+// client, for getters fetching URLs they derive from the source, or u
+// itself if there is no such hook.
+func (g *getter) rewriteURL(u *url.URL) (*url.URL, error) {
+	if g == nil || g.client == nil {
+		return u, nil
+	}
+	return g.client.rewriteURL(u)
+}
+
+// progressReader returns r reporting the progress of reading the file at u
+// of the given total size to the Progress hook and the ProgressTracker of the
+// getter's client, or r itself if there are none.
+func (g *getter) progressReader(u *url.URL, r io.Reader, total int64) io.Reader {
+	if g == nil || g.client == nil {
+		return r
+	}
+	return g.client.progressReader(u, r, total)
+}
+
+// rateLimitReader returns rc throttled to the RateLimit of the getter's
+// client, or rc itself if there is none.
+func (g *getter) rateLimitReader(rc io.ReadCloser) io.ReadCloser {
+	if g == nil || g.client == nil {
+		return rc
+	}
+	return newRateLimitReader(g.ctx(), rc, g.client.RateLimit)
+}
+
+// progress reports the progress of downloading the file at u to the getter's
+// client, if any.
+func (g *getter) progress(u *url.URL, read, total int64) {
+	if g != nil && g.client != nil {
+		g.client.progress(u, read, total)
+	}
+}
+
+// decompressors returns the Decompressors of the getter's client, falling
+// back to the default Decompressors.
+func (g *getter) decompressors() map[string]Decompressor {
+	if g == nil || g.client == nil || g.client.Decompressors == nil {
+		return Decompressors
+	}
+	return g.client.Decompressors
+}
+
+// outputHash returns the hash the file downloaded to dst should be written
+// to while it is downloaded, to compute the output checksum of the client,
+// or nil if there is none for dst. Getters calling this must write the whole
+// file to it, as it is saved.
+func (g *getter) outputHash(dst string) hash.Hash {
+	if g == nil || g.client == nil || g.client.outputHash == nil || g.client.outputHash.path != dst {
+		return nil
+	}
+	g.client.outputHash.streamed = true
+	return g.client.outputHash.Hash
+}
+
+// checksummers returns the checksum types supported by the getter's client,
+// see Client.Checksummers.
+func (g *getter) checksummers() map[string]func() hash.Hash {
+	if g == nil || g.client == nil || g.client.Checksummers == nil {
+		return Checksummers
+	}
+	return g.client.Checksummers
+}
+
+// httpBackedGetter is the base of the getters downloading over HTTP, such
+// as those of package registries.
+type httpBackedGetter struct {
+	getter
+
+	// HttpGet is the HttpGetter files are downloaded with. Its client,
+	// credentials and headers are used for the other requests of the getter
+	// too. If this is nil, a copy of the HttpGetter of the "https" Getter
+	// of the Client is used.
+	HttpGet *HttpGetter
+}
+
+// SetClient sets the Client for the getter and its HttpGetter. The
+// HttpGetter is copied, as it may be shared with other getters.
+func (g *httpBackedGetter) SetClient(c *Client) {
+	g.getter.SetClient(c)
+	hg := *g.http()
+	hg.SetClient(c)
+	g.HttpGet = &hg
+}
+
+// http returns HttpGet or, if it's nil, a copy of the HttpGetter of the
+// "https" Getter of the getter's client, falling back to the default
+// Getters, or a new HttpGetter using netrc if there is none. Callers must
+// use the returned HttpGetter for all the requests of a download.
+func (g *httpBackedGetter) http() *HttpGetter {
+	if g.HttpGet != nil {
+		return g.HttpGet
+	}
+
+	getters := Getters
+	if g.client != nil && g.client.Getters != nil {
+		getters = g.client.Getters
+	}
+	hg := &HttpGetter{Netrc: true}
+	if https, ok := getters["https"].(*HttpGetter); ok {
+		*hg = *https
+	}
+	return hg
+}
+
+// clientSetter is implemented by Getters that want access to the Client
+// they are used by.
+type clientSetter interface {
+	SetClient(*Client)
+}
+
+// bindGetter returns g with access to c. The Getters are shared by all
+// Clients, so rather than setting c on g, which races with other Clients
+// using it, a copy of g is returned with c set. Getters that can't be
+// copied, not being pointers to structs, are set c as is.
+func bindGetter(g Getter, c *Client) Getter {
+	cs, ok := g.(clientSetter)
+	if !ok {
+		return g
+	}
+
+	v := reflect.ValueOf(g)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		cs.SetClient(c)
+		return g
+	}
+
+	cp := reflect.New(v.Elem().Type())
+	cp.Elem().Set(v.Elem())
+	g = cp.Interface().(Getter)
+	g.(clientSetter).SetClient(c)
+	return g
+}
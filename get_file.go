@@ -8,7 +8,11 @@ import (
 // FileGetter is a Getter implementation that will download a module from
 // a file scheme.
 type FileGetter struct {
-	// Copy, if set to true, will copy data instead of using a symlink
+	getter
+
+	// Copy, if set to true, will copy data instead of using a symlink.
+	// Directories are copied recursively, keeping the modes and
+	// modification times of their contents.
 	Copy bool
 }
 
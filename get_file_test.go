@@ -1,9 +1,12 @@
 package getter
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func TestFileGetter_impl(t *testing.T) {
@@ -103,6 +106,85 @@ func TestFileGetter_dirSymlink(t *testing.T) {
 	}
 }
 
+func TestFileGetter_dirCopy(t *testing.T) {
+	g := &FileGetter{Copy: true}
+	dst := tempDir(t)
+
+	// Give the source file a distinct mode and mtime to check they're kept
+	src := tempDir(t)
+	if err := os.MkdirAll(filepath.Join(src, "subdir"), 0700); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	srcFile := filepath.Join(src, "subdir", "main.tf")
+	if err := ioutil.WriteFile(srcFile, []byte("Hello\n"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	mtime := time.Unix(1000000000, 0)
+	if err := os.Chtimes(srcFile, mtime, mtime); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := g.Get(dst, testURL("file://"+filepath.ToSlash(src))); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Verify the destination folder is not a symlink
+	fi, err := os.Lstat(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		t.Fatal("destination is a symlink")
+	}
+
+	dstFile := filepath.Join(dst, "subdir", "main.tf")
+	assertContents(t, dstFile, "Hello\n")
+	fi, err = os.Stat(dstFile)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Fatalf("bad mtime: %s", fi.ModTime())
+	}
+	if runtime.GOOS != "windows" {
+		if fi.Mode().Perm() != 0600 {
+			t.Fatalf("bad mode: %o", fi.Mode().Perm())
+		}
+		fi, err = os.Stat(filepath.Join(dst, "subdir"))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if fi.Mode().Perm() != 0700 {
+			t.Fatalf("bad dir mode: %o", fi.Mode().Perm())
+		}
+	}
+
+	// Copying again into the existing directory works
+	if err := g.Get(dst, testURL("file://"+filepath.ToSlash(src))); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestFileGetter_dirCopySubdir(t *testing.T) {
+	dst := tempDir(t)
+	client := &Client{
+		Src:  testModule("basic//subdir"),
+		Dst:  dst,
+		Mode: ClientModeDir,
+		Getters: map[string]Getter{
+			"file": &FileGetter{Copy: true},
+		},
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	mainPath := filepath.Join(dst, "sub.tf")
+	if _, err := os.Stat(mainPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func TestFileGetter_GetFile(t *testing.T) {
 	g := new(FileGetter)
 	dst := tempFile(t)
@@ -30,17 +30,18 @@ func (g *FileGetter) Get(dst string, u *url.URL) error {
 		return err
 	}
 
-	// If the destination already exists, it must be a symlink
+	// If the destination already exists, it must be a symlink, or a
+	// directory to copy into
 	if err == nil {
 		mode := fi.Mode()
-		if mode&os.ModeSymlink == 0 {
+		if mode&os.ModeSymlink != 0 {
+			// Remove the destination
+			if err := os.Remove(dst); err != nil {
+				return err
+			}
+		} else if !g.Copy || !fi.IsDir() {
 			return fmt.Errorf("destination exists and is not a symlink")
 		}
-
-		// Remove the destination
-		if err := os.Remove(dst); err != nil {
-			return err
-		}
 	}
 
 	// Create all the parent directories
@@ -48,7 +49,16 @@ func (g *FileGetter) Get(dst string, u *url.URL) error {
 		return err
 	}
 
-	return os.Symlink(path, dst)
+	// If we're not copying, just symlink and we're done
+	if !g.Copy {
+		return os.Symlink(path, dst)
+	}
+
+	// Copy the directory tree, keeping modes and modification times
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	return copyDir(dst, path, false)
 }
 
 func (g *FileGetter) GetFile(dst string, u *url.URL) error {
@@ -65,7 +75,8 @@ func (g *FileGetter) GetFile(dst string, u *url.URL) error {
 		return fmt.Errorf("source path must be a file")
 	}
 
-	if _, err := os.Lstat(dst); err != nil && !os.IsNotExist(err) {
+	_, err = os.Lstat(dst)
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
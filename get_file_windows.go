@@ -30,17 +30,18 @@ func (g *FileGetter) Get(dst string, u *url.URL) error {
 		return err
 	}
 
-	// If the destination already exists, it must be a symlink
+	// If the destination already exists, it must be a symlink, or a
+	// directory to copy into
 	if err == nil {
 		mode := fi.Mode()
-		if mode&os.ModeSymlink == 0 {
+		if mode&os.ModeSymlink != 0 {
+			// Remove the destination
+			if err := os.Remove(dst); err != nil {
+				return err
+			}
+		} else if !g.Copy || !fi.IsDir() {
 			return fmt.Errorf("destination exists and is not a symlink")
 		}
-
-		// Remove the destination
-		if err := os.Remove(dst); err != nil {
-			return err
-		}
 	}
 
 	// Create all the parent directories
@@ -48,6 +49,15 @@ func (g *FileGetter) Get(dst string, u *url.URL) error {
 		return err
 	}
 
+	// If we're copying, copy the directory tree, keeping modes and
+	// modification times
+	if g.Copy {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+		return copyDir(dst, path, false)
+	}
+
 	sourcePath := toBackslash(path)
 
 	// Use mklink to create a junction point
@@ -0,0 +1,586 @@
+package getter
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FtpGetter is a Getter implementation that will download a file, or all
+// the files below a directory, from an FTP server.
+//
+// URLs take the form ftp://[user[:password]@]host[:port]/path, logging in as
+// the anonymous user if there is no user. Like with curl, the path is
+// relative to the directory the user logs in to, unless it starts with an
+// encoded slash, Ex., ftp://host/%2Fetc/file. ftps:// URLs upgrade the
+// connection to TLS with AUTH TLS (explicit FTPS) before logging in, and
+// protect the data connections as well.
+//
+// Data connections are passive by default. Set the mode query parameter to
+// "active" to have the server connect back to the client instead.
+type FtpGetter struct {
+	getter
+
+	// TLSClientConfig is the TLS configuration of ftps connections, Ex.,
+	// with the root CAs of internal servers. The ServerName defaults to the
+	// host of the URL.
+	TLSClientConfig *tls.Config
+}
+
+// ftpTimeout bounds connecting to the server, and waiting for it to connect
+// back in active mode.
+const ftpTimeout = 30 * time.Second
+
+// ftpEntry is a file or directory listed in a directory.
+type ftpEntry struct {
+	Name string
+	Dir  bool
+}
+
+func (g *FtpGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	c, err := g.dial(u)
+	if err != nil {
+		return 0, err
+	}
+	defer c.quit()
+
+	if strings.HasSuffix(u.Path, "/") {
+		return ClientModeDir, nil
+	}
+	dir, err := c.isDir(c.path(u.Path))
+	if err != nil {
+		return 0, err
+	}
+	if dir {
+		return ClientModeDir, nil
+	}
+	return ClientModeFile, nil
+}
+
+func (g *FtpGetter) GetFilename(u *url.URL) (string, error) {
+	return "", nil
+}
+
+func (g *FtpGetter) Get(dst string, u *url.URL) error {
+	c, err := g.dial(u)
+	if err != nil {
+		return err
+	}
+	defer c.quit()
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	return g.getDir(c, dst, c.path(u.Path), u)
+}
+
+func (g *FtpGetter) GetFile(dst string, u *url.URL) error {
+	c, err := g.dial(u)
+	if err != nil {
+		return err
+	}
+	defer c.quit()
+
+	return g.getFile(c, dst, c.path(u.Path), u)
+}
+
+// getDir downloads the files below the remote directory dir to dst.
+func (g *FtpGetter) getDir(c *ftpConn, dst, dir string, u *url.URL) error {
+	entries, err := c.list(dir)
+	if err != nil {
+		return fmt.Errorf("error listing %s: %s", dir, err)
+	}
+
+	for _, e := range entries {
+		if e.Name == "." || e.Name == ".." {
+			continue
+		}
+		if strings.ContainsAny(e.Name, `/\`) {
+			return fmt.Errorf("invalid file name listed in %s: %q", dir, e.Name)
+		}
+
+		local := filepath.Join(dst, e.Name)
+		remote := path.Join(dir, e.Name)
+		if e.Dir {
+			if err := os.MkdirAll(local, 0755); err != nil {
+				return err
+			}
+			err = g.getDir(c, local, remote, u)
+		} else {
+			u2 := *u
+			u2.Path = remote
+			err = g.getFile(c, local, remote, &u2)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getFile downloads the remote file p, at u, to dst.
+func (g *FtpGetter) getFile(c *ftpConn, dst, p string, u *url.URL) error {
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if h := g.outputHash(dst); h != nil {
+		w = io.MultiWriter(f, h)
+	}
+
+	g.logger().Printf("Downloading remote %s to local %s", p, dst)
+	total := c.size(p)
+	conn, err := c.open("RETR %s", p)
+	if err != nil {
+		return fmt.Errorf("error downloading %s: %s", p, err)
+	}
+
+	_, err = io.Copy(w, g.progressReader(u, g.rateLimitReader(conn), total))
+	if cerr := c.closeData(conn); err == nil && cerr != nil {
+		err = fmt.Errorf("error downloading %s: %s", p, cerr)
+	}
+	return err
+}
+
+// dial connects to the server of u and logs in, in binary mode.
+func (g *FtpGetter) dial(u *url.URL) (*ftpConn, error) {
+	var secure bool
+	switch u.Scheme {
+	case "ftp":
+	case "ftps":
+		secure = true
+	default:
+		return nil, fmt.Errorf("unsupported scheme for FTP: %s", u.Scheme)
+	}
+
+	var active bool
+	switch mode := u.Query().Get("mode"); mode {
+	case "", "passive":
+	case "active":
+		active = true
+	default:
+		return nil, fmt.Errorf("invalid FTP mode, must be 'passive' or 'active': %s", mode)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "21")
+	}
+	dialer := &net.Dialer{Timeout: ftpTimeout}
+	nc, err := dialer.DialContext(g.ctx(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ftpConn{
+		Conn:   textproto.NewConn(nc),
+		conn:   nc,
+		active: active,
+		done:   make(chan struct{}),
+	}
+
+	// Abort the transfer in progress when the context is done
+	ctx := g.ctx()
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.abort()
+		case <-c.done:
+		}
+	}()
+
+	if err := g.login(c, u, secure); err != nil {
+		c.quit()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("error connecting to %s: %s", u.Host, err)
+	}
+
+	return c, nil
+}
+
+// login logs in to the server as the user of u, upgrading the connection to
+// TLS first if secure.
+func (g *FtpGetter) login(c *ftpConn, u *url.URL, secure bool) error {
+	if _, _, err := c.ReadResponse(220); err != nil {
+		return err
+	}
+
+	if secure {
+		if _, _, err := c.cmd(234, "AUTH TLS"); err != nil {
+			return err
+		}
+
+		var config *tls.Config
+		if g.TLSClientConfig != nil {
+			config = g.TLSClientConfig.Clone()
+		} else {
+			config = &tls.Config{}
+		}
+		if config.ServerName == "" {
+			config.ServerName = u.Hostname()
+		}
+		// Servers commonly require the data connections to resume the TLS
+		// session of the control connection
+		if config.ClientSessionCache == nil {
+			config.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+		}
+		c.Conn = textproto.NewConn(tls.Client(c.conn, config))
+		c.tls = config
+	}
+
+	user, password := "anonymous", "anonymous"
+	if u.User != nil {
+		user = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	code, msg, err := c.cmd(0, "USER %s", user)
+	if err == nil && code == 331 {
+		code, msg, err = c.cmd(0, "PASS %s", password)
+	}
+	if err != nil {
+		return err
+	}
+	if code/100 != 2 {
+		return fmt.Errorf("error logging in as %s: %d %s", user, code, msg)
+	}
+
+	if secure {
+		if _, _, err := c.cmd(200, "PBSZ 0"); err != nil {
+			return err
+		}
+		if _, _, err := c.cmd(200, "PROT P"); err != nil {
+			return err
+		}
+	}
+
+	if _, _, err := c.cmd(200, "TYPE I"); err != nil {
+		return err
+	}
+
+	// Paths are relative to the directory the user logs in to
+	_, msg, err = c.cmd(257, "PWD")
+	if err != nil {
+		return err
+	}
+	start, end := strings.Index(msg, `"`), strings.LastIndex(msg, `"`)
+	if start < 0 || end <= start {
+		return fmt.Errorf("invalid PWD response: %s", msg)
+	}
+	c.home = strings.Replace(msg[start+1:end], `""`, `"`, -1)
+
+	return nil
+}
+
+// ftpConn is the control connection to an FTP server.
+type ftpConn struct {
+	*textproto.Conn
+
+	// conn is the underlying network connection, without TLS.
+	conn net.Conn
+
+	// tls is the TLS configuration of the data connections, if protected.
+	tls *tls.Config
+
+	active bool
+	home   string
+	done   chan struct{}
+
+	lock sync.Mutex
+	data net.Conn
+}
+
+// cmd sends a command and reads the response, which must have the given
+// code, see textproto.Conn.ReadResponse.
+func (c *ftpConn) cmd(expect int, format string, args ...interface{}) (int, string, error) {
+	if _, err := c.Cmd(format, args...); err != nil {
+		return 0, "", err
+	}
+	return c.ReadResponse(expect)
+}
+
+// path returns the absolute path of p, the path of a URL, which is relative
+// to the home directory unless it starts with an encoded slash.
+func (c *ftpConn) path(p string) string {
+	p = strings.TrimPrefix(p, "/")
+	if strings.HasPrefix(p, "/") {
+		return path.Clean(p)
+	}
+	return path.Join(c.home, p)
+}
+
+// isDir returns whether p is a directory, by changing to it.
+func (c *ftpConn) isDir(p string) (bool, error) {
+	code, _, err := c.cmd(0, "CWD %s", p)
+	if err != nil {
+		return false, err
+	}
+	return code/100 == 2, nil
+}
+
+// size returns the size of the file p, or -1 if unknown.
+func (c *ftpConn) size(p string) int64 {
+	code, msg, err := c.cmd(0, "SIZE %s", p)
+	if err != nil || code != 213 {
+		return -1
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(msg), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+// list returns the files and directories of the directory dir, with MLSD,
+// or with NLST for servers not supporting it.
+func (c *ftpConn) list(dir string) ([]ftpEntry, error) {
+	entries, err := c.mlsd(dir)
+	if err, ok := err.(*textproto.Error); ok && (err.Code == 500 || err.Code == 502) {
+		return c.nlst(dir)
+	}
+	return entries, err
+}
+
+func (c *ftpConn) mlsd(dir string) ([]ftpEntry, error) {
+	lines, err := c.readLines("MLSD %s", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ftpEntry
+	for _, line := range lines {
+		// Lines are facts ending with a semicolon, a space and the name,
+		// Ex., "type=file;size=6; file.txt". The directory itself and its
+		// parent, typed cdir and pdir, and links are skipped.
+		idx := strings.Index(line, " ")
+		if idx < 0 {
+			continue
+		}
+		facts := ";" + strings.ToLower(line[:idx])
+		switch {
+		case strings.Contains(facts, ";type=file;"):
+			entries = append(entries, ftpEntry{Name: line[idx+1:]})
+		case strings.Contains(facts, ";type=dir;"):
+			entries = append(entries, ftpEntry{Name: line[idx+1:], Dir: true})
+		}
+	}
+	return entries, nil
+}
+
+func (c *ftpConn) nlst(dir string) ([]ftpEntry, error) {
+	lines, err := c.readLines("NLST %s", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// NLST only lists names, some servers as paths, whether they are
+	// directories is checked one by one
+	var entries []ftpEntry
+	for _, line := range lines {
+		name := path.Base(line)
+		if line == "" || name == "." || name == ".." {
+			continue
+		}
+		isDir, err := c.isDir(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, ftpEntry{Name: name, Dir: isDir})
+	}
+	return entries, nil
+}
+
+// readLines returns the lines transferred by the command.
+func (c *ftpConn) readLines(format string, args ...interface{}) ([]string, error) {
+	conn, err := c.open(format, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	err = scanner.Err()
+	if cerr := c.closeData(conn); err == nil {
+		err = cerr
+	}
+	return lines, err
+}
+
+// pasvRegexp matches the address of a PASV response, Ex., "127,0,0,1,4,1".
+var pasvRegexp = regexp.MustCompile(`(\d+),(\d+),(\d+),(\d+),(\d+),(\d+)`)
+
+// open opens a data connection and sends the command transferring over it,
+// Ex., "RETR file".
+func (c *ftpConn) open(format string, args ...interface{}) (net.Conn, error) {
+	var conn net.Conn
+	if c.active {
+		ln, err := c.port()
+		if err != nil {
+			return nil, err
+		}
+		defer ln.Close()
+
+		if _, _, err := c.cmd(1, format, args...); err != nil {
+			return nil, err
+		}
+		ln.SetDeadline(time.Now().Add(ftpTimeout))
+		if conn, err = ln.Accept(); err != nil {
+			return nil, err
+		}
+	} else {
+		addr, err := c.pasv()
+		if err != nil {
+			return nil, err
+		}
+		if conn, err = net.DialTimeout("tcp", addr, ftpTimeout); err != nil {
+			return nil, err
+		}
+
+		if _, _, err := c.cmd(1, format, args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if c.tls != nil {
+		conn = tls.Client(conn, c.tls)
+	}
+	c.lock.Lock()
+	c.data = conn
+	c.lock.Unlock()
+	return conn, nil
+}
+
+// closeData closes the data connection and reads the result of the
+// transfer.
+func (c *ftpConn) closeData(conn net.Conn) error {
+	c.lock.Lock()
+	c.data = nil
+	c.lock.Unlock()
+
+	conn.Close()
+	_, _, err := c.ReadResponse(2)
+	return err
+}
+
+// pasv returns the address to open a data connection to in passive mode.
+// The address given by the server is ignored, as servers behind a NAT often
+// give their private one, the host of the control connection is used instead.
+func (c *ftpConn) pasv() (string, error) {
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if err != nil {
+		return "", err
+	}
+
+	code, msg, err := c.cmd(0, "EPSV")
+	if err != nil {
+		return "", err
+	}
+	if code == 229 {
+		// Ex., "Entering Extended Passive Mode (|||1025|)"
+		start, end := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if start < 0 || end <= start+4 {
+			return "", fmt.Errorf("invalid EPSV response: %s", msg)
+		}
+		return net.JoinHostPort(host, msg[start+4:end]), nil
+	}
+
+	// Fall back to PASV for servers not supporting EPSV
+	_, msg, err = c.cmd(227, "PASV")
+	if err != nil {
+		return "", err
+	}
+	m := pasvRegexp.FindStringSubmatch(msg)
+	if m == nil {
+		return "", fmt.Errorf("invalid PASV response: %s", msg)
+	}
+	p1, _ := strconv.Atoi(m[5])
+	p2, _ := strconv.Atoi(m[6])
+	return net.JoinHostPort(host, strconv.Itoa(p1*256+p2)), nil
+}
+
+// port listens for the data connection of active mode, and tells the server
+// where to connect to.
+func (c *ftpConn) port() (*net.TCPListener, error) {
+	host, _, err := net.SplitHostPort(c.conn.LocalAddr().String())
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, err
+	}
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	if ip := net.ParseIP(host).To4(); ip != nil {
+		p, _ := strconv.Atoi(port)
+		_, _, err = c.cmd(200, "PORT %d,%d,%d,%d,%d,%d", ip[0], ip[1], ip[2], ip[3], p/256, p%256)
+	} else {
+		_, _, err = c.cmd(200, "EPRT |2|%s|%s|", host, port)
+	}
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln.(*net.TCPListener), nil
+}
+
+// abort closes the connections, failing the command or transfer in progress.
+func (c *ftpConn) abort() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.data != nil {
+		c.data.Close()
+	}
+	c.conn.Close()
+}
+
+// quit logs out and closes the connection.
+func (c *ftpConn) quit() {
+	close(c.done)
+	c.cmd(0, "QUIT")
+	c.Close()
+}
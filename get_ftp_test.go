@@ -0,0 +1,405 @@
+package getter
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"net/textproto"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFtpGetter_impl(t *testing.T) {
+	var _ Getter = new(FtpGetter)
+}
+
+func TestFtpGetter_file(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Server *testFtpServer
+		Query  string
+	}{
+		{"passive", &testFtpServer{}, ""},
+		{"pasv", &testFtpServer{NoEPSV: true}, ""},
+		{"active", &testFtpServer{}, "?mode=active"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.Server.start(t)
+			defer tc.Server.Close()
+
+			g := new(FtpGetter)
+			u := testURL(tc.Server.URL("file.txt") + tc.Query)
+
+			mode, err := g.ClientMode(u)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if mode != ClientModeFile {
+				t.Fatalf("bad mode: %d", mode)
+			}
+
+			dst := tempFile(t)
+			if err := g.GetFile(dst, u); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			assertContents(t, dst, "Hello\n")
+		})
+	}
+}
+
+func TestFtpGetter_fileMissing(t *testing.T) {
+	s := &testFtpServer{}
+	s.start(t)
+	defer s.Close()
+
+	g := new(FtpGetter)
+	if err := g.GetFile(tempFile(t), testURL(s.URL("missing.txt"))); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestFtpGetter_dir(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Server *testFtpServer
+	}{
+		{"mlsd", &testFtpServer{}},
+		{"nlst", &testFtpServer{NoMLSD: true}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.Server.start(t)
+			defer tc.Server.Close()
+
+			g := new(FtpGetter)
+			u := testURL(tc.Server.URL("module"))
+
+			mode, err := g.ClientMode(u)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if mode != ClientModeDir {
+				t.Fatalf("bad mode: %d", mode)
+			}
+
+			dst := tempDir(t)
+			if err := g.Get(dst, u); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			assertContents(t, filepath.Join(dst, "main.tf"), "# Hello\n")
+			assertContents(t, filepath.Join(dst, "sub", "foo.txt"), "foo\n")
+		})
+	}
+}
+
+func TestFtpGetter_login(t *testing.T) {
+	s := &testFtpServer{}
+	s.start(t)
+	defer s.Close()
+
+	// Paths are relative to the home directory of the user, unless they
+	// start with an encoded slash
+	g := new(FtpGetter)
+	dst := tempFile(t)
+	if err := g.GetFile(dst, testURL("ftp://user:secret@"+s.Addr()+"/file.txt")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello user\n")
+
+	if err := g.GetFile(dst, testURL("ftp://user:secret@"+s.Addr()+"/%2Ffile.txt")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+
+	if err := g.GetFile(dst, testURL("ftp://user:wrong@"+s.Addr()+"/file.txt")); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestFtpGetter_ftps(t *testing.T) {
+	// Borrow the certificate of an httptest TLS server
+	ts := httptest.NewUnstartedServer(nil)
+	ts.StartTLS()
+	cert := ts.TLS.Certificates[0]
+	ts.Close()
+
+	for _, query := range []string{"", "?mode=active"} {
+		t.Run(query, func(t *testing.T) {
+			s := &testFtpServer{TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}}}
+			s.start(t)
+			defer s.Close()
+
+			g := &FtpGetter{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+			dst := tempDir(t)
+			if err := g.Get(dst, testURL("ftps://"+s.Addr()+"/module"+query)); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			assertContents(t, filepath.Join(dst, "sub", "foo.txt"), "foo\n")
+
+			// Plain connections are refused by the server
+			if err := g.Get(tempDir(t), testURL(s.URL("module")+query)); err == nil {
+				t.Fatal("should error")
+			}
+		})
+	}
+}
+
+func TestFtpGetter_client(t *testing.T) {
+	s := &testFtpServer{}
+	s.start(t)
+	defer s.Close()
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:  s.URL("module"),
+		Dst:  dst,
+		Mode: ClientModeAny,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "# Hello\n")
+}
+
+// testFtpServer is a minimal FTP server. Anonymous users log in to /, the
+// user "user" with the password "secret" to /home/user. With a TLSConfig,
+// it requires AUTH TLS and protected data connections.
+type testFtpServer struct {
+	TLSConfig *tls.Config
+	NoEPSV    bool
+	NoMLSD    bool
+
+	ln net.Listener
+}
+
+// testFtpFiles are the files served by testFtpServer.
+var testFtpFiles = map[string]string{
+	"/file.txt":           "Hello\n",
+	"/module/main.tf":     "# Hello\n",
+	"/module/sub/foo.txt": "foo\n",
+	"/home/user/file.txt": "Hello user\n",
+}
+
+func (s *testFtpServer) start(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	s.ln = ln
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+}
+
+func (s *testFtpServer) Close() error {
+	return s.ln.Close()
+}
+
+func (s *testFtpServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *testFtpServer) URL(p string) string {
+	return fmt.Sprintf("ftp://%s/%s", s.Addr(), p)
+}
+
+// children returns the names of the files and directories in dir, with a
+// trailing slash for directories, or false if dir isn't a directory.
+func (s *testFtpServer) children(dir string) ([]string, bool) {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	seen := map[string]bool{}
+	var names []string
+	for p := range testFtpFiles {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(p, prefix)
+		if idx := strings.Index(name, "/"); idx >= 0 {
+			name = name[:idx+1]
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, len(names) > 0
+}
+
+func (s *testFtpServer) serve(nc net.Conn) {
+	defer nc.Close()
+	c := textproto.NewConn(nc)
+	c.PrintfLine("220 Ready")
+
+	var user, cwd, port string
+	var secure, prot bool
+	var pasv net.Listener
+	data := func() (net.Conn, error) {
+		var conn net.Conn
+		var err error
+		if pasv != nil {
+			conn, err = pasv.Accept()
+			pasv.Close()
+			pasv = nil
+		} else {
+			conn, err = net.Dial("tcp", port)
+		}
+		if err == nil && prot {
+			conn = tls.Server(conn, s.TLSConfig)
+		}
+		return conn, err
+	}
+
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg := line, ""
+		if idx := strings.Index(line, " "); idx >= 0 {
+			cmd, arg = line[:idx], line[idx+1:]
+		}
+		p := path.Join(cwd, arg)
+		if path.IsAbs(arg) {
+			p = path.Clean(arg)
+		}
+
+		switch cmd {
+		case "AUTH":
+			if s.TLSConfig == nil {
+				c.PrintfLine("502 Not implemented")
+				continue
+			}
+			c.PrintfLine("234 Proceed")
+			c = textproto.NewConn(tls.Server(nc, s.TLSConfig))
+			secure = true
+		case "PBSZ":
+			c.PrintfLine("200 OK")
+		case "PROT":
+			prot = arg == "P"
+			c.PrintfLine("200 OK")
+		case "USER":
+			if s.TLSConfig != nil && !secure {
+				c.PrintfLine("530 TLS required")
+				continue
+			}
+			user = arg
+			c.PrintfLine("331 Password required")
+		case "PASS":
+			switch {
+			case user == "anonymous":
+				cwd = "/"
+			case user == "user" && arg == "secret":
+				cwd = "/home/user"
+			default:
+				c.PrintfLine("530 Login incorrect")
+				continue
+			}
+			c.PrintfLine("230 Logged in")
+		case "TYPE":
+			c.PrintfLine("200 OK")
+		case "PWD":
+			c.PrintfLine(`257 "%s" is the current directory`, cwd)
+		case "CWD":
+			if _, ok := s.children(p); ok {
+				cwd = p
+				c.PrintfLine("250 OK")
+			} else {
+				c.PrintfLine("550 Not a directory")
+			}
+		case "SIZE":
+			if content, ok := testFtpFiles[p]; ok {
+				c.PrintfLine("213 %d", len(content))
+			} else {
+				c.PrintfLine("550 Not found")
+			}
+		case "EPSV", "PASV":
+			if cmd == "EPSV" && s.NoEPSV {
+				c.PrintfLine("502 Not implemented")
+				continue
+			}
+			pasv, _ = net.Listen("tcp", "127.0.0.1:0")
+			_, portRaw, _ := net.SplitHostPort(pasv.Addr().String())
+			n, _ := strconv.Atoi(portRaw)
+			if cmd == "EPSV" {
+				c.PrintfLine("229 Entering Extended Passive Mode (|||%d|)", n)
+			} else {
+				// The address is ignored by the client
+				c.PrintfLine("227 Entering Passive Mode (10,0,0,1,%d,%d)", n/256, n%256)
+			}
+		case "PORT":
+			parts := strings.Split(arg, ",")
+			p1, _ := strconv.Atoi(parts[4])
+			p2, _ := strconv.Atoi(parts[5])
+			port = net.JoinHostPort(strings.Join(parts[:4], "."), strconv.Itoa(p1*256+p2))
+			c.PrintfLine("200 OK")
+		case "RETR":
+			content, ok := testFtpFiles[p]
+			if !ok {
+				c.PrintfLine("550 Not found")
+				continue
+			}
+			c.PrintfLine("150 Opening data connection")
+			conn, err := data()
+			if err != nil {
+				c.PrintfLine("425 Can't open data connection")
+				continue
+			}
+			fmt.Fprint(conn, content)
+			conn.Close()
+			c.PrintfLine("226 Done")
+		case "MLSD", "NLST":
+			if cmd == "MLSD" && s.NoMLSD {
+				c.PrintfLine("500 Unknown command")
+				continue
+			}
+			names, ok := s.children(p)
+			if !ok {
+				c.PrintfLine("550 Not a directory")
+				continue
+			}
+			c.PrintfLine("150 Opening data connection")
+			conn, err := data()
+			if err != nil {
+				c.PrintfLine("425 Can't open data connection")
+				continue
+			}
+			if cmd == "MLSD" {
+				fmt.Fprintf(conn, "type=cdir; %s\r\n", p)
+			}
+			for _, name := range names {
+				switch {
+				case cmd == "NLST":
+					// Listed as paths, like some servers do
+					fmt.Fprintf(conn, "%s\r\n", path.Join(p, name))
+				case strings.HasSuffix(name, "/"):
+					fmt.Fprintf(conn, "type=dir;perm=el; %s\r\n", strings.TrimSuffix(name, "/"))
+				default:
+					fmt.Fprintf(conn, "type=file;size=%d; %s\r\n", len(testFtpFiles[path.Join(p, name)]), name)
+				}
+			}
+			conn.Close()
+			c.PrintfLine("226 Done")
+		case "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("502 Not implemented")
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package getter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// GCSGetter is a Getter implementation that will download a module from
+// a GCS bucket.
+//
+// Application default credentials are used if they are available, otherwise
+// the bucket is accessed anonymously.
+// uri format: gcs::https://www.googleapis.com/storage/v1/bucket/path
+type GCSGetter struct {
+	getter
+}
+
+func (g *GCSGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	ctx := g.ctx()
+
+	// Parse URL
+	bucket, object, err := g.parseURL(u)
+	if err != nil {
+		return 0, err
+	}
+
+	client, err := g.newClient(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	// List the object(s) at the given prefix
+	iter := client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: object})
+	for {
+		obj, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		// Use file mode on exact match.
+		if obj.Name == object {
+			return ClientModeFile, nil
+		}
+
+		// Use dir mode if child keys are found.
+		if strings.HasPrefix(obj.Name, object+"/") {
+			return ClientModeDir, nil
+		}
+	}
+
+	// There was no match, so just return file mode. The download is going
+	// to fail but we will let GCS return the proper error later.
+	return ClientModeFile, nil
+}
+
+func (g *GCSGetter) GetFilename(u *url.URL) (string, error) {
+	return "", nil
+}
+
+func (g *GCSGetter) Get(dst string, u *url.URL) error {
+	ctx := g.ctx()
+
+	// Parse URL
+	bucket, object, err := g.parseURL(u)
+	if err != nil {
+		return err
+	}
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	client, err := g.newClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Iterate through all matching objects.
+	iter := client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: object})
+	for {
+		obj, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// If the name ends with a slash assume it is a directory and ignore
+		if strings.HasSuffix(obj.Name, "/") {
+			continue
+		}
+
+		// Get the object destination path
+		objDst, err := filepath.Rel(object, obj.Name)
+		if err != nil {
+			return err
+		}
+		objDst = filepath.Join(dst, objDst)
+
+		if err := g.getObject(ctx, client, objDst, bucket, obj.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *GCSGetter) GetFile(dst string, u *url.URL) error {
+	ctx := g.ctx()
+
+	// Parse URL
+	bucket, object, err := g.parseURL(u)
+	if err != nil {
+		return err
+	}
+
+	client, err := g.newClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return g.getObject(ctx, client, dst, bucket, object)
+}
+
+func (g *GCSGetter) getObject(ctx context.Context, client *storage.Client, dst, bucket, object string) error {
+	rc, err := client.Bucket(bucket).Object(object).NewReader(ctx)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, g.rateLimitReader(rc))
+	return err
+}
+
+// newClient creates a GCS client using the application default credentials,
+// falling back to an unauthenticated client for public buckets if no
+// credentials can be found.
+func (g *GCSGetter) newClient(ctx context.Context) (*storage.Client, error) {
+	client, err := storage.NewClient(ctx)
+	if err == nil {
+		return client, nil
+	}
+
+	return storage.NewClient(ctx, option.WithoutAuthentication())
+}
+
+func (g *GCSGetter) parseURL(u *url.URL) (bucket, path string, err error) {
+	// Expected format: https://www.googleapis.com/storage/v1/bucket/path
+	if !strings.Contains(u.Host, "googleapis.com") {
+		err = fmt.Errorf("URL is not a valid GCS URL")
+		return
+	}
+
+	hostParts := strings.Split(u.Host, ".")
+	if len(hostParts) != 3 {
+		err = fmt.Errorf("URL is not a valid GCS URL")
+		return
+	}
+
+	pathParts := strings.SplitN(u.Path, "/", 5)
+	if len(pathParts) != 5 || pathParts[1] != "storage" {
+		err = fmt.Errorf("URL is not a valid GCS URL")
+		return
+	}
+	bucket = pathParts[3]
+	path = pathParts[4]
+
+	return
+}
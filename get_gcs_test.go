@@ -0,0 +1,66 @@
+package getter
+
+import (
+	"testing"
+)
+
+func TestGCSGetter_impl(t *testing.T) {
+	var _ Getter = new(GCSGetter)
+}
+
+func TestGCSGetter_Url(t *testing.T) {
+	var gcstests = []struct {
+		name   string
+		url    string
+		bucket string
+		path   string
+		err    bool
+	}{
+		{
+			name:   "test1",
+			url:    "gcs::https://www.googleapis.com/storage/v1/hc-go-getter-test/go-getter/foo/null.zip",
+			bucket: "hc-go-getter-test",
+			path:   "go-getter/foo/null.zip",
+		},
+		{
+			name:   "test2",
+			url:    "gcs::https://www.googleapis.com/storage/v1/hc-go-getter-test/go-getter/foo",
+			bucket: "hc-go-getter-test",
+			path:   "go-getter/foo",
+		},
+		{
+			name: "missing path",
+			url:  "gcs::https://www.googleapis.com/storage/v1/hc-go-getter-test",
+			err:  true,
+		},
+		{
+			name: "not gcs",
+			url:  "gcs::https://example.com/storage/v1/bucket/foo",
+			err:  true,
+		},
+	}
+
+	for i, pt := range gcstests {
+		t.Run(pt.name, func(t *testing.T) {
+			g := new(GCSGetter)
+			forced, src := getForcedGetter(pt.url)
+			if forced != "gcs" {
+				t.Fatalf("expected forced protocol to be gcs")
+			}
+
+			bucket, path, err := g.parseURL(testURL(src))
+			if (err != nil) != pt.err {
+				t.Fatalf("test %d: unexpected error: %v", i, err)
+			}
+			if pt.err {
+				return
+			}
+			if bucket != pt.bucket {
+				t.Fatalf("expected %s, got %s", pt.bucket, bucket)
+			}
+			if path != pt.path {
+				t.Fatalf("expected %s, got %s", pt.path, path)
+			}
+		})
+	}
+}
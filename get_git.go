@@ -8,6 +8,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	urlhelper "github.com/hashicorp/go-getter/helper/url"
@@ -16,7 +18,9 @@ import (
 
 // GitGetter is a Getter implementation that will download a module from
 // a git repository.
-type GitGetter struct{}
+type GitGetter struct {
+	getter
+}
 
 func (g *GitGetter) ClientMode(_ *url.URL) (ClientMode, error) {
 	return ClientModeDir, nil
@@ -27,12 +31,45 @@ func (g *GitGetter) GetFilename(u *url.URL) (string, error) {
 }
 
 func (g *GitGetter) Get(dst string, u *url.URL) error {
+	return g.get(dst, u, "")
+}
+
+// GetSubdir downloads the repository into dst like Get, but only checks out
+// subDir using git sparse-checkout so that the rest of the repository isn't
+// materialized. If sparse-checkout isn't available in the installed git
+// version, or subDir contains glob patterns, the full repository is cloned.
+func (g *GitGetter) GetSubdir(dst string, u *url.URL, subDir string) error {
+	// Sparse checkouts only apply to a fresh clone, updates of an existing
+	// repository keep whatever is checked out already.
+	if _, err := os.Stat(dst); err == nil {
+		g.logger().Printf("%s exists, updating the full repository for subdir %q", dst, subDir)
+		return g.get(dst, u, "")
+	}
+
+	if strings.ContainsAny(subDir, "*?[") {
+		g.logger().Printf("subdir %q is a glob, cloning the full repository", subDir)
+		return g.get(dst, u, "")
+	}
+
+	if err := checkGitVersion("2.25"); err != nil {
+		g.logger().Printf("git sparse-checkout unavailable (%s), cloning the full repository for subdir %q", err, subDir)
+		return g.get(dst, u, "")
+	}
+
+	g.logger().Printf("using git sparse-checkout for subdir %q", subDir)
+	return g.get(dst, u, subDir)
+}
+
+// get downloads the repository into dst. If sparseDir is set, a fresh clone
+// only checks out that directory.
+func (g *GitGetter) get(dst string, u *url.URL, sparseDir string) error {
 	if _, err := exec.LookPath("git"); err != nil {
-		return fmt.Errorf("git must be available and on the PATH")
+		return fmt.Errorf("git must be available and on the PATH to download git sources: %s", err)
 	}
 
 	// Extract some query parameters we use
 	var ref, sshKey string
+	var depth int
 	q := u.Query()
 	if len(q) > 0 {
 		ref = q.Get("ref")
@@ -41,55 +78,38 @@ func (g *GitGetter) Get(dst string, u *url.URL) error {
 		sshKey = q.Get("sshkey")
 		q.Del("sshkey")
 
+		if v := q.Get("depth"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid depth %q: must be a non-negative integer", v)
+			}
+			depth = n
+		}
+		q.Del("depth")
+
 		// Copy the URL
 		var newU url.URL = *u
 		u = &newU
 		u.RawQuery = q.Encode()
 	}
 
-	var sshKeyFile string
-	if sshKey != "" {
-		// Check that the git version is sufficiently new.
-		if err := checkGitVersion("2.3"); err != nil {
-			return fmt.Errorf("Error using ssh key: %v", err)
-		}
-
-		// We have an SSH key - decode it.
-		raw, err := base64.StdEncoding.DecodeString(sshKey)
-		if err != nil {
-			return err
-		}
-
-		// Create a temp file for the key and ensure it is removed.
-		fh, err := ioutil.TempFile("", "go-getter")
-		if err != nil {
-			return err
-		}
-		sshKeyFile = fh.Name()
+	sshKeyFile, err := g.writeSSHKey(sshKey)
+	if err != nil {
+		return err
+	}
+	if sshKeyFile != "" {
 		defer os.Remove(sshKeyFile)
-
-		// Set the permissions prior to writing the key material.
-		if err := os.Chmod(sshKeyFile, 0600); err != nil {
-			return err
-		}
-
-		// Write the raw key into the temp file.
-		_, err = fh.Write(raw)
-		fh.Close()
-		if err != nil {
-			return err
-		}
 	}
 
 	// Clone or update the repository
-	_, err := os.Stat(dst)
+	_, err = os.Stat(dst)
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 	if err == nil {
-		err = g.update(dst, sshKeyFile, ref)
+		err = g.update(dst, sshKeyFile, ref, depth)
 	} else {
-		err = g.clone(dst, sshKeyFile, u)
+		err = g.clone(dst, sshKeyFile, u, ref, depth, sparseDir)
 	}
 	if err != nil {
 		return err
@@ -97,7 +117,7 @@ func (g *GitGetter) Get(dst string, u *url.URL) error {
 
 	// Next: check out the proper tag/branch if it is specified, and checkout
 	if ref != "" {
-		if err := g.checkout(dst, ref); err != nil {
+		if err := g.checkout(dst, sshKeyFile, ref); err != nil {
 			return err
 		}
 	}
@@ -106,10 +126,97 @@ func (g *GitGetter) Get(dst string, u *url.URL) error {
 	return g.fetchSubmodules(dst, sshKeyFile)
 }
 
+// Validate checks the repository is reachable, and has the ref if one is
+// given, with git ls-remote so that nothing is cloned. Refs that look like
+// commit hashes can't be listed, so only the repository is checked for them.
+func (g *GitGetter) Validate(u *url.URL) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("git must be available and on the PATH to download git sources: %s", err)
+	}
+
+	q := u.Query()
+	ref := q.Get("ref")
+	sshKey := q.Get("sshkey")
+	q.Del("ref")
+	q.Del("sshkey")
+	q.Del("depth")
+
+	// Copy the URL
+	var newU url.URL = *u
+	u = &newU
+	u.RawQuery = q.Encode()
+
+	sshKeyFile, err := g.writeSSHKey(sshKey)
+	if err != nil {
+		return err
+	}
+	if sshKeyFile != "" {
+		defer os.Remove(sshKeyFile)
+	}
+
+	args := []string{"ls-remote", "--exit-code", u.String()}
+	if ref != "" && !gitCommitRegexp.MatchString(ref) {
+		args = append(args, ref)
+	}
+	cmd := exec.CommandContext(g.ctx(), "git", args...)
+	setupGitEnv(cmd, sshKeyFile)
+	if err := getRunCommand(cmd); err != nil {
+		if ref != "" {
+			return fmt.Errorf("error listing ref %q: %s", ref, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// gitCommitRegexp matches refs that are (abbreviated) commit hashes.
+var gitCommitRegexp = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// writeSSHKey writes the base64 encoded SSH key to a temporary file only
+// readable by the user, and returns its path. The caller must remove it. An
+// empty path is returned if sshKey is empty.
+func (g *GitGetter) writeSSHKey(sshKey string) (string, error) {
+	if sshKey == "" {
+		return "", nil
+	}
+
+	// Check that the git version is sufficiently new.
+	if err := checkGitVersion("2.3"); err != nil {
+		return "", fmt.Errorf("Error using ssh key: %v", err)
+	}
+
+	// We have an SSH key - decode it.
+	raw, err := base64.StdEncoding.DecodeString(sshKey)
+	if err != nil {
+		return "", err
+	}
+
+	// Create a temp file for the key.
+	fh, err := ioutil.TempFile(g.tempDir(), "go-getter")
+	if err != nil {
+		return "", err
+	}
+	defer fh.Close()
+
+	// Set the permissions prior to writing the key material.
+	if err := os.Chmod(fh.Name(), 0600); err != nil {
+		os.Remove(fh.Name())
+		return "", err
+	}
+
+	// Write the raw key into the temp file.
+	if _, err := fh.Write(raw); err != nil {
+		os.Remove(fh.Name())
+		return "", err
+	}
+
+	return fh.Name(), nil
+}
+
 // GetFile for Git doesn't support updating at this time. It will download
 // the file every time.
 func (g *GitGetter) GetFile(dst string, u *url.URL) error {
-	td, err := ioutil.TempDir("", "getter-git")
+	td, err := ioutil.TempDir(g.tempDir(), "getter-git")
 	if err != nil {
 		return err
 	}
@@ -127,6 +234,14 @@ func (g *GitGetter) GetFile(dst string, u *url.URL) error {
 		return err
 	}
 
+	// A repository is inherently a directory, so the URL must reference
+	// a single file within it.
+	if fi, err := os.Stat(filepath.Join(td, filename)); err == nil && fi.IsDir() {
+		return fmt.Errorf(
+			"%q is a directory, git sources can only be downloaded as a single file "+
+				"if the path references a file in the repository", filename)
+	}
+
 	// Copy the single file
 	u, err = urlhelper.Parse(fmtFileURL(filepath.Join(td, filename)))
 	if err != nil {
@@ -137,22 +252,67 @@ func (g *GitGetter) GetFile(dst string, u *url.URL) error {
 	return fg.GetFile(dst, u)
 }
 
-func (g *GitGetter) checkout(dst string, ref string) error {
-	cmd := exec.Command("git", "checkout", ref)
+// checkout checks out ref in dst. The SSH key is needed for partial clones,
+// whose missing blobs are fetched from the remote as they're checked out.
+func (g *GitGetter) checkout(dst, sshKeyFile, ref string) error {
+	cmd := exec.CommandContext(g.ctx(), "git", "checkout", ref)
 	cmd.Dir = dst
+	setupGitEnv(cmd, sshKeyFile)
 	return getRunCommand(cmd)
 }
 
-func (g *GitGetter) clone(dst, sshKeyFile string, u *url.URL) error {
-	cmd := exec.Command("git", "clone", u.String(), dst)
+// clone clones the repository into dst. If depth is greater than zero a
+// shallow clone with that history depth is made, in which case ref must be a
+// branch or tag name since git can't shallow clone an arbitrary commit.
+//
+// If sparseDir is set, only that directory is checked out and blobs outside
+// of it are not fetched if the remote supports partial clones.
+func (g *GitGetter) clone(dst, sshKeyFile string, u *url.URL, ref string, depth int, sparseDir string) error {
+	args := []string{"clone"}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+		if ref != "" {
+			args = append(args, "--branch", ref)
+		}
+	}
+	if sparseDir != "" {
+		args = append(args, "--filter=blob:none", "--no-checkout")
+	}
+	args = append(args, u.String(), dst)
+
+	cmd := exec.CommandContext(g.ctx(), "git", args...)
+	setupGitEnv(cmd, sshKeyFile)
+	if err := getRunCommand(cmd); err != nil {
+		return err
+	}
+
+	if sparseDir == "" {
+		return nil
+	}
+
+	cmd = exec.CommandContext(g.ctx(), "git", "sparse-checkout", "init", "--cone")
+	cmd.Dir = dst
+	if err := getRunCommand(cmd); err != nil {
+		return err
+	}
+
+	cmd = exec.CommandContext(g.ctx(), "git", "sparse-checkout", "set", filepath.ToSlash(sparseDir))
+	cmd.Dir = dst
+	if err := getRunCommand(cmd); err != nil {
+		return err
+	}
+
+	// Check out the default branch, any ref is checked out afterwards
+	cmd = exec.CommandContext(g.ctx(), "git", "checkout")
+	cmd.Dir = dst
 	setupGitEnv(cmd, sshKeyFile)
 	return getRunCommand(cmd)
 }
 
-func (g *GitGetter) update(dst, sshKeyFile, ref string) error {
+func (g *GitGetter) update(dst, sshKeyFile, ref string, depth int) error {
 	// Determine if we're a branch. If we're NOT a branch, then we just
 	// switch to master prior to checking out
-	cmd := exec.Command("git", "show-ref", "-q", "--verify", "refs/heads/"+ref)
+	cmd := exec.CommandContext(g.ctx(), "git", "show-ref", "-q", "--verify", "refs/heads/"+ref)
 	cmd.Dir = dst
 
 	if getRunCommand(cmd) != nil {
@@ -163,11 +323,15 @@ func (g *GitGetter) update(dst, sshKeyFile, ref string) error {
 	}
 
 	// We have to be on a branch to pull
-	if err := g.checkout(dst, ref); err != nil {
+	if err := g.checkout(dst, sshKeyFile, ref); err != nil {
 		return err
 	}
 
-	cmd = exec.Command("git", "pull", "--ff-only")
+	if depth > 0 {
+		cmd = exec.CommandContext(g.ctx(), "git", "pull", "--depth", strconv.Itoa(depth), "--ff-only")
+	} else {
+		cmd = exec.CommandContext(g.ctx(), "git", "pull", "--ff-only")
+	}
 	cmd.Dir = dst
 	setupGitEnv(cmd, sshKeyFile)
 	return getRunCommand(cmd)
@@ -175,7 +339,7 @@ func (g *GitGetter) update(dst, sshKeyFile, ref string) error {
 
 // fetchSubmodules downloads any configured submodules recursively.
 func (g *GitGetter) fetchSubmodules(dst, sshKeyFile string) error {
-	cmd := exec.Command("git", "submodule", "update", "--init", "--recursive")
+	cmd := exec.CommandContext(g.ctx(), "git", "submodule", "update", "--init", "--recursive")
 	cmd.Dir = dst
 	setupGitEnv(cmd, sshKeyFile)
 	return getRunCommand(cmd)
@@ -1,7 +1,10 @@
 package getter
 
 import (
+	"bytes"
 	"encoding/base64"
+	"fmt"
+	"log"
 	"io/ioutil"
 	"net/url"
 	"os"
@@ -169,6 +172,281 @@ func TestGitGetter_tag(t *testing.T) {
 	}
 }
 
+func TestGitGetter_validate(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	g := new(GitGetter)
+
+	repo := testGitRepo(t, "validate")
+	repo.commitFile("tag.txt", "tag")
+	repo.git("tag", "v1.0")
+
+	cases := []struct {
+		Ref string
+		Err bool
+	}{
+		{"", false},
+		{"v1.0", false},
+		{"v2.0", true},
+	}
+
+	for _, tc := range cases {
+		u := *repo.url
+		if tc.Ref != "" {
+			u.RawQuery = "ref=" + tc.Ref
+		}
+
+		err := g.Validate(&u)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%q: err: %v", tc.Ref, err)
+		}
+	}
+
+	// Repositories that don't exist fail too
+	u := *repo.url
+	u.Path += "-missing"
+	if err := g.Validate(&u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestGitGetter_shallowClone(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	g := new(GitGetter)
+	dst := tempDir(t)
+
+	repo := testGitRepo(t, "upstream")
+	for i := 0; i < 3; i++ {
+		repo.commitFile("commit.txt", fmt.Sprintf("commit %d", i))
+	}
+
+	// Set the depth so we only clone the latest commit
+	q := repo.url.Query()
+	q.Add("depth", "1")
+	repo.url.RawQuery = q.Encode()
+
+	if err := g.Get(dst, repo.url); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Assert rev-list count is '1'
+	cmd := exec.Command("git", "rev-list", "HEAD", "--count")
+	cmd.Dir = dst
+	b, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	out := strings.TrimSpace(string(b))
+	if out != "1" {
+		t.Fatalf("expected rev-list count to be '1' but got %v", out)
+	}
+}
+
+func TestGitGetter_badDepth(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	g := new(GitGetter)
+	dst := tempDir(t)
+
+	repo := testGitRepo(t, "bad-depth")
+	repo.commitFile("foo.txt", "hello")
+
+	q := repo.url.Query()
+	q.Add("depth", "foo")
+	repo.url.RawQuery = q.Encode()
+
+	if err := g.Get(dst, repo.url); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestGitGetter_GetSubdir(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+	if err := checkGitVersion("2.25"); err != nil {
+		t.Skipf("skipping, git sparse-checkout unavailable: %s", err)
+	}
+
+	g := new(GitGetter)
+	dst := tempDir(t)
+
+	repo := testGitRepo(t, "sparse")
+	for _, dir := range []string{"wanted", "unwanted"} {
+		if err := os.Mkdir(filepath.Join(repo.dir, dir), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		repo.commitFile(dir+"/main.tf", dir)
+	}
+
+	if err := g.GetSubdir(dst, repo.url, "wanted"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Only the subdir should be checked out
+	if _, err := os.Stat(filepath.Join(dst, "wanted", "main.tf")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "unwanted")); !os.IsNotExist(err) {
+		t.Fatalf("expected unwanted to not be checked out, got: %v", err)
+	}
+}
+
+func TestGitGetter_GetSubdir_logger(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	dst := tempDir(t)
+
+	repo := testGitRepo(t, "sparse-logger")
+	if err := os.Mkdir(filepath.Join(repo.dir, "wanted"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	repo.commitFile("wanted/main.tf", "wanted")
+
+	// The strategy used should be logged through the client's logger
+	var buf bytes.Buffer
+	client := &Client{
+		Src:    "git::" + repo.url.String() + "//wanted",
+		Dst:    dst,
+		Dir:    true,
+		Logger: log.New(&buf, "", 0),
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "main.tf")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(buf.String(), "subdir \"wanted\"") {
+		t.Fatalf("expected the subdir strategy to be logged, got: %q", buf.String())
+	}
+}
+
+func TestGitGetter_GetSubdir_sshKeyRef(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+	if runtime.GOOS == "windows" {
+		t.Skipf("skipping on windows since the test requires sh")
+	}
+	if err := checkGitVersion("2.25"); err != nil {
+		t.Skipf("skipping, git sparse-checkout unavailable: %s", err)
+	}
+
+	repo := testGitRepo(t, "sparse-ssh")
+	repo.git("config", "uploadpack.allowFilter", "true")
+	if err := os.Mkdir(filepath.Join(repo.dir, "wanted"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	repo.commitFile("wanted/main.tf", "default")
+	repo.git("checkout", "-b", "other")
+	repo.commitFile("wanted/main.tf", "other")
+	repo.git("checkout", "-")
+
+	// ssh runs the git command locally, failing without the key, which is
+	// also needed to fetch the blobs of the ref as it is checked out
+	fakeSSH := filepath.Join(tempDir(t), "ssh")
+	if err := os.MkdirAll(filepath.Dir(fakeSSH), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	script := "#!/bin/sh\nkey=\nwhile [ $# -gt 1 ]; do\n\t[ \"$1\" = -i ] && key=$2\n\tshift\ndone\n" +
+		"[ -n \"$key\" ] || { echo 'no ssh key' >&2; exit 1; }\nexec sh -c \"$1\"\n"
+	if err := ioutil.WriteFile(fakeSSH, []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if old, ok := os.LookupEnv("GIT_SSH_COMMAND"); ok {
+		defer os.Setenv("GIT_SSH_COMMAND", old)
+	} else {
+		defer os.Unsetenv("GIT_SSH_COMMAND")
+	}
+	os.Setenv("GIT_SSH_COMMAND", fakeSSH)
+
+	u, err := url.Parse("ssh://git@localhost" + repo.dir + "?ref=other&sshkey=" +
+		base64.StdEncoding.EncodeToString([]byte(testGitToken)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := tempDir(t)
+	if err := new(GitGetter).GetSubdir(dst, u, "wanted"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "wanted", "main.tf"), "other")
+}
+
+func TestGitGetter_GetSubdir_glob(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	g := new(GitGetter)
+	dst := tempDir(t)
+
+	repo := testGitRepo(t, "sparse-glob")
+	for _, dir := range []string{"wanted", "unwanted"} {
+		if err := os.Mkdir(filepath.Join(repo.dir, dir), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		repo.commitFile(dir+"/main.tf", dir)
+	}
+
+	// Globs fall back to a full clone
+	if err := g.GetSubdir(dst, repo.url, "want*"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, dir := range []string{"wanted", "unwanted"} {
+		if _, err := os.Stat(filepath.Join(dst, dir, "main.tf")); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func TestGitGetter_GetFile_dir(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	g := new(GitGetter)
+	dst := tempFile(t)
+
+	repo := testGitRepo(t, "file-dir")
+	if err := os.Mkdir(filepath.Join(repo.dir, "dir"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	repo.commitFile("dir/foo.txt", "hello")
+
+	// Point the URL at a directory within the repository
+	repo.url.Path = filepath.Join(repo.url.Path, "dir")
+
+	err := g.GetFile(dst, repo.url)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "is a directory") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
 func TestGitGetter_GetFile(t *testing.T) {
 	if !testHasGit {
 		t.Log("git not found, skipping")
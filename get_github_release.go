@@ -0,0 +1,255 @@
+package getter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// GitHubReleaseGetter is a Getter implementation that will download the
+// assets of a GitHub release.
+//
+// URLs take the form ghrelease://owner/repo. The release is resolved with
+// the GitHub API, so private repositories can be downloaded from with a
+// token, from the token query parameter, Token or GITHUB_TOKEN.
+//
+// Query parameters:
+//   - tag: the tag of the release, default as 'latest' for the latest release
+//   - asset: a glob of the asset names to download, Ex., '*_linux_amd64.zip',
+//     which can be omitted if the release has a single asset
+//   - token: the token to authenticate to GitHub with
+//
+// A single matching asset is downloaded as a file, several ones into a
+// directory.
+type GitHubReleaseGetter struct {
+	httpBackedGetter
+
+	// BaseURL is the URL of the GitHub API, Ex.,
+	// https://github.example.com/api/v3 for GitHub Enterprise. This defaults
+	// to https://api.github.com if left unset.
+	BaseURL string
+
+	// Token is the token to authenticate to GitHub with, if the URL has
+	// none. This defaults to the GITHUB_TOKEN environment variable.
+	Token string
+}
+
+// gitHubRelease is a release returned by the GitHub API.
+type gitHubRelease struct {
+	TagName string        `json:"tag_name"`
+	Assets  []gitHubAsset `json:"assets"`
+}
+
+// gitHubAsset is an asset of a release, downloaded from its API URL.
+type gitHubAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+func (g *GitHubReleaseGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	assets, err := g.assets(u)
+	if err != nil {
+		return 0, err
+	}
+	if len(assets) > 1 {
+		return ClientModeDir, nil
+	}
+	return ClientModeFile, nil
+}
+
+// GetFilename returns the name of the asset, if a single one matches.
+func (g *GitHubReleaseGetter) GetFilename(u *url.URL) (string, error) {
+	assets, err := g.assets(u)
+	if err != nil {
+		return "", err
+	}
+	if len(assets) > 1 {
+		return "", nil
+	}
+	return assets[0].Name, nil
+}
+
+func (g *GitHubReleaseGetter) Get(dst string, u *url.URL) error {
+	assets, err := g.assets(u)
+	if err != nil {
+		return err
+	}
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	for _, a := range assets {
+		if err := g.getAsset(filepath.Join(dst, a.Name), u, a.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GitHubReleaseGetter) GetFile(dst string, u *url.URL) error {
+	assets, err := g.assets(u)
+	if err != nil {
+		return err
+	}
+	if len(assets) > 1 {
+		return fmt.Errorf("several assets of the release match, expected a single file: %s", assetNames(assets))
+	}
+	return g.getAsset(dst, u, assets[0].URL)
+}
+
+// getAsset downloads the asset at the API URL assetURL to dst.
+func (g *GitHubReleaseGetter) getAsset(dst string, u *url.URL, assetURL string) error {
+	au, err := url.Parse(assetURL)
+	if err != nil {
+		return err
+	}
+
+	// The asset itself, rather than its JSON, is returned for the binary
+	// media type, redirecting to the storage it's downloaded from
+	hg := g.http()
+	httpGet := *hg
+	httpGet.Header = http.Header{}
+	for k, v := range hg.Header {
+		httpGet.Header[k] = v
+	}
+	httpGet.Header.Set("Accept", "application/octet-stream")
+	if token := g.token(u); token != "" {
+		httpGet.Header.Set("Authorization", "token "+token)
+	}
+	return httpGet.GetFile(dst, au)
+}
+
+// assets returns the assets of the release of u matching the asset query
+// parameter.
+func (g *GitHubReleaseGetter) assets(u *url.URL) ([]gitHubAsset, error) {
+	release, err := g.release(u)
+	if err != nil {
+		return nil, err
+	}
+
+	// The assets are downloaded into a directory under their name
+	for _, a := range release.Assets {
+		if a.Name == "" || a.Name == "." || a.Name == ".." || strings.ContainsAny(a.Name, `/\`) {
+			return nil, fmt.Errorf("invalid asset name: %q", a.Name)
+		}
+	}
+
+	pattern := u.Query().Get("asset")
+	if pattern == "" {
+		if len(release.Assets) != 1 {
+			return nil, fmt.Errorf("release %s has %d assets, select them with the asset query parameter",
+				release.TagName, len(release.Assets))
+		}
+		return release.Assets, nil
+	}
+
+	var assets []gitHubAsset
+	for _, a := range release.Assets {
+		match, err := path.Match(pattern, a.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset pattern %q: %s", pattern, err)
+		}
+		if match {
+			assets = append(assets, a)
+		}
+	}
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("no asset of release %s matches %q, it has: %s",
+			release.TagName, pattern, assetNames(release.Assets))
+	}
+	return assets, nil
+}
+
+// release returns the release of u from the GitHub API.
+func (g *GitHubReleaseGetter) release(u *url.URL) (*gitHubRelease, error) {
+	repo := strings.Trim(u.Path, "/")
+	if u.Scheme != "ghrelease" || u.Host == "" || repo == "" || strings.Contains(repo, "/") {
+		return nil, fmt.Errorf("URL is not a valid GitHub release URL, expected ghrelease://owner/repo: %s", u)
+	}
+
+	base := g.BaseURL
+	if base == "" {
+		base = "https://api.github.com"
+	}
+	releaseURL := fmt.Sprintf("%s/repos/%s/%s/releases/", strings.TrimSuffix(base, "/"),
+		url.PathEscape(u.Host), url.PathEscape(repo))
+	tag := u.Query().Get("tag")
+	if tag == "" {
+		tag = "latest"
+	}
+	if tag == "latest" {
+		releaseURL += "latest"
+	} else {
+		releaseURL += "tags/" + url.PathEscape(tag)
+	}
+
+	ru, err := url.Parse(releaseURL)
+	if err != nil {
+		return nil, err
+	}
+	httpGet := g.http()
+	httpGet.initClient()
+	req, err := httpGet.newRequest("GET", ru)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := g.token(u); token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+
+	resp, err := httpGet.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		// GitHub answers 404 for private repositories without access
+		return nil, fmt.Errorf("bad response code getting release %s of %s/%s: %d", tag, u.Host, repo, resp.StatusCode)
+	}
+
+	var release gitHubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("error parsing release %s of %s/%s: %s", tag, u.Host, repo, err)
+	}
+	return &release, nil
+}
+
+// token returns the token to authenticate to GitHub with, if any.
+func (g *GitHubReleaseGetter) token(u *url.URL) string {
+	if token := u.Query().Get("token"); token != "" {
+		return token
+	}
+	if g.Token != "" {
+		return g.Token
+	}
+	return os.Getenv("GITHUB_TOKEN")
+}
+
+// assetNames returns the names of the assets, separated by commas.
+func assetNames(assets []gitHubAsset) string {
+	var names []string
+	for _, a := range assets {
+		names = append(names, a.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,209 @@
+package getter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitHubReleaseGetter_impl(t *testing.T) {
+	var _ Getter = new(GitHubReleaseGetter)
+}
+
+func TestGitHubReleaseGetter_file(t *testing.T) {
+	ln := testGitHubServer(t)
+	defer ln.Close()
+
+	g := &GitHubReleaseGetter{BaseURL: fmt.Sprintf("http://%s", ln.Addr())}
+	u := testURL("ghrelease://owner/repo?asset=*_linux_amd64.zip")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeFile {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	filename, err := g.GetFilename(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if filename != "tool_2.0_linux_amd64.zip" {
+		t.Fatalf("bad filename: %s", filename)
+	}
+
+	dst := tempFile(t)
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "linux 2.0\n")
+
+	// A release by tag
+	dst = tempFile(t)
+	if err := g.GetFile(dst, testURL("ghrelease://owner/repo?tag=v1.0&asset=*_linux_amd64.zip")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "linux 1.0\n")
+}
+
+func TestGitHubReleaseGetter_dir(t *testing.T) {
+	ln := testGitHubServer(t)
+	defer ln.Close()
+
+	g := &GitHubReleaseGetter{BaseURL: fmt.Sprintf("http://%s", ln.Addr())}
+	u := testURL("ghrelease://owner/repo?asset=*.zip")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempDir(t)
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "tool_2.0_linux_amd64.zip"), "linux 2.0\n")
+	assertContents(t, filepath.Join(dst, "tool_2.0_darwin_arm64.zip"), "darwin 2.0\n")
+
+	if err := g.GetFile(tempFile(t), u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestGitHubReleaseGetter_bad(t *testing.T) {
+	ln := testGitHubServer(t)
+	defer ln.Close()
+
+	g := &GitHubReleaseGetter{BaseURL: fmt.Sprintf("http://%s", ln.Addr())}
+	for _, input := range []string{
+		"ghrelease://owner/repo",
+		"ghrelease://owner/repo?asset=*.tar.gz",
+		"ghrelease://owner/repo?tag=v9.9&asset=*.zip",
+		"ghrelease://owner/repo/extra?asset=*.zip",
+		"ghrelease://owner/private?asset=*",
+	} {
+		if err := g.GetFile(tempFile(t), testURL(input)); err == nil {
+			t.Fatalf("%s: should error", input)
+		}
+	}
+}
+
+func TestGitHubReleaseGetter_assetName(t *testing.T) {
+	ln := testGitHubServer(t)
+	defer ln.Close()
+
+	// Assets whose name is a path aren't downloaded outside of dst
+	g := &GitHubReleaseGetter{BaseURL: fmt.Sprintf("http://%s", ln.Addr())}
+	dst := filepath.Join(tempDir(t), "a", "b")
+	if err := g.Get(dst, testURL("ghrelease://owner/evil")); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(filepath.Join(dst, "..", "evil.zip")); err == nil {
+		t.Fatal("asset written outside of dst")
+	}
+}
+
+func TestGitHubReleaseGetter_token(t *testing.T) {
+	ln := testGitHubServer(t)
+	defer ln.Close()
+
+	g := &GitHubReleaseGetter{BaseURL: fmt.Sprintf("http://%s", ln.Addr())}
+	dst := tempFile(t)
+	if err := g.GetFile(dst, testURL("ghrelease://owner/private?token=secret")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "private\n")
+
+	defer os.Setenv("GITHUB_TOKEN", os.Getenv("GITHUB_TOKEN"))
+	os.Setenv("GITHUB_TOKEN", "secret")
+	dst = tempFile(t)
+	if err := g.GetFile(dst, testURL("ghrelease://owner/private")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "private\n")
+}
+
+// testGitHubServer starts a fake GitHub API with the releases v1.0 and
+// v2.0, the latest, of owner/repo, a release of owner/private which
+// requires the token "secret", and a release of owner/evil whose asset
+// name is a path. Assets redirect to their download below
+// /download/.
+func testGitHubServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assets := map[string]string{
+		"tool_1.0_linux_amd64.zip":  "linux 1.0\n",
+		"tool_2.0_linux_amd64.zip":  "linux 2.0\n",
+		"tool_2.0_darwin_arm64.zip": "darwin 2.0\n",
+		"checksums.txt":             "sums\n",
+		"private.txt":               "private\n",
+		"../evil.zip":               "evil\n",
+	}
+	releases := map[string][]string{
+		"/repos/owner/repo/releases/tags/v1.0": {"tool_1.0_linux_amd64.zip"},
+		"/repos/owner/repo/releases/latest":    {"tool_2.0_linux_amd64.zip", "tool_2.0_darwin_arm64.zip", "checksums.txt"},
+		"/repos/owner/private/releases/latest": {"private.txt"},
+		"/repos/owner/evil/releases/latest":    {"../evil.zip"},
+	}
+
+	// The paths aren't cleaned by a ServeMux, so names with encoded
+	// slashes are served
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/download/") {
+			fmt.Fprint(w, assets[strings.TrimPrefix(r.URL.Path, "/download/")])
+			return
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/repos/owner/private/") && r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(404)
+			return
+		}
+
+		if strings.Contains(r.URL.Path, "/releases/assets/") {
+			name := strings.SplitN(r.URL.Path, "/releases/assets/", 2)[1]
+			if _, ok := assets[name]; !ok || r.Header.Get("Accept") != "application/octet-stream" {
+				w.WriteHeader(404)
+				return
+			}
+			http.Redirect(w, r, "/download/"+url.PathEscape(name), http.StatusFound)
+			return
+		}
+
+		names, ok := releases[r.URL.Path]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		release := gitHubRelease{TagName: "v1.0"}
+		if strings.HasSuffix(r.URL.Path, "/latest") {
+			release.TagName = "v2.0"
+		}
+		base := strings.SplitN(r.URL.Path, "/releases/", 2)[0]
+		for _, name := range names {
+			release.Assets = append(release.Assets, gitHubAsset{
+				Name: name,
+				URL:  fmt.Sprintf("http://%s%s/releases/assets/%s", r.Host, base, url.PathEscape(name)),
+			})
+		}
+		json.NewEncoder(w).Encode(release)
+	}
+
+	var server http.Server
+	server.Handler = http.HandlerFunc(handler)
+	go server.Serve(ln)
+
+	return ln
+}
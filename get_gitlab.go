@@ -0,0 +1,342 @@
+package getter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// GitLabGetter is a Getter implementation that will download the files of
+// a package of the GitLab generic package registry, or the assets of a
+// GitLab release.
+//
+// URLs take the form gitlab://group/project, where the project can be in
+// subgroups, Ex., gitlab://group/subgroup/project?package=tool&version=1.0.
+// Requests authenticate with, in order, the token query parameter, Token or
+// GITLAB_TOKEN as a personal, project or group access token, or
+// CI_JOB_TOKEN as the job token in GitLab CI.
+//
+// Query parameters:
+//   - package, version: the name and version of the generic package
+//   - release: the tag of the release, instead of a package
+//   - file: a glob of the names of the files to download, which can be
+//     omitted if the package or release has a single file
+//   - token: the access token to authenticate with
+//
+// A single matching file is downloaded as a file, several ones into a
+// directory.
+type GitLabGetter struct {
+	httpBackedGetter
+
+	// BaseURL is the URL of the v4 API of GitLab. This defaults to
+	// CI_API_V4_URL in GitLab CI, and https://gitlab.com/api/v4 otherwise.
+	BaseURL string
+
+	// Token is the access token to authenticate with, if the URL has none.
+	// This defaults to the GITLAB_TOKEN environment variable.
+	Token string
+}
+
+// gitLabFile is a file of a package, or an asset of a release.
+type gitLabFile struct {
+	Name string
+	URL  string
+}
+
+func (g *GitLabGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	files, err := g.files(u)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) > 1 {
+		return ClientModeDir, nil
+	}
+	return ClientModeFile, nil
+}
+
+// GetFilename returns the name of the file, if a single one matches.
+func (g *GitLabGetter) GetFilename(u *url.URL) (string, error) {
+	files, err := g.files(u)
+	if err != nil {
+		return "", err
+	}
+	if len(files) > 1 {
+		return "", nil
+	}
+	return files[0].Name, nil
+}
+
+func (g *GitLabGetter) Get(dst string, u *url.URL) error {
+	files, err := g.files(u)
+	if err != nil {
+		return err
+	}
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if err := g.getFile(filepath.Join(dst, f.Name), u, f.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GitLabGetter) GetFile(dst string, u *url.URL) error {
+	files, err := g.files(u)
+	if err != nil {
+		return err
+	}
+	if len(files) > 1 {
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name)
+		}
+		return fmt.Errorf("several files match, expected a single file: %s", strings.Join(names, ", "))
+	}
+	return g.getFile(dst, u, files[0].URL)
+}
+
+// getFile downloads the file at fileURL to dst, authenticating only to
+// GitLab itself, as release assets can link to other hosts.
+func (g *GitLabGetter) getFile(dst string, u *url.URL, fileURL string) error {
+	fu, err := url.Parse(fileURL)
+	if err != nil {
+		return err
+	}
+
+	hg := g.http()
+	httpGet := *hg
+	if base, err := url.Parse(g.baseURL()); err == nil && base.Host == fu.Host {
+		httpGet.Header = http.Header{}
+		for k, v := range hg.Header {
+			httpGet.Header[k] = v
+		}
+		if k, v := g.authHeader(u); k != "" {
+			httpGet.Header.Set(k, v)
+		}
+	}
+	return httpGet.GetFile(dst, fu)
+}
+
+// files returns the files of the package, or release, of u matching the
+// file query parameter.
+func (g *GitLabGetter) files(u *url.URL) ([]gitLabFile, error) {
+	project := strings.Trim(u.Host+u.Path, "/")
+	if u.Scheme != "gitlab" || u.Host == "" || !strings.Contains(project, "/") {
+		return nil, fmt.Errorf("URL is not a valid GitLab URL, expected gitlab://group/project: %s", u)
+	}
+
+	var files []gitLabFile
+	var err error
+	q := u.Query()
+	switch {
+	case q.Get("package") != "":
+		if q.Get("version") == "" {
+			return nil, fmt.Errorf("query parameter 'version' is required for packages")
+		}
+		files, err = g.packageFiles(u, project, q.Get("package"), q.Get("version"))
+	case q.Get("release") != "":
+		files, err = g.releaseAssets(u, project, q.Get("release"))
+	default:
+		return nil, fmt.Errorf("query parameter 'package' or 'release' is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The files are downloaded into a directory under their name
+	var names []string
+	for _, f := range files {
+		if f.Name == "" || f.Name == "." || f.Name == ".." || strings.ContainsAny(f.Name, `/\`) {
+			return nil, fmt.Errorf("invalid file name: %q", f.Name)
+		}
+		names = append(names, f.Name)
+	}
+
+	pattern := q.Get("file")
+	if pattern == "" {
+		if len(files) != 1 {
+			return nil, fmt.Errorf("%d files found, select them with the file query parameter: %s",
+				len(files), strings.Join(names, ", "))
+		}
+		return files, nil
+	}
+
+	var matches []gitLabFile
+	for _, f := range files {
+		match, err := path.Match(pattern, f.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file pattern %q: %s", pattern, err)
+		}
+		if match {
+			matches = append(matches, f)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no file matches %q, found: %s", pattern, strings.Join(names, ", "))
+	}
+	return matches, nil
+}
+
+// packageFiles returns the files of the version of the generic package of
+// the project.
+func (g *GitLabGetter) packageFiles(u *url.URL, project, name, version string) ([]gitLabFile, error) {
+	projectPath := "projects/" + url.PathEscape(project)
+
+	var packages []struct {
+		ID      int    `json:"id"`
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	q := url.Values{}
+	q.Set("package_type", "generic")
+	q.Set("package_name", name)
+	q.Set("per_page", "100")
+	if err := g.api(u, projectPath+"/packages?"+q.Encode(), &packages); err != nil {
+		return nil, err
+	}
+
+	// The name is matched by prefix by the API
+	id := 0
+	for _, p := range packages {
+		if p.Name == name && p.Version == version {
+			id = p.ID
+		}
+	}
+	if id == 0 {
+		return nil, fmt.Errorf("package %s %s not found in %s", name, version, project)
+	}
+
+	var packageFiles []struct {
+		FileName string `json:"file_name"`
+	}
+	if err := g.api(u, fmt.Sprintf("%s/packages/%d/package_files?per_page=100", projectPath, id), &packageFiles); err != nil {
+		return nil, err
+	}
+
+	// A file uploaded again is listed again, the latest upload is downloaded
+	var files []gitLabFile
+	seen := map[string]bool{}
+	for _, f := range packageFiles {
+		if seen[f.FileName] {
+			continue
+		}
+		seen[f.FileName] = true
+		files = append(files, gitLabFile{
+			Name: f.FileName,
+			URL: fmt.Sprintf("%s/%s/packages/generic/%s/%s/%s", g.baseURL(), projectPath,
+				url.PathEscape(name), url.PathEscape(version), url.PathEscape(f.FileName)),
+		})
+	}
+	return files, nil
+}
+
+// releaseAssets returns the asset links of the release of the project.
+func (g *GitLabGetter) releaseAssets(u *url.URL, project, tag string) ([]gitLabFile, error) {
+	var release struct {
+		Assets struct {
+			Links []struct {
+				Name           string `json:"name"`
+				URL            string `json:"url"`
+				DirectAssetURL string `json:"direct_asset_url"`
+			} `json:"links"`
+		} `json:"assets"`
+	}
+	apiPath := fmt.Sprintf("projects/%s/releases/%s", url.PathEscape(project), url.PathEscape(tag))
+	if err := g.api(u, apiPath, &release); err != nil {
+		return nil, err
+	}
+
+	var files []gitLabFile
+	for _, l := range release.Assets.Links {
+		f := gitLabFile{Name: l.Name, URL: l.DirectAssetURL}
+		if f.URL == "" {
+			f.URL = l.URL
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// api gets the JSON of the API path into v.
+func (g *GitLabGetter) api(u *url.URL, apiPath string, v interface{}) error {
+	au, err := url.Parse(g.baseURL() + "/" + apiPath)
+	if err != nil {
+		return err
+	}
+
+	httpGet := g.http()
+	httpGet.initClient()
+	req, err := httpGet.newRequest("GET", au)
+	if err != nil {
+		return err
+	}
+	if k, v := g.authHeader(u); k != "" {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpGet.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("bad response code from the GitLab API for %s: %d", au.Path, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error parsing the GitLab API response for %s: %s", au.Path, err)
+	}
+	return nil
+}
+
+// baseURL returns the URL of the v4 API, without a trailing slash.
+func (g *GitLabGetter) baseURL() string {
+	base := g.BaseURL
+	if base == "" {
+		base = os.Getenv("CI_API_V4_URL")
+	}
+	if base == "" {
+		base = "https://gitlab.com/api/v4"
+	}
+	return strings.TrimSuffix(base, "/")
+}
+
+// authHeader returns the header, and its value, to authenticate with, if
+// any.
+func (g *GitLabGetter) authHeader(u *url.URL) (string, string) {
+	if token := u.Query().Get("token"); token != "" {
+		return "PRIVATE-TOKEN", token
+	}
+	if g.Token != "" {
+		return "PRIVATE-TOKEN", g.Token
+	}
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		return "PRIVATE-TOKEN", token
+	}
+	if token := os.Getenv("CI_JOB_TOKEN"); token != "" {
+		return "JOB-TOKEN", token
+	}
+	return "", ""
+}
@@ -0,0 +1,204 @@
+package getter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitLabGetter_impl(t *testing.T) {
+	var _ Getter = new(GitLabGetter)
+}
+
+func TestGitLabGetter_package(t *testing.T) {
+	ln := testGitLabServer(t)
+	defer ln.Close()
+
+	g := &GitLabGetter{BaseURL: fmt.Sprintf("http://%s/api/v4", ln.Addr()), Token: "secret"}
+	u := testURL("gitlab://group/sub/project?package=tool&version=1.0&file=*linux*")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeFile {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	filename, err := g.GetFilename(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if filename != "tool_linux.tar.gz" {
+		t.Fatalf("bad filename: %s", filename)
+	}
+
+	dst := tempFile(t)
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "linux\n")
+
+	// All the files of the package
+	u = testURL("gitlab://group/sub/project?package=tool&version=1.0&file=*")
+	mode, err = g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst = tempDir(t)
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "tool_linux.tar.gz"), "linux\n")
+	assertContents(t, filepath.Join(dst, "tool_darwin.tar.gz"), "darwin\n")
+}
+
+func TestGitLabGetter_release(t *testing.T) {
+	ln := testGitLabServer(t)
+	defer ln.Close()
+
+	g := &GitLabGetter{BaseURL: fmt.Sprintf("http://%s/api/v4", ln.Addr()), Token: "secret"}
+	dst := tempFile(t)
+	if err := g.GetFile(dst, testURL("gitlab://group/sub/project?release=v1.0")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "linux\n")
+}
+
+func TestGitLabGetter_bad(t *testing.T) {
+	ln := testGitLabServer(t)
+	defer ln.Close()
+
+	g := &GitLabGetter{BaseURL: fmt.Sprintf("http://%s/api/v4", ln.Addr()), Token: "secret"}
+	for _, input := range []string{
+		"gitlab://project?package=tool&version=1.0",
+		"gitlab://group/sub/project",
+		"gitlab://group/sub/project?package=tool",
+		"gitlab://group/sub/project?package=tool&version=1.0",
+		"gitlab://group/sub/project?package=tool&version=2.0&file=*",
+		"gitlab://group/sub/project?package=tool&version=1.0&file=*.zip",
+		"gitlab://group/sub/project?release=v9.9",
+	} {
+		if err := g.GetFile(tempFile(t), testURL(input)); err == nil {
+			t.Fatalf("%s: should error", input)
+		}
+	}
+}
+
+func TestGitLabGetter_auth(t *testing.T) {
+	ln := testGitLabServer(t)
+	defer ln.Close()
+
+	for _, k := range []string{"GITLAB_TOKEN", "CI_JOB_TOKEN"} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Unsetenv(k)
+	}
+
+	cases := []struct {
+		Name  string
+		Token string
+		Query string
+		Env   map[string]string
+		Err   bool
+	}{
+		{"token", "secret", "", nil, false},
+		{"query", "", "&token=secret", nil, false},
+		{"env", "", "", map[string]string{"GITLAB_TOKEN": "secret"}, false},
+		{"job token", "", "", map[string]string{"CI_JOB_TOKEN": "job"}, false},
+		{"bad token", "wrong", "", nil, true},
+		{"no token", "", "", nil, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			for k, v := range tc.Env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+
+			g := &GitLabGetter{BaseURL: fmt.Sprintf("http://%s/api/v4", ln.Addr()), Token: tc.Token}
+			u := testURL("gitlab://group/sub/project?package=tool&version=1.0&file=*linux*" + tc.Query)
+			dst := tempFile(t)
+			err := g.GetFile(dst, u)
+			if (err != nil) != tc.Err {
+				t.Fatalf("err: %v", err)
+			}
+			if !tc.Err {
+				assertContents(t, dst, "linux\n")
+			}
+		})
+	}
+}
+
+// testGitLabServer starts a fake GitLab API with the generic package tool
+// 1.0, and the release v1.0, of the project group/sub/project. Requests
+// require the access token "secret", or the job token "job".
+func testGitLabServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	files := map[string]string{
+		"tool_linux.tar.gz":  "linux\n",
+		"tool_darwin.tar.gz": "darwin\n",
+	}
+	project := "/api/v4/projects/group%2Fsub%2Fproject"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" && r.Header.Get("JOB-TOKEN") != "job" {
+			w.WriteHeader(401)
+			return
+		}
+
+		p := r.URL.EscapedPath()
+		switch {
+		case p == project+"/packages":
+			q := r.URL.Query()
+			if q.Get("package_type") != "generic" || !strings.HasPrefix("tool", q.Get("package_name")) {
+				fmt.Fprint(w, "[]")
+				return
+			}
+			fmt.Fprint(w, `[{"id": 1, "name": "tool", "version": "0.9"}, {"id": 2, "name": "tool", "version": "1.0"},
+				{"id": 3, "name": "toolbox", "version": "1.0"}]`)
+		case p == project+"/packages/2/package_files":
+			fmt.Fprint(w, `[{"file_name": "tool_linux.tar.gz"}, {"file_name": "tool_darwin.tar.gz"},
+				{"file_name": "tool_linux.tar.gz"}]`)
+		case strings.HasPrefix(p, project+"/packages/generic/tool/1.0/"):
+			content, ok := files[strings.TrimPrefix(p, project+"/packages/generic/tool/1.0/")]
+			if !ok {
+				w.WriteHeader(404)
+				return
+			}
+			fmt.Fprint(w, content)
+		case p == project+"/releases/v1.0":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"assets": map[string]interface{}{
+					"links": []map[string]string{{
+						"name":             "tool_linux.tar.gz",
+						"url":              "https://example.com/tool_linux.tar.gz",
+						"direct_asset_url": fmt.Sprintf("http://%s%s/packages/generic/tool/1.0/tool_linux.tar.gz", r.Host, project),
+					}},
+				},
+			})
+		default:
+			w.WriteHeader(404)
+		}
+	})
+
+	var server http.Server
+	server.Handler = mux
+	go server.Serve(ln)
+
+	return ln
+}
@@ -0,0 +1,437 @@
+package getter
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// GoModGetter is a Getter implementation that will download the source of
+// a Go module from a Go module proxy, without the go tool.
+//
+// URLs take the form gomod::https://golang.org/x/text?version=v0.14.0,
+// where the host and path of the URL are the path of the module. The
+// proxies are taken from GOPROXY, as the go tool does: they are tried in
+// order, falling through to the next one after a not found error when
+// separated by commas, and after any error when separated by pipes. 'off'
+// disables downloads, and 'direct', or modules matching GONOPROXY or
+// GOPRIVATE, aren't supported, as they require the go tool.
+//
+// The hash of the module zip is verified against the sum query parameter,
+// or looked up in the checksum database of GOSUMDB, unless GOSUMDB is off
+// or the module matches GONOSUMDB or GOPRIVATE. The signed tree of the
+// checksum database isn't verified.
+//
+// Query parameters:
+//   - version: the version, 'latest', or range of versions, Ex., '^1.2.0',
+//     default as the version the proxy reports as the latest
+//   - proxy: the proxies to use instead of GOPROXY, in the same syntax
+//   - sum: the expected hash of the module zip, as in go.sum, Ex., 'h1:...'
+type GoModGetter struct {
+	httpBackedGetter
+}
+
+// goModProxy is a proxy of a GOPROXY list.
+type goModProxy struct {
+	URL string
+
+	// FallbackOnError is true if the next proxy is tried after any error,
+	// rather than after a not found error only.
+	FallbackOnError bool
+}
+
+// goModNotFoundError is returned by a proxy which doesn't have a module or
+// version.
+type goModNotFoundError struct {
+	url string
+}
+
+func (e *goModNotFoundError) Error() string {
+	return fmt.Sprintf("not found: %s", e.url)
+}
+
+// goModSemver matches the complete versions of modules, Ex., 'v1.2.3' or
+// 'v0.0.0-20230101000000-abcdef123456'.
+var goModSemver = regexp.MustCompile(`^v\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+func (g *GoModGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	return ClientModeDir, nil
+}
+
+// GetFilename returns the name of the module zip of the resolved version,
+// Ex., 'v0.14.0.zip'.
+func (g *GoModGetter) GetFilename(u *url.URL) (string, error) {
+	_, version, _, err := g.resolve(u)
+	if err != nil {
+		return "", err
+	}
+	return version + ".zip", nil
+}
+
+// Get extracts the source of the module into the directory dst.
+func (g *GoModGetter) Get(dst string, u *url.URL) error {
+	module, version, proxy, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+
+	td, err := ioutil.TempDir(g.tempDir(), g.tempPattern("gomod"))
+	if err != nil {
+		return err
+	}
+	defer g.removeTemp(td)
+
+	archive := filepath.Join(td, "module.zip")
+	if err := g.getZip(archive, u, module, version, proxy); err != nil {
+		return err
+	}
+	data := filepath.Join(td, "data")
+	if err := g.decompressors()["zip"].Decompress(data, archive, true); err != nil {
+		return err
+	}
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	// The files are in the directory 'module@version'
+	return copyDir(dst, filepath.Join(data, filepath.FromSlash(module+"@"+version)), false)
+}
+
+// GetFile downloads the module zip to dst.
+func (g *GoModGetter) GetFile(dst string, u *url.URL) error {
+	module, version, proxy, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+	return g.getZip(dst, u, module, version, proxy)
+}
+
+// getZip downloads the zip of the module version from proxy to dst and
+// verifies its hash.
+func (g *GoModGetter) getZip(dst string, u *url.URL, module, version string, proxy *url.URL) error {
+	zipURL, err := proxy.Parse(goModEscape(module) + "/@v/" + goModEscape(version) + ".zip")
+	if err != nil {
+		return err
+	}
+	if err := g.http().GetFile(dst, zipURL); err != nil {
+		return err
+	}
+
+	expected := u.Query().Get("sum")
+	if expected == "" {
+		if expected, err = g.lookupSum(module, version); err != nil {
+			return err
+		}
+	}
+	if expected == "" {
+		return nil
+	}
+
+	actual, err := goModHashZip(dst)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("hash of %s@%s doesn't match, expected %s, got %s", module, version, expected, actual)
+	}
+	return nil
+}
+
+// resolve returns the module path of u, the version to download, and the
+// URL of the proxy to download it from.
+func (g *GoModGetter) resolve(u *url.URL) (string, string, *url.URL, error) {
+	module := strings.Trim(u.Host+u.Path, "/")
+	if module == "" || strings.ContainsAny(module, "@!\\") {
+		return "", "", nil, fmt.Errorf("invalid module path: %q", module)
+	}
+	for _, elem := range strings.Split(module, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return "", "", nil, fmt.Errorf("invalid module path: %q", module)
+		}
+	}
+
+	proxies := goModProxies(u.Query().Get("proxy"))
+	if goModMatch(goModEnv("GONOPROXY", "GOPRIVATE"), module) {
+		return "", "", nil, fmt.Errorf("module %s matches GONOPROXY or GOPRIVATE, which requires the go tool", module)
+	}
+
+	// A complete version is checked with its info, which also gives its
+	// canonical form, and ranges are resolved with the list of versions
+	expr := u.Query().Get("version")
+	var rel string
+	switch {
+	case expr == "" || expr == "latest":
+		rel = goModEscape(module) + "/@latest"
+	case goModSemver.MatchString(expr):
+		rel = goModEscape(module) + "/@v/" + goModEscape(expr) + ".info"
+	default:
+		rel = goModEscape(module) + "/@v/list"
+	}
+
+	var version string
+	var proxy *url.URL
+	err := g.fromProxies(proxies, rel, func(base *url.URL, body []byte) error {
+		if strings.HasSuffix(rel, "/@v/list") {
+			r, err := parseVersionRange(expr)
+			if err != nil {
+				return err
+			}
+			latest, ok := r.latest(strings.Fields(string(body)))
+			if !ok {
+				return fmt.Errorf("no version of module %s matches %q", module, expr)
+			}
+			version, proxy = latest, base
+			return nil
+		}
+
+		var info struct {
+			Version string
+		}
+		if err := json.Unmarshal(body, &info); err != nil {
+			return fmt.Errorf("error parsing the version of module %s: %s", module, err)
+		}
+		if !goModSemver.MatchString(info.Version) {
+			return fmt.Errorf("invalid version of module %s: %q", module, info.Version)
+		}
+		version, proxy = info.Version, base
+		return nil
+	})
+	if err != nil {
+		return "", "", nil, err
+	}
+	return module, version, proxy, nil
+}
+
+// fromProxies gets rel from each of the proxies in turn, as the go tool
+// does, and calls fn with the proxy and the body of the response until it
+// succeeds.
+func (g *GoModGetter) fromProxies(proxies []goModProxy, rel string, fn func(*url.URL, []byte) error) error {
+	var err error
+	for _, p := range proxies {
+		switch p.URL {
+		case "off":
+			return fmt.Errorf("module downloads are disabled by GOPROXY=off")
+		case "direct":
+			if err != nil {
+				return fmt.Errorf("%s, and GOPROXY=direct requires the go tool", err)
+			}
+			return fmt.Errorf("GOPROXY=direct requires the go tool")
+		}
+
+		var base *url.URL
+		if base, err = url.Parse(strings.TrimSuffix(p.URL, "/") + "/"); err != nil {
+			return fmt.Errorf("invalid GOPROXY URL %q: %s", p.URL, err)
+		}
+		var body []byte
+		if body, err = g.get(base, rel); err == nil {
+			if err = fn(base, body); err == nil {
+				return nil
+			}
+		}
+		if _, notFound := err.(*goModNotFoundError); !notFound && !p.FallbackOnError {
+			return err
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("no GOPROXY")
+	}
+	return err
+}
+
+// get downloads rel relative to base.
+func (g *GoModGetter) get(base *url.URL, rel string) ([]byte, error) {
+	u, err := base.Parse(rel)
+	if err != nil {
+		return nil, err
+	}
+	reqURL := *u
+	httpGet := g.http()
+	if httpGet.Netrc {
+		if err := addAuthFromNetrc(&reqURL); err != nil {
+			return nil, err
+		}
+	}
+
+	httpGet.initClient()
+	resp, err := httpGet.do("GET", &reqURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+	case 404, 410:
+		return nil, &goModNotFoundError{url: redactURL(u.String())}
+	default:
+		return nil, fmt.Errorf("bad response code getting %s: %d", redactURL(u.String()), resp.StatusCode)
+	}
+	return ioutil.ReadAll(g.rateLimitReader(resp.Body))
+}
+
+// lookupSum returns the hash of the module zip from the checksum database
+// of GOSUMDB, or nothing if it's disabled for the module.
+func (g *GoModGetter) lookupSum(module, version string) (string, error) {
+	sumdb := os.Getenv("GOSUMDB")
+	if sumdb == "" {
+		sumdb = "sum.golang.org"
+	}
+	if sumdb == "off" || goModMatch(goModEnv("GONOSUMDB", "GOPRIVATE"), module) {
+		return "", nil
+	}
+
+	// GOSUMDB is 'name[+key] [url]', where the URL defaults to the name
+	fields := strings.Fields(sumdb)
+	name := strings.SplitN(fields[0], "+", 2)[0]
+	base := "https://" + name
+	if len(fields) > 1 {
+		base = fields[1]
+	}
+	baseURL, err := url.Parse(strings.TrimSuffix(base, "/") + "/")
+	if err != nil {
+		return "", fmt.Errorf("invalid GOSUMDB URL %q: %s", base, err)
+	}
+
+	body, err := g.get(baseURL, "lookup/"+goModEscape(module)+"@"+goModEscape(version))
+	if err != nil {
+		return "", fmt.Errorf("error verifying %s@%s with %s: %s", module, version, name, err)
+	}
+	for _, line := range strings.Split(string(body), "\n") {
+		if f := strings.Fields(line); len(f) == 3 && f[0] == module && f[1] == version {
+			return f[2], nil
+		}
+	}
+	return "", fmt.Errorf("%s has no hash of %s@%s", name, module, version)
+}
+
+// goModProxies returns the proxies of the GOPROXY list, given by list or
+// the GOPROXY environment variable.
+func goModProxies(list string) []goModProxy {
+	if list == "" {
+		list = os.Getenv("GOPROXY")
+	}
+	if list == "" {
+		list = "https://proxy.golang.org,direct"
+	}
+
+	var proxies []goModProxy
+	for list != "" {
+		i := strings.IndexAny(list, ",|")
+		p := goModProxy{URL: list}
+		list = ""
+		if i >= 0 {
+			p.URL, p.FallbackOnError, list = p.URL[:i], p.URL[i] == '|', p.URL[i+1:]
+		}
+		if p.URL = strings.TrimSpace(p.URL); p.URL != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
+
+// goModEnv returns the value of the environment variable key, or of
+// fallback if it's unset, Ex., GONOSUMDB defaults to GOPRIVATE.
+func goModEnv(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return os.Getenv(fallback)
+}
+
+// goModMatch returns whether the module path matches one of the
+// comma-separated glob patterns, which match its leading elements, Ex.,
+// '*.corp.example.com' matches 'git.corp.example.com/team/module'.
+func goModMatch(patterns, module string) bool {
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.Trim(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+		n := strings.Count(pattern, "/") + 1
+		prefix := module
+		if elems := strings.SplitN(module, "/", n+1); len(elems) > n {
+			prefix = strings.Join(elems[:n], "/")
+		}
+		if ok, _ := path.Match(pattern, prefix); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// goModEscape escapes the module path or version s for the proxy protocol,
+// which replaces upper case letters with '!' and the lower case letter,
+// Ex., 'github.com/!azure/azure-sdk-for-go' for 'github.com/Azure/...'.
+func goModEscape(s string) string {
+	var buf []rune
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			buf = append(buf, '!', unicode.ToLower(r))
+			continue
+		}
+		buf = append(buf, r)
+	}
+	return string(buf)
+}
+
+// goModHashZip returns the hash of the module zip at path, as in go.sum,
+// Ex., 'h1:<base64>': the SHA-256 of the sorted list of the SHA-256 and
+// names of its files.
+func goModHashZip(zipPath string) (string, error) {
+	z, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return "", err
+	}
+	defer z.Close()
+
+	files := map[string]*zip.File{}
+	var names []string
+	for _, f := range z.File {
+		if strings.Contains(f.Name, "\n") {
+			return "", fmt.Errorf("file names with newlines aren't supported: %q", f.Name)
+		}
+		files[f.Name] = f
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		r, err := files[name].Open()
+		if err != nil {
+			return "", err
+		}
+		hf := sha256.New()
+		_, err = io.Copy(hf, r)
+		r.Close()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%x  %s\n", hf.Sum(nil), name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,325 @@
+package getter
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGoModGetter_impl(t *testing.T) {
+	var _ Getter = new(GoModGetter)
+}
+
+func TestGoModProxies(t *testing.T) {
+	actual := goModProxies("https://a.example.com|https://b.example.com, https://c.example.com,direct")
+	expected := []goModProxy{
+		{URL: "https://a.example.com", FallbackOnError: true},
+		{URL: "https://b.example.com"},
+		{URL: "https://c.example.com"},
+		{URL: "direct"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad proxies: %#v", actual)
+	}
+}
+
+func TestGoModMatch(t *testing.T) {
+	cases := []struct {
+		Patterns string
+		Module   string
+		Match    bool
+	}{
+		{"", "example.com/a", false},
+		{"example.com", "example.com/a/b", true},
+		{"example.com/a", "example.com/a/b", true},
+		{"example.com/a", "example.com/ab", false},
+		{"*.corp.example.com", "git.corp.example.com/team/module", true},
+		{"other.com, example.com/*/b", "example.com/a/b/c", true},
+		{"example.com/a/b/c", "example.com/a", false},
+	}
+	for _, tc := range cases {
+		if actual := goModMatch(tc.Patterns, tc.Module); actual != tc.Match {
+			t.Fatalf("%q %q: bad match: %v", tc.Patterns, tc.Module, actual)
+		}
+	}
+}
+
+func TestGoModEscape(t *testing.T) {
+	if actual := goModEscape("github.com/Azure/azure-sdk-for-go"); actual != "github.com/!azure/azure-sdk-for-go" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestGoModGetter(t *testing.T) {
+	ln := testGoModServer(t)
+	defer ln.Close()
+	defer testGoModEnv(fmt.Sprintf("sum.test+key http://%s/sumdb", ln.Addr()))()
+
+	g := new(GoModGetter)
+	cases := []struct {
+		Query   string
+		Version string
+	}{
+		{"", "v1.1.0"},
+		{"version=latest", "v1.1.0"},
+		{"version=v1.0.0", "v1.0.0"},
+		{"version=~1.0", "v1.0.0"},
+		{"version=>=v1.2.0-beta.1", "v1.2.0-beta.1"},
+	}
+	for _, tc := range cases {
+		u := testURL(fmt.Sprintf("https://example.com/Demo?proxy=http://%s/proxy&%s", ln.Addr(), tc.Query))
+
+		mode, err := g.ClientMode(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		if mode != ClientModeDir {
+			t.Fatalf("%s: bad mode: %d", tc.Query, mode)
+		}
+
+		filename, err := g.GetFilename(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		if filename != tc.Version+".zip" {
+			t.Fatalf("%s: bad filename: %s", tc.Query, filename)
+		}
+
+		dst := tempDir(t)
+		if err := g.Get(dst, u); err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		assertContents(t, filepath.Join(dst, "go.mod"), "module example.com/Demo\n")
+		assertContents(t, filepath.Join(dst, "cmd", "demo", "main.go"), "package main // "+tc.Version+"\n")
+	}
+
+	// The zip itself
+	u := testURL(fmt.Sprintf("https://example.com/Demo?proxy=http://%s/proxy&version=v1.0.0", ln.Addr()))
+	if err := g.GetFile(tempFile(t), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestGoModGetter_proxies(t *testing.T) {
+	ln := testGoModServer(t)
+	defer ln.Close()
+	defer testGoModEnv("off")()
+
+	g := new(GoModGetter)
+	cases := []struct {
+		Proxy string
+		Err   bool
+	}{
+		// Not found falls through to the next proxy
+		{"http://%[1]s/empty,http://%[1]s/proxy", false},
+		// Other errors only fall through after a pipe
+		{"http://%[1]s/broken,http://%[1]s/proxy", true},
+		{"http://%[1]s/broken|http://%[1]s/proxy", false},
+		{"http://%[1]s/empty,direct", true},
+		{"off", true},
+	}
+	for _, tc := range cases {
+		proxy := fmt.Sprintf(tc.Proxy, ln.Addr())
+		os.Setenv("GOPROXY", proxy)
+		u := testURL("https://example.com/Demo?version=v1.0.0")
+		err := g.Get(tempDir(t), u)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %v", proxy, err)
+		}
+	}
+
+	// Private modules aren't downloaded from proxies
+	os.Setenv("GOPROXY", fmt.Sprintf("http://%s/proxy", ln.Addr()))
+	os.Setenv("GOPRIVATE", "example.com")
+	if err := g.Get(tempDir(t), testURL("https://example.com/Demo")); err == nil {
+		t.Fatal("should error for a private module")
+	}
+}
+
+func TestGoModGetter_sum(t *testing.T) {
+	ln := testGoModServer(t)
+	defer ln.Close()
+	defer testGoModEnv(fmt.Sprintf("sum.test+key http://%s/sumdb", ln.Addr()))()
+
+	g := new(GoModGetter)
+	src := fmt.Sprintf("https://example.com/Demo?proxy=http://%s/proxy&version=v1.1.0-tampered", ln.Addr())
+
+	// The checksum database has another hash
+	if err := g.Get(tempDir(t), testURL(src)); err == nil {
+		t.Fatal("should error")
+	}
+
+	// GONOSUMDB skips the checksum database
+	os.Setenv("GONOSUMDB", "example.com")
+	if err := g.Get(tempDir(t), testURL(src)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The sum query parameter is verified regardless
+	if err := g.Get(tempDir(t), testURL(src+"&sum=h1:AAAA")); err == nil {
+		t.Fatal("should error")
+	}
+	hash, err := goModHashZip(testGoModZipFile(t, "v1.1.0-tampered"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := g.Get(tempDir(t), testURL(src+"&sum="+hash)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestGoModGetter_client(t *testing.T) {
+	ln := testGoModServer(t)
+	defer ln.Close()
+	defer testGoModEnv(fmt.Sprintf("sum.test+key http://%s/sumdb", ln.Addr()))()
+	os.Setenv("GOPROXY", fmt.Sprintf("http://%s/proxy", ln.Addr()))
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:  "gomod::https://example.com/Demo?version=v1.0.0",
+		Dst:  dst,
+		Mode: ClientModeAny,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "cmd", "demo", "main.go"), "package main // v1.0.0\n")
+}
+
+// testGoModEnv sets GOSUMDB, unsets the other environment variables of the
+// GoModGetter, and returns a func restoring them.
+func testGoModEnv(sumdb string) func() {
+	keys := []string{"GOPROXY", "GOSUMDB", "GONOSUMDB", "GONOPROXY", "GOPRIVATE"}
+	old := map[string]string{}
+	for _, k := range keys {
+		if v, ok := os.LookupEnv(k); ok {
+			old[k] = v
+		}
+		os.Unsetenv(k)
+	}
+	os.Setenv("GOSUMDB", sumdb)
+
+	return func() {
+		for _, k := range keys {
+			os.Unsetenv(k)
+			if v, ok := old[k]; ok {
+				os.Setenv(k, v)
+			}
+		}
+	}
+}
+
+// testGoModServer starts a proxy at /proxy with the versions v1.0.0,
+// v1.1.0, the latest, and v1.2.0-beta.1 of example.com/Demo, and
+// v1.1.0-tampered, whose hash doesn't match the checksum database at
+// /sumdb. The proxy at /empty has no modules, and the one at /broken
+// fails.
+func testGoModServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	zips := map[string][]byte{}
+	sums := map[string]string{}
+	for _, v := range []string{"v1.0.0", "v1.1.0", "v1.2.0-beta.1", "v1.1.0-tampered"} {
+		zips[v] = testGoModZip(t, v)
+		hash, err := goModHashZip(testGoModZipFile(t, v))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		sums[v] = hash
+	}
+	sums["v1.1.0-tampered"] = sums["v1.1.0"]
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/proxy/example.com/!demo/", func(w http.ResponseWriter, r *http.Request) {
+		rel := strings.TrimPrefix(r.URL.Path, "/proxy/example.com/!demo/")
+		switch {
+		case rel == "@latest":
+			json.NewEncoder(w).Encode(map[string]string{"Version": "v1.1.0"})
+		case rel == "@v/list":
+			fmt.Fprint(w, "v1.0.0\nv1.1.0\nv1.2.0-beta.1\n")
+		case strings.HasSuffix(rel, ".info"):
+			v := strings.TrimSuffix(strings.TrimPrefix(rel, "@v/"), ".info")
+			if _, ok := zips[v]; !ok {
+				http.Error(w, "not found", 404)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"Version": v})
+		case strings.HasSuffix(rel, ".zip"):
+			content, ok := zips[strings.TrimSuffix(strings.TrimPrefix(rel, "@v/"), ".zip")]
+			if !ok {
+				http.Error(w, "not found", 404)
+				return
+			}
+			w.Write(content)
+		default:
+			http.Error(w, "not found", 404)
+		}
+	})
+	mux.HandleFunc("/empty/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", 410)
+	})
+	mux.HandleFunc("/broken/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", 500)
+	})
+	mux.HandleFunc("/sumdb/lookup/", func(w http.ResponseWriter, r *http.Request) {
+		v := strings.TrimPrefix(r.URL.Path, "/sumdb/lookup/example.com/!demo@")
+		sum, ok := sums[v]
+		if !ok {
+			http.Error(w, "not found", 404)
+			return
+		}
+		fmt.Fprintf(w, "1234\nexample.com/Demo %s %s\nexample.com/Demo %s/go.mod h1:xxx=\n\n— sum.test signature\n", v, sum, v)
+	})
+
+	var server http.Server
+	server.Handler = mux
+	go server.Serve(ln)
+
+	return ln
+}
+
+// testGoModZip returns the zip of the version of example.com/Demo, with a
+// go.mod and a main package mentioning the version.
+func testGoModZip(t *testing.T, version string) []byte {
+	var buf bytes.Buffer
+	zipW := zip.NewWriter(&buf)
+	files := map[string]string{
+		"go.mod":           "module example.com/Demo\n",
+		"cmd/demo/main.go": "package main // " + version + "\n",
+	}
+	for _, name := range []string{"go.mod", "cmd/demo/main.go"} {
+		w, err := zipW.Create("example.com/Demo@" + version + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(files[name]))
+	}
+	if err := zipW.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testGoModZipFile writes the zip of testGoModZip to a temporary file.
+func testGoModZipFile(t *testing.T, version string) string {
+	path := tempFile(t)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, testGoModZip(t, version), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,233 @@
+package getter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// HelmGetter is a Getter implementation that will download a chart from a
+// Helm chart repository, Ex., ChartMuseum, Harbor or GitHub Pages, resolving
+// the newest version in a range from the index.yaml of the repository.
+//
+// URLs take the form helm::https://[user[:password]@]host/repo?chart=name.
+// The chart is downloaded as a file, or unpacked into a directory with the
+// untar query parameter. Its digest in the index, if any, is verified.
+//
+// Query parameters:
+//   - chart: the name of the chart
+//   - version: the version, or range of versions in the syntax of Helm,
+//     Ex., '^1.2.0' or '>= 1.2, < 2', default as the newest version that
+//     isn't a pre-release
+//   - untar: true to unpack the chart into a directory, like helm pull --untar
+type HelmGetter struct {
+	httpBackedGetter
+}
+
+// helmChartVersion is a version of a chart listed by the index.yaml of a
+// repository.
+type helmChartVersion struct {
+	Version string   `yaml:"version"`
+	Digest  string   `yaml:"digest"`
+	URLs    []string `yaml:"urls"`
+}
+
+// helmQueryParams are the query parameters of the HelmGetter, which aren't
+// part of the URL of the repository.
+var helmQueryParams = []string{"chart", "version", "untar"}
+
+func (g *HelmGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	if untar, _ := strconv.ParseBool(u.Query().Get("untar")); untar {
+		return ClientModeDir, nil
+	}
+	return ClientModeFile, nil
+}
+
+// GetFilename returns the name of the chart archive of the resolved
+// version, Ex., 'nginx-1.2.3.tgz'.
+func (g *HelmGetter) GetFilename(u *url.URL) (string, error) {
+	chartURL, _, err := g.resolve(u)
+	if err != nil {
+		return "", err
+	}
+	return path.Base(chartURL.Path), nil
+}
+
+func (g *HelmGetter) Get(dst string, u *url.URL) error {
+	chartURL, cv, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+
+	td, err := ioutil.TempDir(g.tempDir(), g.tempPattern("helm"))
+	if err != nil {
+		return err
+	}
+	defer g.removeTemp(td)
+
+	archive := filepath.Join(td, path.Base(chartURL.Path))
+	if err := g.getChart(archive, chartURL, cv); err != nil {
+		return err
+	}
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	// The archive has the chart in a directory named after it
+	return g.decompressors()["tgz"].Decompress(dst, archive, true)
+}
+
+func (g *HelmGetter) GetFile(dst string, u *url.URL) error {
+	chartURL, cv, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+	return g.getChart(dst, chartURL, cv)
+}
+
+// getChart downloads the chart archive to dst and verifies its digest.
+func (g *HelmGetter) getChart(dst string, chartURL *url.URL, cv *helmChartVersion) error {
+	if err := g.http().GetFile(dst, chartURL); err != nil {
+		return err
+	}
+	if cv.Digest == "" {
+		return nil
+	}
+
+	f, err := os.Open(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != strings.ToLower(cv.Digest) {
+		return fmt.Errorf("digest of %s doesn't match the index, expected %s, got %s",
+			path.Base(chartURL.Path), cv.Digest, actual)
+	}
+	return nil
+}
+
+// resolve returns the URL of the chart archive of the newest version of the
+// chart of u in the version range, and that version.
+func (g *HelmGetter) resolve(u *url.URL) (*url.URL, *helmChartVersion, error) {
+	q := u.Query()
+	chart := q.Get("chart")
+	if chart == "" {
+		return nil, nil, fmt.Errorf("query parameter 'chart' is required")
+	}
+	r, err := parseVersionRange(q.Get("version"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The index is at the root of the repository
+	repo := *u
+	for _, k := range helmQueryParams {
+		q.Del(k)
+	}
+	repo.RawQuery = q.Encode()
+	indexURL := repo
+	indexURL.Path = strings.TrimSuffix(repo.Path, "/") + "/index.yaml"
+	indexURL.RawPath = ""
+
+	index, err := g.index(&indexURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	versions, ok := index[chart]
+	if !ok {
+		return nil, nil, fmt.Errorf("chart %s not found in %s", chart, redactURL(indexURL.String()))
+	}
+
+	byVersion := map[string]*helmChartVersion{}
+	var names []string
+	for i, cv := range versions {
+		byVersion[cv.Version] = &versions[i]
+		names = append(names, cv.Version)
+	}
+	latest, ok := r.latest(names)
+	if !ok {
+		return nil, nil, fmt.Errorf("no version of chart %s matches %q", chart, q.Get("version"))
+	}
+	cv := byVersion[latest]
+	if len(cv.URLs) == 0 {
+		return nil, nil, fmt.Errorf("chart %s %s has no URL in the index", chart, latest)
+	}
+
+	// URLs can be relative to the repository, which keeps its credentials
+	ref, err := url.Parse(cv.URLs[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid URL of chart %s %s: %s", chart, latest, err)
+	}
+	return indexURL.ResolveReference(ref), cv, nil
+}
+
+// index downloads and parses the index.yaml at indexURL.
+func (g *HelmGetter) index(indexURL *url.URL) (map[string][]helmChartVersion, error) {
+	reqURL := *indexURL
+	httpGet := g.http()
+	if httpGet.Netrc {
+		if err := addAuthFromNetrc(&reqURL); err != nil {
+			return nil, err
+		}
+	}
+
+	httpGet.initClient()
+	resp, err := httpGet.do("GET", &reqURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("bad response code getting %s: %d", redactURL(indexURL.String()), resp.StatusCode)
+	}
+
+	index, err := parseHelmIndex(g.rateLimitReader(resp.Body))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %s", redactURL(indexURL.String()), err)
+	}
+	return index, nil
+}
+
+// helmIndex is the index.yaml of a repository, listing the versions of
+// each chart under its name.
+type helmIndex struct {
+	Entries map[string][]helmChartVersion `yaml:"entries"`
+}
+
+// parseHelmIndex parses the versions and URLs of the charts of an
+// index.yaml.
+func parseHelmIndex(r io.Reader) (map[string][]helmChartVersion, error) {
+	var index helmIndex
+	if err := yaml.NewDecoder(r).Decode(&index); err != nil {
+		return nil, err
+	}
+	if index.Entries == nil {
+		return nil, fmt.Errorf("no entries")
+	}
+	return index.Entries, nil
+}
@@ -0,0 +1,266 @@
+package getter
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHelmGetter_impl(t *testing.T) {
+	var _ Getter = new(HelmGetter)
+}
+
+func TestParseHelmIndex(t *testing.T) {
+	index := `apiVersion: v1
+entries:
+    "nginx":
+        - annotations:
+              category: Infrastructure
+          description: |
+              A chart
+              - not a list
+          urls:
+              - 'https://example.com/nginx-1.0.0.tgz'
+              - https://mirror.example.com/nginx-1.0.0.tgz
+          version: "1.0.0"
+        - urls: [nginx-0.9.0.tgz]
+          version: 0.9.0
+    redis: []
+generated: "2021-01-01T00:00:00Z"
+`
+	actual, err := parseHelmIndex(strings.NewReader(index))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[string][]helmChartVersion{
+		"nginx": {
+			{Version: "1.0.0", URLs: []string{"https://example.com/nginx-1.0.0.tgz", "https://mirror.example.com/nginx-1.0.0.tgz"}},
+			{Version: "0.9.0", URLs: []string{"nginx-0.9.0.tgz"}},
+		},
+		"redis": {},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad index: %#v", actual)
+	}
+
+	if _, err := parseHelmIndex(strings.NewReader("<html></html>")); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestHelmGetter_file(t *testing.T) {
+	ln := testHelmServer(t)
+	defer ln.Close()
+
+	g := new(HelmGetter)
+	u := testURL(fmt.Sprintf("http://%s/charts?chart=nginx&version=^1.0", ln.Addr()))
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeFile {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	filename, err := g.GetFilename(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if filename != "nginx-1.2.0.tgz" {
+		t.Fatalf("bad filename: %s", filename)
+	}
+
+	dst := tempFile(t)
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The newest version, which isn't a pre-release, by default
+	filename, err = g.GetFilename(testURL(fmt.Sprintf("http://%s/charts/?chart=nginx", ln.Addr())))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if filename != "nginx-2.0.0.tgz" {
+		t.Fatalf("bad filename: %s", filename)
+	}
+}
+
+func TestHelmGetter_untar(t *testing.T) {
+	ln := testHelmServer(t)
+	defer ln.Close()
+
+	g := new(HelmGetter)
+	u := testURL(fmt.Sprintf("http://%s/charts?chart=nginx&version=1.0.0&untar=true", ln.Addr()))
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempDir(t)
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "nginx", "Chart.yaml"), "name: nginx\nversion: 1.0.0\n")
+}
+
+func TestHelmGetter_bad(t *testing.T) {
+	ln := testHelmServer(t)
+	defer ln.Close()
+
+	g := new(HelmGetter)
+	for _, query := range []string{
+		"",
+		"chart=missing",
+		"chart=nginx&version=^3",
+		"chart=nginx&version=abc",
+		"chart=corrupt",
+	} {
+		u := testURL(fmt.Sprintf("http://%s/charts?%s", ln.Addr(), query))
+		if err := g.GetFile(tempFile(t), u); err == nil {
+			t.Fatalf("%s: should error", query)
+		}
+	}
+}
+
+func TestHelmGetter_client(t *testing.T) {
+	ln := testHelmServer(t)
+	defer ln.Close()
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:  fmt.Sprintf("helm::http://user:secret@%s/private?chart=nginx&version=~1.0&untar=true", ln.Addr()),
+		Dst:  dst,
+		Mode: ClientModeAny,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "nginx", "Chart.yaml"), "name: nginx\nversion: 1.0.0\n")
+}
+
+func TestHelmGetter_clientHttpGetter(t *testing.T) {
+	ln := testHelmServer(t)
+	defer ln.Close()
+
+	// Without HttpGet, the https getter of the client is downloaded with
+	getters := make(map[string]Getter, len(Getters))
+	for k, g := range Getters {
+		getters[k] = g
+	}
+	getters["https"] = &HttpGetter{Username: "user", Password: "secret"}
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:     fmt.Sprintf("helm::http://%s/private?chart=nginx&version=~1.0&untar=true", ln.Addr()),
+		Dst:     dst,
+		Mode:    ClientModeAny,
+		Getters: getters,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "nginx", "Chart.yaml"), "name: nginx\nversion: 1.0.0\n")
+}
+
+// testHelmServer starts a chart repository at /charts, and one requiring
+// basic auth as user:secret at /private, with the versions 1.0.0, 1.2.0,
+// 2.0.0 and 2.1.0-rc.1 of the chart nginx, and the chart corrupt whose
+// digest doesn't match.
+func testHelmServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	charts := map[string][]byte{}
+	for _, v := range []string{"1.0.0", "1.2.0", "2.0.0", "2.1.0-rc.1"} {
+		charts["nginx-"+v+".tgz"] = testHelmChart(t, "nginx", v)
+	}
+	charts["corrupt-1.0.0.tgz"] = testHelmChart(t, "corrupt", "1.0.0")
+
+	// The index is laid out as Helm writes it, with relative and absolute
+	// URLs
+	var index bytes.Buffer
+	fmt.Fprint(&index, "apiVersion: v1\nentries:\n")
+	for _, chart := range []string{"corrupt", "nginx"} {
+		fmt.Fprintf(&index, "  %s:\n", chart)
+		for name, content := range charts {
+			if !strings.HasPrefix(name, chart+"-") {
+				continue
+			}
+			sum := sha256.Sum256(content)
+			digest := hex.EncodeToString(sum[:])
+			if chart == "corrupt" {
+				digest = strings.Repeat("0", 64)
+			}
+			chartURL := name
+			if strings.Contains(name, "2.0.0") {
+				chartURL = fmt.Sprintf("http://%s/charts/%s", ln.Addr(), name)
+			}
+			fmt.Fprintf(&index, "  - apiVersion: v2\n    created: \"2021-01-01T00:00:00Z\"\n"+
+				"    description: A chart\n      on two lines\n    digest: %s\n"+
+				"    maintainers:\n    - name: someone\n    name: %s\n    urls:\n    - %s\n    version: %s\n",
+				digest, chart, chartURL, strings.TrimSuffix(strings.TrimPrefix(name, chart+"-"), ".tgz"))
+		}
+	}
+	fmt.Fprint(&index, "generated: \"2021-01-01T00:00:00Z\"\n")
+
+	mux := http.NewServeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/private/") {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+				w.WriteHeader(401)
+				return
+			}
+		}
+
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if name == "index.yaml" {
+			w.Write(index.Bytes())
+			return
+		}
+		content, ok := charts[name]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write(content)
+	}
+	mux.HandleFunc("/charts/", handler)
+	mux.HandleFunc("/private/", handler)
+
+	var server http.Server
+	server.Handler = mux
+	go server.Serve(ln)
+
+	return ln
+}
+
+// testHelmChart returns the archive of a chart with a Chart.yaml only.
+func testHelmChart(t *testing.T, name, version string) []byte {
+	var buf bytes.Buffer
+	gzipW := gzip.NewWriter(&buf)
+	tarW := tar.NewWriter(gzipW)
+	chartYAML := fmt.Sprintf("name: %s\nversion: %s\n", name, version)
+	if err := tarW.WriteHeader(&tar.Header{Name: name + "/Chart.yaml", Mode: 0644, Size: int64(len(chartYAML))}); err != nil {
+		t.Fatal(err)
+	}
+	tarW.Write([]byte(chartYAML))
+	tarW.Close()
+	gzipW.Close()
+	return buf.Bytes()
+}
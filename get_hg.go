@@ -14,7 +14,9 @@ import (
 
 // HgGetter is a Getter implementation that will download a module from
 // a Mercurial repository.
-type HgGetter struct{}
+type HgGetter struct {
+	getter
+}
 
 func (g *HgGetter) ClientMode(_ *url.URL) (ClientMode, error) {
 	return ClientModeDir, nil
@@ -68,7 +70,7 @@ func (g *HgGetter) Get(dst string, u *url.URL) error {
 // GetFile for Hg doesn't support updating at this time. It will download
 // the file every time.
 func (g *HgGetter) GetFile(dst string, u *url.URL) error {
-	td, err := ioutil.TempDir("", "getter-hg")
+	td, err := ioutil.TempDir(g.tempDir(), "getter-hg")
 	if err != nil {
 		return err
 	}
@@ -102,12 +104,12 @@ func (g *HgGetter) GetFile(dst string, u *url.URL) error {
 }
 
 func (g *HgGetter) clone(dst string, u *url.URL) error {
-	cmd := exec.Command("hg", "clone", "-U", u.String(), dst)
+	cmd := exec.CommandContext(g.ctx(), "hg", "clone", "-U", u.String(), dst)
 	return getRunCommand(cmd)
 }
 
 func (g *HgGetter) pull(dst string, u *url.URL) error {
-	cmd := exec.Command("hg", "pull")
+	cmd := exec.CommandContext(g.ctx(), "hg", "pull")
 	cmd.Dir = dst
 	return getRunCommand(cmd)
 }
@@ -118,7 +120,7 @@ func (g *HgGetter) update(dst string, u *url.URL, rev string) error {
 		args = append(args, rev)
 	}
 
-	cmd := exec.Command("hg", args...)
+	cmd := exec.CommandContext(g.ctx(), "hg", args...)
 	cmd.Dir = dst
 	return getRunCommand(cmd)
 }
@@ -1,17 +1,27 @@
 package getter
 
 import (
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/tls"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cheggaaa/pb"
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	"golang.org/x/net/http2"
 )
 
 // HttpGetter is a Getter implementation that will download from an HTTP
@@ -34,7 +44,18 @@ import (
 // The source URL, whether from the header or meta tag, must be a fully
 // formed URL. The shorthand syntax of "github.com/foo/bar" or relative
 // paths are not allowed.
+//
+// If instead the response is an archive, as determined by its
+// Content-Disposition or Content-Type header, it is unpacked into the
+// directory.
+//
+// If AutoIndex is set and neither of these are found, the response is
+// parsed as a directory listing instead, such as those of Apache's
+// mod_autoindex, and the files it links to are downloaded into the
+// directory.
 type HttpGetter struct {
+	getter
+
 	// Netrc, if true, will lookup and use auth information found
 	// in the user's netrc file if available.
 	Netrc bool
@@ -42,17 +63,375 @@ type HttpGetter struct {
 	// Client is the http.Client to use for Get requests.
 	// This defaults to a cleanhttp.DefaultClient if left unset.
 	Client *http.Client
+
+	// Username and Password, if set, are used for basic auth, so that the
+	// credentials don't have to be put in the URL. If unset, the Username
+	// and Password of the Client are used.
+	Username string
+	Password string
+
+	// DialTimeout, TLSHandshakeTimeout and ResponseHeaderTimeout, if set,
+	// bound connecting to the server, the TLS handshake and waiting for the
+	// response headers respectively, so that dead hosts fail fast. Reading
+	// the body is not bounded by them, so large downloads from slow servers
+	// aren't cut off. They are only used if Client is unset.
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+
+	// UserAgent is the User-Agent header sent with every request, including
+	// the metadata requests of Maven downloads. It defaults to
+	// DefaultUserAgent if unset.
+	UserAgent string
+
+	// MaxIdleConnsPerHost, if non-zero, is the number of idle connections
+	// kept alive per host for reuse by later requests, such as the many
+	// metadata and artifact requests of Maven downloads. It defaults to 2,
+	// and is only used if Client is unset.
+	MaxIdleConnsPerHost int
+
+	// TLSClientConfig, if set, is the TLS configuration of connections to
+	// the server, Ex., to trust an internal CA, or to skip verifying the
+	// certificate of a development server with InsecureSkipVerify. It is
+	// only used if Client is unset.
+	TLSClientConfig *tls.Config
+
+	// DecompressContentType, if true, will decompress files downloaded
+	// with GetFile whose Content-Type is a known compression format
+	// (gzip, bzip2 or xz), even if the URL has no matching extension.
+	DecompressContentType bool
+
+	// Header are additional headers sent with every request, Ex., an API
+	// token required by the server for uploads with PutFile.
+	Header http.Header
+
+	// RateLimit, if non-zero, caps the rate files are downloaded at, in
+	// bytes per second, including the metadata of Maven downloads. If zero,
+	// the RateLimit of the Client is used.
+	RateLimit int64
+
+	// AutoIndex, if true, downloads the files linked to by a directory
+	// listing page in ClientModeDir, when the page doesn't give a source
+	// URL with the X-Terraform-Get header. Only links to files below the
+	// listed directory on the same host are followed, so parent directory
+	// and sorting links are skipped, and subdirectories aren't descended
+	// into. As parsing the HTML of the listing is heuristic, it is opt-in.
+	AutoIndex bool
+
+	// ContentDispositionFilename, if true, saves files downloaded in
+	// ClientModeAny under the filename of their Content-Disposition header,
+	// if any, instead of the base name of the URL path, like curl -OJ.
+	ContentDispositionFilename bool
+
+	// Resume, if true, resumes downloading a file with GetFile after a
+	// failed download, Ex., of a large file over a flaky link. Files are
+	// downloaded to "<dst>.part", with the ETag or Last-Modified date of
+	// the file saved to "<dst>.part.validator", and renamed to dst once
+	// complete. The rest of a partial file is requested with a Range header
+	// and the validator in If-Range, and appended to it, or the whole file
+	// is downloaded again if it changed or the server doesn't support
+	// ranges. It is ignored if DecompressContentType is set.
+	Resume bool
+
+	// Chunks, if greater than 1, downloads files of at least ChunkMinSize
+	// bytes with GetFile in that many concurrent ranged requests, which is
+	// faster from servers throttling the throughput of each connection,
+	// like some CDNs. ChunkMinSize defaults to 1 MiB. Files are downloaded
+	// in a single request if the server doesn't advertise support for
+	// ranges in the response to a HEAD request. It is ignored if
+	// DecompressContentType is set, or when resuming a download.
+	Chunks       int
+	ChunkMinSize int64
 }
 
+// initClient sets Client to the default client if it is unset, with a
+// transport using the configured timeouts, idle connections and TLS
+// configuration if any.
+func (g *HttpGetter) initClient() {
+	if g.Client != nil {
+		return
+	}
+	config := httpTransportConfig{
+		dialTimeout:           g.DialTimeout,
+		tlsHandshakeTimeout:   g.TLSHandshakeTimeout,
+		responseHeaderTimeout: g.ResponseHeaderTimeout,
+		maxIdleConnsPerHost:   g.MaxIdleConnsPerHost,
+		tlsClientConfig:       g.TLSClientConfig,
+	}
+	if config == (httpTransportConfig{}) {
+		g.Client = httpClient
+		return
+	}
+
+	// Clients download with copies of the getter, which would each build a
+	// transport of their own, so the client is shared by configuration for
+	// their connections to be reused
+	tunedClients.Lock()
+	defer tunedClients.Unlock()
+	if c, ok := tunedClients.m[config]; ok {
+		g.Client = c
+		return
+	}
+
+	transport := newHttpTransport(g.TLSClientConfig)
+	if g.DialTimeout != 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   g.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if g.TLSHandshakeTimeout != 0 {
+		transport.TLSHandshakeTimeout = g.TLSHandshakeTimeout
+	}
+	if g.ResponseHeaderTimeout != 0 {
+		transport.ResponseHeaderTimeout = g.ResponseHeaderTimeout
+	}
+	if g.MaxIdleConnsPerHost != 0 {
+		transport.MaxIdleConnsPerHost = g.MaxIdleConnsPerHost
+	}
+	g.Client = &http.Client{Transport: transport}
+	tunedClients.m[config] = g.Client
+}
+
+// httpTransportConfig is the configuration of the transport of an
+// HttpGetter without a Client.
+type httpTransportConfig struct {
+	dialTimeout           time.Duration
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+	maxIdleConnsPerHost   int
+	tlsClientConfig       *tls.Config
+}
+
+// tunedClients are the clients built by initClient, by the configuration
+// of their transport.
+var tunedClients = struct {
+	sync.Mutex
+	m map[httpTransportConfig]*http.Client
+}{m: make(map[httpTransportConfig]*http.Client)}
+
+// newHttpTransport returns a transport keeping connections alive for reuse,
+// and using HTTP/2 with servers supporting it. The TLS configuration, if
+// any, is copied, as HTTP/2 is enabled by adding to it.
+func newHttpTransport(tlsConfig *tls.Config) *http.Transport {
+	transport := cleanhttp.DefaultPooledTransport()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+	// This only errors if the transport was configured for HTTP/2 already
+	http2.ConfigureTransport(transport)
+	return transport
+}
+
+// do issues a request with the given method for the URL, using basic auth
+// if a username is configured.
+func (g *HttpGetter) do(method string, u *url.URL) (*http.Response, error) {
+	req, err := g.newRequest(method, u)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.Client.Do(req)
+}
+
+// newRequest returns a request with the given method for the URL, using
+// basic auth if a username is configured.
+func (g *HttpGetter) newRequest(method string, u *url.URL) (*http.Request, error) {
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(g.ctx())
+	g.hooks().requestStarted(method, u)
+
+	if username, password := g.basicAuth(); username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	userAgent := g.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	for k, v := range g.Header {
+		req.Header[k] = v
+	}
+
+	return req, nil
+}
+
+// Version is the version of go-getter.
+const Version = "1.0.0"
+
+// DefaultUserAgent is the User-Agent header sent by HttpGetters without a
+// UserAgent of their own.
+var DefaultUserAgent = "go-getter/" + Version
+
+// rateLimit returns the configured download rate limit of the getter,
+// falling back to that of the Client.
+func (g *HttpGetter) rateLimit() int64 {
+	if g.RateLimit != 0 || g.client == nil {
+		return g.RateLimit
+	}
+	return g.client.RateLimit
+}
+
+// basicAuth returns the configured basic auth credentials of the getter,
+// falling back to those of the Client.
+func (g *HttpGetter) basicAuth() (string, string) {
+	if g.Username != "" {
+		return g.Username, g.Password
+	}
+	if g.client != nil {
+		return g.client.Username, g.client.Password
+	}
+	return "", ""
+}
+
+// httpArchiveTypes maps the Content-Type of archives to the key of the
+// Decompressor that unpacks them.
+var httpArchiveTypes = map[string]string{
+	"application/zip":                   "zip",
+	"application/x-zip-compressed":      "zip",
+	"application/x-gtar":                "tar.gz",
+	"application/x-tgz":                 "tar.gz",
+	"application/x-compressed-tar":      "tar.gz",
+	"application/x-bzip-compressed-tar": "tar.bz2",
+	"application/x-xz-compressed-tar":   "tar.xz",
+	"application/x-lz4-compressed-tar":  "tar.lz4",
+}
+
+// httpCompressedTypes maps the Content-Type of single compressed files to
+// the key of the Decompressor that decompresses them.
+var httpCompressedTypes = map[string]string{
+	"application/gzip":    "gz",
+	"application/x-gzip":  "gz",
+	"application/x-bzip2": "bz2",
+	"application/x-xz":    "xz",
+	"application/x-lz4":   "lz4",
+}
+
+// httpArchiveExts are the Decompressor keys of archives, as opposed to
+// single compressed files, matched against the filename of the
+// Content-Disposition header.
+var httpArchiveExts = []string{"tar.bz2", "tar.gz", "tar.lz4", "tar.xz", "tbz2", "tgz", "tlz4", "txz", "zip"}
+
+// ClientMode issues a HEAD request to determine whether the URL serves an
+// archive, which is unpacked into a directory, or a single file. If the
+// server doesn't support HEAD requests, file mode is used.
 func (g *HttpGetter) ClientMode(u *url.URL) (ClientMode, error) {
 	if strings.HasSuffix(u.Path, "/") {
 		return ClientModeDir, nil
 	}
+
+	resp, err := g.head(u)
+	if err != nil {
+		return ClientModeFile, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return ClientModeFile, nil
+	}
+
+	if g.archiveType(resp) != "" {
+		return ClientModeDir, nil
+	}
 	return ClientModeFile, nil
 }
 
+// Validate issues a HEAD request to check the URL can be downloaded. Servers
+// that don't support HEAD requests are assumed to serve the URL.
+func (g *HttpGetter) Validate(u *url.URL) error {
+	resp, err := g.head(u)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("bad response code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// head issues a HEAD request for the given URL.
+func (g *HttpGetter) head(u *url.URL) (*http.Response, error) {
+	// Copy the URL so we can modify it
+	var newU url.URL = *u
+	u = &newU
+
+	if g.Netrc {
+		// Add auth from netrc if we can
+		if err := addAuthFromNetrc(u); err != nil {
+			return nil, err
+		}
+	}
+
+	g.initClient()
+
+	return g.do("HEAD", u)
+}
+
+// archiveType returns the key of the Decompressor for the archive served
+// in resp, based on the filename of its Content-Disposition header or else
+// its Content-Type. An empty string is returned if resp isn't an archive.
+func (g *HttpGetter) archiveType(resp *http.Response) string {
+	if v := resp.Header.Get("Content-Disposition"); v != "" {
+		if _, params, err := mime.ParseMediaType(v); err == nil && params["filename"] != "" {
+			for _, ext := range httpArchiveExts {
+				if strings.HasSuffix(params["filename"], "."+ext) {
+					return ext
+				}
+			}
+		}
+	}
+
+	if v := resp.Header.Get("Content-Type"); v != "" {
+		if mediaType, _, err := mime.ParseMediaType(v); err == nil {
+			return httpArchiveTypes[mediaType]
+		}
+	}
+
+	return ""
+}
+
+// GetFilename returns the filename of the Content-Disposition header of
+// the URL if ContentDispositionFilename is set. Only the base name is used,
+// so that the file can't be written outside of the destination directory.
 func (g *HttpGetter) GetFilename(u *url.URL) (string, error) {
-	return "", nil
+	if !g.ContentDispositionFilename {
+		return "", nil
+	}
+
+	resp, err := g.head(u)
+	if err != nil {
+		return "", nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", nil
+	}
+
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition"))
+	if err != nil {
+		return "", nil
+	}
+	return sanitizeFilename(params["filename"]), nil
+}
+
+// sanitizeFilename returns the base name of a filename given by a server,
+// or an empty string if it has none, so that it can't point outside of the
+// directory it is saved in.
+func sanitizeFilename(name string) string {
+	name = path.Base(strings.Replace(name, "\\", "/", -1))
+	if name == "." || name == ".." || name == "/" {
+		return ""
+	}
+	return name
 }
 
 func (g *HttpGetter) Get(dst string, u *url.URL) error {
@@ -67,9 +446,7 @@ func (g *HttpGetter) Get(dst string, u *url.URL) error {
 		}
 	}
 
-	if g.Client == nil {
-		g.Client = httpClient
-	}
+	g.initClient()
 
 	// Add terraform-get to the parameter.
 	q := u.Query()
@@ -77,7 +454,7 @@ func (g *HttpGetter) Get(dst string, u *url.URL) error {
 	u.RawQuery = q.Encode()
 
 	// Get the URL
-	resp, err := g.Client.Get(u.String())
+	resp, err := g.do("GET", u)
 	if err != nil {
 		return err
 	}
@@ -90,6 +467,11 @@ func (g *HttpGetter) Get(dst string, u *url.URL) error {
 	var source string
 	if v := resp.Header.Get("X-Terraform-Get"); v != "" {
 		source = v
+	} else if archiveV := g.archiveType(resp); archiveV != "" {
+		// The URL serves an archive itself, unpack it into the directory
+		return g.decompress(dst, resp.Body, archiveV, true)
+	} else if g.AutoIndex {
+		return g.getIndex(dst, u, resp)
 	} else {
 		source, err = g.parseMeta(resp.Body)
 		if err != nil {
@@ -112,24 +494,40 @@ func (g *HttpGetter) Get(dst string, u *url.URL) error {
 }
 
 func (g *HttpGetter) GetFile(dst string, u *url.URL) error {
-	if g.Netrc {
-		// Add auth from netrc if we can
-		if err := addAuthFromNetrc(u); err != nil {
+	// Resume the partial file of a failed download, if any, as long as the
+	// file didn't change since, see partialFile
+	resume := g.Resume && !g.DecompressContentType
+	part, validatorPath := dst+".part", dst+".part.validator"
+	var offset int64
+	var validator string
+	if resume {
+		offset, validator = partialFile(part, validatorPath)
+	}
+
+	if g.Chunks > 1 && offset == 0 && !g.DecompressContentType {
+		if ok, err := g.getChunks(dst, u); ok || err != nil {
 			return err
 		}
 	}
 
-	if g.Client == nil {
-		g.Client = httpClient
+	resp, err := g.getFile(u, offset, validator)
+	if e, ok := err.(*badResponseError); ok && offset > 0 && e.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The file changed and is shorter than the partial file, or the
+		// partial file is complete already, start over
+		offset = 0
+		resp, err = g.getFile(u, 0, "")
 	}
-
-	resp, err := g.Client.Get(u.String())
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("bad response code: %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusPartialContent {
+		// The server sends the whole file if it doesn't support ranges, or
+		// the file changed since the partial file was downloaded
+		offset = 0
+	}
+	if offset > 0 {
+		g.logger().Printf("resuming the download of '%s' at byte %d", redactURL(u.String()), offset)
 	}
 
 	// Create all the parent directories
@@ -137,25 +535,358 @@ func (g *HttpGetter) GetFile(dst string, u *url.URL) error {
 		return err
 	}
 
-	f, err := os.Create(dst)
+	bar := pb.New64(resp.ContentLength).SetUnits(pb.U_BYTES)
+	bar.Start()
+	reader := bar.NewProxyReader(g.progressReader(u, resp.Body, resp.ContentLength))
+	defer bar.Finish()
+
+	if key := g.compressedType(u, resp); key != "" {
+		return g.decompress(dst, reader, key, false)
+	}
+
+	// When resuming, the file is saved to the partial file until it is
+	// complete, with the validator of the response to resume it with
+	path := dst
+	if resume {
+		path = part
+		if offset == 0 {
+			validator = resumeValidator(resp)
+			if validator == "" {
+				os.Remove(validatorPath)
+			} else if err := ioutil.WriteFile(validatorPath, []byte(validator), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Hash the file for the client while it is downloaded, starting with
+	// the part downloaded already if resuming
+	var body io.Reader = reader
+	if h := g.outputHash(dst); h != nil {
+		if offset > 0 {
+			if err := hashFile(path, h); err != nil {
+				return err
+			}
+		}
+		body = io.TeeReader(reader, h)
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if offset > 0 {
+		flag = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flag, 0666)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if !resume {
+		return err
+	}
+	if err != nil {
+		// Without a validator, the partial file can't be resumed
+		if validator == "" {
+			os.Remove(part)
+		}
+		return err
+	}
+	os.Remove(validatorPath)
+	return os.Rename(part, dst)
+}
+
+// partialFile returns the size of the partial file at path, and the
+// validator saved at validatorPath when it was started, to resume it with
+// If-Range. Without both, 0 is returned so that the file is downloaded
+// from the start.
+func partialFile(path, validatorPath string) (int64, string) {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return 0, ""
+	}
+	validator, err := ioutil.ReadFile(validatorPath)
+	if err != nil || len(validator) == 0 {
+		return 0, ""
+	}
+	return fi.Size(), string(validator)
+}
+
+// resumeValidator returns the validator of the file of resp to resume
+// downloading it with If-Range: its strong ETag, as weak ones can't be
+// used for ranges, or else its Last-Modified date, or "" if it has neither.
+func resumeValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// hashFile writes the contents of the file at path to h.
+func hashFile(path string, h io.Writer) error {
+	f, err := os.Open(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	bar := pb.New64(resp.ContentLength).SetUnits(pb.U_BYTES)
-	bar.Start()
-	reader := bar.NewProxyReader(resp.Body)
-	_, err = io.Copy(f, reader)
-	bar.Finish()
+	_, err = io.Copy(h, f)
 	return err
 }
 
+// GetReader returns a reader of the file the URL references. If
+// DecompressContentType is set, compressed files are decompressed while
+// being read, as with GetFile.
+func (g *HttpGetter) GetReader(u *url.URL) (io.ReadCloser, error) {
+	resp, err := g.getFile(u, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if key := g.compressedType(u, resp); key != "" {
+		return decompressReader(resp.Body, streamDecompressors[key])
+	}
+	return resp.Body, nil
+}
+
+// PutFile uploads the file src to the URL with a PUT request, using the
+// same auth as downloads. Any 2xx response is a success.
+func (g *HttpGetter) PutFile(src string, u *url.URL) error {
+	// Copy the URL so we can modify it
+	var newU url.URL = *u
+	u = &newU
+
+	if g.Netrc {
+		// Add auth from netrc if we can
+		if err := addAuthFromNetrc(u); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	g.initClient()
+	req, err := g.newRequest("PUT", u)
+	if err != nil {
+		return err
+	}
+
+	// An empty body is left nil, as a non-nil one of zero length would be
+	// sent chunked
+	if fi.Size() > 0 {
+		req.Body = f
+		req.ContentLength = fi.Size()
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("bad response code uploading to %s: %d", u.Host+u.Path, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// getFile requests the file the URL references, returning an error if the
+// response isn't successful. If offset is non-zero, only the rest of the
+// file from that byte is requested, and a 206 Partial Content response is
+// successful as well. The rest is requested with the validator, if any, in
+// If-Range, so that the whole file is sent if it changed.
+func (g *HttpGetter) getFile(u *url.URL, offset int64, validator string) (*http.Response, error) {
+	if g.Netrc {
+		// Add auth from netrc if we can
+		if err := addAuthFromNetrc(u); err != nil {
+			return nil, err
+		}
+	}
+
+	g.initClient()
+
+	req, err := g.newRequest("GET", u)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ask for compressed responses ourselves, so that the transport doesn't
+	// transparently decode them and we can tell a Content-Encoding applied
+	// for the transfer from a file that is compressed itself.
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	// Ranges of encoded responses are ranges of the encoded bytes, so only
+	// ask for the file as is when resuming
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("Accept-Encoding", "identity")
+		if validator != "" {
+			req.Header.Set("If-Range", validator)
+		}
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 && !(offset > 0 && resp.StatusCode == http.StatusPartialContent) {
+		resp.Body.Close()
+		return nil, &badResponseError{StatusCode: resp.StatusCode}
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		if cr := resp.Header.Get("Content-Range"); !strings.HasPrefix(cr, fmt.Sprintf("bytes %d-", offset)) {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected Content-Range resuming at byte %d: %q", offset, cr)
+		}
+	}
+
+	// Throttle the transfer itself, before its Content-Encoding is decoded
+	resp.Body = newRateLimitReader(g.ctx(), resp.Body, g.rateLimit())
+
+	if err := decodeContentEncoding(u, resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// decodeContentEncoding replaces the body of resp so that it is decoded
+// from its Content-Encoding. Files that are gzip compressed themselves, such
+// as "foo.tar.gz", are often served with "Content-Encoding: gzip" as well,
+// in which case the body is kept as is so that the file is saved unchanged
+// and left to the decompressors.
+func decodeContentEncoding(u *url.URL, resp *http.Response) error {
+	var r io.Reader
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip", "x-gzip":
+		if isGzipFile(u, resp) {
+			return nil
+		}
+
+		gzipR, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return err
+		}
+		r = gzipR
+	case "deflate":
+		zlibR, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			return err
+		}
+		r = zlibR
+	default:
+		return nil
+	}
+
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{r, resp.Body}
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.Uncompressed = true
+	return nil
+}
+
+// isGzipFile returns whether the file served in resp is gzip compressed
+// itself, based on the extension of the URL or its Content-Type.
+func isGzipFile(u *url.URL, resp *http.Response) bool {
+	if strings.HasSuffix(u.Path, ".gz") || strings.HasSuffix(u.Path, ".tgz") {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return httpCompressedTypes[mediaType] == "gz" ||
+		httpArchiveTypes[mediaType] == "tar.gz"
+}
+
+// badResponseError is returned when a file is requested but the response
+// isn't successful.
+type badResponseError struct {
+	StatusCode int
+}
+
+func (e *badResponseError) Error() string {
+	return fmt.Sprintf("bad response code: %d", e.StatusCode)
+}
+
+// compressedType returns the key of the Decompressor for the compressed
+// file served in resp, based on its Content-Type. An empty string is
+// returned if DecompressContentType isn't set, resp isn't compressed, or
+// the URL already has the matching extension and so is left to the client
+// to decompress.
+func (g *HttpGetter) compressedType(u *url.URL, resp *http.Response) string {
+	if !g.DecompressContentType {
+		return ""
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+
+	key := httpCompressedTypes[mediaType]
+	if key == "" || strings.HasSuffix(u.Path, "."+key) {
+		return ""
+	}
+	return key
+}
+
+// decompress decompresses the data read from body into dst, using the
+// Decompressor for archiveV.
+func (g *HttpGetter) decompress(dst string, body io.Reader, archiveV string, dir bool) error {
+	decompressor := g.decompressors()[archiveV]
+	if decompressor == nil {
+		return fmt.Errorf("no decompressor for archive type %q", archiveV)
+	}
+
+	// Extract directories while they are downloaded if the client asks for
+	// it and the archive can be
+	if rd := asReaderDecompressor(decompressor); rd != nil && dir && g.client != nil && g.client.StreamArchives {
+		return rd.decompressReader(dst, body, archiveV+" archive", dir)
+	}
+
+	// Otherwise decompressors work on files, so download the archive first
+	td, err := ioutil.TempDir(g.tempDir(), g.tempPattern("getter"))
+	if err != nil {
+		return err
+	}
+	defer g.removeTemp(td)
+
+	archive := filepath.Join(td, "archive")
+	f, err := os.Create(archive)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, body)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	return decompressor.Decompress(dst, archive, dir)
+}
+
 // getSubdir downloads the source into the destination, but with
 // the proper subdir.
 func (g *HttpGetter) getSubdir(dst, source, subDir string) error {
 	// Create a temporary directory to store the full source
-	td, err := ioutil.TempDir("", "tf")
+	td, err := ioutil.TempDir(g.tempDir(), "tf")
 	if err != nil {
 		return err
 	}
@@ -195,6 +926,112 @@ func (g *HttpGetter) getSubdir(dst, source, subDir string) error {
 	return copyDir(dst, sourcePath, false)
 }
 
+// autoIndexParallel is the number of files of a directory listing that are
+// downloaded concurrently.
+const autoIndexParallel = 4
+
+// getIndex downloads the files linked to by the directory listing in resp,
+// requested for u, into dst.
+func (g *HttpGetter) getIndex(dst string, u *url.URL, resp *http.Response) error {
+	// Links are relative to the listing after redirects, Ex., from
+	// 'http://host/files' to 'http://host/files/'
+	base := *resp.Request.URL
+	base.User = u.User
+	base.RawQuery = ""
+
+	files, err := parseIndex(&base, resp.Body)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files found in the directory listing of %s", base.Host+base.Path)
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var firstErr error
+	sem := make(chan struct{}, autoIndexParallel)
+	for _, f := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(f indexFile) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			g.logger().Printf("downloading %s from the directory listing", f.Path)
+			err := g.GetFile(filepath.Join(dst, filepath.FromSlash(f.Path)), f.URL)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("error downloading %s: %s", f.Path, err)
+			}
+		}(f)
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// indexFile is a file linked to by a directory listing.
+type indexFile struct {
+	// Path is the path of the file relative to the listed directory.
+	Path string
+	URL  *url.URL
+}
+
+// parseIndex returns the files linked to by the directory listing at base
+// in r. Links to other hosts, to subdirectories and to anything not below
+// the listed directory, such as the parent directory or the sorting links
+// of Apache listings, are skipped.
+func parseIndex(base *url.URL, r io.Reader) ([]indexFile, error) {
+	dir := base.Path[:strings.LastIndex(base.Path, "/")+1]
+
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charsetReader
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	var files []indexFile
+	seen := make(map[string]bool)
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		e, ok := t.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "a") {
+			continue
+		}
+		href := attrValue(e.Attr, "href")
+		if href == "" {
+			continue
+		}
+
+		link, err := base.Parse(href)
+		if err != nil || link.Scheme != base.Scheme || link.Host != base.Host {
+			continue
+		}
+		link.Fragment = ""
+
+		rel := strings.TrimPrefix(link.Path, dir)
+		if !strings.HasPrefix(link.Path, dir) || rel == "" || strings.HasSuffix(rel, "/") || containsDotDot(rel) {
+			continue
+		}
+		if seen[rel] {
+			continue
+		}
+		seen[rel] = true
+
+		files = append(files, indexFile{Path: rel, URL: link})
+	}
+}
+
 // parseMeta looks for the first meta tag in the given reader that
 // will give us the source URL.
 func (g *HttpGetter) parseMeta(r io.Reader) (string, error) {
@@ -0,0 +1,189 @@
+package getter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// defaultChunkMinSize is the size files must have to be downloaded in
+// chunks if the HttpGetter has no ChunkMinSize.
+const defaultChunkMinSize = 1 << 20
+
+// getChunks downloads the file at u to dst in g.Chunks concurrent ranged
+// requests. It returns false, having downloaded nothing, if the file is too
+// small or the server doesn't support ranges, so that the file is
+// downloaded in a single request instead.
+func (g *HttpGetter) getChunks(dst string, u *url.URL) (bool, error) {
+	size, err := g.rangeSize(u)
+	if err != nil {
+		return false, err
+	}
+	minSize := g.ChunkMinSize
+	if minSize <= 0 {
+		minSize = defaultChunkMinSize
+	}
+	if size < minSize {
+		return false, nil
+	}
+	g.logger().Printf("downloading '%s' in %d chunks", redactURL(u.String()), g.Chunks)
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return true, err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return true, err
+	}
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		return true, err
+	}
+
+	// The rate limit is shared by the chunks
+	rate := g.rateLimit()
+	if rate > 0 {
+		rate = rate/int64(g.Chunks) + 1
+	}
+
+	var lock sync.Mutex
+	var read int64
+	progress := func(n int64) {
+		lock.Lock()
+		defer lock.Unlock()
+		read += n
+		g.progress(u, read, size)
+	}
+
+	// The first chunk to fail cancels the others, as the file is discarded
+	ctx, cancel := context.WithCancel(g.ctx())
+	defer cancel()
+	var chunkErr error
+	fail := func(err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if chunkErr == nil {
+			chunkErr = err
+			cancel()
+		}
+	}
+
+	chunkSize := (size + int64(g.Chunks) - 1) / int64(g.Chunks)
+	var wg sync.WaitGroup
+	for i := 0; i < g.Chunks; i++ {
+		start := int64(i) * chunkSize
+		if start >= size {
+			break
+		}
+		end := start + chunkSize - 1
+		if end >= size {
+			end = size - 1
+		}
+
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			w := &chunkWriter{f: f, off: start, progress: progress}
+			if err := g.getChunk(ctx, w, u, start, end, rate); err != nil {
+				fail(err)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	if chunkErr != nil {
+		// The file has holes where chunks are missing, don't leave it
+		// behind to be mistaken for a partial download
+		f.Close()
+		os.Remove(dst)
+		return true, chunkErr
+	}
+
+	// Hash the file for the client now that it is complete
+	if h := g.outputHash(dst); h != nil {
+		return true, hashFile(dst, h)
+	}
+	return true, nil
+}
+
+// rangeSize returns the size of the file at u if the server supports
+// requesting ranges of it, or -1 otherwise, including if the HEAD request
+// probing it fails, so that the file is downloaded in a single request.
+func (g *HttpGetter) rangeSize(u *url.URL) (int64, error) {
+	if g.Netrc {
+		// Add auth from netrc if we can
+		if err := addAuthFromNetrc(u); err != nil {
+			return 0, err
+		}
+	}
+
+	g.initClient()
+	resp, err := g.do("HEAD", u)
+	if err != nil {
+		g.logger().Printf("error probing '%s' for ranges, downloading it in a single request: %s", redactURL(u.String()), err)
+		return -1, nil
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 200 || resp.Header.Get("Accept-Ranges") != "bytes" || resp.Header.Get("Content-Encoding") != "" {
+		return -1, nil
+	}
+	return resp.ContentLength, nil
+}
+
+// getChunk downloads the bytes start to end, inclusive, of the file at u to
+// w, at up to rate bytes per second if rate is positive, until ctx is done.
+func (g *HttpGetter) getChunk(ctx context.Context, w io.Writer, u *url.URL, start, end, rate int64) error {
+	req, err := g.newRequest("GET", u)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	req.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return &badResponseError{StatusCode: resp.StatusCode}
+	}
+	if cr := resp.Header.Get("Content-Range"); !strings.HasPrefix(cr, fmt.Sprintf("bytes %d-%d/", start, end)) {
+		return fmt.Errorf("unexpected Content-Range for bytes %d-%d: %q", start, end, cr)
+	}
+
+	body := newRateLimitReader(ctx, resp.Body, rate)
+	n, err := io.Copy(w, io.LimitReader(body, end-start+1))
+	if err != nil {
+		return err
+	}
+	if n != end-start+1 {
+		return fmt.Errorf("error downloading bytes %d-%d: %s", start, end, io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
+// chunkWriter writes a chunk of a file at its offset, reporting the bytes
+// written to progress.
+type chunkWriter struct {
+	f        *os.File
+	off      int64
+	progress func(n int64)
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.off)
+	w.off += int64(n)
+	w.progress(int64(n))
+	return n, err
+}
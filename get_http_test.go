@@ -1,18 +1,32 @@
 package getter
 
 import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestHttpGetter_impl(t *testing.T) {
 	var _ Getter = new(HttpGetter)
+	var _ Putter = new(HttpGetter)
 }
 
 func TestHttpGetter_header(t *testing.T) {
@@ -153,6 +167,890 @@ func TestHttpGetter_file(t *testing.T) {
 	assertContents(t, dst, "Hello\n")
 }
 
+func TestHttpGetter_ClientMode(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Path string
+		Mode ClientMode
+	}{
+		{"/file", ClientModeFile},
+		{"/dir/", ClientModeDir},
+		{"/archive", ClientModeDir},
+		{"/archive-disposition", ClientModeDir},
+		{"/archive-no-head", ClientModeFile},
+		{"/none-such", ClientModeFile},
+	}
+
+	for _, tc := range cases {
+		g := new(HttpGetter)
+
+		var u url.URL
+		u.Scheme = "http"
+		u.Host = ln.Addr().String()
+		u.Path = tc.Path
+
+		mode, err := g.ClientMode(&u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Path, err)
+		}
+		if mode != tc.Mode {
+			t.Fatalf("%s: bad mode: %d", tc.Path, mode)
+		}
+	}
+}
+
+func TestHttpGetter_archive(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	for _, path := range []string{"/archive", "/archive-disposition"} {
+		g := new(HttpGetter)
+		dst := tempDir(t)
+
+		var u url.URL
+		u.Scheme = "http"
+		u.Host = ln.Addr().String()
+		u.Path = path
+
+		// Get it!
+		if err := g.Get(dst, &u); err != nil {
+			t.Fatalf("%s: err: %s", path, err)
+		}
+
+		// Verify the main file exists
+		mainPath := filepath.Join(dst, "main.tf")
+		if _, err := os.Stat(mainPath); err != nil {
+			t.Fatalf("%s: err: %s", path, err)
+		}
+	}
+}
+
+func TestHttpGetter_archiveClient(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:  fmt.Sprintf("http://%s/archive", ln.Addr().String()),
+		Dst:  dst,
+		Mode: ClientModeAny,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	mainPath := filepath.Join(dst, "main.tf")
+	if _, err := os.Stat(mainPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestHttpGetter_streamArchives(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	// The temporary directory doesn't exist, so only archives that aren't
+	// saved to a temporary file first can be extracted
+	tmp := tempDir(t)
+	for _, path := range []string{"/archive?archive=tar.gz", "/archive"} {
+		dst := tempDir(t)
+		client := &Client{
+			Src:            fmt.Sprintf("http://%s%s", ln.Addr().String(), path),
+			Dst:            dst,
+			Mode:           ClientModeDir,
+			TempDir:        tmp,
+			StreamArchives: true,
+		}
+		if err := client.Get(); err != nil {
+			t.Fatalf("%s: err: %s", path, err)
+		}
+		assertContents(t, filepath.Join(dst, "main.tf"), "foo\n")
+	}
+
+	// Archives that are kept are still saved first
+	client := &Client{
+		Src:            fmt.Sprintf("http://%s/archive?archive=tar.gz", ln.Addr().String()),
+		Dst:            tempDir(t),
+		Mode:           ClientModeDir,
+		TempDir:        tmp,
+		StreamArchives: true,
+		KeepArchive:    true,
+	}
+	if err := client.Get(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestHttpGetter_archiveKeepTempOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-gtar")
+		w.Write([]byte("corrupt"))
+	}))
+	defer server.Close()
+
+	td, err := ioutil.TempDir("", "getter")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// The archive that failed to extract is kept, and reported
+	client := &Client{
+		Src:             server.URL + "/archive",
+		Dst:             tempDir(t),
+		Mode:            ClientModeDir,
+		TempDir:         td,
+		TempPattern:     "test-",
+		KeepTempOnError: true,
+	}
+	err = client.Get()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	archive := ""
+	fis, err2 := ioutil.ReadDir(td)
+	if err2 != nil {
+		t.Fatalf("err: %s", err2)
+	}
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), "test-getter") {
+			archive = filepath.Join(td, fi.Name(), "archive")
+		}
+	}
+	if archive == "" {
+		t.Fatalf("expected the archive to be kept, got %d entries", len(fis))
+	}
+	assertContents(t, archive, "corrupt")
+	if !strings.Contains(err.Error(), filepath.Dir(archive)) {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestHttpGetter_resume(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Path      string
+		Existing  string
+		Partial   string
+		Validator string
+		Expected  string
+	}{
+		// The rest of the file is appended to the partial file
+		{"/range", "", "HELLO, ", `"v1"`, "HELLO, world!\n"},
+		{"/range", "", "", "", "Hello, world!\n"},
+		// The file changed since the partial file was downloaded
+		{"/range", "", "HELLO, ", `"v0"`, "Hello, world!\n"},
+		// The partial file has no validator to resume it with
+		{"/range", "", "HELLO, ", "", "Hello, world!\n"},
+		// The partial file is longer than the file, it is downloaded again
+		{"/range", "", "Hello, world!\nfoo\n", `"v1"`, "Hello, world!\n"},
+		// The server doesn't support ranges
+		{"/file", "", "HE", `"v1"`, "Hello\n"},
+		// A file at the destination isn't resumed
+		{"/range", "HELLO", "", "", "Hello, world!\n"},
+	}
+
+	g := &HttpGetter{Resume: true}
+	for _, tc := range cases {
+		dst := tempFile(t)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		for path, contents := range map[string]string{
+			dst:                     tc.Existing,
+			dst + ".part":           tc.Partial,
+			dst + ".part.validator": tc.Validator,
+		} {
+			if contents == "" {
+				continue
+			}
+			if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+
+		u := testURL(fmt.Sprintf("http://%s%s", ln.Addr().String(), tc.Path))
+		if err := g.GetFile(dst, u); err != nil {
+			t.Fatalf("%s %q: err: %s", tc.Path, tc.Partial, err)
+		}
+		assertContents(t, dst, tc.Expected)
+		for _, path := range []string{dst + ".part", dst + ".part.validator"} {
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Fatalf("%s %q: %s wasn't removed: %v", tc.Path, tc.Partial, path, err)
+			}
+		}
+	}
+}
+
+func TestHttpGetter_resumeFailed(t *testing.T) {
+	// The first download fails halfway, and the second one resumes it
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("Range")+" "+r.Header.Get("If-Range"))
+		w.Header().Set("ETag", `"v1"`)
+		if len(requests) == 1 {
+			w.Header().Set("Content-Length", "14")
+			w.Write([]byte("Hello, "))
+			return
+		}
+		testHttpHandlerRange(w, r)
+	}))
+	defer server.Close()
+
+	g := &HttpGetter{Resume: true}
+	dst := tempFile(t)
+	u := testURL(server.URL + "/range")
+	if err := g.GetFile(dst, u); err == nil {
+		t.Fatal("should error")
+	}
+	assertContents(t, dst+".part", "Hello, ")
+	assertContents(t, dst+".part.validator", `"v1"`)
+
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello, world!\n")
+	if expected := []string{" ", `bytes=7- "v1"`}; !reflect.DeepEqual(requests, expected) {
+		t.Fatalf("bad requests: %q", requests)
+	}
+}
+
+func TestHttpGetter_chunks(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Path     string
+		Requests []string
+		Expected string
+	}{
+		{"/range", []string{"HEAD", "GET", "GET", "GET", "GET"}, "Hello, world!\n"},
+		// The server doesn't support ranges
+		{"/file", []string{"HEAD", "GET"}, "Hello\n"},
+	}
+
+	for _, tc := range cases {
+		var lock sync.Mutex
+		var requests []string
+		var read int64
+		g := &HttpGetter{Chunks: 4, ChunkMinSize: 1}
+		g.SetClient(&Client{Hooks: &Hooks{
+			RequestStarted: func(method string, u *url.URL) {
+				lock.Lock()
+				defer lock.Unlock()
+				requests = append(requests, method)
+			},
+			Progress: func(u *url.URL, n, total int64) {
+				read = n
+			},
+		}})
+
+		dst := tempFile(t)
+		u := testURL(fmt.Sprintf("http://%s%s", ln.Addr().String(), tc.Path))
+		if err := g.GetFile(dst, u); err != nil {
+			t.Fatalf("%s: err: %s", tc.Path, err)
+		}
+		assertContents(t, dst, tc.Expected)
+		if !reflect.DeepEqual(requests, tc.Requests) {
+			t.Fatalf("%s: bad requests: %v", tc.Path, requests)
+		}
+		if read != int64(len(tc.Expected)) {
+			t.Fatalf("%s: bad progress: %d", tc.Path, read)
+		}
+	}
+}
+
+func TestHttpGetter_chunksHeadFailed(t *testing.T) {
+	// The server doesn't allow HEAD requests, or drops them
+	for _, status := range []int{405, 0} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method != "HEAD":
+				testHttpHandlerRange(w, r)
+			case status != 0:
+				w.WriteHeader(status)
+			default:
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					conn.Close()
+				}
+			}
+		}))
+
+		g := &HttpGetter{Chunks: 4, ChunkMinSize: 1}
+		dst := tempFile(t)
+		if err := g.GetFile(dst, testURL(server.URL+"/range")); err != nil {
+			t.Fatalf("%d: err: %s", status, err)
+		}
+		assertContents(t, dst, "Hello, world!\n")
+		server.Close()
+	}
+}
+
+func TestHttpGetter_chunksFailed(t *testing.T) {
+	// The first chunk fails, while the others stall until they're cancelled
+	content := "Hello, world!\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		if r.Method == "HEAD" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+		var start, end int
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		if start == 0 {
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	g := &HttpGetter{Chunks: 4, ChunkMinSize: 1}
+	dst := tempFile(t)
+	start := time.Now()
+	if err := g.GetFile(dst, testURL(server.URL+"/range")); err == nil {
+		t.Fatal("should error")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("the other chunks weren't cancelled, took %s", d)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("expected the file to be removed, got: %v", err)
+	}
+}
+
+func TestHttpGetter_fileContentType(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	g := &HttpGetter{DecompressContentType: true}
+	dst := tempFile(t)
+
+	var u url.URL
+	u.Scheme = "http"
+	u.Host = ln.Addr().String()
+	u.Path = "/gzip/file.txt"
+
+	// Get it!
+	if err := g.GetFile(dst, &u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "foo\n")
+}
+
+func TestHttpGetter_fileContentTypeDisabled(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	g := new(HttpGetter)
+	dst := tempFile(t)
+
+	var u url.URL
+	u.Scheme = "http"
+	u.Host = ln.Addr().String()
+	u.Path = "/gzip/file.txt"
+
+	// Get it!
+	if err := g.GetFile(dst, &u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected, err := ioutil.ReadFile(filepath.Join(fixtureDir, "decompress-gz", "single.gz"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, string(expected))
+}
+
+func TestHttpGetter_fileContentTypeExtension(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	g := &HttpGetter{DecompressContentType: true}
+	dst := tempFile(t)
+
+	var u url.URL
+	u.Scheme = "http"
+	u.Host = ln.Addr().String()
+	u.Path = "/gzip/file.gz"
+
+	// Get it! The extension matches, so decompression is left to the client.
+	if err := g.GetFile(dst, &u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected, err := ioutil.ReadFile(filepath.Join(fixtureDir, "decompress-gz", "single.gz"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, string(expected))
+}
+
+func TestHttpGetter_getReader(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	g := new(HttpGetter)
+
+	var u url.URL
+	u.Scheme = "http"
+	u.Host = ln.Addr().String()
+	u.Path = "/file"
+
+	rc, err := g.GetReader(&u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer rc.Close()
+
+	actual, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(actual) != "Hello\n" {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestHttpGetter_getReaderContentType(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	g := &HttpGetter{DecompressContentType: true}
+
+	var u url.URL
+	u.Scheme = "http"
+	u.Host = ln.Addr().String()
+	u.Path = "/gzip/file.txt"
+
+	rc, err := g.GetReader(&u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer rc.Close()
+
+	actual, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(actual) != "foo\n" {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestHttpGetter_contentDispositionFilename(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Path     string
+		Filename string
+	}{
+		{"/disposition", "hello.txt"},
+		{"/disposition-traversal", "evil.txt"},
+		{"/file", "file"},
+	}
+
+	for _, tc := range cases {
+		dst := tempDir(t)
+		client := &Client{
+			Src:  fmt.Sprintf("http://%s%s", ln.Addr().String(), tc.Path),
+			Dst:  dst,
+			Mode: ClientModeAny,
+			Getters: map[string]Getter{
+				"http": &HttpGetter{ContentDispositionFilename: true},
+			},
+		}
+		if err := client.Get(); err != nil {
+			t.Fatalf("%s: err: %s", tc.Path, err)
+		}
+		assertContents(t, filepath.Join(dst, tc.Filename), "Hello\n")
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Output string
+	}{
+		{"hello.txt", "hello.txt"},
+		{"../../evil.txt", "evil.txt"},
+		{"/etc/passwd", "passwd"},
+		{`..\evil.txt`, "evil.txt"},
+		{"..", ""},
+		{"/", ""},
+		{"", ""},
+	}
+
+	for _, tc := range cases {
+		if actual := sanitizeFilename(tc.Input); actual != tc.Output {
+			t.Fatalf("%q: bad: %q", tc.Input, actual)
+		}
+	}
+}
+
+func TestHttpGetter_validate(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	g := new(HttpGetter)
+
+	var u url.URL
+	u.Scheme = "http"
+	u.Host = ln.Addr().String()
+	u.Path = "/file"
+
+	if err := g.Validate(&u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	u.Path = "/missing"
+	if err := g.Validate(&u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestHttpGetter_contentEncoding(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	single, err := ioutil.ReadFile(filepath.Join(fixtureDir, "decompress-gz", "single.gz"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		Path     string
+		Expected string
+	}{
+		{"/encoding/gzip", `{"foo":"bar"}`},
+		{"/encoding/deflate", `{"foo":"bar"}`},
+		{"/encoding/file.gz", string(single)},
+	}
+
+	for _, tc := range cases {
+		g := new(HttpGetter)
+		dst := tempFile(t)
+
+		var u url.URL
+		u.Scheme = "http"
+		u.Host = ln.Addr().String()
+		u.Path = tc.Path
+
+		if err := g.GetFile(dst, &u); err != nil {
+			t.Fatalf("%s: err: %s", tc.Path, err)
+		}
+		assertContents(t, dst, tc.Expected)
+	}
+}
+
+func TestHttpGetter_responseHeaderTimeout(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	g := &HttpGetter{ResponseHeaderTimeout: 100 * time.Millisecond}
+	dst := tempFile(t)
+
+	var u url.URL
+	u.Scheme = "http"
+	u.Host = ln.Addr().String()
+
+	// Slow response headers fail fast
+	u.Path = "/slow-header"
+	if err := g.GetFile(dst, &u); err == nil {
+		t.Fatal("should error")
+	}
+
+	// A slow body isn't bounded by the timeout
+	u.Path = "/slow-body"
+	if err := g.GetFile(dst, &u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestHttpGetter_tlsClientConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(testHttpHandlerFile))
+	defer server.Close()
+
+	u := testURL(server.URL + "/file")
+	dst := tempFile(t)
+
+	// The self-signed certificate of the server isn't trusted by default
+	if err := new(HttpGetter).GetFile(dst, u); err == nil {
+		t.Fatal("should error")
+	}
+
+	g := &HttpGetter{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestHttpGetter_keepAlive(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ln.Close()
+
+	var lock sync.Mutex
+	conns := 0
+	server := http.Server{
+		Handler: http.HandlerFunc(testHttpHandlerFile),
+		ConnState: func(c net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				lock.Lock()
+				conns++
+				lock.Unlock()
+			}
+		},
+	}
+	go server.Serve(ln)
+
+	for _, g := range []*HttpGetter{new(HttpGetter), {MaxIdleConnsPerHost: 4}} {
+		lock.Lock()
+		conns = 0
+		lock.Unlock()
+
+		var u url.URL
+		u.Scheme = "http"
+		u.Host = ln.Addr().String()
+		u.Path = "/file"
+
+		for i := 0; i < 3; i++ {
+			if err := g.GetFile(tempFile(t), &u); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+
+		lock.Lock()
+		if conns != 1 {
+			t.Fatalf("expected the connection to be reused, got %d connections", conns)
+		}
+		lock.Unlock()
+	}
+}
+
+func TestHttpGetter_keepAliveClient(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ln.Close()
+
+	var lock sync.Mutex
+	conns := 0
+	server := http.Server{
+		Handler: http.HandlerFunc(testHttpHandlerFile),
+		ConnState: func(c net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				lock.Lock()
+				conns++
+				lock.Unlock()
+			}
+		},
+	}
+	go server.Serve(ln)
+
+	// Clients download with copies of the getter, which share its transport
+	g := &HttpGetter{DialTimeout: time.Second, MaxIdleConnsPerHost: 4}
+	for i := 0; i < 2; i++ {
+		client := &Client{
+			Src:     fmt.Sprintf("http://%s/file", ln.Addr()),
+			Dst:     tempFile(t),
+			Mode:    ClientModeFile,
+			Getters: map[string]Getter{"http": g},
+		}
+		if err := client.Get(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if conns != 1 {
+		t.Fatalf("expected the connection to be reused, got %d connections", conns)
+	}
+}
+
+func TestHttpGetter_userAgent(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Getter   *HttpGetter
+		Expected string
+	}{
+		{new(HttpGetter), DefaultUserAgent},
+		{&HttpGetter{UserAgent: "custom/1.0"}, "custom/1.0"},
+	}
+
+	for _, tc := range cases {
+		dst := tempFile(t)
+
+		var u url.URL
+		u.Scheme = "http"
+		u.Host = ln.Addr().String()
+		u.Path = "/user-agent"
+
+		if err := tc.Getter.GetFile(dst, &u); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		assertContents(t, dst, tc.Expected)
+	}
+}
+
+func TestHttpGetter_putFile(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	src, closer := tempFileContents(t, "Hello\n")
+	defer closer()
+
+	header := http.Header{"X-Token": []string{"secret"}}
+	cases := []struct {
+		Name   string
+		Getter *HttpGetter
+		User   *url.Userinfo
+		Err    bool
+	}{
+		{"auth fields", &HttpGetter{Username: "foo", Password: "bar", Header: header}, nil, false},
+		{"auth in URL", &HttpGetter{Header: header}, url.UserPassword("foo", "bar"), false},
+		{"no header", &HttpGetter{Username: "foo", Password: "bar"}, nil, true},
+		{"no auth", &HttpGetter{Header: header}, nil, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var u url.URL
+			u.Scheme = "http"
+			u.Host = ln.Addr().String()
+			u.Path = "/upload"
+			u.User = tc.User
+
+			err := tc.Getter.PutFile(src, &u)
+			if (err != nil) != tc.Err {
+				t.Fatalf("err: %v", err)
+			}
+		})
+	}
+}
+
+func TestHttpGetter_rateLimit(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	// At half the size per second, the first half is read at once from the
+	// full bucket and the second half takes a second
+	rate := int64(testHttpLargeSize / 2)
+	cases := []struct {
+		Name   string
+		Getter *HttpGetter
+		Client *Client
+	}{
+		{"getter", &HttpGetter{RateLimit: rate}, nil},
+		{"client", new(HttpGetter), &Client{RateLimit: rate}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			if tc.Client != nil {
+				tc.Getter.SetClient(tc.Client)
+			}
+
+			var u url.URL
+			u.Scheme = "http"
+			u.Host = ln.Addr().String()
+			u.Path = "/large"
+
+			dst := tempFile(t)
+			start := time.Now()
+			if err := tc.Getter.GetFile(dst, &u); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			elapsed := time.Since(start)
+
+			fi, err := os.Stat(dst)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if fi.Size() != testHttpLargeSize {
+				t.Fatalf("bad size: %d", fi.Size())
+			}
+			if elapsed < 800*time.Millisecond || elapsed > 3*time.Second {
+				t.Fatalf("expected about 1s at %d bytes/s, took %s", rate, elapsed)
+			}
+		})
+	}
+}
+
+func TestHttpGetter_rateLimitCancel(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	// Cancelling the context aborts waiting for the bucket to refill,
+	// rather than after the wait of up to a second
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	g := new(HttpGetter)
+	g.SetClient(&Client{Ctx: ctx, RateLimit: testHttpLargeSize / 20})
+
+	start := time.Now()
+	if err := g.GetFile(tempFile(t), testURL(fmt.Sprintf("http://%s/large", ln.Addr()))); err == nil {
+		t.Fatal("should error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("took %s to abort", elapsed)
+	}
+}
+
+func TestHttpGetter_autoIndex(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	var u url.URL
+	u.Scheme = "http"
+	u.Host = ln.Addr().String()
+	u.Path = "/index"
+
+	// Directory listings aren't parsed by default
+	if err := new(HttpGetter).Get(tempDir(t), &u); err == nil {
+		t.Fatal("should error")
+	}
+
+	g := &HttpGetter{AutoIndex: true}
+	dst := tempDir(t)
+	if err := g.Get(dst, &u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		assertContents(t, filepath.Join(dst, name), name+"\n")
+	}
+
+	// Only the files of the listed directory on the same host are downloaded
+	infos, err := ioutil.ReadDir(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(infos) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(infos))
+	}
+}
+
 func TestHttpGetter_auth(t *testing.T) {
 	ln := testHttpServer(t)
 	defer ln.Close()
@@ -178,6 +1076,57 @@ func TestHttpGetter_auth(t *testing.T) {
 	}
 }
 
+func TestHttpGetter_authFields(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	g := &HttpGetter{Username: "foo", Password: "bar"}
+	dst := tempDir(t)
+
+	var u url.URL
+	u.Scheme = "http"
+	u.Host = ln.Addr().String()
+	u.Path = "/meta-auth"
+
+	// Get it!
+	if err := g.Get(dst, &u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Verify the main file exists
+	mainPath := filepath.Join(dst, "main.tf")
+	if _, err := os.Stat(mainPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestHttpGetter_authClient(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:      fmt.Sprintf("http://%s/meta-auth", ln.Addr().String()),
+		Dst:      dst,
+		Mode:     ClientModeDir,
+		Username: "foo",
+		Password: "bar",
+		Getters: map[string]Getter{
+			"http": new(HttpGetter),
+			"file": new(FileGetter),
+		},
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Verify the main file exists
+	mainPath := filepath.Join(dst, "main.tf")
+	if _, err := os.Stat(mainPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func TestHttpGetter_authNetrc(t *testing.T) {
 	ln := testHttpServer(t)
 	defer ln.Close()
@@ -247,11 +1196,25 @@ func testHttpServer(t *testing.T) net.Listener {
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/file", testHttpHandlerFile)
+	mux.HandleFunc("/range", testHttpHandlerRange)
 	mux.HandleFunc("/header", testHttpHandlerHeader)
 	mux.HandleFunc("/meta", testHttpHandlerMeta)
 	mux.HandleFunc("/meta-auth", testHttpHandlerMetaAuth)
 	mux.HandleFunc("/meta-subdir", testHttpHandlerMetaSubdir)
 	mux.HandleFunc("/meta-subdir-glob", testHttpHandlerMetaSubdirGlob)
+	mux.HandleFunc("/archive", testHttpHandlerArchive)
+	mux.HandleFunc("/archive-disposition", testHttpHandlerArchiveDisposition)
+	mux.HandleFunc("/archive-no-head", testHttpHandlerArchiveNoHead)
+	mux.HandleFunc("/disposition", testHttpHandlerDisposition)
+	mux.HandleFunc("/disposition-traversal", testHttpHandlerDispositionTraversal)
+	mux.HandleFunc("/gzip/", testHttpHandlerGzip)
+	mux.HandleFunc("/user-agent", testHttpHandlerUserAgent)
+	mux.HandleFunc("/upload", testHttpHandlerUpload)
+	mux.HandleFunc("/large", testHttpHandlerLarge)
+	mux.HandleFunc("/index/", testHttpHandlerIndex)
+	mux.HandleFunc("/encoding/", testHttpHandlerEncoding)
+	mux.HandleFunc("/slow-header", testHttpHandlerSlowHeader)
+	mux.HandleFunc("/slow-body", testHttpHandlerSlowBody)
 
 	var server http.Server
 	server.Handler = mux
@@ -264,6 +1227,11 @@ func testHttpHandlerFile(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Hello\n"))
 }
 
+func testHttpHandlerRange(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("ETag", `"v1"`)
+	http.ServeContent(w, r, "range", time.Time{}, strings.NewReader("Hello, world!\n"))
+}
+
 func testHttpHandlerHeader(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("X-Terraform-Get", testModuleURL("basic").String())
 	w.WriteHeader(200)
@@ -296,10 +1264,143 @@ func testHttpHandlerMetaSubdirGlob(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(fmt.Sprintf(testHttpMetaStr, testModuleURL("basic//sub*").String())))
 }
 
+func testHttpHandlerArchive(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-gtar")
+	http.ServeFile(w, r, filepath.Join(fixtureDir, "archive.tar.gz"))
+}
+
+func testHttpHandlerArchiveDisposition(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="archive.tar.gz"`)
+	http.ServeFile(w, r, filepath.Join(fixtureDir, "archive.tar.gz"))
+}
+
+func testHttpHandlerArchiveNoHead(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "HEAD" {
+		w.WriteHeader(405)
+		return
+	}
+	testHttpHandlerArchive(w, r)
+}
+
+func testHttpHandlerDisposition(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Disposition", `attachment; filename="hello.txt"`)
+	w.Write([]byte("Hello\n"))
+}
+
+func testHttpHandlerDispositionTraversal(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Disposition", `attachment; filename="../../evil.txt"`)
+	w.Write([]byte("Hello\n"))
+}
+
+func testHttpHandlerGzip(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/gzip")
+	http.ServeFile(w, r, filepath.Join(fixtureDir, "decompress-gz", "single.gz"))
+}
+
+// testHttpHandlerEncoding serves JSON with the Content-Encoding of the
+// last path segment, or a gzip file with "Content-Encoding: gzip" as some
+// servers do.
+func testHttpHandlerEncoding(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, ".gz") {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Encoding", "gzip")
+		http.ServeFile(w, r, filepath.Join(fixtureDir, "decompress-gz", "single.gz"))
+		return
+	}
+
+	var wc io.WriteCloser
+	switch encoding := path.Base(r.URL.Path); encoding {
+	case "gzip":
+		wc = gzip.NewWriter(w)
+	case "deflate":
+		wc = zlib.NewWriter(w)
+	default:
+		w.WriteHeader(404)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Encoding", path.Base(r.URL.Path))
+	wc.Write([]byte(`{"foo":"bar"}`))
+	wc.Close()
+}
+
+func testHttpHandlerUserAgent(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.UserAgent()))
+}
+
+// testHttpHandlerIndex serves an Apache style directory listing at /index/,
+// and the files below it.
+func testHttpHandlerIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/index/" {
+		fmt.Fprintf(w, "%s\n", path.Base(r.URL.Path))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	fmt.Fprintf(w, testHttpIndexStr, r.Host)
+}
+
+// testHttpLargeSize is the size of the file served by testHttpHandlerLarge.
+const testHttpLargeSize = 64 * 1024
+
+func testHttpHandlerLarge(w http.ResponseWriter, r *http.Request) {
+	w.Write(make([]byte, testHttpLargeSize))
+}
+
+// testHttpHandlerUpload accepts uploads of "Hello\n" authorized with basic
+// auth for foo:bar and the X-Token header.
+func testHttpHandlerUpload(w http.ResponseWriter, r *http.Request) {
+	user, pass, _ := r.BasicAuth()
+	if r.Method != "PUT" || user != "foo" || pass != "bar" || r.Header.Get("X-Token") != "secret" {
+		w.WriteHeader(403)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil || string(body) != "Hello\n" {
+		w.WriteHeader(400)
+		return
+	}
+	w.WriteHeader(201)
+}
+
+func testHttpHandlerSlowHeader(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(500 * time.Millisecond)
+	w.Write([]byte("Hello\n"))
+}
+
+func testHttpHandlerSlowBody(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(200)
+	w.(http.Flusher).Flush()
+	time.Sleep(300 * time.Millisecond)
+	w.Write([]byte("Hello\n"))
+}
+
 func testHttpHandlerNone(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(testHttpNoneStr))
 }
 
+const testHttpIndexStr = `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 3.2 Final//EN">
+<html>
+ <head>
+  <title>Index of /index</title>
+ </head>
+ <body>
+<h1>Index of /index</h1>
+<pre><img src="/icons/blank.gif" alt="Icon "> <a href="?C=N;O=D">Name</a>                    <a href="?C=M;O=A">Last modified</a>
+<hr><img src="/icons/back.gif" alt="[PARENTDIR]"> <a href="/">Parent Directory</a>
+<img src="/icons/text.gif" alt="[TXT]"> <a href="a.txt">a.txt</a>&nbsp;                   2018-01-01 00:00    6
+<img src="/icons/text.gif" alt="[TXT]"> <a href="/index/b.txt">b.txt</a>                   2018-01-01 00:00    6
+<img src="/icons/text.gif" alt="[TXT]"> <a href="http://%s/index/c.txt">c.txt</a>           2018-01-01 00:00    6
+<img src="/icons/folder.gif" alt="[DIR]"> <a href="sub/">sub/</a>                        2018-01-01 00:00    -
+<img src="/icons/text.gif" alt="[TXT]"> <a href="../other.txt">other.txt</a>
+<img src="/icons/text.gif" alt="[TXT]"> <a href="http://example.com/index/d.txt">d.txt</a>
+<hr></pre>
+</body></html>
+`
+
 const testHttpMetaStr = `
 <html>
 <head>
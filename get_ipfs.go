@@ -0,0 +1,237 @@
+package getter
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IpfsGetter is a Getter implementation that will download a file, or a
+// UnixFS directory, from IPFS.
+//
+// URLs take the form ipfs://CID/path. The content is fetched through the
+// HTTP API of a local IPFS daemon, or through the gateway given by the
+// gateway query parameter or Gateway, if set, Ex.
+// ipfs://CID/path?gateway=https://ipfs.io. Directories are downloaded as a
+// tar stream, from the get API or with ?format=tar from the gateway.
+type IpfsGetter struct {
+	getter
+
+	// API is the URL of the HTTP API of the IPFS daemon. This defaults to
+	// http://127.0.0.1:5001 if left unset.
+	API string
+
+	// Gateway is the URL of the IPFS gateway to use instead of the daemon,
+	// Ex. https://ipfs.io, if set.
+	Gateway string
+
+	// Client is the http.Client to use for requests to the daemon or
+	// gateway. This defaults to the client shared with the HttpGetter if
+	// left unset.
+	Client *http.Client
+}
+
+func (g *IpfsGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	resp, err := g.request(u, true)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// The first entry of the archive is the file or directory itself
+	tarR := tar.NewReader(resp.Body)
+	for {
+		hdr, err := tarR.Next()
+		if err != nil {
+			return 0, fmt.Errorf("error reading the archive of %s: %s", u, err)
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader || hdr.Typeflag == tar.TypeXHeader {
+			continue
+		}
+		if hdr.FileInfo().IsDir() {
+			return ClientModeDir, nil
+		}
+		return ClientModeFile, nil
+	}
+}
+
+func (g *IpfsGetter) GetFilename(u *url.URL) (string, error) {
+	return "", nil
+}
+
+func (g *IpfsGetter) Get(dst string, u *url.URL) error {
+	// Remove destination if it already exists
+	_, err := os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	resp, err := g.request(u, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	tarR := tar.NewReader(g.rateLimitReader(resp.Body))
+	for {
+		hdr, err := tarR.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading the archive of %s: %s", u, err)
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader || hdr.Typeflag == tar.TypeXHeader {
+			continue
+		}
+
+		// Entries are below the directory itself, named after the CID or
+		// the last element of the path
+		parts := strings.SplitN(strings.TrimPrefix(hdr.Name, "/"), "/", 2)
+		if len(parts) == 1 || parts[1] == "" {
+			if !hdr.FileInfo().IsDir() {
+				return fmt.Errorf("%s is not a directory", u)
+			}
+			continue
+		}
+		if containsDotDot(parts[1]) {
+			return fmt.Errorf("entry contains '..': %s", hdr.Name)
+		}
+
+		local := filepath.Join(dst, filepath.FromSlash(parts[1]))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(local, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := g.writeFile(local, tarR); err != nil {
+				return err
+			}
+		default:
+			// Symlinks could point outside of dst, they're skipped
+		}
+	}
+}
+
+func (g *IpfsGetter) GetFile(dst string, u *url.URL) error {
+	resp, err := g.request(u, false)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return g.writeFile(dst, g.rateLimitReader(resp.Body))
+}
+
+// writeFile writes the contents of r to the file dst.
+func (g *IpfsGetter) writeFile(dst string, r io.Reader) error {
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if h := g.outputHash(dst); h != nil {
+		w = io.MultiWriter(f, h)
+	}
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// request requests the content of u from the gateway or the daemon, as a
+// tar archive if archive is true.
+func (g *IpfsGetter) request(u *url.URL, archive bool) (*http.Response, error) {
+	if g.Client == nil {
+		g.Client = httpClient
+	}
+
+	p, err := ipfsPath(u)
+	if err != nil {
+		return nil, err
+	}
+
+	gateway := u.Query().Get("gateway")
+	if gateway == "" {
+		gateway = g.Gateway
+	}
+
+	var req *http.Request
+	if gateway != "" {
+		rawURL := strings.TrimSuffix(gateway, "/") + (&url.URL{Path: p}).EscapedPath()
+		if archive {
+			rawURL += "?format=tar"
+		}
+		req, err = http.NewRequest("GET", rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if archive {
+			req.Header.Set("Accept", "application/x-tar")
+		}
+	} else {
+		api := g.API
+		if api == "" {
+			api = "http://127.0.0.1:5001"
+		}
+		command := "cat"
+		if archive {
+			command = "get"
+		}
+
+		// The daemon API only accepts POST requests
+		q := url.Values{}
+		q.Set("arg", p)
+		rawURL := fmt.Sprintf("%s/api/v0/%s?%s", strings.TrimSuffix(api, "/"), command, q.Encode())
+		req, err = http.NewRequest("POST", rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	req = req.WithContext(g.ctx())
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("bad response code fetching %s: %d", p, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// ipfsPath returns the /ipfs/CID/path path of u.
+func ipfsPath(u *url.URL) (string, error) {
+	if u.Scheme != "ipfs" {
+		return "", fmt.Errorf("unsupported scheme for IPFS: %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("URL is not a valid IPFS URL, it has no CID: %s", u)
+	}
+	return path.Clean("/ipfs/" + u.Host + "/" + u.Path), nil
+}
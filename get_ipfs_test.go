@@ -0,0 +1,205 @@
+package getter
+
+import (
+	"archive/tar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestIpfsGetter_impl(t *testing.T) {
+	var _ Getter = new(IpfsGetter)
+}
+
+func TestIpfsGetter_file(t *testing.T) {
+	ln := testIpfsServer(t)
+	defer ln.Close()
+
+	g := &IpfsGetter{API: fmt.Sprintf("http://%s", ln.Addr())}
+	u := testURL("ipfs://QmFile")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeFile {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempFile(t)
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+
+	if err := g.Get(tempDir(t), u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestIpfsGetter_dir(t *testing.T) {
+	ln := testIpfsServer(t)
+	defer ln.Close()
+
+	g := &IpfsGetter{API: fmt.Sprintf("http://%s", ln.Addr())}
+	u := testURL("ipfs://QmDir")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempDir(t)
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "# Hello\n")
+	assertContents(t, filepath.Join(dst, "sub", "foo.txt"), "foo\n")
+
+	// A path below the CID
+	dst = tempFile(t)
+	if err := g.GetFile(dst, testURL("ipfs://QmDir/sub/foo.txt")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "foo\n")
+
+	if err := g.Get(tempDir(t), testURL("ipfs://QmMissing")); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestIpfsGetter_gateway(t *testing.T) {
+	ln := testIpfsServer(t)
+	defer ln.Close()
+
+	gateway := fmt.Sprintf("http://%s/gateway", ln.Addr())
+	for _, g := range []*IpfsGetter{
+		{Gateway: gateway},
+		{API: "http://127.0.0.1:1"},
+	} {
+		u := testURL("ipfs://QmDir/sub?gateway=" + url.QueryEscape(gateway))
+		if g.Gateway != "" {
+			u = testURL("ipfs://QmDir/sub")
+		}
+
+		mode, err := g.ClientMode(u)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if mode != ClientModeDir {
+			t.Fatalf("bad mode: %d", mode)
+		}
+
+		dst := tempDir(t)
+		if err := g.Get(dst, u); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		assertContents(t, filepath.Join(dst, "foo.txt"), "foo\n")
+	}
+}
+
+func TestIpfsGetter_client(t *testing.T) {
+	ln := testIpfsServer(t)
+	defer ln.Close()
+
+	gateway := url.QueryEscape(fmt.Sprintf("http://%s/gateway", ln.Addr()))
+	dst := tempDir(t)
+	client := &Client{
+		Src:  "ipfs://QmDir?gateway=" + gateway,
+		Dst:  dst,
+		Mode: ClientModeAny,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "# Hello\n")
+}
+
+// testIpfsServer starts a fake IPFS daemon API, and a gateway below
+// /gateway, with the file QmFile and the directory QmDir.
+func testIpfsServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	files := map[string]string{
+		"QmFile":             "Hello\n",
+		"QmDir/main.tf":      "# Hello\n",
+		"QmDir/sub/foo.txt":  "foo\n",
+		"QmDir/sub/bar.json": "{}\n",
+	}
+
+	// serve writes the file at p, or the tar archive of the file or
+	// directory at p
+	serve := func(w http.ResponseWriter, p string, archive bool) {
+		p = strings.TrimPrefix(path.Clean(p), "/ipfs/")
+		if content, ok := files[p]; ok && !archive {
+			fmt.Fprint(w, content)
+			return
+		}
+
+		var names []string
+		for name := range files {
+			if name == p || strings.HasPrefix(name, p+"/") {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 || !archive {
+			w.WriteHeader(404)
+			return
+		}
+		sort.Strings(names)
+
+		tarW := tar.NewWriter(w)
+		defer tarW.Close()
+		root := path.Base(p)
+		if _, ok := files[p]; !ok {
+			tarW.WriteHeader(&tar.Header{Name: root, Typeflag: tar.TypeDir, Mode: 0755})
+		}
+		dirs := map[string]bool{}
+		for _, name := range names {
+			rel := path.Join(root, strings.TrimPrefix(name, p))
+			if dir := path.Dir(rel); dir != root && dir != "." && !dirs[dir] {
+				dirs[dir] = true
+				tarW.WriteHeader(&tar.Header{Name: dir, Typeflag: tar.TypeDir, Mode: 0755})
+			}
+			tarW.WriteHeader(&tar.Header{Name: rel, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(files[name]))})
+			tarW.Write([]byte(files[name]))
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(405)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v0/cat":
+			serve(w, r.URL.Query().Get("arg"), false)
+		case "/api/v0/get":
+			serve(w, r.URL.Query().Get("arg"), true)
+		default:
+			w.WriteHeader(404)
+		}
+	})
+	mux.HandleFunc("/gateway/ipfs/", func(w http.ResponseWriter, r *http.Request) {
+		serve(w, strings.TrimPrefix(r.URL.Path, "/gateway"), r.URL.Query().Get("format") == "tar")
+	})
+
+	var server http.Server
+	server.Handler = mux
+	go server.Serve(ln)
+
+	return ln
+}
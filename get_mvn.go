@@ -1,23 +1,36 @@
 package getter
 
 import (
+	"encoding/hex"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/url"
-	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
 // MvnGetter is a Getter implementation that will download an artifact from maven repository, e.g. Sonatype Nexus,
 // uri format: mvn::http://[username@]hostname[:port]/directoryname[?options]
 type MvnGetter struct {
-	HttpGet HttpGetter
+	httpBackedGetter
+
+	// TempDir is the directory the maven metadata is temporarily downloaded
+	// to. If this is empty, the TempDir of the Client is used, falling back
+	// to the system's temporary directory.
+	TempDir string
 }
 
+// ClientMode returns ClientModeFile, or ClientModeDir when several artifacts
+// are given with repeated 'artifactId' query parameters.
 func (g *MvnGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	if len(u.Query()["artifactId"]) > 1 {
+		return ClientModeDir, nil
+	}
 	return ClientModeFile, nil
 }
 
@@ -34,6 +47,27 @@ func (g *MvnGetter) GetFilename(u *url.URL) (string, error) {
 		return "", fmt.Errorf("query parameter 'version' is required.")
 	}
 
+	// resolve the meta versions and ranges so the filename contains the concrete version
+	if isMvnMetaVersion(version) {
+		groupId := q.Get("groupId")
+		if groupId == "" {
+			return "", fmt.Errorf("query parameter 'groupId' is required.")
+		}
+
+		metaVersion := version
+		err := g.eachRepo(u, func(repo *url.URL) error {
+			artifactUrl, err := mvnArtifactUrl(repo, groupId, artifactId)
+			if err != nil {
+				return err
+			}
+			version, err = g.ResolveVersion(artifactUrl, metaVersion)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
 	classifier := q.Get("classifier")
 
 	artType := q.Get("type")
@@ -51,6 +85,10 @@ func (g *MvnGetter) GetFilename(u *url.URL) (string, error) {
 }
 
 func (g *MvnGetter) Get(dstDir string, u *url.URL) error {
+	if artifactIds := u.Query()["artifactId"]; len(artifactIds) > 1 {
+		return g.getArtifacts(dstDir, u, artifactIds)
+	}
+
 	filename, err := g.GetFilename(u)
 	if err != nil {
 		return err
@@ -64,25 +102,162 @@ func (g *MvnGetter) Get(dstDir string, u *url.URL) error {
 //
 // If the version is a snapshot version, it will get the latest snapshot artifact.
 // Query parameters:
+//   - repo: the base URL of another maven repo to try, in order, if the artifact can't be got from the repo of the url, can be repeated
 //   - groupId: the group id
-//   - artifactId: the artifact id
-//   - version: the artifact version
+//   - artifactId: the artifact id, repeated to get several artifacts of the group into dst as a directory
+//   - version: the artifact version, or 'LATEST' / 'RELEASE' to resolve the version from the maven-metadata.xml, or a
+//     version range, Ex., '[1.2,2.0)', to resolve the highest version of the maven-metadata.xml in the range
 //   - type: the artifact type, default as 'jar'
+//   - classifier: the artifact classifier, Ex., 'sources', 'javadoc' or 'linux-x86_64'
+//   - snapshotFallback: true to get the non-timestamped '<artifactId>-<version>.<type>' of a snapshot version if it has no maven-metadata.xml
+//   - snapshot: the timestamp and build number of the snapshot build to get instead of the latest, Ex., '20171126.202552-6'
+//   - withPom: true to also download the pom of the artifact, as '<artifactId>-<version>.pom' in the same directory
+//   - verify: a checksum type of the client, such as 'sha1' or 'sha256', to verify the artifact against the checksum
+//     file published next to it, or 'none' not to verify it. By default, the first published of the sha256, sha1 and
+//     md5 checksum files is used
+//
 // example url: mvn::http://username@host/mavan/repo/path?groupId=org.example&artifactId=test&version=1.0.0-SNAPSHOT
 func (g *MvnGetter) GetFile(dst string, u *url.URL) error {
+	if artifactIds := u.Query()["artifactId"]; len(artifactIds) > 1 {
+		return g.getArtifacts(dst, u, artifactIds)
+	}
+
+	return g.eachRepo(u, func(repo *url.URL) error {
+		return g.getFile(dst, repo)
+	})
+}
+
+// getFile gets the artifact from the single maven repo of the url.
+func (g *MvnGetter) getFile(dst string, u *url.URL) error {
+	a, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+
+	// the latest snapshot may be redeployed, and its build removed, between
+	// reading the metadata and downloading the artifact, so the metadata is
+	// re-read for a newer build when the artifact is not found
+	for attempt := 1; ; attempt++ {
+		getErr := g.download(dst, a.Url)
+		if getErr == nil {
+			break
+		}
+		if e, ok := getErr.(*badResponseError); !ok || e.StatusCode != 404 || !a.LatestSnapshot {
+			return getErr
+		}
+		if attempt == mvnSnapshotAttempts {
+			return fmt.Errorf(
+				"snapshot metadata moved: %s was not found after re-reading the metadata %d times",
+				a.Url, attempt-1)
+		}
+
+		prev := a.FileVersion
+		if a, err = g.resolve(u); err != nil {
+			return err
+		}
+		if a.FileVersion == prev {
+			return fmt.Errorf("error downloading %s, the latest snapshot in the metadata: %s", a.Url, getErr)
+		}
+		g.logger().Printf("snapshot %s was not found, retrying with %s", prev, a.FileVersion)
+	}
+
+	// verify the artifact against its checksum file, Ex., 'testng-6.13.1.jar.sha256'
+	q := u.Query()
+	switch alg := q.Get("verify"); alg {
+	case "none":
+	case "":
+		if err := g.verifyPublished(dst, a.Url); err != nil {
+			return err
+		}
+	default:
+		if err := g.verify(dst, a.Url, alg); err != nil {
+			return err
+		}
+	}
+
+	// download the pom of the artifact next to it, named after the artifact version
+	if withPom, _ := strconv.ParseBool(q.Get("withPom")); withPom {
+		artifactId := q.Get("artifactId")
+		pomUrl := a.VersionUrl
+		pomUrl.Path = path.Join(pomUrl.Path, artifactId+"-"+a.FileVersion+".pom")
+
+		pomDst := filepath.Join(filepath.Dir(dst), artifactId+"-"+a.Version+".pom")
+		if err := g.download(pomDst, &pomUrl); err != nil {
+			return fmt.Errorf("error downloading pom %s: %s", pomUrl.String(), err)
+		}
+	}
+
+	return nil
+}
+
+// getArtifacts gets the artifacts with the given ids, sharing the other query
+// parameters of u, Ex., the groupId and version, into dstDir. Every artifact
+// is attempted, and those that failed are reported together in the error.
+func (g *MvnGetter) getArtifacts(dstDir string, u *url.URL, artifactIds []string) error {
+	var failed []string
+	for _, artifactId := range artifactIds {
+		q := u.Query()
+		q.Set("artifactId", artifactId)
+		artifactUrl := *u
+		artifactUrl.RawQuery = q.Encode()
+
+		if err := g.Get(dstDir, &artifactUrl); err != nil {
+			g.logger().Printf("error getting artifact %s: %s", artifactId, err)
+			failed = append(failed, fmt.Sprintf("%s: %s", artifactId, err))
+			continue
+		}
+		g.logger().Printf("got artifact %s", artifactId)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("error getting %d of %d artifacts:\n  %s",
+			len(failed), len(artifactIds), strings.Join(failed, "\n  "))
+	}
+	return nil
+}
+
+// mvnSnapshotAttempts is the number of times the latest build of a snapshot
+// is resolved and downloaded, when it is not found after being resolved.
+const mvnSnapshotAttempts = 3
+
+// mvnSnapshotRegexp matches the timestamp and build number of a snapshot
+// build, Ex., '20171126.202552-6'.
+var mvnSnapshotRegexp = regexp.MustCompile(`^[0-9]{8}\.[0-9]{6}-[0-9]+$`)
+
+// mvnArtifact is an artifact resolved to the file to download.
+type mvnArtifact struct {
+	// Url is the URL of the artifact file, and VersionUrl the URL of the
+	// directory of its version.
+	Url        *url.URL
+	VersionUrl url.URL
+
+	// Version is the concrete version of the artifact, Ex., '6.13-SNAPSHOT',
+	// and FileVersion the version of its file, Ex., '6.13-20171126.202552-6'.
+	Version     string
+	FileVersion string
+
+	// LatestSnapshot is true if FileVersion is the latest build of a
+	// snapshot, resolved from the maven metadata.
+	LatestSnapshot bool
+}
+
+// resolve resolves the artifact referenced by the query parameters of u to
+// the file to download, using the maven metadata for meta versions and
+// snapshots.
+func (g *MvnGetter) resolve(u *url.URL) (*mvnArtifact, error) {
 	q := u.Query()
 	groupId := q.Get("groupId")
 	if groupId == "" {
-		return fmt.Errorf("query parameter 'groupId' is required.")
+		return nil, fmt.Errorf("query parameter 'groupId' is required.")
 	}
 	artifactId := q.Get("artifactId")
 	if artifactId == "" {
-		return fmt.Errorf("query parameter 'artifactId' is required.")
+		return nil, fmt.Errorf("query parameter 'artifactId' is required.")
 	}
 	// the artifact version, Ex., 6.13.1 or 6.13-SNAPSHOT
 	version := q.Get("version")
 	if version == "" {
-		return fmt.Errorf("query parameter 'version' is required.")
+		return nil, fmt.Errorf("query parameter 'version' is required.")
 	}
 	classifier := q.Get("classifier")
 	artType := q.Get("type")
@@ -91,69 +266,613 @@ func (g *MvnGetter) GetFile(dst string, u *url.URL) error {
 	}
 
 	// construct the real url hits the maven repo
-	artifactUrl, err := url.Parse(u.String())
+	artifactUrl, err := mvnArtifactUrl(u, groupId, artifactId)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	artifactUrl.RawQuery = ""
-	artifactUrl.Path = path.Join(artifactUrl.Path, fmt.Sprintf("/%s/%s/%s", strings.Replace(groupId, ".", "/", -1), artifactId, version))
+
+	// resolve the meta versions and ranges to a concrete version, Ex., 'RELEASE' or '[6.0,7.0)' to '6.13.1'
+	if isMvnMetaVersion(version) {
+		metaVersion := version
+		version, err = g.ResolveVersion(artifactUrl, version)
+		if err != nil {
+			return nil, err
+		}
+		g.logger().Printf("resolved %s version of %s:%s to %s", metaVersion, groupId, artifactId, version)
+	}
+	artifactUrl.Path = path.Join(artifactUrl.Path, version)
 
 	// the artifact file version.
 	//   when the artifact version is a snapshot version, the artifact file version will be expanded to the latest snapshot version, Ex., '6.13-20171126.202552-6'
 	artifactFileVer := version
-	if strings.HasSuffix(version, "-SNAPSHOT") {
+	latestSnapshot := false
+	if snapshot := q.Get("snapshot"); snapshot != "" {
+		// a pinned snapshot build, Ex., '20171126.202552-6'
+		if !strings.HasSuffix(version, "-SNAPSHOT") {
+			return nil, fmt.Errorf("query parameter 'snapshot' requires a snapshot version, got '%s'", version)
+		}
+		if !mvnSnapshotRegexp.MatchString(snapshot) {
+			return nil, fmt.Errorf("query parameter 'snapshot' must be formatted as 'yyyyMMdd.HHmmss-buildNumber', got '%s'", snapshot)
+		}
+		artifactFileVer = strings.TrimSuffix(version, "SNAPSHOT") + snapshot
+	} else if strings.HasSuffix(version, "-SNAPSHOT") {
 		// get the latest snapshot
 		snapshotVer, err := g.ParseLastestSnapshotVersion(artifactUrl)
 		if err != nil {
-			return err
+			// some repositories don't publish metadata for non-unique snapshots, but serve them as is
+			fallback, _ := strconv.ParseBool(q.Get("snapshotFallback"))
+			if e, ok := err.(*badResponseError); !ok || e.StatusCode != 404 || !fallback {
+				return nil, err
+			}
+			g.logger().Printf("no metadata for snapshot %s of %s:%s, falling back to the non-timestamped artifact", version, groupId, artifactId)
+			snapshotVer = version
+		} else {
+			g.logger().Printf("resolved snapshot %s of %s:%s to %s", version, groupId, artifactId, snapshotVer)
+			g.hooks().snapshotResolved(version, snapshotVer)
+			latestSnapshot = true
 		}
-
 		artifactFileVer = snapshotVer
 	}
 
+	a := &mvnArtifact{
+		VersionUrl:     *artifactUrl,
+		Version:        version,
+		FileVersion:    artifactFileVer,
+		LatestSnapshot: latestSnapshot,
+	}
+
 	filename := artifactId + "-" + artifactFileVer
 	if classifier != "" {
 		filename += "-" + classifier
 	}
 	filename += "." + artType
 	artifactUrl.Path = path.Join(artifactUrl.Path, filename)
+	a.Url = artifactUrl
+
+	return a, nil
+}
+
+// Validate resolves the artifact and checks the repository has it, without
+// downloading it.
+func (g *MvnGetter) Validate(u *url.URL) error {
+	return g.eachRepo(u, func(repo *url.URL) error {
+		a, err := g.resolve(repo)
+		if err != nil {
+			return err
+		}
+		artifactUrl, err := g.rewriteURL(a.Url)
+		if err != nil {
+			return err
+		}
+		return g.http().Validate(artifactUrl)
+	})
+}
 
-	return g.HttpGet.GetFile(dst, artifactUrl)
+// download the file at u, rewritten by the URLRewrite hook of the client if any, to dst.
+func (g *MvnGetter) download(dst string, u *url.URL) error {
+	u, err := g.rewriteURL(u)
+	if err != nil {
+		return err
+	}
+	return g.http().GetFile(dst, u)
+}
+
+// mvnRepoUrls returns the urls of the maven repos to try in order, the repo of u followed by those of
+// its 'repo' query parameters, all with the other query parameters of u.
+func mvnRepoUrls(u *url.URL) ([]*url.URL, error) {
+	q := u.Query()
+	repos := q["repo"]
+	q.Del("repo")
+
+	base := *u
+	base.RawQuery = q.Encode()
+	repoUrls := []*url.URL{&base}
+	for _, repo := range repos {
+		repoUrl, err := url.Parse(repo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repo '%s': %s", repo, err)
+		}
+		if repoUrl.Scheme == "" || repoUrl.Host == "" {
+			return nil, fmt.Errorf("query parameter 'repo' must be the full base URL of a maven repo, got '%s'", repo)
+		}
+		repoUrl.RawQuery = base.RawQuery
+		repoUrls = append(repoUrls, repoUrl)
+	}
+	return repoUrls, nil
+}
+
+// eachRepo calls fn with the url of each maven repo of u in order, until it succeeds with one of them.
+// The errors with every repo are reported together if it never does.
+func (g *MvnGetter) eachRepo(u *url.URL, fn func(repo *url.URL) error) error {
+	repoUrls, err := mvnRepoUrls(u)
+	if err != nil {
+		return err
+	}
+	if len(repoUrls) == 1 {
+		return fn(repoUrls[0])
+	}
+
+	var errs []string
+	for _, repoUrl := range repoUrls {
+		// the repo without the query parameters and password, for logging
+		bare := *repoUrl
+		bare.RawQuery = ""
+		repo := redactURL(bare.String())
+
+		err := fn(repoUrl)
+		if err == nil {
+			g.logger().Printf("using maven repo %s", repo)
+			return nil
+		}
+		g.logger().Printf("error using maven repo %s: %s", repo, err)
+		errs = append(errs, fmt.Sprintf("%s: %s", repo, err))
+	}
+	return fmt.Errorf("error getting the artifact from all %d maven repos:\n  %s",
+		len(repoUrls), strings.Join(errs, "\n  "))
+}
+
+// mvnChecksumAlgs are the algorithms of the checksum files the artifacts are verified against by default, in
+// order of preference.
+var mvnChecksumAlgs = []string{"sha256", "sha1", "md5"}
+
+// verifyPublished verifies the downloaded artifact against the first checksum file of mvnChecksumAlgs published next
+// to it, so that truncated or corrupted downloads fail. Artifacts without any checksum file that can be fetched aren't
+// verified, only a checksum that doesn't match is an error.
+func (g *MvnGetter) verifyPublished(dst string, artifactUrl *url.URL) error {
+	for _, alg := range mvnChecksumAlgs {
+		newHash, ok := g.checksummers()[alg]
+		if !ok {
+			continue
+		}
+		sum, err := g.getChecksum(artifactUrl, alg)
+		if err != nil {
+			g.logger().Printf("error getting the %s checksum of %s, skipping it: %s", alg, redactURL(artifactUrl.String()), err)
+			continue
+		}
+		if sum != nil {
+			return checksum(dst, newHash(), sum)
+		}
+	}
+	g.logger().Printf("no checksum is published for %s, not verifying it", redactURL(artifactUrl.String()))
+	return nil
+}
+
+// verify the downloaded artifact against the checksum file of the given algorithm published next to it.
+func (g *MvnGetter) verify(dst string, artifactUrl *url.URL, alg string) error {
+	newHash, ok := g.checksummers()[alg]
+	if !ok {
+		return fmt.Errorf("unsupported verify algorithm '%s'", alg)
+	}
+
+	sum, err := g.getChecksum(artifactUrl, alg)
+	if err != nil {
+		return err
+	}
+	if sum == nil {
+		return fmt.Errorf("no %s checksum is published for %s", alg, redactURL(artifactUrl.String()))
+	}
+	return checksum(dst, newHash(), sum)
+}
+
+// getChecksum gets the checksum in the checksum file of the given algorithm published next to the artifact, Ex.,
+// 'testng-6.13.1.jar.sha256', or nil if there's none. Repositories answering 401 or 403 for checksum files they
+// don't have, like some proxies do, are considered not to publish it.
+func (g *MvnGetter) getChecksum(artifactUrl *url.URL, alg string) ([]byte, error) {
+	sumUrl := *artifactUrl
+	sumUrl.Path += "." + alg
+	rewrittenUrl, err := g.rewriteURL(&sumUrl)
+	if err != nil {
+		return nil, err
+	}
+	sumUrl = *rewrittenUrl
+	httpGet := g.http()
+	if httpGet.Netrc {
+		if err := addAuthFromNetrc(&sumUrl); err != nil {
+			return nil, err
+		}
+	}
+
+	httpGet.initClient()
+	resp, err := httpGet.do("GET", &sumUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+	case 401, 403, 404:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("bad response code fetching %s: %d", redactURL(sumUrl.String()), resp.StatusCode)
+	}
+
+	// the checksum file may contain the filename after the checksum, Ex., '<checksum>  testng-6.13.1.jar'
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty checksum file %s", redactURL(sumUrl.String()))
+	}
+	sum, err := hex.DecodeString(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum in %s: %s", redactURL(sumUrl.String()), err)
+	}
+	return sum, nil
+}
+
+// mvnArtifactUrl returns the url to the artifact in the remote maven repo, Ex., 'https://repo1.maven.org/maven2/org/testng/testng'
+func mvnArtifactUrl(u *url.URL, groupId, artifactId string) (*url.URL, error) {
+	artifactUrl, err := url.Parse(u.String())
+	if err != nil {
+		return nil, err
+	}
+	artifactUrl.RawQuery = ""
+	artifactUrl.Path = path.Join(artifactUrl.Path, fmt.Sprintf("/%s/%s", strings.Replace(groupId, ".", "/", -1), artifactId))
+	return artifactUrl, nil
 }
 
 // get the latest snapshot version by parsig the maven-metadata.xml from remote maven repo.
 //   - artifactVerUrl the url to the artifact version, Ex., 'https://repo1.maven.org/maven2/org/testng/testng/6.13.1/'
 func (g *MvnGetter) ParseLastestSnapshotVersion(artifactVerUrl *url.URL) (string, error) {
-	mvnMetaUrl, err := url.Parse(artifactVerUrl.String())
+	meta, mvnMetaUrl, err := g.getMetadata(artifactVerUrl)
 	if err != nil {
 		return "", err
 	}
-	mvnMetaUrl.Path = path.Join(mvnMetaUrl.Path, "maven-metadata.xml")
 
-	mvnMetaFile, err := ioutil.TempFile("", "maven-metadata")
+	version := meta.Version
+	if version == "" {
+		version = path.Base(artifactVerUrl.Path)
+	}
+	snapshotVer := latestSnapshotVersion(meta, version)
+	if snapshotVer == "" {
+		return "", fmt.Errorf("no snapshot versions in the %s", mvnMetaUrl)
+	}
+	return snapshotVer, nil
+}
+
+// latestSnapshotVersion returns the latest build of the snapshot version in the metadata, Ex., '6.13-20171126.202552-6'.
+//
+// The order of the <snapshotVersion> entries isn't guaranteed across repositories, so the build is
+// constructed from the <snapshot> timestamp and build number when available, otherwise the
+// <snapshotVersion> entry updated last is used.
+func latestSnapshotVersion(meta *Metadata, version string) string {
+	snapshot := meta.Versioning.Snapshot
+	if snapshot.Timestamp != "" && snapshot.BuildNumber != "" {
+		return strings.TrimSuffix(version, "-SNAPSHOT") + "-" + snapshot.Timestamp + "-" + snapshot.BuildNumber
+	}
+
+	var latest *SnapshotVersion
+	vers := meta.Versioning.SnapshotVersions.VersionList
+	for i := range vers {
+		// the updated timestamps are fixed width, Ex., '20171126202552', so they compare as strings
+		if latest == nil || vers[i].Updated > latest.Updated {
+			latest = &vers[i]
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.Value
+}
+
+// isMvnMetaVersion returns true if the version is resolved by ResolveVersion, instead of being a concrete version.
+func isMvnMetaVersion(version string) bool {
+	return version == "LATEST" || version == "RELEASE" || strings.HasPrefix(version, "[") || strings.HasPrefix(version, "(")
+}
+
+// ResolveVersion resolves the meta version 'LATEST' or 'RELEASE', or a version range, Ex., '[1.2,2.0)', to a concrete
+// version by parsing the artifact level maven-metadata.xml from remote maven repo.
+//   - artifactUrl the url to the artifact, Ex., 'https://repo1.maven.org/maven2/org/testng/testng/'
+func (g *MvnGetter) ResolveVersion(artifactUrl *url.URL, metaVersion string) (string, error) {
+	var r mvnVersionRange
+	if metaVersion != "LATEST" && metaVersion != "RELEASE" {
+		var err error
+		if !isMvnMetaVersion(metaVersion) {
+			return "", fmt.Errorf("unsupported meta version '%s', must be 'LATEST', 'RELEASE' or a version range", metaVersion)
+		}
+		if r, err = parseMvnVersionRange(metaVersion); err != nil {
+			return "", err
+		}
+	}
+
+	meta, mvnMetaUrl, err := g.getMetadata(artifactUrl)
 	if err != nil {
 		return "", err
 	}
-	defer os.Remove(mvnMetaFile.Name())
 
-	if err := g.HttpGet.GetFile(mvnMetaFile.Name(), mvnMetaUrl); err != nil {
-		return "", err
+	var version string
+	switch metaVersion {
+	case "LATEST":
+		version = meta.Versioning.Latest
+	case "RELEASE":
+		version = meta.Versioning.Release
+	default:
+		version = r.highest(meta.Versioning.Versions)
+		if version == "" {
+			return "", fmt.Errorf("no version in the %s is in the range %s", mvnMetaUrl, metaVersion)
+		}
+	}
+	if version == "" {
+		return "", fmt.Errorf("no <%s> version in the %s", strings.ToLower(metaVersion), mvnMetaUrl)
+	}
+	return version, nil
+}
+
+// mvnVersionRange is a maven version range, the union of its restrictions, Ex., '(,1.0],[1.2,)'.
+type mvnVersionRange []mvnRestriction
+
+// mvnRestriction is a restriction of a version range between a lower and an upper bound, Ex., '[1.2,2.0)'. An empty
+// bound is unbounded.
+type mvnRestriction struct {
+	Lower, Upper                   string
+	LowerInclusive, UpperInclusive bool
+}
+
+// parseMvnVersionRange parses a maven version range, Ex., '[1.2,2.0)', '[1.5,)', '(,1.0],[1.2,)' or '[1.2]' for
+// exactly 1.2.
+func parseMvnVersionRange(expr string) (mvnVersionRange, error) {
+	var r mvnVersionRange
+	rest := strings.TrimSpace(expr)
+	for rest != "" {
+		end := strings.IndexAny(rest, "])")
+		if (rest[0] != '[' && rest[0] != '(') || end < 0 {
+			return nil, fmt.Errorf("invalid version range '%s'", expr)
+		}
+		bounds := strings.TrimSpace(rest[1:end])
+		restriction := mvnRestriction{LowerInclusive: rest[0] == '[', UpperInclusive: rest[end] == ']'}
+		if strings.ContainsAny(bounds, "[]()") {
+			return nil, fmt.Errorf("invalid version range '%s'", expr)
+		}
+
+		if i := strings.Index(bounds, ","); i < 0 {
+			// a single version, Ex., '[1.2]'
+			if bounds == "" || !restriction.LowerInclusive || !restriction.UpperInclusive {
+				return nil, fmt.Errorf("invalid version range '%s'", expr)
+			}
+			restriction.Lower, restriction.Upper = bounds, bounds
+		} else {
+			restriction.Lower = strings.TrimSpace(bounds[:i])
+			restriction.Upper = strings.TrimSpace(bounds[i+1:])
+			if strings.Contains(restriction.Upper, ",") ||
+				(restriction.Lower != "" && restriction.Upper != "" && compareMvnVersions(restriction.Lower, restriction.Upper) > 0) {
+				return nil, fmt.Errorf("invalid version range '%s'", expr)
+			}
+		}
+		r = append(r, restriction)
+
+		rest = strings.TrimSpace(rest[end+1:])
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimSpace(rest[1:])
+			if rest == "" {
+				return nil, fmt.Errorf("invalid version range '%s'", expr)
+			}
+		} else if rest != "" {
+			return nil, fmt.Errorf("invalid version range '%s'", expr)
+		}
+	}
+	if len(r) == 0 {
+		return nil, fmt.Errorf("invalid version range '%s'", expr)
+	}
+	return r, nil
+}
+
+// contains returns true if the version is in the range.
+func (r mvnVersionRange) contains(version string) bool {
+	for _, restriction := range r {
+		if restriction.Lower != "" {
+			cmp := compareMvnVersions(version, restriction.Lower)
+			if cmp < 0 || (cmp == 0 && !restriction.LowerInclusive) {
+				continue
+			}
+		}
+		if restriction.Upper != "" {
+			cmp := compareMvnVersions(version, restriction.Upper)
+			if cmp > 0 || (cmp == 0 && !restriction.UpperInclusive) {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// highest returns the highest of the versions in the range, or "" if there's none. Snapshot versions are only
+// considered if a bound of the range is a snapshot.
+func (r mvnVersionRange) highest(versions []string) string {
+	snapshots := false
+	for _, restriction := range r {
+		if strings.HasSuffix(restriction.Lower, "-SNAPSHOT") || strings.HasSuffix(restriction.Upper, "-SNAPSHOT") {
+			snapshots = true
+		}
+	}
+
+	var highest string
+	for _, v := range versions {
+		v = strings.TrimSpace(v)
+		if v == "" || (!snapshots && strings.HasSuffix(v, "-SNAPSHOT")) || !r.contains(v) {
+			continue
+		}
+		if highest == "" || compareMvnVersions(v, highest) > 0 {
+			highest = v
+		}
+	}
+	return highest
+}
+
+// mvnQualifiers are the well-known qualifiers of maven versions in their order, where "" is a release. Other
+// qualifiers come after them, in lexical order.
+var mvnQualifiers = []string{"alpha", "beta", "milestone", "rc", "snapshot", "", "sp"}
+
+// mvnQualifierAliases are the alternative names of the well-known qualifiers.
+var mvnQualifierAliases = map[string]string{"ga": "", "final": "", "release": "", "cr": "rc"}
+
+// compareMvnVersions compares two maven versions like maven's ComparableVersion, returning -1, 0 or 1. Versions are
+// split into numbers and qualifiers at dots, hyphens and transitions between digits and letters, Ex., '1.0-rc1' is
+// [1 0 rc 1], numbers compare numerically and qualifiers by mvnQualifiers, a release being newer than its snapshot or
+// release candidate, Ex., '1.0-rc1' < '1.0-SNAPSHOT' < '1.0' = '1.0.0' < '1.0-sp1' < '1.0.1'.
+func compareMvnVersions(a, b string) int {
+	itemsA, itemsB := mvnVersionItems(a), mvnVersionItems(b)
+	for i := 0; i < len(itemsA) || i < len(itemsB); i++ {
+		// missing items compare as 0, or as a release against qualifiers
+		var x, y string
+		if i < len(itemsA) {
+			x = itemsA[i]
+		}
+		if i < len(itemsB) {
+			y = itemsB[i]
+		}
+		if cmp := compareMvnItems(x, y); cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+// mvnVersionItems returns the numbers and qualifiers of the version, with the aliases of the qualifiers replaced,
+// and the zeros and release qualifiers trailing the version, or preceding a hyphen or a qualifier, removed.
+func mvnVersionItems(version string) []string {
+	var items []string
+	var item []rune
+	// zeros and release qualifiers don't change the version, Ex., '1.0.0' = '1', '1.0.Final' = '1' and
+	// '1.0-rc1' = '1-rc1'
+	trim := func() {
+		for len(items) > 0 && (items[len(items)-1] == "" || items[len(items)-1] == "0") {
+			items = items[:len(items)-1]
+		}
+	}
+	flush := func(next rune) {
+		if len(item) == 0 {
+			return
+		}
+		s := string(item)
+		// 'a1', 'b1' and 'm1' are short for alpha, beta and milestone 1
+		if len(s) == 1 && next >= '0' && next <= '9' {
+			switch s {
+			case "a":
+				s = "alpha"
+			case "b":
+				s = "beta"
+			case "m":
+				s = "milestone"
+			}
+		}
+		if alias, ok := mvnQualifierAliases[s]; ok {
+			s = alias
+		}
+		if s != "" && s[0] >= '0' && s[0] <= '9' {
+			s = strings.TrimLeft(s, "0")
+			if s == "" {
+				s = "0"
+			}
+		}
+		items = append(items, s)
+		item = nil
+	}
+
+	for _, c := range strings.ToLower(version) {
+		isDigit := c >= '0' && c <= '9'
+		switch {
+		case c == '.' || c == '_':
+			flush(0)
+		case c == '-':
+			flush(0)
+			trim()
+		case len(item) > 0 && isDigit != (item[0] >= '0' && item[0] <= '9'):
+			flush(c)
+			if !isDigit {
+				trim()
+			}
+			item = append(item, c)
+		default:
+			item = append(item, c)
+		}
+	}
+	flush(0)
+	trim()
+	return items
+}
+
+// compareMvnItems compares two items of maven versions, numbers without leading zeros or qualifiers, where "" is
+// either a release or a missing item.
+func compareMvnItems(x, y string) int {
+	isNum := func(s string) bool { return s != "" && s[0] >= '0' && s[0] <= '9' }
+	switch {
+	case isNum(x) && isNum(y):
+		if len(x) != len(y) {
+			if len(x) < len(y) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(x, y)
+	case isNum(x):
+		// numbers are newer than qualifiers, and than a missing item
+		return 1
+	case isNum(y):
+		return -1
+	}
+
+	rank := func(s string) int {
+		for i, q := range mvnQualifiers {
+			if s == q {
+				return i
+			}
+		}
+		return len(mvnQualifiers)
+	}
+	rx, ry := rank(x), rank(y)
+	switch {
+	case rx < ry:
+		return -1
+	case rx > ry:
+		return 1
+	}
+	return strings.Compare(x, y)
+}
+
+// get and parse the maven-metadata.xml under the given url from remote maven repo.
+func (g *MvnGetter) getMetadata(baseUrl *url.URL) (*Metadata, *url.URL, error) {
+	mvnMetaUrl, err := url.Parse(baseUrl.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	mvnMetaUrl.Path = path.Join(mvnMetaUrl.Path, "maven-metadata.xml")
+
+	tempDir := g.TempDir
+	if tempDir == "" {
+		tempDir = g.tempDir()
+	}
+	mvnMetaFile, err := ioutil.TempFile(tempDir, g.tempPattern("maven-metadata"))
+	if err != nil {
+		return nil, nil, err
+	}
+	mvnMetaFile.Close()
+	defer g.removeTemp(mvnMetaFile.Name())
+
+	if err := g.download(mvnMetaFile.Name(), mvnMetaUrl); err != nil {
+		return nil, nil, err
 	}
 
 	mvnMetaXml, err := ioutil.ReadFile(mvnMetaFile.Name())
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
 	var meta Metadata
 	if err := xml.Unmarshal(mvnMetaXml, &meta); err != nil {
-		return "", err
+		return nil, nil, fmt.Errorf("error parsing %s: %s, body: %q", mvnMetaUrl, err, snippet(mvnMetaXml, 200))
 	}
-	vers := meta.Versioning.SnapshotVersions.VersionList
-	if len(vers) == 0 {
-		return "", fmt.Errorf("no snapshot versions in the %s", mvnMetaUrl)
+	return &meta, mvnMetaUrl, nil
+}
+
+// snippet returns at most the first n bytes of b, for use in error messages.
+func snippet(b []byte, n int) string {
+	if len(b) <= n {
+		return string(b)
 	}
-	return vers[0].Value, nil
+	return string(b[:n]) + "..."
 }
 
 type Metadata struct {
@@ -163,12 +882,22 @@ type Metadata struct {
 	Versioning SnapshotVerioning `xml:"versioning"`
 }
 type SnapshotVerioning struct {
+	Latest           string           `xml:"latest"`
+	Release          string           `xml:"release"`
+	Versions         []string         `xml:"versions>version"`
+	Snapshot         Snapshot         `xml:"snapshot"`
+	LastUpdated      string           `xml:"lastUpdated"`
 	SnapshotVersions SnapshotVersions `xml:"snapshotVersions"`
 }
+type Snapshot struct {
+	Timestamp   string `xml:"timestamp"`
+	BuildNumber string `xml:"buildNumber"`
+}
 type SnapshotVersions struct {
 	VersionList []SnapshotVersion `xml:"snapshotVersion"`
 }
 type SnapshotVersion struct {
 	Extension string `xml:"extension"`
 	Value     string `xml:"value"`
+	Updated   string `xml:"updated"`
 }
@@ -4,11 +4,17 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -141,3 +147,820 @@ func hashFileMd5(filePath string) (string, error) {
 
 	return returnMD5String, nil
 }
+
+func TestMvnGetter_metaVersion(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Version  string
+		Filename string
+		Contents string
+	}{
+		{"RELEASE", "test-1.0.0.jar", "1.0.0\n"},
+		{"LATEST", "test-1.1.0-SNAPSHOT.jar", "1.1.0-20171126.202552-2\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Version, func(t *testing.T) {
+			g := new(MvnGetter)
+			u := testMvnURL(ln, "groupId=org.example&artifactId=test&version="+tc.Version)
+
+			filename, err := g.GetFilename(u)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if filename != tc.Filename {
+				t.Fatalf("expected filename %s, got %s", tc.Filename, filename)
+			}
+
+			dst := filepath.Join(tempDir(t), filename)
+			if err := g.GetFile(dst, u); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			assertContents(t, dst, tc.Contents)
+		})
+	}
+}
+
+func TestMvnGetter_metaVersionMissing(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	g := new(MvnGetter)
+	u := testMvnURL(ln, "groupId=org.example&artifactId=snapshot-only&version=RELEASE")
+
+	err := g.GetFile(tempFile(t), u)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "no <release> version") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestMvnGetter_versionRange(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Version  string
+		Filename string
+		Contents string
+	}{
+		{"[1.0,2.0)", "test-1.0.0.jar", "1.0.0\n"},
+		{"[0.9,1.0)", "test-0.9.0.jar", "0.9.0\n"},
+		{"(,1.0]", "test-1.0.0.jar", "1.0.0\n"},
+		{"[0.9]", "test-0.9.0.jar", "0.9.0\n"},
+		{"[1.1.0-SNAPSHOT,)", "test-1.1.0-SNAPSHOT.jar", "1.1.0-20171126.202552-2\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Version, func(t *testing.T) {
+			g := new(MvnGetter)
+			u := testMvnURL(ln, "groupId=org.example&artifactId=test&version="+url.QueryEscape(tc.Version))
+
+			filename, err := g.GetFilename(u)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if filename != tc.Filename {
+				t.Fatalf("expected filename %s, got %s", tc.Filename, filename)
+			}
+
+			dst := filepath.Join(tempDir(t), filename)
+			if err := g.GetFile(dst, u); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			assertContents(t, dst, tc.Contents)
+		})
+	}
+
+	for _, version := range []string{"[2.0,)", "(1.0,1.1)", "[1.0", "1.0]"} {
+		g := new(MvnGetter)
+		u := testMvnURL(ln, "groupId=org.example&artifactId=test&version="+url.QueryEscape(version))
+		if err := g.GetFile(tempFile(t), u); err == nil {
+			t.Fatalf("%s: should error", version)
+		}
+	}
+}
+
+func TestCompareMvnVersions(t *testing.T) {
+	cases := []struct {
+		A, B string
+		Cmp  int
+	}{
+		{"1.0", "1.0", 0},
+		{"1", "1.0.0", 0},
+		{"1.0", "1.0.Final", 0},
+		{"1.0-ga", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1.0.1", "1.0", 1},
+		{"1.0-SNAPSHOT", "1.0", -1},
+		{"1.0-rc1", "1.0-SNAPSHOT", -1},
+		{"1.0-alpha1", "1.0-a1", 0},
+		{"1.0-alpha2", "1.0-beta1", -1},
+		{"1.0-beta1", "1.0-m1", -1},
+		{"1.0-cr1", "1.0-rc1", 0},
+		{"1.0-rc2", "1.0-rc10", -1},
+		{"1.0-sp1", "1.0", 1},
+		{"1.0-sp1", "1.0.1", -1},
+		{"1.0.5", "1-sp1", 1},
+		{"1.0-rc1", "1-rc1", 0},
+		{"1.0rc1", "1-rc1", 0},
+		{"1.0.0-SNAPSHOT", "1-SNAPSHOT", 0},
+		{"31.1-jre", "31.1-android", 1},
+		{"31.1-jre", "32.0-jre", -1},
+		{"2.0", "10.0", -1},
+	}
+	for _, tc := range cases {
+		if cmp := compareMvnVersions(tc.A, tc.B); cmp != tc.Cmp {
+			t.Fatalf("%s %s: expected %d, got %d", tc.A, tc.B, tc.Cmp, cmp)
+		}
+		if cmp := compareMvnVersions(tc.B, tc.A); cmp != -tc.Cmp {
+			t.Fatalf("%s %s: expected %d, got %d", tc.B, tc.A, -tc.Cmp, cmp)
+		}
+	}
+}
+
+func TestParseMvnVersionRange(t *testing.T) {
+	versions := []string{"0.9", "1.0", "1.1-SNAPSHOT", "1.1", "1.5", "2.0-rc1", "2.0", "3.0"}
+	cases := []struct {
+		Range   string
+		Highest string
+	}{
+		{"[1.0,2.0)", "2.0-rc1"},
+		{"[1.0,2.0-rc1)", "1.5"},
+		{"[1.0,2.0]", "2.0"},
+		{"(1.0,1.5)", "1.1"},
+		{"[1.5,)", "3.0"},
+		{"(,1.0]", "1.0"},
+		{"(,1.0)", "0.9"},
+		{"[1.1]", "1.1"},
+		{"(,1.0],[1.2,1.9]", "1.5"},
+		{"[ 1.0 , 1.5 )", "1.1"},
+		{"[1.1-SNAPSHOT,1.1)", "1.1-SNAPSHOT"},
+		{"[4.0,)", ""},
+	}
+	for _, tc := range cases {
+		r, err := parseMvnVersionRange(tc.Range)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Range, err)
+		}
+		if highest := r.highest(versions); highest != tc.Highest {
+			t.Fatalf("%s: expected %q, got %q", tc.Range, tc.Highest, highest)
+		}
+	}
+
+	for _, expr := range []string{"", "1.0", "[1.0", "[1.0,2.0", "(1.0)", "[]", "[2.0,1.0]", "[1.0,2.0),", "[1.0,2.0) x", "[1,2,3]"} {
+		if _, err := parseMvnVersionRange(expr); err == nil {
+			t.Fatalf("%q: should error", expr)
+		}
+	}
+}
+
+func TestMvnGetter_snapshotOrdering(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	// The first <snapshotVersion> in the metadata is not the latest build
+	g := new(MvnGetter)
+	u := testMvnURL(ln, "groupId=org.example&artifactId=unordered&version=2.0.0-SNAPSHOT")
+
+	dst := tempFile(t)
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "newest\n")
+}
+
+func TestMvnGetter_pinnedSnapshot(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Query    string
+		Expected string
+		Err      bool
+	}{
+		{"version=2.0.0-SNAPSHOT&snapshot=20180101.000000-1", "oldest\n", false},
+		{"version=2.0.0-SNAPSHOT&snapshot=20180301.120000-3", "newest\n", false},
+		{"version=2.0.0-SNAPSHOT&snapshot=20180301.120000-9", "", true},
+		{"version=2.0.0-SNAPSHOT&snapshot=latest", "", true},
+		{"version=2.0.0&snapshot=20180101.000000-1", "", true},
+	}
+
+	for _, tc := range cases {
+		g := new(MvnGetter)
+		u := testMvnURL(ln, "groupId=org.example&artifactId=unordered&"+tc.Query)
+
+		dst := tempFile(t)
+		err := g.GetFile(dst, u)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %v", tc.Query, err)
+		}
+		if !tc.Err {
+			assertContents(t, dst, tc.Expected)
+		}
+	}
+}
+
+func TestMvnGetter_snapshotFallback(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	// The snapshot has no metadata, so it can't be resolved by default
+	g := new(MvnGetter)
+	u := testMvnURL(ln, "groupId=org.example&artifactId=test&version=1.2.0-SNAPSHOT")
+	dst := tempFile(t)
+	if err := g.GetFile(dst, u); err == nil {
+		t.Fatal("should error")
+	}
+
+	u = testMvnURL(ln, "groupId=org.example&artifactId=test&version=1.2.0-SNAPSHOT&snapshotFallback=true")
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "snapshot\n")
+
+	// Snapshots with metadata are still resolved to the latest build
+	u = testMvnURL(ln, "groupId=org.example&artifactId=unordered&version=2.0.0-SNAPSHOT&snapshotFallback=true")
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "newest\n")
+}
+
+func TestMvnGetter_snapshotRedeployed(t *testing.T) {
+	cases := []struct {
+		Name string
+		// Builds are the builds the metadata resolves to, for each read
+		Builds []int
+		Err    string
+	}{
+		{"redeployed", []int{1, 2}, ""},
+		{"still moving", []int{1, 3, 4, 5}, "snapshot metadata moved"},
+		{"missing", []int{1, 1}, "bad response code: 404"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			defer ln.Close()
+
+			// Only build 2 of the snapshot exists
+			reads := 0
+			var server http.Server
+			server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch path.Base(r.URL.Path) {
+				case "maven-metadata.xml":
+					build := tc.Builds[len(tc.Builds)-1]
+					if reads < len(tc.Builds) {
+						build = tc.Builds[reads]
+					}
+					reads++
+					fmt.Fprintf(w, "<metadata><versioning><snapshot>"+
+						"<timestamp>20180101.000000</timestamp><buildNumber>%d</buildNumber>"+
+						"</snapshot></versioning></metadata>", build)
+				case "racy-1.0.0-20180101.000000-2.jar":
+					fmt.Fprint(w, "build 2\n")
+				default:
+					w.WriteHeader(404)
+				}
+			})
+			go server.Serve(ln)
+
+			g := new(MvnGetter)
+			dst := tempFile(t)
+			err = g.GetFile(dst, testMvnURL(ln, "groupId=org.example&artifactId=racy&version=1.0.0-SNAPSHOT"))
+			if tc.Err == "" {
+				if err != nil {
+					t.Fatalf("err: %s", err)
+				}
+				assertContents(t, dst, "build 2\n")
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.Err) {
+				t.Fatalf("expected error containing %q, got: %v", tc.Err, err)
+			}
+		})
+	}
+}
+
+func TestMvnGetter_latestSnapshotVersion(t *testing.T) {
+	// Without a <snapshot> element, the entry updated last is used
+	meta := &Metadata{
+		Versioning: SnapshotVerioning{
+			SnapshotVersions: SnapshotVersions{
+				VersionList: []SnapshotVersion{
+					{Extension: "jar", Value: "2.0.0-20180101.000000-1", Updated: "20180101000000"},
+					{Extension: "jar", Value: "2.0.0-20180301.120000-3", Updated: "20180301120000"},
+					{Extension: "jar", Value: "2.0.0-20180215.080000-2", Updated: "20180215080000"},
+				},
+			},
+		},
+	}
+	if v := latestSnapshotVersion(meta, "2.0.0-SNAPSHOT"); v != "2.0.0-20180301.120000-3" {
+		t.Fatalf("bad: %s", v)
+	}
+
+	meta.Versioning.Snapshot = Snapshot{Timestamp: "20180401.100000", BuildNumber: "4"}
+	if v := latestSnapshotVersion(meta, "2.0.0-SNAPSHOT"); v != "2.0.0-20180401.100000-4" {
+		t.Fatalf("bad: %s", v)
+	}
+
+	if v := latestSnapshotVersion(&Metadata{}, "2.0.0-SNAPSHOT"); v != "" {
+		t.Fatalf("bad: %s", v)
+	}
+}
+
+func TestMvnGetter_auth(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ln.Close()
+
+	// Require basic auth for the metadata and the artifact
+	files := http.FileServer(http.Dir(filepath.Join(fixtureDir, "mvn-repo")))
+	var server http.Server
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "foo" || pass != "bar" {
+			w.WriteHeader(401)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+	go server.Serve(ln)
+
+	dst := tempFile(t)
+	client := &Client{
+		Src:      "mvn::" + testMvnURL(ln, "groupId=org.example&artifactId=test&version=1.1.0-SNAPSHOT").String(),
+		Dst:      dst,
+		Mode:     ClientModeFile,
+		Username: "foo",
+		Password: "bar",
+		Getters: map[string]Getter{
+			"mvn": new(MvnGetter),
+		},
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "1.1.0-20171126.202552-2\n")
+}
+
+func TestMvnGetter_verify(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Query string
+		Err   string
+	}{
+		{"artifactId=test&version=1.0.0&verify=sha1", ""},
+		{"artifactId=test&version=1.0.0&verify=sha256", ""},
+		{"artifactId=test&version=1.0.0&verify=sha512", "no sha512 checksum is published"},
+		{"artifactId=test&version=1.0.0&verify=md5", "Checksums did not match"},
+		{"artifactId=test&version=1.0.0&verify=crc32", "unsupported verify algorithm"},
+
+		// The first published checksum is verified by default
+		{"artifactId=test&version=1.0.0", ""},
+		{"artifactId=test&version=0.9.0", ""},
+		{"artifactId=corrupt&version=1.0.0", "Checksums did not match"},
+		{"artifactId=corrupt&version=1.0.0&verify=none", ""},
+	}
+
+	for _, tc := range cases {
+		g := new(MvnGetter)
+		u := testMvnURL(ln, "groupId=org.example&"+tc.Query)
+
+		err := g.GetFile(tempFile(t), u)
+		if tc.Err == "" {
+			if err != nil {
+				t.Fatalf("%s: err: %s", tc.Query, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.Err) {
+			t.Fatalf("%s: expected error %q, got: %v", tc.Query, tc.Err, err)
+		}
+	}
+}
+
+func TestMvnGetter_verifyUnpublished(t *testing.T) {
+	// The repository forbids getting the checksum files it doesn't have,
+	// and fails getting the md5 ones
+	files := http.FileServer(http.Dir(filepath.Join(fixtureDir, "mvn-repo")))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path.Ext(r.URL.Path) {
+		case ".sha256":
+			w.WriteHeader(403)
+		case ".sha1":
+			w.WriteHeader(401)
+		case ".md5":
+			w.WriteHeader(500)
+		default:
+			files.ServeHTTP(w, r)
+		}
+	}))
+	defer server.Close()
+
+	u := testURL(server.URL + "?groupId=org.example&artifactId=corrupt&version=1.0.0")
+	if err := new(MvnGetter).GetFile(tempFile(t), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	u.RawQuery += "&verify=sha1"
+	if err := new(MvnGetter).GetFile(tempFile(t), u); err == nil || !strings.Contains(err.Error(), "no sha1 checksum is published") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestMvnGetter_verifyChecksummers(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	// Only the checksum types of the client are verified, so the md5
+	// checksum, which doesn't match, is used
+	client := &Client{
+		Src:          "mvn::" + testMvnURL(ln, "groupId=org.example&artifactId=test&version=1.0.0").String(),
+		Dst:          tempFile(t),
+		Mode:         ClientModeFile,
+		Checksummers: map[string]func() hash.Hash{"md5": md5.New},
+	}
+	if err := client.Get(); err == nil || !strings.Contains(err.Error(), "Checksums did not match") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	client.Src += "&verify=sha256"
+	if err := client.Get(); err == nil || !strings.Contains(err.Error(), "unsupported verify algorithm") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestMvnGetter_validate(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Query string
+		Err   bool
+	}{
+		{"groupId=org.example&artifactId=test&version=1.0.0", false},
+		{"groupId=org.example&artifactId=unordered&version=2.0.0-SNAPSHOT", false},
+		{"groupId=org.example&artifactId=test&version=9.9.9", true},
+		{"groupId=org.example&artifactId=missing&version=LATEST", true},
+		{"groupId=org.example&artifactId=test", true},
+	}
+
+	for _, tc := range cases {
+		g := new(MvnGetter)
+		err := g.Validate(testMvnURL(ln, tc.Query))
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %v", tc.Query, err)
+		}
+	}
+}
+
+// testMvnServer starts an HTTP server serving the test maven repository
+// from the test fixtures.
+func testMvnServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var server http.Server
+	server.Handler = http.FileServer(http.Dir(filepath.Join(fixtureDir, "mvn-repo")))
+	go server.Serve(ln)
+
+	return ln
+}
+
+// testMvnURL returns the URL of the test maven repository served by ln
+// with the given query.
+func testMvnURL(ln net.Listener, query string) *url.URL {
+	return &url.URL{
+		Scheme:   "http",
+		Host:     ln.Addr().String(),
+		RawQuery: query,
+	}
+}
+
+func TestMvnGetter_withPom(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Version string
+		Pom     string
+	}{
+		{"1.0.0", "test-1.0.0.pom"},
+		{"1.1.0-SNAPSHOT", "test-1.1.0-SNAPSHOT.pom"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Version, func(t *testing.T) {
+			g := new(MvnGetter)
+			u := testMvnURL(ln, "groupId=org.example&artifactId=test&withPom=true&version="+tc.Version)
+
+			td := tempDir(t)
+			dst := filepath.Join(td, "test.jar")
+			if err := g.GetFile(dst, u); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			if _, err := os.Stat(dst); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			pom := filepath.Join(td, tc.Pom)
+			if _, err := os.Stat(pom); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			assertContents(t, pom, "<project>\n  <artifactId>test</artifactId>\n  <version>"+tc.Version+"</version>\n</project>\n")
+		})
+	}
+}
+
+func TestMvnGetter_multipleArtifacts(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	g := new(MvnGetter)
+	u := testMvnURL(ln, "groupId=org.example&artifactId=test&artifactId=other&version=1.1.0-SNAPSHOT")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	// Each artifact is resolved to its own latest snapshot build
+	dst := tempDir(t)
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "test-1.1.0-SNAPSHOT.jar"), "1.1.0-20171126.202552-2\n")
+	assertContents(t, filepath.Join(dst, "other-1.1.0-SNAPSHOT.jar"), "1.1.0-20180101.000000-5\n")
+}
+
+func TestMvnGetter_multipleArtifactsMissing(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	client := &Client{
+		Src:  "mvn::" + testMvnURL(ln, "groupId=org.example&artifactId=missing&artifactId=other&version=1.1.0-SNAPSHOT").String(),
+		Dst:  tempDir(t),
+		Mode: ClientModeAny,
+	}
+	err := client.Get()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "1 of 2 artifacts") || !strings.Contains(err.Error(), "missing: ") {
+		t.Fatalf("bad error: %s", err)
+	}
+
+	// The other artifacts are still downloaded
+	assertContents(t, filepath.Join(client.Dst, "other-1.1.0-SNAPSHOT.jar"), "1.1.0-20180101.000000-5\n")
+}
+
+func TestMvnGetter_filename(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	client := &Client{
+		Src:  "mvn::" + testMvnURL(ln, "groupId=org.example&artifactId=test&version=1.1.0-SNAPSHOT&filename=app.jar").String(),
+		Dst:  tempDir(t),
+		Mode: ClientModeAny,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(client.Dst, "app.jar"), "1.1.0-20171126.202552-2\n")
+
+	// The file name can't escape the destination
+	for _, filename := range []string{"../app.jar", "sub/app.jar", ".."} {
+		client.Src = "mvn::" + testMvnURL(ln, "groupId=org.example&artifactId=test&version=1.1.0-SNAPSHOT&filename="+url.QueryEscape(filename)).String()
+		if err := client.Get(); err == nil {
+			t.Fatalf("%s: should error", filename)
+		}
+	}
+}
+
+func TestMvnGetter_urlRewrite(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	// The public repo is redirected to the test server
+	var paths []string
+	client := &Client{
+		Src:  "mvn::https://repo.example.com/maven2?groupId=org.example&artifactId=test&version=1.1.0-SNAPSHOT&withPom=true",
+		Dst:  tempFile(t),
+		Mode: ClientModeFile,
+		URLRewrite: func(u *url.URL) (*url.URL, error) {
+			paths = append(paths, path.Base(u.Path))
+			if u.Host == "repo.example.com" {
+				u.Scheme = "http"
+				u.Host = ln.Addr().String()
+				u.Path = strings.TrimPrefix(u.Path, "/maven2")
+			}
+			return u, nil
+		},
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, client.Dst, "1.1.0-20171126.202552-2\n")
+
+	expected := []string{
+		"maven2",
+		"maven-metadata.xml",
+		"test-1.1.0-20171126.202552-2.jar",
+		"test-1.1.0-20171126.202552-2.jar.sha256",
+		"test-1.1.0-20171126.202552-2.jar.sha1",
+		"test-1.1.0-20171126.202552-2.jar.md5",
+		"test-1.1.0-20171126.202552-2.pom",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("bad rewritten urls: %v", paths)
+	}
+
+	// An error of the hook aborts the download
+	client.Dst = tempFile(t)
+	client.URLRewrite = func(u *url.URL) (*url.URL, error) {
+		if path.Base(u.Path) == "maven-metadata.xml" {
+			return nil, fmt.Errorf("forbidden")
+		}
+		return u, nil
+	}
+	client.Src = "mvn::" + testMvnURL(ln, "groupId=org.example&artifactId=test&version=1.1.0-SNAPSHOT").String()
+	if err := client.Get(); err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := os.Stat(client.Dst); !os.IsNotExist(err) {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestMvnGetter_tempDir(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	td, err := ioutil.TempDir("", "getter")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	g := &MvnGetter{TempDir: td}
+	u := testMvnURL(ln, "groupId=org.example&artifactId=test&version=1.1.0-SNAPSHOT")
+
+	dst := tempFile(t)
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "1.1.0-20171126.202552-2\n")
+
+	// The metadata must have been removed from the temp dir
+	fis, err := ioutil.ReadDir(td)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(fis) != 0 {
+		t.Fatalf("expected temp dir to be empty, got %d entries", len(fis))
+	}
+
+	// A temp dir that doesn't exist must be used, and fail
+	g.TempDir = filepath.Join(td, "missing")
+	if err := g.GetFile(tempFile(t), u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestMvnGetter_repos(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	// The repo of the url doesn't have the artifact, the first other repo does
+	missing := fmt.Sprintf("http://%s/missing", ln.Addr().String())
+	repo := fmt.Sprintf("http://%s/", ln.Addr().String())
+	u := testMvnURL(ln, "groupId=org.example&artifactId=test&version=RELEASE&repo="+url.QueryEscape(repo))
+	u.Path = "/missing"
+
+	g := new(MvnGetter)
+	filename, err := g.GetFilename(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if filename != "test-1.0.0.jar" {
+		t.Fatalf("bad: %s", filename)
+	}
+
+	dst := tempFile(t)
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "1.0.0\n")
+
+	if err := g.Validate(u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The errors of all the repos are reported
+	u = testMvnURL(ln, "groupId=org.example&artifactId=test&version=9.9.9&repo="+url.QueryEscape(missing))
+	err = g.GetFile(tempFile(t), u)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "all 2 maven repos") || !strings.Contains(err.Error(), missing+": ") {
+		t.Fatalf("bad error: %s", err)
+	}
+
+	// Repos must be full URLs
+	u = testMvnURL(ln, "groupId=org.example&artifactId=test&version=1.0.0&repo=central")
+	if err := g.GetFile(tempFile(t), u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestMvnGetter_keepTempOnError(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	td, err := ioutil.TempDir("", "getter")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	client := &Client{
+		Src:             "mvn::" + testMvnURL(ln, "groupId=org.example&artifactId=test&version=1.1.0-SNAPSHOT").String(),
+		Dst:             tempFile(t),
+		Mode:            ClientModeFile,
+		TempDir:         td,
+		TempPattern:     "test-",
+		KeepTempOnError: true,
+	}
+
+	// The metadata is removed once the download succeeded
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	fis, err := ioutil.ReadDir(td)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(fis) != 0 {
+		t.Fatalf("expected temp dir to be empty, got %d entries", len(fis))
+	}
+
+	// The metadata is kept, and reported, when the artifact is missing
+	client.Src += "&classifier=missing"
+	err = client.Get()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	fis, err2 := ioutil.ReadDir(td)
+	if err2 != nil {
+		t.Fatalf("err: %s", err2)
+	}
+	if len(fis) == 0 {
+		t.Fatal("expected the metadata to be kept")
+	}
+	for _, fi := range fis {
+		if !strings.HasPrefix(fi.Name(), "test-maven-metadata") {
+			t.Fatalf("bad temp file: %s", fi.Name())
+		}
+		if !strings.Contains(err.Error(), filepath.Join(td, fi.Name())) {
+			t.Fatalf("bad error: %s", err)
+		}
+	}
+}
+
+func TestMvnGetter_invalidMetadata(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	g := new(MvnGetter)
+	u := testMvnURL(ln, "groupId=org.example&artifactId=broken&version=1.0.0-SNAPSHOT")
+
+	err := g.GetFile(tempFile(t), u)
+	if err == nil {
+		t.Fatal("should error")
+	}
+
+	// The error must name the metadata and show what was received
+	for _, v := range []string{"broken/1.0.0-SNAPSHOT/maven-metadata.xml", "Service Unavailable"} {
+		if !strings.Contains(err.Error(), v) {
+			t.Fatalf("expected error to contain %q, got: %s", v, err)
+		}
+	}
+}
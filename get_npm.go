@@ -0,0 +1,304 @@
+package getter
+
+import (
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// NpmGetter is a Getter implementation that will download the tarball of a
+// package from an npm registry, resolving the newest version in a range
+// like npm install does.
+//
+// URLs take the form npm::https://registry.npmjs.org?package=name, where
+// the package can be scoped, Ex., '@scope/name'. Private registries
+// authenticate with basic auth, given in the URL, or with a token from the
+// token query parameter, Token or NPM_TOKEN, which is only sent to the host
+// of the registry. The integrity of the tarball in the registry is verified.
+//
+// Query parameters:
+//   - package: the name of the package
+//   - version: the version, range of versions, Ex., '^1.2.0', or dist-tag,
+//     default as the 'latest' dist-tag
+//   - untar: true to unpack the package into a directory, without the
+//     top-level 'package' directory of the tarball
+//   - token: the token to authenticate to the registry with
+type NpmGetter struct {
+	httpBackedGetter
+
+	// Token is the token to authenticate to the registry with, if the URL
+	// has none. This defaults to the NPM_TOKEN environment variable.
+	Token string
+}
+
+// npmPackument is the metadata of a package, as abbreviated by the
+// registry for installs.
+type npmPackument struct {
+	DistTags map[string]string `json:"dist-tags"`
+	Versions map[string]struct {
+		Dist struct {
+			Tarball   string `json:"tarball"`
+			Shasum    string `json:"shasum"`
+			Integrity string `json:"integrity"`
+		} `json:"dist"`
+	} `json:"versions"`
+}
+
+// npmQueryParams are the query parameters of the NpmGetter, which aren't
+// part of the URL of the registry.
+var npmQueryParams = []string{"package", "version", "untar", "token"}
+
+func (g *NpmGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	if untar, _ := strconv.ParseBool(u.Query().Get("untar")); untar {
+		return ClientModeDir, nil
+	}
+	return ClientModeFile, nil
+}
+
+// GetFilename returns the name of the tarball of the resolved version, Ex.,
+// 'name-1.2.3.tgz'.
+func (g *NpmGetter) GetFilename(u *url.URL) (string, error) {
+	tarballURL, _, err := g.resolve(u)
+	if err != nil {
+		return "", err
+	}
+	return path.Base(tarballURL.Path), nil
+}
+
+func (g *NpmGetter) Get(dst string, u *url.URL) error {
+	tarballURL, integrity, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+
+	td, err := ioutil.TempDir(g.tempDir(), g.tempPattern("npm"))
+	if err != nil {
+		return err
+	}
+	defer g.removeTemp(td)
+
+	archive := filepath.Join(td, "package.tgz")
+	if err := g.getTarball(archive, u, tarballURL, integrity); err != nil {
+		return err
+	}
+	data := filepath.Join(td, "data")
+	if err := g.decompressors()["tgz"].Decompress(data, archive, true); err != nil {
+		return err
+	}
+
+	// The files are in a top-level directory, usually named 'package'
+	entries, err := ioutil.ReadDir(data)
+	if err != nil {
+		return err
+	}
+	if len(entries) != 1 || !entries[0].IsDir() {
+		return fmt.Errorf("expected a single top-level directory in %s", path.Base(tarballURL.Path))
+	}
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	return copyDir(dst, filepath.Join(data, entries[0].Name()), false)
+}
+
+func (g *NpmGetter) GetFile(dst string, u *url.URL) error {
+	tarballURL, integrity, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+	return g.getTarball(dst, u, tarballURL, integrity)
+}
+
+// getTarball downloads the tarball to dst and verifies its integrity, a
+// Subresource Integrity string, Ex., 'sha512-<base64>', or a hex SHA-1.
+func (g *NpmGetter) getTarball(dst string, u, tarballURL *url.URL, integrity string) error {
+	httpGet := g.authHttpGetter(u, tarballURL)
+	if err := httpGet.GetFile(dst, tarballURL); err != nil {
+		return err
+	}
+	if integrity == "" {
+		return nil
+	}
+
+	var h hash.Hash
+	var expected []byte
+	var err error
+	switch {
+	case strings.HasPrefix(integrity, "sha512-"):
+		h = sha512.New()
+		expected, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(integrity, "sha512-"))
+	case strings.HasPrefix(integrity, "sha1-"):
+		h = sha1.New()
+		expected, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(integrity, "sha1-"))
+	default:
+		h = sha1.New()
+		expected, err = hex.DecodeString(integrity)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid integrity of %s: %s", path.Base(tarballURL.Path), integrity)
+	}
+
+	f, err := os.Open(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if actual := h.Sum(nil); hex.EncodeToString(actual) != hex.EncodeToString(expected) {
+		return fmt.Errorf("integrity of %s doesn't match the registry, expected %s", path.Base(tarballURL.Path), integrity)
+	}
+	return nil
+}
+
+// resolve returns the URL of the tarball of the version of the package of
+// u, and its integrity.
+func (g *NpmGetter) resolve(u *url.URL) (*url.URL, string, error) {
+	q := u.Query()
+	name := q.Get("package")
+	if name == "" {
+		return nil, "", fmt.Errorf("query parameter 'package' is required")
+	}
+	if strings.HasPrefix(name, ".") || strings.Count(name, "/") > 1 ||
+		(strings.Contains(name, "/") && !strings.HasPrefix(name, "@")) {
+		return nil, "", fmt.Errorf("invalid package name: %s", name)
+	}
+
+	expr := q.Get("version")
+	if expr == "" {
+		expr = "latest"
+	}
+
+	// The metadata of scoped packages is at '@scope%2fname'
+	registry := *u
+	for _, k := range npmQueryParams {
+		q.Del(k)
+	}
+	registry.RawQuery = q.Encode()
+	metaURL := registry
+	metaURL.Path = strings.TrimSuffix(registry.Path, "/") + "/" + name
+	metaURL.RawPath = strings.TrimSuffix(registry.EscapedPath(), "/") + "/" +
+		strings.Replace(url.PathEscape(name), "%2F", "%2f", -1)
+
+	var p npmPackument
+	if err := g.metadata(u, &metaURL, &p); err != nil {
+		return nil, "", err
+	}
+
+	// The version is a dist-tag, Ex., 'latest' or 'next', or a range
+	version, ok := p.DistTags[expr]
+	if !ok {
+		r, err := parseVersionRange(expr)
+		if err != nil {
+			return nil, "", err
+		}
+		var versions []string
+		for v := range p.Versions {
+			versions = append(versions, v)
+		}
+		if version, ok = r.latest(versions); !ok {
+			return nil, "", fmt.Errorf("no version of package %s matches %q", name, expr)
+		}
+	}
+	v, ok := p.Versions[version]
+	if !ok || v.Dist.Tarball == "" {
+		return nil, "", fmt.Errorf("package %s %s has no tarball", name, version)
+	}
+
+	tarballURL, err := metaURL.Parse(v.Dist.Tarball)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid tarball URL of package %s %s: %s", name, version, err)
+	}
+	// Tarballs on the registry keep its credentials
+	if tarballURL.Host == u.Host && tarballURL.User == nil {
+		tarballURL.User = u.User
+	}
+	integrity := v.Dist.Integrity
+	if integrity == "" {
+		integrity = v.Dist.Shasum
+	}
+	return tarballURL, integrity, nil
+}
+
+// metadata gets the JSON metadata of the package at metaURL into p.
+func (g *NpmGetter) metadata(u, metaURL *url.URL, p *npmPackument) error {
+	reqURL := *metaURL
+	if g.http().Netrc {
+		if err := addAuthFromNetrc(&reqURL); err != nil {
+			return err
+		}
+	}
+
+	httpGet := g.authHttpGetter(u, metaURL)
+	httpGet.initClient()
+	req, err := httpGet.newRequest("GET", &reqURL)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.npm.install-v1+json; q=1.0, application/json; q=0.8")
+
+	resp, err := httpGet.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("bad response code getting %s: %d", redactURL(metaURL.String()), resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(g.rateLimitReader(resp.Body)).Decode(p); err != nil {
+		return fmt.Errorf("error parsing %s: %s", redactURL(metaURL.String()), err)
+	}
+	return nil
+}
+
+// authHttpGetter returns HttpGet sending the token, if any, with requests
+// to target, if it's on the host of the registry of u.
+func (g *NpmGetter) authHttpGetter(u, target *url.URL) HttpGetter {
+	hg := g.http()
+	httpGet := *hg
+	if target.Host != u.Host {
+		return httpGet
+	}
+
+	token := u.Query().Get("token")
+	if token == "" {
+		token = g.Token
+	}
+	if token == "" {
+		token = os.Getenv("NPM_TOKEN")
+	}
+	if token != "" {
+		httpGet.Header = make(map[string][]string)
+		for k, v := range hg.Header {
+			httpGet.Header[k] = v
+		}
+		httpGet.Header.Set("Authorization", "Bearer "+token)
+	}
+	return httpGet
+}
@@ -0,0 +1,249 @@
+package getter
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNpmGetter_impl(t *testing.T) {
+	var _ Getter = new(NpmGetter)
+}
+
+func TestNpmGetter_file(t *testing.T) {
+	ln := testNpmServer(t)
+	defer ln.Close()
+
+	g := new(NpmGetter)
+	cases := []struct {
+		Query    string
+		Filename string
+	}{
+		{"package=left-pad", "left-pad-1.3.0.tgz"},
+		{"package=left-pad&version=^1.1", "left-pad-1.3.0.tgz"},
+		{"package=left-pad&version=~1.1.0", "left-pad-1.1.3.tgz"},
+		{"package=left-pad&version=next", "left-pad-2.0.0-beta.1.tgz"},
+		{"package=@acme/widget&version=1.x", "widget-1.0.0.tgz"},
+	}
+	for _, tc := range cases {
+		u := testURL(fmt.Sprintf("http://%s/registry?%s", ln.Addr(), tc.Query))
+
+		mode, err := g.ClientMode(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		if mode != ClientModeFile {
+			t.Fatalf("%s: bad mode: %d", tc.Query, mode)
+		}
+
+		filename, err := g.GetFilename(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		if filename != tc.Filename {
+			t.Fatalf("%s: bad filename: %s", tc.Query, filename)
+		}
+
+		if err := g.GetFile(tempFile(t), u); err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+	}
+}
+
+func TestNpmGetter_untar(t *testing.T) {
+	ln := testNpmServer(t)
+	defer ln.Close()
+
+	g := new(NpmGetter)
+	u := testURL(fmt.Sprintf("http://%s/registry/?package=left-pad&version=1.1.3&untar=true", ln.Addr()))
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempDir(t)
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "package.json"), `{"name":"left-pad","version":"1.1.3"}`)
+}
+
+func TestNpmGetter_token(t *testing.T) {
+	ln := testNpmServer(t)
+	defer ln.Close()
+
+	defer func(v string) { os.Setenv("NPM_TOKEN", v) }(os.Getenv("NPM_TOKEN"))
+	os.Setenv("NPM_TOKEN", "")
+
+	u := testURL(fmt.Sprintf("http://%s/private?package=@acme/secret", ln.Addr()))
+	if err := new(NpmGetter).GetFile(tempFile(t), u); err == nil {
+		t.Fatal("should error without a token")
+	}
+
+	if err := (&NpmGetter{Token: "s3cr3t"}).GetFile(tempFile(t), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	os.Setenv("NPM_TOKEN", "s3cr3t")
+	dst := tempDir(t)
+	client := &Client{
+		Src:  fmt.Sprintf("npm::http://%s/private?package=@acme/secret&untar=true", ln.Addr()),
+		Dst:  dst,
+		Mode: ClientModeAny,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "package.json"), `{"name":"@acme/secret","version":"0.1.0"}`)
+}
+
+func TestNpmGetter_bad(t *testing.T) {
+	ln := testNpmServer(t)
+	defer ln.Close()
+
+	g := new(NpmGetter)
+	for _, query := range []string{
+		"",
+		"package=missing",
+		"package=../left-pad",
+		"package=left-pad&version=^3",
+		"package=left-pad&version=abc",
+		"package=corrupt",
+	} {
+		u := testURL(fmt.Sprintf("http://%s/registry?%s", ln.Addr(), query))
+		if err := g.GetFile(tempFile(t), u); err == nil {
+			t.Fatalf("%s: should error", query)
+		}
+	}
+}
+
+// testNpmServer starts a registry at /registry, with the versions 1.0.0,
+// 1.1.3, 1.3.0 and 2.0.0-beta.1 of left-pad, tagged next, @acme/widget 1.0.0
+// and corrupt 1.0.0, whose integrity doesn't match, and one at /private
+// with @acme/secret 0.1.0, requiring the token s3cr3t.
+func testNpmServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	type dist struct {
+		Tarball   string `json:"tarball"`
+		Shasum    string `json:"shasum,omitempty"`
+		Integrity string `json:"integrity,omitempty"`
+	}
+	type version struct {
+		Dist dist `json:"dist"`
+	}
+	tarballs := map[string][]byte{}
+	packuments := map[string]map[string]interface{}{}
+	add := func(prefix, name, v, tag string) {
+		content := testNpmTarball(t, name, v)
+		basename := name[strings.LastIndex(name, "/")+1:] + "-" + v + ".tgz"
+		tarballPath := prefix + "/" + name + "/-/" + basename
+		tarballs[tarballPath] = content
+
+		// Scoped packages have an absolute tarball URL, others a relative one,
+		// and older versions a SHA-1 shasum only
+		d := dist{Tarball: name + "/-/" + basename}
+		if strings.HasPrefix(name, "@") {
+			d.Tarball = fmt.Sprintf("http://%s%s", ln.Addr(), tarballPath)
+		}
+		if v == "1.0.0" {
+			sum := sha1.Sum(content)
+			d.Shasum = hex.EncodeToString(sum[:])
+		} else {
+			sum := sha512.Sum512(content)
+			d.Integrity = "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+		}
+		if name == "corrupt" {
+			d.Integrity = "sha512-" + base64.StdEncoding.EncodeToString(make([]byte, sha512.Size))
+		}
+
+		key := prefix + "/" + name
+		if packuments[key] == nil {
+			packuments[key] = map[string]interface{}{
+				"name":      name,
+				"dist-tags": map[string]string{},
+				"versions":  map[string]version{},
+			}
+		}
+		packuments[key]["versions"].(map[string]version)[v] = version{Dist: d}
+		if tag != "" {
+			packuments[key]["dist-tags"].(map[string]string)[tag] = v
+		}
+	}
+	add("/registry", "left-pad", "1.0.0", "")
+	add("/registry", "left-pad", "1.1.3", "")
+	add("/registry", "left-pad", "1.3.0", "latest")
+	add("/registry", "left-pad", "2.0.0-beta.1", "next")
+	add("/registry", "@acme/widget", "1.0.0", "latest")
+	add("/registry", "corrupt", "1.0.0", "latest")
+	add("/private", "@acme/secret", "0.1.0", "latest")
+
+	mux := http.NewServeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/private/") && r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			w.WriteHeader(401)
+			return
+		}
+
+		if content, ok := tarballs[r.URL.Path]; ok {
+			w.Write(content)
+			return
+		}
+
+		// The names of scoped packages are escaped, Ex., '@acme%2fwidget'
+		if strings.Contains(r.URL.Path, "@") && !strings.Contains(r.URL.RawPath, "%2f") {
+			w.WriteHeader(404)
+			return
+		}
+		p, ok := packuments[r.URL.Path]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.npm.install-v1+json")
+		json.NewEncoder(w).Encode(p)
+	}
+	mux.HandleFunc("/registry/", handler)
+	mux.HandleFunc("/private/", handler)
+
+	var server http.Server
+	server.Handler = mux
+	go server.Serve(ln)
+
+	return ln
+}
+
+// testNpmTarball returns the tarball of a package with a package.json only,
+// in the top-level 'package' directory.
+func testNpmTarball(t *testing.T, name, version string) []byte {
+	var buf bytes.Buffer
+	gzipW := gzip.NewWriter(&buf)
+	tarW := tar.NewWriter(gzipW)
+	packageJSON := fmt.Sprintf(`{"name":"%s","version":"%s"}`, name, version)
+	if err := tarW.WriteHeader(&tar.Header{Name: "package/package.json", Mode: 0644, Size: int64(len(packageJSON))}); err != nil {
+		t.Fatal(err)
+	}
+	tarW.Write([]byte(packageJSON))
+	tarW.Close()
+	gzipW.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,301 @@
+package getter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// NugetGetter is a Getter implementation that will download a package from
+// a NuGet v3 feed, Ex., nuget.org or Azure Artifacts, resolving the newest
+// version in a range like NuGet does.
+//
+// URLs take the form nuget::https://[user[:password]@]host/v3/index.json?package=id,
+// where the URL is that of the service index of the feed. Private feeds
+// authenticate with basic auth, Ex., with a personal access token as the
+// password for Azure Artifacts, which is also sent to the resources of the
+// feed on the same host. The .nupkg is downloaded as a file, or extracted
+// into a directory with the extract query parameter.
+//
+// Query parameters:
+//   - package: the id of the package
+//   - version: the version, which is matched exactly, or range of versions
+//     in the syntax of NuGet, Ex., '[1.0,2.0)' or '1.*', default as the
+//     newest version that isn't a pre-release
+//   - extract: true to extract the package into a directory
+type NugetGetter struct {
+	httpBackedGetter
+}
+
+// nugetQueryParams are the query parameters of the NugetGetter, which
+// aren't part of the URL of the service index.
+var nugetQueryParams = []string{"package", "version", "extract"}
+
+func (g *NugetGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	if extract, _ := strconv.ParseBool(u.Query().Get("extract")); extract {
+		return ClientModeDir, nil
+	}
+	return ClientModeFile, nil
+}
+
+// GetFilename returns the name of the package of the resolved version, Ex.,
+// 'newtonsoft.json.13.0.3.nupkg'.
+func (g *NugetGetter) GetFilename(u *url.URL) (string, error) {
+	pkgURL, err := g.resolve(u)
+	if err != nil {
+		return "", err
+	}
+	return path.Base(pkgURL.Path), nil
+}
+
+// Get extracts the package into the directory dst.
+func (g *NugetGetter) Get(dst string, u *url.URL) error {
+	pkgURL, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+
+	td, err := ioutil.TempDir(g.tempDir(), g.tempPattern("nuget"))
+	if err != nil {
+		return err
+	}
+	defer g.removeTemp(td)
+
+	archive := filepath.Join(td, "package.nupkg")
+	if err := g.http().GetFile(archive, pkgURL); err != nil {
+		return err
+	}
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	// Packages are zip files
+	return g.decompressors()["zip"].Decompress(dst, archive, true)
+}
+
+func (g *NugetGetter) GetFile(dst string, u *url.URL) error {
+	pkgURL, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+	return g.http().GetFile(dst, pkgURL)
+}
+
+// resolve returns the URL of the .nupkg of the newest version of the
+// package of u in the version range.
+func (g *NugetGetter) resolve(u *url.URL) (*url.URL, error) {
+	q := u.Query()
+	id := strings.ToLower(q.Get("package"))
+	if id == "" {
+		return nil, fmt.Errorf("query parameter 'package' is required")
+	}
+	if strings.ContainsAny(id, "/\\") || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("invalid package id: %s", q.Get("package"))
+	}
+	r, err := nugetVersionRange(q.Get("version"))
+	if err != nil {
+		return nil, err
+	}
+
+	serviceIndex := *u
+	for _, k := range nugetQueryParams {
+		q.Del(k)
+	}
+	serviceIndex.RawQuery = q.Encode()
+
+	// The versions and packages are in the package base address resource
+	// of the feed, Ex., https://api.nuget.org/v3-flatcontainer/
+	var index struct {
+		Resources []struct {
+			ID   string `json:"@id"`
+			Type string `json:"@type"`
+		} `json:"resources"`
+	}
+	if err := g.getJSON(&serviceIndex, &index); err != nil {
+		return nil, err
+	}
+	var base *url.URL
+	for _, res := range index.Resources {
+		if res.Type != "PackageBaseAddress/3.0.0" {
+			continue
+		}
+		if base, err = g.feedURL(&serviceIndex, strings.TrimSuffix(res.ID, "/")+"/"); err != nil {
+			return nil, err
+		}
+		break
+	}
+	if base == nil {
+		return nil, fmt.Errorf("%s has no PackageBaseAddress/3.0.0 resource", redactURL(serviceIndex.String()))
+	}
+
+	versionsURL, err := g.feedURL(base, id+"/index.json")
+	if err != nil {
+		return nil, err
+	}
+	var versions struct {
+		Versions []string `json:"versions"`
+	}
+	if err := g.getJSON(versionsURL, &versions); err != nil {
+		return nil, err
+	}
+	latest, ok := r.latest(versions.Versions)
+	if !ok {
+		return nil, fmt.Errorf("no version of package %s matches %q", id, u.Query().Get("version"))
+	}
+
+	// The versions listed are normalized and lower case, as in the paths
+	latest = strings.ToLower(latest)
+	return g.feedURL(base, fmt.Sprintf("%s/%s/%s.%s.nupkg", id, latest, id, latest))
+}
+
+// feedURL returns the URL ref relative to base, which keeps the
+// credentials of base if it's on the same host.
+func (g *NugetGetter) feedURL(base *url.URL, ref string) (*url.URL, error) {
+	u, err := base.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q in %s: %s", ref, redactURL(base.String()), err)
+	}
+	if u.Host == base.Host && u.User == nil {
+		u.User = base.User
+	}
+	return u, nil
+}
+
+// getJSON downloads and parses the JSON document at u into v.
+func (g *NugetGetter) getJSON(u *url.URL, v interface{}) error {
+	reqURL := *u
+	httpGet := g.http()
+	if httpGet.Netrc {
+		if err := addAuthFromNetrc(&reqURL); err != nil {
+			return err
+		}
+	}
+
+	httpGet.initClient()
+	resp, err := httpGet.do("GET", &reqURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("bad response code getting %s: %d", redactURL(u.String()), resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(g.rateLimitReader(resp.Body)).Decode(v); err != nil {
+		return fmt.Errorf("error parsing %s: %s", redactURL(u.String()), err)
+	}
+	return nil
+}
+
+// nugetVersionRange returns the range of the NuGet version range expr, Ex.,
+// '[1.0,2.0)', '(,2.0]', '[1.2.3]', '1.*' or '*'. Unlike NuGet, which takes
+// it as a minimum, a version without brackets is matched exactly.
+func nugetVersionRange(expr string) (versionRange, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" || expr == "*" {
+		return parseVersionRange("")
+	}
+
+	// Floating versions, Ex., '1.*' or '1.2.*'
+	if strings.HasSuffix(expr, ".*") {
+		if strings.ContainsAny(expr, "[]()-") {
+			return nil, fmt.Errorf("unsupported floating version %q", expr)
+		}
+		return parseVersionRange("=" + expr)
+	}
+
+	if !strings.HasPrefix(expr, "[") && !strings.HasPrefix(expr, "(") {
+		v, err := nugetVersion(expr)
+		if err != nil {
+			return nil, err
+		}
+		return parseVersionRange("=" + v)
+	}
+
+	if len(expr) < 3 || !strings.HasSuffix(expr, "]") && !strings.HasSuffix(expr, ")") {
+		return nil, fmt.Errorf("invalid version range %q", expr)
+	}
+	bounds := strings.Split(expr[1:len(expr)-1], ",")
+	switch {
+	case len(bounds) == 1 && expr[0] == '[' && expr[len(expr)-1] == ']':
+		// An exact version, Ex., '[1.2.3]'
+		v, err := nugetVersion(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		return parseVersionRange("=" + v)
+	case len(bounds) != 2:
+		return nil, fmt.Errorf("invalid version range %q", expr)
+	}
+
+	var comparators []string
+	if lower := strings.TrimSpace(bounds[0]); lower != "" {
+		v, err := nugetVersion(lower)
+		if err != nil {
+			return nil, err
+		}
+		op := ">="
+		if expr[0] == '(' {
+			op = ">"
+		}
+		comparators = append(comparators, op+v)
+	}
+	if upper := strings.TrimSpace(bounds[1]); upper != "" {
+		v, err := nugetVersion(upper)
+		if err != nil {
+			return nil, err
+		}
+		op := "<="
+		if expr[len(expr)-1] == ')' {
+			op = "<"
+		}
+		comparators = append(comparators, op+v)
+	}
+	if len(comparators) == 0 {
+		return nil, fmt.Errorf("invalid version range %q", expr)
+	}
+	return parseVersionRange(strings.Join(comparators, " "))
+}
+
+// nugetVersion returns the NuGet version v with three parts, as partial
+// versions are complete in NuGet, Ex., '1.2' is '1.2.0'. A fourth part of
+// zero is dropped, as NuGet normalizes it.
+func nugetVersion(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	pre := ""
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v, pre = v[:i], v[i:]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) == 4 && parts[3] == "0" {
+		parts = parts[:3]
+	}
+	if len(parts) > 3 {
+		return "", fmt.Errorf("unsupported version %q", v+pre)
+	}
+	for _, p := range parts {
+		if _, err := strconv.Atoi(p); err != nil {
+			return "", fmt.Errorf("invalid version %q", v+pre)
+		}
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	return strings.Join(parts, ".") + pre, nil
+}
@@ -0,0 +1,246 @@
+package getter
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNugetGetter_impl(t *testing.T) {
+	var _ Getter = new(NugetGetter)
+}
+
+func TestNugetVersionRange(t *testing.T) {
+	versions := []string{"1.0.0", "1.2.0", "1.2.5", "2.0.0-beta.1", "2.0.0", "2.1.0", "invalid"}
+
+	cases := []struct {
+		Range  string
+		Latest string
+	}{
+		{"", "2.1.0"},
+		{"*", "2.1.0"},
+		{"1.2", "1.2.0"},
+		{"1.2.5", "1.2.5"},
+		{"1.2.0.0", "1.2.0"},
+		{"[1.2.5]", "1.2.5"},
+		{"1.*", "1.2.5"},
+		{"1.2.*", "1.2.5"},
+		{"[1.0,2.0)", "1.2.5"},
+		{"[1.0, 2.0]", "2.0.0"},
+		{"(1.0,1.2.5)", "1.2.0"},
+		{"(,1.2]", "1.2.0"},
+		{"[2.0,)", "2.1.0"},
+		{"2.0.0-beta.1", "2.0.0-beta.1"},
+		{"[3.0,)", ""},
+	}
+	for _, tc := range cases {
+		r, err := nugetVersionRange(tc.Range)
+		if err != nil {
+			t.Fatalf("%q: err: %s", tc.Range, err)
+		}
+		latest, ok := r.latest(versions)
+		if latest != tc.Latest || ok != (tc.Latest != "") {
+			t.Fatalf("%q: bad latest: %q", tc.Range, latest)
+		}
+	}
+
+	for _, expr := range []string{"abc", "[1.0", "[1.0)", "(,)", "[1,2,3]", "1.2.3.4", "1.0-*"} {
+		if _, err := nugetVersionRange(expr); err == nil {
+			t.Fatalf("%q: should error", expr)
+		}
+	}
+}
+
+func TestNugetGetter_file(t *testing.T) {
+	ln := testNugetServer(t)
+	defer ln.Close()
+
+	g := new(NugetGetter)
+	cases := []struct {
+		Query    string
+		Filename string
+	}{
+		{"package=Demo.Lib", "demo.lib.2.0.0.nupkg"},
+		{"package=demo.lib&version=[1.0,2.0)", "demo.lib.1.1.0.nupkg"},
+		{"package=Demo.Lib&version=1.0.0", "demo.lib.1.0.0.nupkg"},
+		{"package=Demo.Lib&version=3.0.0-rc.1", "demo.lib.3.0.0-rc.1.nupkg"},
+	}
+	for _, tc := range cases {
+		u := testURL(fmt.Sprintf("http://%s/v3/index.json?%s", ln.Addr(), tc.Query))
+
+		mode, err := g.ClientMode(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		if mode != ClientModeFile {
+			t.Fatalf("%s: bad mode: %d", tc.Query, mode)
+		}
+
+		filename, err := g.GetFilename(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		if filename != tc.Filename {
+			t.Fatalf("%s: bad filename: %s", tc.Query, filename)
+		}
+
+		if err := g.GetFile(tempFile(t), u); err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+	}
+}
+
+func TestNugetGetter_extract(t *testing.T) {
+	ln := testNugetServer(t)
+	defer ln.Close()
+
+	g := new(NugetGetter)
+	u := testURL(fmt.Sprintf("http://%s/v3/index.json?package=Demo.Lib&version=1.*&extract=true", ln.Addr()))
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempDir(t)
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "Demo.Lib.nuspec"), "Demo.Lib 1.1.0")
+	assertContents(t, filepath.Join(dst, "lib", "net6.0", "Demo.Lib.dll"), "Demo.Lib 1.1.0")
+}
+
+func TestNugetGetter_bad(t *testing.T) {
+	ln := testNugetServer(t)
+	defer ln.Close()
+
+	g := new(NugetGetter)
+	for _, query := range []string{
+		"",
+		"package=missing",
+		"package=../demo.lib",
+		"package=Demo.Lib&version=[4.0,)",
+		"package=Demo.Lib&version=abc",
+	} {
+		u := testURL(fmt.Sprintf("http://%s/v3/index.json?%s", ln.Addr(), query))
+		if err := g.GetFile(tempFile(t), u); err == nil {
+			t.Fatalf("%s: should error", query)
+		}
+	}
+
+	// The service index has no package base address
+	u := testURL(fmt.Sprintf("http://%s/empty/index.json?package=Demo.Lib", ln.Addr()))
+	if err := g.GetFile(tempFile(t), u); err == nil {
+		t.Fatal("should error")
+	}
+
+	// The private feed requires basic auth
+	u = testURL(fmt.Sprintf("http://%s/private/v3/index.json?package=Demo.Lib", ln.Addr()))
+	if err := g.GetFile(tempFile(t), u); err == nil {
+		t.Fatal("should error without credentials")
+	}
+}
+
+func TestNugetGetter_client(t *testing.T) {
+	ln := testNugetServer(t)
+	defer ln.Close()
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:  fmt.Sprintf("nuget::http://user:pat@%s/private/v3/index.json?package=Demo.Lib&version=[1.0.0]&extract=true", ln.Addr()),
+		Dst:  dst,
+		Mode: ClientModeAny,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "Demo.Lib.nuspec"), "Demo.Lib 1.0.0")
+}
+
+// testNugetServer starts a feed with its service index at /v3/index.json,
+// one requiring basic auth as user:pat at /private/v3/index.json, and one
+// without a package base address at /empty/index.json. Demo.Lib has the
+// versions 1.0.0, 1.1.0, 2.0.0 and 3.0.0-rc.1.
+func testNugetServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	versions := []string{"1.0.0", "1.1.0", "2.0.0", "3.0.0-rc.1"}
+	packages := map[string][]byte{}
+	for _, v := range versions {
+		packages[fmt.Sprintf("demo.lib/%s/demo.lib.%s.nupkg", v, v)] = testNugetPackage(t, "Demo.Lib", v)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path
+		if strings.HasPrefix(p, "/private/") {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pat" {
+				w.WriteHeader(401)
+				return
+			}
+			p = strings.TrimPrefix(p, "/private")
+		}
+
+		switch {
+		case p == "/v3/index.json":
+			// The package base address is absolute
+			base := fmt.Sprintf("http://%s%s", ln.Addr(), strings.TrimSuffix(r.URL.Path, "/v3/index.json")+"/flat")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"version": "3.0.0",
+				"resources": []map[string]string{
+					{"@id": base + "/query", "@type": "SearchQueryService"},
+					{"@id": base, "@type": "PackageBaseAddress/3.0.0"},
+				},
+			})
+		case p == "/empty/index.json":
+			fmt.Fprint(w, `{"version": "3.0.0", "resources": []}`)
+		case p == "/flat/demo.lib/index.json":
+			json.NewEncoder(w).Encode(map[string][]string{"versions": versions})
+		case strings.HasPrefix(p, "/flat/"):
+			content, ok := packages[strings.TrimPrefix(p, "/flat/")]
+			if !ok {
+				w.WriteHeader(404)
+				return
+			}
+			w.Write(content)
+		default:
+			w.WriteHeader(404)
+		}
+	})
+
+	var server http.Server
+	server.Handler = mux
+	go server.Serve(ln)
+
+	return ln
+}
+
+// testNugetPackage returns a package with a .nuspec and an assembly, whose
+// contents are the id and version.
+func testNugetPackage(t *testing.T, id, version string) []byte {
+	var buf bytes.Buffer
+	zipW := zip.NewWriter(&buf)
+	for _, name := range []string{id + ".nuspec", "lib/net6.0/" + id + ".dll"} {
+		w, err := zipW.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(id + " " + version))
+	}
+	if err := zipW.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,601 @@
+package getter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// OCIGetter is a Getter implementation that will download the primary layer
+// of an image or artifact from an OCI registry, such as a Docker registry.
+//
+// The reference is given as oci://registry/repository:tag, or with a digest
+// as oci://registry/repository@sha256:digest. If the tag is omitted, "latest"
+// is used. To talk to a registry over plain HTTP, force the getter on an
+// HTTP URL instead: oci::http://registry/repository:tag.
+//
+// The registry is accessed with the username and password given in the URL,
+// or else with the credentials stored for it by docker login in the Docker
+// config file, including with credential helpers. They are used for basic
+// auth and to request bearer tokens from the registry's token service.
+// Without any, the registry is accessed anonymously.
+//
+// The primary layer is the first layer of the manifest, or the first one of
+// the media type given by the mediaType query parameter, which can be a
+// pattern, Ex., "application/vnd.oci.image.layer.v1.*". If it is a tar
+// layer, it is unpacked into the destination directory, otherwise it is
+// downloaded as a single file. Artifacts of several files, as pushed by
+// ORAS, are downloaded as a directory, every layer being saved under its
+// title annotation.
+type OCIGetter struct {
+	getter
+
+	// Client is the http.Client to use for requests to the registry.
+	// This defaults to a cleanhttp.DefaultClient if left unset.
+	Client *http.Client
+}
+
+// ociManifestTypes are the manifest media types accepted from the registry.
+var ociManifestTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// ociManifest is an OCI image manifest, or Docker image manifest v2.
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// ociDescriptor describes a blob in the registry.
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// ociRef is a parsed reference to an image or artifact in a registry.
+type ociRef struct {
+	// Base is the base URL of the registry, Ex., 'https://ghcr.io'
+	Base string
+
+	Repository string
+	Reference  string
+
+	Username string
+	Password string
+}
+
+func (g *OCIGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	layers, _, err := g.layers(u)
+	if err != nil {
+		return 0, err
+	}
+
+	if ociLayerArchive(layers[0].MediaType) != "" || len(layers) > 1 {
+		return ClientModeDir, nil
+	}
+	return ClientModeFile, nil
+}
+
+// GetFilename returns the title annotation of the primary layer, if any.
+func (g *OCIGetter) GetFilename(u *url.URL) (string, error) {
+	layer, _, err := g.primaryLayer(u)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Base(layer.Annotations["org.opencontainers.image.title"]), nil
+}
+
+func (g *OCIGetter) Get(dst string, u *url.URL) error {
+	layers, ref, err := g.layers(u)
+	if err != nil {
+		return err
+	}
+
+	layer := &layers[0]
+	archiveV := ociLayerArchive(layer.MediaType)
+	if archiveV == "" {
+		return g.getArtifact(dst, ref, layers)
+	}
+
+	// Decompressors work on files, so download the layer first
+	td, err := ioutil.TempDir(g.tempDir(), "getter")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(td)
+
+	layerPath := filepath.Join(td, "layer")
+	if err := g.getBlob(layerPath, ref, layer); err != nil {
+		return err
+	}
+
+	var decompressor Decompressor = new(tarDecompressor)
+	if archiveV != "tar" {
+		decompressor = g.decompressors()[archiveV]
+		if decompressor == nil {
+			return fmt.Errorf("no decompressor for archive type %q", archiveV)
+		}
+	}
+	return decompressor.Decompress(dst, layerPath, true)
+}
+
+func (g *OCIGetter) GetFile(dst string, u *url.URL) error {
+	layer, ref, err := g.primaryLayer(u)
+	if err != nil {
+		return err
+	}
+
+	return g.getBlob(dst, ref, layer)
+}
+
+// getArtifact downloads the layers with a title annotation into the
+// directory dst, under their title.
+func (g *OCIGetter) getArtifact(dst string, ref *ociRef, layers []ociDescriptor) error {
+	found := false
+	for i := range layers {
+		layer := &layers[i]
+		title := layer.Annotations["org.opencontainers.image.title"]
+		if title == "" {
+			continue
+		}
+		if containsDotDot(title) || path.IsAbs(title) {
+			return fmt.Errorf("layer %s has an invalid title: %s", layer.Digest, title)
+		}
+
+		found = true
+		if err := g.getBlob(filepath.Join(dst, filepath.FromSlash(title)), ref, layer); err != nil {
+			return err
+		}
+	}
+
+	if !found {
+		return fmt.Errorf(
+			"layer %s of %s is not a tar layer, and no layers have a title: %s",
+			layers[0].Digest, ref.Repository, layers[0].MediaType)
+	}
+	return nil
+}
+
+// primaryLayer fetches the manifest for the reference in u, and returns
+// its primary layer.
+func (g *OCIGetter) primaryLayer(u *url.URL) (*ociDescriptor, *ociRef, error) {
+	layers, ref, err := g.layers(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &layers[0], ref, nil
+}
+
+// layers fetches the manifest for the reference in u, and returns its
+// layers, only those of the media type of the mediaType query parameter if
+// given.
+func (g *OCIGetter) layers(u *url.URL) ([]ociDescriptor, *ociRef, error) {
+	ref, err := parseOCIRef(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ref.Username == "" {
+		ref.Username, ref.Password, err = ociDockerCredentials(u.Host)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", ref.Base, ref.Repository, ref.Reference)
+	resp, err := g.request(ref, manifestURL, strings.Join(ociManifestTypes, ", "))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	var manifest ociManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, nil, fmt.Errorf("error parsing manifest %s: %s", manifestURL, err)
+	}
+	if len(manifest.Layers) == 0 {
+		return nil, nil, fmt.Errorf(
+			"manifest %s has no layers, manifest lists are not supported", manifestURL)
+	}
+
+	mediaType := u.Query().Get("mediaType")
+	if mediaType == "" {
+		return manifest.Layers, ref, nil
+	}
+	var layers []ociDescriptor
+	for _, layer := range manifest.Layers {
+		match, err := path.Match(mediaType, layer.MediaType)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid mediaType pattern %q: %s", mediaType, err)
+		}
+		if match {
+			layers = append(layers, layer)
+		}
+	}
+	if len(layers) == 0 {
+		return nil, nil, fmt.Errorf("manifest %s has no layers of media type %s", manifestURL, mediaType)
+	}
+
+	return layers, ref, nil
+}
+
+// getBlob downloads the blob described by layer to dst, and verifies its
+// digest.
+func (g *OCIGetter) getBlob(dst string, ref *ociRef, layer *ociDescriptor) error {
+	if !strings.HasPrefix(layer.Digest, "sha256:") {
+		return fmt.Errorf("unsupported digest: %s", layer.Digest)
+	}
+
+	blobURL := fmt.Sprintf("%s/v2/%s/blobs/%s", ref.Base, ref.Repository, layer.Digest)
+	resp, err := g.request(ref, blobURL, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), g.rateLimitReader(resp.Body)); err != nil {
+		return err
+	}
+
+	if actual := "sha256:" + hex.EncodeToString(h.Sum(nil)); actual != layer.Digest {
+		return fmt.Errorf("digest mismatch for %s: expected %s, got %s", blobURL, layer.Digest, actual)
+	}
+
+	return nil
+}
+
+// request issues a GET request to the registry. If the registry requires
+// authentication, the request is retried with basic auth or a bearer token
+// as asked for by its WWW-Authenticate challenge.
+func (g *OCIGetter) request(ref *ociRef, rawURL, accept string) (*http.Response, error) {
+	if g.Client == nil {
+		g.Client = httpClient
+	}
+
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(g.ctx())
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == 401 {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		req, err = newRequest()
+		if err != nil {
+			return nil, err
+		}
+		if err := g.authorize(req, ref, challenge); err != nil {
+			return nil, err
+		}
+		resp, err = g.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("bad response code from %s: %d", rawURL, resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+// authorize adds the authorization asked for by the challenge to req.
+func (g *OCIGetter) authorize(req *http.Request, ref *ociRef, challenge string) error {
+	scheme, params := parseOCIChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if ref.Username == "" {
+			return fmt.Errorf("registry requires basic auth, but no credentials were given")
+		}
+		req.SetBasicAuth(ref.Username, ref.Password)
+		return nil
+
+	case "bearer":
+		token, err := g.token(ref, params)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported registry auth challenge: %q", challenge)
+	}
+}
+
+// token requests a bearer token from the token service of the registry.
+func (g *OCIGetter) token(ref *ociRef, params map[string]string) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("bearer auth challenge has no realm")
+	}
+
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", err
+	}
+	q := tokenURL.Query()
+	if v := params["service"]; v != "" {
+		q.Set("service", v)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", ref.Repository)
+	}
+	q.Set("scope", scope)
+	tokenURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(g.ctx())
+	if ref.Username != "" {
+		req.SetBasicAuth(ref.Username, ref.Password)
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("bad response code from token service %s: %d", realm, resp.StatusCode)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("error parsing token from %s: %s", realm, err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("no token returned by %s", realm)
+}
+
+// parseOCIRef parses the reference in u, Ex., 'oci://ghcr.io/org/repo:1.0'
+func parseOCIRef(u *url.URL) (*ociRef, error) {
+	scheme := u.Scheme
+	switch scheme {
+	case "oci":
+		scheme = "https"
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported scheme for OCI reference: %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("OCI reference has no registry: %s", u)
+	}
+
+	repository := strings.Trim(u.Path, "/")
+	reference := "latest"
+	if idx := strings.Index(repository, "@"); idx > -1 {
+		repository, reference = repository[:idx], repository[idx+1:]
+	} else if idx := strings.LastIndex(repository, ":"); idx > strings.LastIndex(repository, "/") {
+		repository, reference = repository[:idx], repository[idx+1:]
+	}
+	if repository == "" || reference == "" {
+		return nil, fmt.Errorf("invalid OCI reference: %s", u)
+	}
+
+	ref := &ociRef{
+		Base:       fmt.Sprintf("%s://%s", scheme, u.Host),
+		Repository: repository,
+		Reference:  reference,
+	}
+	if u.User != nil {
+		ref.Username = u.User.Username()
+		ref.Password, _ = u.User.Password()
+	}
+
+	return ref, nil
+}
+
+// ociDockerCredentials returns the username and password stored by docker
+// login for the registry host, in the Docker config file or its credential
+// helper, or empty strings if there are none.
+func ociDockerCredentials(host string) (string, string, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", "", nil
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	configPath := filepath.Join(dir, "config.json")
+	data, err := ioutil.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	var config struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+		CredsStore  string            `json:"credsStore"`
+		CredHelpers map[string]string `json:"credHelpers"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", "", fmt.Errorf("error parsing Docker config %s: %s", configPath, err)
+	}
+
+	// Docker Hub credentials are stored under its legacy index URL
+	server := host
+	if host == "docker.io" || host == "registry-1.docker.io" || host == "index.docker.io" {
+		server = "https://index.docker.io/v1/"
+	}
+
+	helper := config.CredHelpers[host]
+	if helper == "" {
+		helper = config.CredsStore
+	}
+	if helper != "" {
+		return ociCredentialHelper(helper, server)
+	}
+
+	for _, key := range []string{server, "https://" + host, "http://" + host} {
+		auth, ok := config.Auths[key]
+		if !ok {
+			continue
+		}
+		if auth.Auth == "" {
+			return auth.Username, auth.Password, nil
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid auth for %s in Docker config %s: %s", key, configPath, err)
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return "", "", fmt.Errorf("invalid auth for %s in Docker config %s", key, configPath)
+		}
+		return parts[0], parts[1], nil
+	}
+
+	return "", "", nil
+}
+
+// ociCredentialHelper returns the username and password of server from the
+// Docker credential helper, Ex., "desktop" for docker-credential-desktop, or
+// empty strings if it has none.
+func ociCredentialHelper(helper, server string) (string, string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stdout.String(), "credentials not found") {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("error running docker-credential-%s: %s: %s",
+			helper, err, strings.TrimSpace(stdout.String()))
+	}
+
+	var creds struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return "", "", fmt.Errorf("error parsing the output of docker-credential-%s: %s", helper, err)
+	}
+	return creds.Username, creds.Secret, nil
+}
+
+// parseOCIChallenge parses a WWW-Authenticate challenge, Ex.,
+// 'Bearer realm="https://auth.docker.io/token",service="registry.docker.io"'
+func parseOCIChallenge(challenge string) (string, map[string]string) {
+	params := make(map[string]string)
+
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	if len(parts) < 2 {
+		return parts[0], params
+	}
+
+	rest := parts[1]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(rest[:eq])
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.Index(rest, ","); comma > -1 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+
+		params[strings.ToLower(key)] = value
+		rest = strings.TrimLeft(rest, ", ")
+	}
+
+	return parts[0], params
+}
+
+// ociLayerArchive returns the key of the Decompressor for a tar layer of
+// the given media type, Ex., 'tar.gz' for
+// 'application/vnd.oci.image.layer.v1.tar+gzip', or an empty string if
+// it isn't a tar layer.
+func ociLayerArchive(mediaType string) string {
+	switch {
+	case strings.HasSuffix(mediaType, ".tar+gzip"), strings.HasSuffix(mediaType, ".tar.gzip"):
+		return "tar.gz"
+	case strings.HasSuffix(mediaType, ".tar+xz"):
+		return "tar.xz"
+	case strings.HasSuffix(mediaType, ".tar"):
+		return "tar"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,343 @@
+package getter
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOCIGetter_impl(t *testing.T) {
+	var _ Getter = new(OCIGetter)
+}
+
+func TestOCIGetter_parseOCIRef(t *testing.T) {
+	cases := []struct {
+		Input      string
+		Base       string
+		Repository string
+		Reference  string
+		Err        bool
+	}{
+		{"oci://ghcr.io/org/repo:1.0", "https://ghcr.io", "org/repo", "1.0", false},
+		{"oci://ghcr.io/org/repo", "https://ghcr.io", "org/repo", "latest", false},
+		{"oci://localhost:5000/repo@sha256:abc", "https://localhost:5000", "repo", "sha256:abc", false},
+		{"http://localhost:5000/org/repo:dev", "http://localhost:5000", "org/repo", "dev", false},
+		{"oci://ghcr.io/", "", "", "", true},
+		{"ftp://ghcr.io/repo:1.0", "", "", "", true},
+	}
+
+	for _, tc := range cases {
+		ref, err := parseOCIRef(testURL(tc.Input))
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %s", tc.Input, err)
+		}
+		if tc.Err {
+			continue
+		}
+		if ref.Base != tc.Base || ref.Repository != tc.Repository || ref.Reference != tc.Reference {
+			t.Fatalf("%s: bad: %#v", tc.Input, ref)
+		}
+	}
+}
+
+func TestOCIGetter_file(t *testing.T) {
+	ln := testOCIRegistry(t)
+	defer ln.Close()
+
+	g := new(OCIGetter)
+	u := testOCIURL(ln, "test/file:1.0")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeFile {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	filename, err := g.GetFilename(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if filename != "hello.txt" {
+		t.Fatalf("bad filename: %s", filename)
+	}
+
+	dst := tempFile(t)
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestOCIGetter_dir(t *testing.T) {
+	ln := testOCIRegistry(t)
+	defer ln.Close()
+
+	g := new(OCIGetter)
+	u := testOCIURL(ln, "test/module:1.0")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempDir(t)
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	mainPath := filepath.Join(dst, "main.tf")
+	if _, err := os.Stat(mainPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestOCIGetter_bearerAuth(t *testing.T) {
+	ln := testOCIRegistry(t)
+	defer ln.Close()
+
+	g := new(OCIGetter)
+
+	// Anonymous access is denied by the token service
+	dst := tempFile(t)
+	if err := g.GetFile(dst, testOCIURL(ln, "private/file:1.0")); err == nil {
+		t.Fatal("should error")
+	}
+
+	u := testOCIURL(ln, "private/file:1.0")
+	u.User = url.UserPassword("foo", "bar")
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestOCIGetter_badDigest(t *testing.T) {
+	ln := testOCIRegistry(t)
+	defer ln.Close()
+
+	g := new(OCIGetter)
+	dst := tempFile(t)
+	err := g.GetFile(dst, testOCIURL(ln, "test/corrupt:1.0"))
+	if err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Fatalf("expected digest mismatch, got: %v", err)
+	}
+}
+
+func TestOCIGetter_artifact(t *testing.T) {
+	ln := testOCIRegistry(t)
+	defer ln.Close()
+
+	g := new(OCIGetter)
+	u := testOCIURL(ln, "test/artifact:1.0")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempDir(t)
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "config", "config.json"), "{}\n")
+	assertContents(t, filepath.Join(dst, "hello.txt"), "Hello\n")
+}
+
+func TestOCIGetter_mediaType(t *testing.T) {
+	ln := testOCIRegistry(t)
+	defer ln.Close()
+
+	g := new(OCIGetter)
+	for _, mediaType := range []string{"text/plain", "text/*"} {
+		u := testOCIURL(ln, "test/artifact:1.0")
+		u.RawQuery = "mediaType=" + url.QueryEscape(mediaType)
+
+		mode, err := g.ClientMode(u)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if mode != ClientModeFile {
+			t.Fatalf("bad mode: %d", mode)
+		}
+
+		dst := tempFile(t)
+		if err := g.GetFile(dst, u); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		assertContents(t, dst, "Hello\n")
+	}
+
+	u := testOCIURL(ln, "test/artifact:1.0")
+	u.RawQuery = "mediaType=image/png"
+	if err := g.GetFile(tempFile(t), u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestOCIGetter_dockerConfig(t *testing.T) {
+	ln := testOCIRegistry(t)
+	defer ln.Close()
+
+	dir := tempDir(t)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	config := fmt.Sprintf(`{"auths": {%q: {"auth": %q}}}`,
+		ln.Addr().String(), base64.StdEncoding.EncodeToString([]byte("foo:bar")))
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	old := os.Getenv("DOCKER_CONFIG")
+	os.Setenv("DOCKER_CONFIG", dir)
+	defer os.Setenv("DOCKER_CONFIG", old)
+
+	g := new(OCIGetter)
+	dst := tempFile(t)
+	if err := g.GetFile(dst, testOCIURL(ln, "private/file:1.0")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestOCIGetter_client(t *testing.T) {
+	ln := testOCIRegistry(t)
+	defer ln.Close()
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:  "oci::" + testOCIURL(ln, "test/file:1.0").String(),
+		Dst:  dst,
+		Mode: ClientModeAny,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "hello.txt"), "Hello\n")
+}
+
+func testOCIURL(ln net.Listener, ref string) *url.URL {
+	return testURL(fmt.Sprintf("http://%s/%s", ln.Addr().String(), ref))
+}
+
+// testOCIRegistry starts a minimal registry serving the repositories:
+//   - test/file: a single file layer
+//   - test/module: a tar+gzip layer of test-fixtures/archive.tar.gz
+//   - test/corrupt: a layer whose content doesn't match its digest
+//   - test/artifact: an artifact of a JSON file and a text file
+//   - private/file: test/file, behind bearer auth for foo:bar
+func testOCIRegistry(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	archive, err := ioutil.ReadFile(filepath.Join(fixtureDir, "archive.tar.gz"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	blobs := make(map[string][]byte)
+	digest := func(b []byte) string {
+		h := sha256.Sum256(b)
+		d := "sha256:" + hex.EncodeToString(h[:])
+		blobs[d] = b
+		return d
+	}
+
+	fileLayer := ociDescriptor{
+		MediaType:   "text/plain",
+		Digest:      digest([]byte("Hello\n")),
+		Annotations: map[string]string{"org.opencontainers.image.title": "hello.txt"},
+	}
+	corruptLayer := ociDescriptor{
+		MediaType: "text/plain",
+		Digest:    "sha256:" + strings.Repeat("0", 64),
+	}
+	blobs[corruptLayer.Digest] = []byte("corrupt\n")
+
+	configLayer := ociDescriptor{
+		MediaType:   "application/vnd.example.config.v1+json",
+		Digest:      digest([]byte("{}\n")),
+		Annotations: map[string]string{"org.opencontainers.image.title": "config/config.json"},
+	}
+
+	manifests := map[string]ociManifest{
+		"test/artifact": {Layers: []ociDescriptor{configLayer, fileLayer}},
+		"test/file":     {Layers: []ociDescriptor{fileLayer}},
+		"private/file":  {Layers: []ociDescriptor{fileLayer}},
+		"test/corrupt":  {Layers: []ociDescriptor{corruptLayer}},
+		"test/module": {Layers: []ociDescriptor{{
+			MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+			Digest:    digest(archive),
+		}}},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "foo" || pass != "bar" {
+			w.WriteHeader(401)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": "secret"})
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/v2/")
+		if strings.HasPrefix(path, "private/") && r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm="http://%s/token",service="test",scope="repository:private/file:pull"`,
+				ln.Addr().String()))
+			w.WriteHeader(401)
+			return
+		}
+
+		if idx := strings.Index(path, "/manifests/"); idx > -1 {
+			manifest, ok := manifests[path[:idx]]
+			if !ok {
+				w.WriteHeader(404)
+				return
+			}
+			w.Header().Set("Content-Type", ociManifestTypes[0])
+			json.NewEncoder(w).Encode(manifest)
+			return
+		}
+
+		if idx := strings.Index(path, "/blobs/"); idx > -1 {
+			blob, ok := blobs[path[idx+len("/blobs/"):]]
+			if !ok {
+				w.WriteHeader(404)
+				return
+			}
+			w.Write(blob)
+			return
+		}
+
+		w.WriteHeader(404)
+	})
+
+	var server http.Server
+	server.Handler = mux
+	go server.Serve(ln)
+
+	return ln
+}
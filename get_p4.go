@@ -0,0 +1,167 @@
+package getter
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// P4Getter is a Getter implementation that will sync a depot path from a
+// Perforce server using the p4 command.
+//
+// URLs take the form p4://[user[:ticket]@]host[:port]/depot/path, where the
+// path is the depot path without its leading slash, or p4:///depot/path to
+// use the server of P4PORT. The user and ticket are passed to p4 in P4USER
+// and P4PASSWD; without them, those of the environment and the tickets file
+// of p4 login are used.
+//
+// Directories are synced with a temporary client workspace, which is deleted
+// afterwards, and files are printed without one.
+//
+// Query parameters:
+//   - change: the changelist to sync
+//   - label: the label to sync
+//   - ssl: true to connect to the server over SSL
+type P4Getter struct {
+	getter
+}
+
+func (g *P4Getter) ClientMode(_ *url.URL) (ClientMode, error) {
+	return ClientModeDir, nil
+}
+
+func (g *P4Getter) GetFilename(u *url.URL) (string, error) {
+	return "", nil
+}
+
+func (g *P4Getter) Get(dst string, u *url.URL) error {
+	depotPath, rev, err := g.parse(u)
+	if err != nil {
+		return err
+	}
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	dst, err = filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+
+	// The client workspace maps the depot path to the destination
+	b := make([]byte, 8)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return err
+	}
+	client := "go-getter-" + hex.EncodeToString(b)
+	spec := fmt.Sprintf("Client: %s\nRoot: %s\nOptions: allwrite clobber nocompress unlocked nomodtime rmdir\n"+
+		"LineEnd: local\nView:\n\t\"%s/...\" \"//%s/...\"\n", client, dst, depotPath, client)
+	if err := g.run(u, strings.NewReader(spec), "client", "-i"); err != nil {
+		return err
+	}
+
+	err = g.run(u, nil, "-c", client, "sync", "-q", depotPath+"/..."+rev)
+	if err == nil {
+		// The have list of the client must be empty to delete it, which
+		// doesn't touch the synced files
+		err = g.run(u, nil, "-c", client, "sync", "-k", "-q", depotPath+"/...#none")
+	}
+	if derr := g.run(u, nil, "client", "-d", client); err == nil {
+		err = derr
+	}
+	return err
+}
+
+func (g *P4Getter) GetFile(dst string, u *url.URL) error {
+	depotPath, rev, err := g.parse(u)
+	if err != nil {
+		return err
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	return g.run(u, nil, "print", "-q", "-o", dst, depotPath+rev)
+}
+
+// parse returns the depot path of u, and the revision specifier, such as
+// "@1234", to append to it from the query of u.
+func (g *P4Getter) parse(u *url.URL) (string, string, error) {
+	depotPath := strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/...")
+	if len(depotPath) < 2 || strings.ContainsAny(depotPath, "@#*%\"") ||
+		strings.Contains(depotPath, "...") {
+		return "", "", fmt.Errorf("invalid depot path: %s", u.Path)
+	}
+
+	q := u.Query()
+	change, label := q.Get("change"), q.Get("label")
+	if change != "" && label != "" {
+		return "", "", fmt.Errorf("only one of change and label can be given")
+	}
+	if strings.ContainsAny(change+label, "@#/\" ") {
+		return "", "", fmt.Errorf("invalid revision: %s", change+label)
+	}
+	var rev string
+	if change != "" || label != "" {
+		rev = "@" + change + label
+	}
+
+	return "/" + depotPath, rev, nil
+}
+
+// run runs p4 with the arguments and stdin, connected to the server of u as
+// its user.
+func (g *P4Getter) run(u *url.URL, stdin io.Reader, args ...string) error {
+	if _, err := exec.LookPath("p4"); err != nil {
+		return fmt.Errorf("p4 must be available and on the PATH")
+	}
+
+	env := os.Environ()
+	if u.Host != "" {
+		port := u.Host
+		if ssl, _ := strconv.ParseBool(u.Query().Get("ssl")); ssl {
+			port = "ssl:" + port
+		}
+		env = append(env, "P4PORT="+port)
+	}
+	if u.User != nil {
+		env = append(env, "P4USER="+u.User.Username())
+		if ticket, ok := u.User.Password(); ok {
+			env = append(env, "P4PASSWD="+ticket)
+		}
+	}
+
+	cmd := exec.CommandContext(g.ctx(), "p4", args...)
+	cmd.Env = env
+	cmd.Stdin = stdin
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error running p4: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
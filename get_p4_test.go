@@ -0,0 +1,119 @@
+package getter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestP4Getter_impl(t *testing.T) {
+	var _ Getter = new(P4Getter)
+}
+
+func TestP4Getter_command(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake p4 is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "go-getter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The fake p4 records its environment and arguments, and the client
+	// spec on stdin. Syncing creates main.tf at the root of the client,
+	// and printing creates the output file.
+	logPath := filepath.Join(dir, "log")
+	specPath := filepath.Join(dir, "spec")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$P4PORT $P4USER $P4PASSWD $@" >> %s
+case "$*" in
+  "client -i") cat > %s ;;
+  *"sync -q"*) root=$(sed -n 's/^Root: //p' %s); mkdir -p "$root"; echo hello > "$root/main.tf" ;;
+  print*) echo hello > "$4" ;;
+esac
+`, logPath, specPath, specPath)
+	if err := ioutil.WriteFile(filepath.Join(dir, "p4"), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(vs ...string) {
+		os.Setenv("PATH", vs[0])
+		os.Setenv("P4PORT", vs[1])
+		os.Setenv("P4USER", vs[2])
+		os.Setenv("P4PASSWD", vs[3])
+	}(os.Getenv("PATH"), os.Getenv("P4PORT"), os.Getenv("P4USER"), os.Getenv("P4PASSWD"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	os.Setenv("P4PORT", "env:1666")
+	os.Setenv("P4USER", "envuser")
+	os.Setenv("P4PASSWD", "")
+
+	assertLog := func(expected string) {
+		actual, err := ioutil.ReadFile(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(actual)) != expected {
+			t.Fatalf("bad log:\n%s\nexpected:\n%s", actual, expected)
+		}
+		os.Remove(logPath)
+	}
+
+	g := new(P4Getter)
+	mode, err := g.ClientMode(testURL("p4://perforce:1666/depot/main"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := filepath.Join(tempDir(t), "dst")
+	u := testURL("p4://bob:TICKET@perforce:1666/depot/main/...?change=1234&ssl=true")
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "hello\n")
+
+	spec, err := ioutil.ReadFile(specPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := regexp.MustCompile(`Client: (go-getter-[0-9a-f]+)`).FindStringSubmatch(string(spec))
+	if client == nil {
+		t.Fatalf("bad spec:\n%s", spec)
+	}
+	if !strings.Contains(string(spec), fmt.Sprintf("Root: %s\n", dst)) ||
+		!strings.Contains(string(spec), fmt.Sprintf("\t\"//depot/main/...\" \"//%s/...\"\n", client[1])) {
+		t.Fatalf("bad spec:\n%s", spec)
+	}
+	assertLog(strings.Replace(`ssl:perforce:1666 bob TICKET client -i
+ssl:perforce:1666 bob TICKET -c CLIENT sync -q //depot/main/...@1234
+ssl:perforce:1666 bob TICKET -c CLIENT sync -k -q //depot/main/...#none
+ssl:perforce:1666 bob TICKET client -d CLIENT`, "CLIENT", client[1], -1))
+
+	// Without a host and user, those of the environment are used
+	dst = tempFile(t)
+	if err := g.GetFile(dst, testURL("p4:///depot/main/main.tf?label=release-1.0")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "hello\n")
+	assertLog(fmt.Sprintf("env:1666 envuser  print -q -o %s //depot/main/main.tf@release-1.0", dst))
+
+	for _, u := range []string{
+		"p4://perforce/",
+		"p4://perforce/depot/.../main",
+		"p4://perforce/depot/main?change=1&label=a",
+		"p4://perforce/depot/main?change=1%23head",
+	} {
+		if err := g.Get(tempDir(t), testURL(u)); err == nil {
+			t.Fatalf("%s: should error", u)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package getter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// PypiGetter is a Getter implementation that will download a wheel or
+// sdist of a project from a Python package index, Ex., PyPI, devpi or
+// Artifactory, resolving the newest version matching a version specifier
+// like pip does.
+//
+// URLs take the form pypi::https://[user[:password]@]host/simple?project=name,
+// where the URL is that of the simple repository API of the index, which is
+// asked for its JSON form and falls back to HTML. The sha256 hash of the
+// file in the index, if any, is verified. Yanked files are skipped.
+//
+// Query parameters:
+//   - project: the name of the project
+//   - version: the version specifier, Ex., '>=2.0,<3' or '~=2.1', default
+//     as the newest version that isn't a pre-release
+//   - dist: wheel or sdist, to only download that kind of distribution
+//   - file: a glob of the name of the file, Ex., '*-py3-none-any.whl'
+type PypiGetter struct {
+	httpBackedGetter
+}
+
+// pypiFile is a file of a project listed by the simple repository API.
+type pypiFile struct {
+	Filename string
+	URL      *url.URL
+	SHA256   string
+	Version  string
+	Dist     string
+}
+
+// pypiQueryParams are the query parameters of the PypiGetter, which aren't
+// part of the URL of the index.
+var pypiQueryParams = []string{"project", "version", "dist", "file"}
+
+var (
+	// pypiNameSeparators are the runs of separators that are equivalent in
+	// project names, normalized as in PEP 503
+	pypiNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+	// pypiAnchor and pypiHref match the links to files of the HTML form of
+	// the simple repository API
+	pypiAnchor = regexp.MustCompile(`(?is)<a\s([^>]*)>(.*?)</a>`)
+	pypiHref   = regexp.MustCompile(`(?is)href\s*=\s*("[^"]*"|'[^']*')`)
+)
+
+func (g *PypiGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	return ClientModeFile, nil
+}
+
+// GetFilename returns the name of the file of the resolved version, Ex.,
+// 'requests-2.31.0-py3-none-any.whl'.
+func (g *PypiGetter) GetFilename(u *url.URL) (string, error) {
+	f, err := g.resolve(u)
+	if err != nil {
+		return "", err
+	}
+	return f.Filename, nil
+}
+
+// Get downloads the file into the directory dst.
+func (g *PypiGetter) Get(dst string, u *url.URL) error {
+	f, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+	return g.getFile(filepath.Join(dst, f.Filename), f)
+}
+
+func (g *PypiGetter) GetFile(dst string, u *url.URL) error {
+	f, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+	return g.getFile(dst, f)
+}
+
+// getFile downloads the file f to dst and verifies its hash.
+func (g *PypiGetter) getFile(dst string, f *pypiFile) error {
+	if err := g.http().GetFile(dst, f.URL); err != nil {
+		return err
+	}
+	if f.SHA256 == "" {
+		return nil
+	}
+
+	file, err := os.Open(dst)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != strings.ToLower(f.SHA256) {
+		return fmt.Errorf("sha256 of %s doesn't match the index, expected %s, got %s",
+			f.Filename, f.SHA256, actual)
+	}
+	return nil
+}
+
+// resolve returns the file of the newest version of the project of u
+// matching the version specifier, dist and file query parameters.
+func (g *PypiGetter) resolve(u *url.URL) (*pypiFile, error) {
+	q := u.Query()
+	project := q.Get("project")
+	if project == "" {
+		return nil, fmt.Errorf("query parameter 'project' is required")
+	}
+	project = pypiNormalize(project)
+	if strings.ContainsAny(project, "/\\") || project == "-" {
+		return nil, fmt.Errorf("invalid project name: %s", q.Get("project"))
+	}
+	r, err := pypiVersionRange(q.Get("version"))
+	if err != nil {
+		return nil, err
+	}
+	dist, pattern := q.Get("dist"), q.Get("file")
+	if dist != "" && dist != "wheel" && dist != "sdist" {
+		return nil, fmt.Errorf("dist must be wheel or sdist, got %q", dist)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid file pattern %q: %s", pattern, err)
+	}
+
+	// The page of the project is at the normalized name, with a trailing
+	// slash
+	index := *u
+	for _, k := range pypiQueryParams {
+		q.Del(k)
+	}
+	index.RawQuery = q.Encode()
+	pageURL := index
+	pageURL.Path = strings.TrimSuffix(index.Path, "/") + "/" + project + "/"
+	pageURL.RawPath = ""
+
+	files, err := g.files(&pageURL, project)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[string][]*pypiFile{}
+	var versions []string
+	for _, f := range files {
+		if dist != "" && f.Dist != dist {
+			continue
+		}
+		if pattern != "" {
+			if ok, _ := path.Match(pattern, f.Filename); !ok {
+				continue
+			}
+		}
+		if _, ok := byVersion[f.Version]; !ok {
+			versions = append(versions, f.Version)
+		}
+		byVersion[f.Version] = append(byVersion[f.Version], f)
+	}
+	latest, ok := r.latest(versions)
+	if !ok {
+		return nil, fmt.Errorf("no file of project %s matches %q", project, q.Get("version"))
+	}
+
+	// Several files of the version match, Ex., the wheels of each platform
+	// and the sdist: a pure Python wheel is the most portable, then the sdist
+	candidates := byVersion[latest]
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	for _, suffix := range []string{"-none-any.whl", ".tar.gz"} {
+		var found []*pypiFile
+		for _, f := range candidates {
+			if strings.HasSuffix(f.Filename, suffix) {
+				found = append(found, f)
+			}
+		}
+		if len(found) == 1 {
+			return found[0], nil
+		}
+	}
+	var names []string
+	for _, f := range candidates {
+		names = append(names, f.Filename)
+	}
+	return nil, fmt.Errorf("several files of project %s %s match, choose one with the file query parameter: %s",
+		project, latest, strings.Join(names, ", "))
+}
+
+// files downloads the page of the project at pageURL and returns the files
+// that aren't yanked.
+func (g *PypiGetter) files(pageURL *url.URL, project string) ([]*pypiFile, error) {
+	reqURL := *pageURL
+	httpGet := g.http()
+	if httpGet.Netrc {
+		if err := addAuthFromNetrc(&reqURL); err != nil {
+			return nil, err
+		}
+	}
+
+	httpGet.initClient()
+	req, err := httpGet.newRequest("GET", &reqURL)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.pypi.simple.v1+json, text/html; q=0.1")
+
+	resp, err := httpGet.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return nil, fmt.Errorf("project %s not found in %s", project, redactURL(pageURL.String()))
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("bad response code getting %s: %d", redactURL(pageURL.String()), resp.StatusCode)
+	}
+
+	type link struct {
+		href, sha256 string
+	}
+	var links []link
+	body := g.rateLimitReader(resp.Body)
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		var page struct {
+			Files []struct {
+				URL    string            `json:"url"`
+				Hashes map[string]string `json:"hashes"`
+				Yanked interface{}       `json:"yanked"`
+			} `json:"files"`
+		}
+		if err := json.NewDecoder(body).Decode(&page); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %s", redactURL(pageURL.String()), err)
+		}
+		for _, f := range page.Files {
+			// Yanked is true, or the reason
+			if yanked, ok := f.Yanked.(bool); yanked || (!ok && f.Yanked != nil) {
+				continue
+			}
+			links = append(links, link{f.URL, f.Hashes["sha256"]})
+		}
+	} else {
+		content, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range pypiAnchor.FindAllStringSubmatch(string(content), -1) {
+			href := pypiHref.FindStringSubmatch(m[1])
+			if href == nil || strings.Contains(strings.ToLower(m[1]), "data-yanked") {
+				continue
+			}
+			links = append(links, link{html.UnescapeString(href[1][1 : len(href[1])-1]), ""})
+		}
+	}
+
+	var files []*pypiFile
+	for _, l := range links {
+		fileURL, err := pageURL.Parse(l.href)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL of a file of project %s: %s", project, err)
+		}
+
+		// The hash can be given in the fragment, Ex., '#sha256=...'
+		sha := l.sha256
+		if strings.HasPrefix(fileURL.Fragment, "sha256=") && sha == "" {
+			sha = strings.TrimPrefix(fileURL.Fragment, "sha256=")
+		}
+		fileURL.Fragment = ""
+
+		// Files on the index keep its credentials
+		if fileURL.Host == pageURL.Host && fileURL.User == nil {
+			fileURL.User = pageURL.User
+		}
+
+		// The file is downloaded into Dst under its name, which mustn't
+		// escape it once unescaped
+		filename, err := url.PathUnescape(path.Base(fileURL.EscapedPath()))
+		if err != nil || strings.ContainsAny(filename, `/\`) || strings.Contains(filename, "..") {
+			continue
+		}
+		version, dist, ok := pypiFileVersion(project, filename)
+		if !ok {
+			continue
+		}
+		files = append(files, &pypiFile{
+			Filename: filename,
+			URL:      fileURL,
+			SHA256:   sha,
+			Version:  version,
+			Dist:     dist,
+		})
+	}
+	return files, nil
+}
+
+// pypiNormalize returns the normalized name of a project, as in PEP 503,
+// Ex., 'zope-interface' for 'Zope.Interface'.
+func pypiNormalize(name string) string {
+	return pypiNameSeparators.ReplaceAllString(strings.ToLower(name), "-")
+}
+
+// pypiFileVersion returns the version and kind of distribution, wheel or
+// sdist, of the file of the project with the normalized name project.
+// Other files, such as eggs, and those of other projects aren't ok.
+func pypiFileVersion(project, filename string) (string, string, bool) {
+	// Wheels are named 'name-version(-build)?-python-abi-platform.whl'
+	if strings.HasSuffix(filename, ".whl") {
+		parts := strings.Split(strings.TrimSuffix(filename, ".whl"), "-")
+		if len(parts) < 5 || pypiNormalize(parts[0]) != project {
+			return "", "", false
+		}
+		return parts[1], "wheel", true
+	}
+
+	// Sdists are named 'name-version.ext', where versions have no hyphens
+	for _, ext := range []string{".tar.gz", ".tar.bz2", ".tgz", ".zip"} {
+		if !strings.HasSuffix(filename, ext) {
+			continue
+		}
+		base := strings.TrimSuffix(filename, ext)
+		i := strings.LastIndex(base, "-")
+		if i < 0 || pypiNormalize(base[:i]) != project {
+			return "", "", false
+		}
+		return base[i+1:], "sdist", true
+	}
+	return "", "", false
+}
+
+// pypiVersionRange returns the range of the version specifier spec, as in
+// PEP 440, Ex., '>=2.0,<3', '==2.*', '~=2.1' or '!=2.0.1'. A version
+// without an operator is matched exactly.
+func pypiVersionRange(spec string) (versionRange, error) {
+	var comparators []string
+	for _, clause := range strings.Split(spec, ",") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+		v := strings.TrimLeft(clause, "<>=!~")
+		op := clause[:len(clause)-len(v)]
+		v = strings.TrimSpace(v)
+		if v == "" || v[0] < '0' || v[0] > '9' {
+			return nil, fmt.Errorf("invalid version specifier %q", clause)
+		}
+
+		// Pre-releases are written like '2.0rc1', and partial versions are
+		// exact, Ex., '<=2.1' is '<=2.1.0'
+		exact := pypiSemver(v)
+		switch op {
+		case "", "==", "===":
+			if strings.HasSuffix(v, ".*") {
+				comparators = append(comparators, "="+v)
+			} else {
+				comparators = append(comparators, "="+exact)
+			}
+		case "~=":
+			// '~=2.1.3' is '>=2.1.3, ==2.1.*'
+			i := strings.LastIndex(v, ".")
+			if i < 0 {
+				return nil, fmt.Errorf("invalid version specifier %q: ~= requires at least two parts", clause)
+			}
+			comparators = append(comparators, ">="+exact, "="+v[:i])
+		case "!=", "<", "<=", ">", ">=":
+			comparators = append(comparators, op+exact)
+		default:
+			return nil, fmt.Errorf("invalid version specifier %q", clause)
+		}
+	}
+	return parseVersionRange(strings.Join(comparators, " "))
+}
+
+// pypiSemver returns the PEP 440 version v with three parts, and a hyphen
+// before its pre-release, Ex., '2.0.0-rc1' for '2.0rc1'.
+func pypiSemver(v string) string {
+	pre := ""
+	if i := strings.IndexFunc(v, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	}); i >= 0 {
+		v, pre = strings.TrimSuffix(v[:i], "."), "-"+strings.TrimLeft(v[i:], ".-_")
+	}
+	for strings.Count(v, ".") < 2 {
+		v += ".0"
+	}
+	return v + pre
+}
@@ -0,0 +1,271 @@
+package getter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPypiGetter_impl(t *testing.T) {
+	var _ Getter = new(PypiGetter)
+}
+
+func TestPypiVersionRange(t *testing.T) {
+	versions := []string{"1.0", "1.4.2", "1.4.5", "1.5", "2.0rc1", "2.0", "2.1.0", "invalid"}
+
+	cases := []struct {
+		Spec   string
+		Latest string
+	}{
+		{"", "2.1.0"},
+		{"1.4.2", "1.4.2"},
+		{"==1.4.2", "1.4.2"},
+		{"==1.4.*", "1.4.5"},
+		{"==1.5", "1.5"},
+		{"~=1.4", "1.5"},
+		{"~=1.4.2", "1.4.5"},
+		{">=1.0,<2", "1.5"},
+		{">= 1.0, < 2, != 1.5", "1.4.5"},
+		{"<=1.4", "1.0"},
+		{">2", "2.1.0"},
+		{"==2.0rc1", "2.0rc1"},
+		{">=2.0rc1,<2.0", "2.0rc1"},
+		{"==3.*", ""},
+	}
+	for _, tc := range cases {
+		r, err := pypiVersionRange(tc.Spec)
+		if err != nil {
+			t.Fatalf("%q: err: %s", tc.Spec, err)
+		}
+		latest, ok := r.latest(versions)
+		if latest != tc.Latest || ok != (tc.Latest != "") {
+			t.Fatalf("%q: bad latest: %q", tc.Spec, latest)
+		}
+	}
+
+	for _, spec := range []string{"abc", ">=", "~=1", "=>1.0", "!=1.*"} {
+		if _, err := pypiVersionRange(spec); err == nil {
+			t.Fatalf("%q: should error", spec)
+		}
+	}
+}
+
+func TestPypiGetter_file(t *testing.T) {
+	ln := testPypiServer(t)
+	defer ln.Close()
+
+	g := new(PypiGetter)
+	cases := []struct {
+		Path     string
+		Query    string
+		Filename string
+	}{
+		{"simple", "project=My.Demo", "my_demo-1.1.0-py3-none-any.whl"},
+		{"simple", "project=my-demo&version=<1.1", "my_demo-1.0.0-py3-none-any.whl"},
+		{"simple", "project=my-demo&dist=sdist", "my-demo-1.1.0.tar.gz"},
+		{"simple", "project=my-demo&file=*manylinux*", "my_demo-1.1.0-cp311-cp311-manylinux_2_17_x86_64.whl"},
+		{"simple", "project=my-demo&version=>=2.0rc1", "my-demo-2.0rc1.tar.gz"},
+		{"html", "project=my-demo&version=~=1.0", "my_demo-1.1.0-py3-none-any.whl"},
+		{"html", "project=my-demo&version===1.0.0&dist=sdist", "my-demo-1.0.0.tar.gz"},
+	}
+	for _, tc := range cases {
+		u := testURL(fmt.Sprintf("http://user:secret@%s/%s?%s", ln.Addr(), tc.Path, tc.Query))
+
+		mode, err := g.ClientMode(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		if mode != ClientModeFile {
+			t.Fatalf("%s: bad mode: %d", tc.Query, mode)
+		}
+
+		filename, err := g.GetFilename(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		if filename != tc.Filename {
+			t.Fatalf("%s: bad filename: %s", tc.Query, filename)
+		}
+
+		dst := tempFile(t)
+		if err := g.GetFile(dst, u); err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		assertContents(t, dst, tc.Filename)
+	}
+}
+
+func TestPypiGetter_bad(t *testing.T) {
+	ln := testPypiServer(t)
+	defer ln.Close()
+
+	g := new(PypiGetter)
+	for _, query := range []string{
+		"",
+		"project=missing",
+		"project=my-demo&version=>=3",
+		"project=my-demo&version=1.2.0",
+		"project=my-demo&version=abc",
+		"project=my-demo&dist=egg",
+		"project=my-demo&version=1.1.0&dist=wheel&file=*cp311*",
+		"project=corrupt",
+	} {
+		u := testURL(fmt.Sprintf("http://user:secret@%s/simple?%s", ln.Addr(), query))
+		if err := g.GetFile(tempFile(t), u); err == nil {
+			t.Fatalf("%s: should error", query)
+		}
+	}
+
+	// The index requires basic auth
+	u := testURL(fmt.Sprintf("http://%s/simple?project=my-demo", ln.Addr()))
+	if err := g.GetFile(tempFile(t), u); err == nil {
+		t.Fatal("should error without credentials")
+	}
+}
+
+func TestPypiGetter_escape(t *testing.T) {
+	ln := testPypiServer(t)
+	defer ln.Close()
+
+	// Links whose unescaped name is a path aren't files of the project
+	g := new(PypiGetter)
+	for _, api := range []string{"simple", "html"} {
+		dst := filepath.Join(tempDir(t), "a", "b")
+		u := testURL(fmt.Sprintf("http://user:secret@%s/%s?project=escape", ln.Addr(), api))
+		if err := g.Get(dst, u); err == nil {
+			t.Fatalf("%s: should error", api)
+		}
+		if _, err := os.Stat(filepath.Join(dst, "..", "..", "escaped.whl")); err == nil {
+			t.Fatalf("%s: file written outside of dst", api)
+		}
+	}
+}
+
+func TestPypiGetter_client(t *testing.T) {
+	ln := testPypiServer(t)
+	defer ln.Close()
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:  fmt.Sprintf("pypi::http://%s/html/?project=my-demo&version=1.0.0&dist=wheel", ln.Addr()),
+		Dst:  filepath.Join(dst, "demo.whl"),
+		Mode: ClientModeFile,
+	}
+	client.Username, client.Password = "user", "secret"
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "demo.whl"), "my_demo-1.0.0-py3-none-any.whl")
+}
+
+// testPypiServer starts an index requiring basic auth as user:secret, with
+// the JSON form of the simple repository API at /simple and the HTML form
+// at /html. The project my-demo has the versions 1.0.0 and 1.1.0, with
+// wheels and sdists, 1.2.0, which is yanked, and 2.0rc1. The file of the
+// project corrupt doesn't match its hash, and that of the project escape
+// has an encoded slash in its name. The content of each file is its
+// name.
+func testPypiServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	type file struct {
+		Filename string            `json:"filename"`
+		URL      string            `json:"url"`
+		Hashes   map[string]string `json:"hashes"`
+		Yanked   interface{}       `json:"yanked,omitempty"`
+	}
+	projects := map[string][]file{
+		"my-demo": {
+			{Filename: "my-demo-1.0.0.tar.gz"},
+			{Filename: "my_demo-1.0.0-py3-none-any.whl"},
+			{Filename: "my-demo-1.1.0.tar.gz"},
+			{Filename: "my_demo-1.1.0-py3-none-any.whl"},
+			{Filename: "my_demo-1.1.0-cp311-cp311-manylinux_2_17_x86_64.whl"},
+			{Filename: "my_demo-1.1.0-cp311-cp311-win_amd64.whl"},
+			{Filename: "my-demo-1.2.0.tar.gz", Yanked: "broken"},
+			{Filename: "my-demo-2.0rc1.tar.gz"},
+			{Filename: "other-3.0.0.tar.gz"},
+		},
+		"corrupt": {
+			{Filename: "corrupt-1.0.tar.gz"},
+		},
+		"escape": {
+			{Filename: "escape-1.0-py3-none-any%2F..%2F..%2F..%2Fescaped.whl"},
+		},
+	}
+	for name, files := range projects {
+		for i, f := range files {
+			sum := sha256.Sum256([]byte(f.Filename))
+			files[i].Hashes = map[string]string{"sha256": hex.EncodeToString(sum[:])}
+			if name == "corrupt" {
+				files[i].Hashes["sha256"] = strings.Repeat("0", 64)
+			}
+			files[i].URL = "../../files/" + f.Filename
+		}
+	}
+
+	// The paths of the files aren't cleaned by a ServeMux, so names with
+	// encoded slashes are served
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			w.WriteHeader(401)
+			return
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/files/") {
+			w.Write([]byte(strings.TrimPrefix(r.URL.Path, "/files/")))
+			return
+		}
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 2 {
+			w.WriteHeader(404)
+			return
+		}
+		files, ok := projects[parts[1]]
+		if !ok || !strings.HasSuffix(r.URL.Path, "/") {
+			w.WriteHeader(404)
+			return
+		}
+
+		switch parts[0] {
+		case "simple":
+			w.Header().Set("Content-Type", "application/vnd.pypi.simple.v1+json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"meta":  map[string]string{"api-version": "1.0"},
+				"name":  parts[1],
+				"files": files,
+			})
+		case "html":
+			// The hashes are in the fragments of the links
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<!DOCTYPE html>\n<html><body>\n")
+			for _, f := range files {
+				yanked := ""
+				if f.Yanked != nil {
+					yanked = ` data-yanked="broken"`
+				}
+				fmt.Fprintf(w, "<a href=\"%s#sha256=%s\" data-requires-python=\"&gt;=3.8\"%s>%s</a><br/>\n",
+					f.URL, f.Hashes["sha256"], yanked, f.Filename)
+			}
+			fmt.Fprint(w, "</body></html>\n")
+		default:
+			w.WriteHeader(404)
+		}
+	}
+
+	var server http.Server
+	server.Handler = http.HandlerFunc(handler)
+	go server.Serve(ln)
+
+	return ln
+}
@@ -0,0 +1,122 @@
+package getter
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RsyncGetter is a Getter implementation that will sync a file, or a
+// directory tree, from an rsync daemon using the rsync command.
+//
+// URLs take the form rsync://[user[:password]@]host[:port]/module/path. The
+// password is passed to rsync in the RSYNC_PASSWORD environment variable.
+//
+// Unlike most getters, the destination isn't removed first, so that files
+// which didn't change since the last download aren't transferred again, and
+// those which did are updated with rsync's delta transfer. Files that no
+// longer exist at the source are deleted from the destination directory.
+//
+// Query parameters:
+//   - exclude: a pattern of files to skip, passed to --exclude, can be repeated
+//   - compress: true to compress the data during the transfer
+type RsyncGetter struct {
+	getter
+}
+
+func (g *RsyncGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	if strings.HasSuffix(u.Path, "/") {
+		return ClientModeDir, nil
+	}
+
+	// Listing a directory without a trailing slash lists the directory
+	// itself, Ex., "drwxr-xr-x          4,096 2021/01/01 12:00:00 dir"
+	out, err := g.run(u, "--list-only", g.source(u))
+	if err != nil {
+		return 0, err
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) == 1 && strings.HasPrefix(lines[0], "d") {
+		return ClientModeDir, nil
+	}
+	return ClientModeFile, nil
+}
+
+func (g *RsyncGetter) GetFilename(u *url.URL) (string, error) {
+	return "", nil
+}
+
+func (g *RsyncGetter) Get(dst string, u *url.URL) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	args := append(g.args(u), "--recursive", "--delete")
+	src := g.source(u)
+	if !strings.HasSuffix(src, "/") {
+		src += "/"
+	}
+	_, err := g.run(u, append(args, src, dst+string(filepath.Separator))...)
+	return err
+}
+
+func (g *RsyncGetter) GetFile(dst string, u *url.URL) error {
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	_, err := g.run(u, append(g.args(u), g.source(u), dst)...)
+	return err
+}
+
+// args returns the options of the rsync command for the query of u.
+func (g *RsyncGetter) args(u *url.URL) []string {
+	args := []string{"--links", "--perms", "--times"}
+	q := u.Query()
+	if compress, _ := strconv.ParseBool(q.Get("compress")); compress {
+		args = append(args, "--compress")
+	}
+	for _, pattern := range q["exclude"] {
+		args = append(args, "--exclude", pattern)
+	}
+	return args
+}
+
+// source returns the rsync URL of u, without its password and query.
+func (g *RsyncGetter) source(u *url.URL) string {
+	u2 := *u
+	u2.RawQuery = ""
+	if u.User != nil {
+		u2.User = url.User(u.User.Username())
+	}
+	return u2.String()
+}
+
+// run runs rsync with the arguments, and the password of u, if any, and
+// returns its output.
+func (g *RsyncGetter) run(u *url.URL, args ...string) (string, error) {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		return "", fmt.Errorf("rsync must be available and on the PATH")
+	}
+
+	cmd := exec.CommandContext(g.ctx(), "rsync", args...)
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			cmd.Env = append(os.Environ(), "RSYNC_PASSWORD="+password)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("error running rsync: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -14,11 +14,14 @@ import (
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/mitchellh/go-homedir"
 )
 
 // S3Getter is a Getter implementation that will download a module from
 // a S3 bucket.
-type S3Getter struct{}
+type S3Getter struct {
+	getter
+}
 
 func (g *S3Getter) ClientMode(u *url.URL) (ClientMode, error) {
 	// Parse URL
@@ -28,7 +31,10 @@ func (g *S3Getter) ClientMode(u *url.URL) (ClientMode, error) {
 	}
 
 	// Create client config
-	config := g.getAWSConfig(region, u, creds)
+	config, err := g.getAWSConfig(region, u, creds)
+	if err != nil {
+		return 0, err
+	}
 	sess := session.New(config)
 	client := s3.New(sess)
 
@@ -88,7 +94,10 @@ func (g *S3Getter) Get(dst string, u *url.URL) error {
 		return err
 	}
 
-	config := g.getAWSConfig(region, u, creds)
+	config, err := g.getAWSConfig(region, u, creds)
+	if err != nil {
+		return err
+	}
 	sess := session.New(config)
 	client := s3.New(sess)
 
@@ -143,7 +152,10 @@ func (g *S3Getter) GetFile(dst string, u *url.URL) error {
 		return err
 	}
 
-	config := g.getAWSConfig(region, u, creds)
+	config, err := g.getAWSConfig(region, u, creds)
+	if err != nil {
+		return err
+	}
 	sess := session.New(config)
 	client := s3.New(sess)
 	return g.getObject(client, dst, bucket, path, version)
@@ -174,11 +186,11 @@ func (g *S3Getter) getObject(client *s3.S3, dst, bucket, key, version string) er
 	}
 	defer f.Close()
 
-	_, err = io.Copy(f, resp.Body)
+	_, err = io.Copy(f, g.rateLimitReader(resp.Body))
 	return err
 }
 
-func (g *S3Getter) getAWSConfig(region string, url *url.URL, creds *credentials.Credentials) *aws.Config {
+func (g *S3Getter) getAWSConfig(region string, url *url.URL, creds *credentials.Credentials) (*aws.Config, error) {
 	conf := &aws.Config{}
 	if creds == nil {
 		// Grab the metadata URL
@@ -197,10 +209,20 @@ func (g *S3Getter) getAWSConfig(region string, url *url.URL, creds *credentials.
 					})),
 				},
 			})
+
+		// If no credentials are configured at all, fall back to anonymous
+		// access so that public buckets can still be read.
+		if _, err := creds.Get(); err != nil {
+			if awsCredentialsConfigured() {
+				return nil, fmt.Errorf("error getting the AWS credentials: %s", err)
+			}
+			creds = credentials.AnonymousCredentials
+		}
 	}
 
 	if creds != nil {
-		conf.Endpoint = &url.Host
+		endpoint := s3Endpoint(url)
+		conf.Endpoint = &endpoint
 		conf.S3ForcePathStyle = aws.Bool(true)
 		if url.Scheme == "http" {
 			conf.DisableSSL = aws.Bool(true)
@@ -212,7 +234,30 @@ func (g *S3Getter) getAWSConfig(region string, url *url.URL, creds *credentials.
 		conf.Region = aws.String(region)
 	}
 
-	return conf
+	return conf, nil
+}
+
+// awsCredentialsConfigured returns whether AWS credentials are configured in
+// the environment or the shared credentials file, in which case failing to
+// get them is an error rather than a reason to access buckets anonymously.
+func awsCredentialsConfigured() bool {
+	for _, k := range []string{
+		"AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY", "AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY", "AWS_PROFILE",
+	} {
+		if os.Getenv(k) != "" {
+			return true
+		}
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		var err error
+		if path, err = homedir.Expand("~/.aws/credentials"); err != nil {
+			return false
+		}
+	}
+	_, err := os.Stat(path)
+	return err == nil
 }
 
 func (g *S3Getter) parseUrl(u *url.URL) (region, bucket, path, version string, creds *credentials.Credentials, err error) {
@@ -220,28 +265,50 @@ func (g *S3Getter) parseUrl(u *url.URL) (region, bucket, path, version string, c
 	// any other S3 compliant service. S3 has a predictable
 	// url as others do not
 	if strings.Contains(u.Host, "amazonaws.com") {
-		// Expected host style: s3.amazonaws.com. They always have 3 parts,
-		// although the first may differ if we're accessing a specific region.
+		// Expected host styles are either path style, e.g. s3.amazonaws.com,
+		// s3-eu-west-1.amazonaws.com or s3.eu-west-1.amazonaws.com, or
+		// virtual hosted style where the bucket is the first part of the
+		// host, e.g. bucket.s3.amazonaws.com or bucket.s3.eu-west-1.amazonaws.com.
 		hostParts := strings.Split(u.Host, ".")
-		if len(hostParts) != 3 {
+		vhost := false
+		switch {
+		case len(hostParts) == 3:
+			// Parse the region out of the first part of the host
+			region = strings.TrimPrefix(strings.TrimPrefix(hostParts[0], "s3-"), "s3")
+		case len(hostParts) == 4 && hostParts[0] == "s3":
+			region = hostParts[1]
+		case len(hostParts) == 4:
+			vhost = true
+			bucket = hostParts[0]
+			region = strings.TrimPrefix(strings.TrimPrefix(hostParts[1], "s3-"), "s3")
+		case len(hostParts) == 5 && hostParts[1] == "s3":
+			vhost = true
+			bucket = hostParts[0]
+			region = hostParts[2]
+		default:
 			err = fmt.Errorf("URL is not a valid S3 URL")
 			return
 		}
-
-		// Parse the region out of the first part of the host
-		region = strings.TrimPrefix(strings.TrimPrefix(hostParts[0], "s3-"), "s3")
 		if region == "" {
 			region = "us-east-1"
 		}
 
-		pathParts := strings.SplitN(u.Path, "/", 3)
-		if len(pathParts) != 3 {
-			err = fmt.Errorf("URL is not a valid S3 URL")
-			return
-		}
+		if vhost {
+			path = strings.TrimPrefix(u.Path, "/")
+			if path == "" {
+				err = fmt.Errorf("URL is not a valid S3 URL")
+				return
+			}
+		} else {
+			pathParts := strings.SplitN(u.Path, "/", 3)
+			if len(pathParts) != 3 {
+				err = fmt.Errorf("URL is not a valid S3 URL")
+				return
+			}
 
-		bucket = pathParts[1]
-		path = pathParts[2]
+			bucket = pathParts[1]
+			path = pathParts[2]
+		}
 		version = u.Query().Get("version")
 
 	} else {
@@ -259,16 +326,42 @@ func (g *S3Getter) parseUrl(u *url.URL) (region, bucket, path, version string, c
 		}
 	}
 
-	_, hasAwsId := u.Query()["aws_access_key_id"]
-	_, hasAwsSecret := u.Query()["aws_access_key_secret"]
-	_, hasAwsToken := u.Query()["aws_access_token"]
-	if hasAwsId || hasAwsSecret || hasAwsToken {
-		creds = credentials.NewStaticCredentials(
-			u.Query().Get("aws_access_key_id"),
-			u.Query().Get("aws_access_key_secret"),
-			u.Query().Get("aws_access_token"),
-		)
+	// aws_secret_access_key and aws_session_token follow the naming of the
+	// AWS CLI; aws_access_key_secret and aws_access_token are still accepted
+	// for backwards compatibility.
+	q := u.Query()
+	id := q.Get("aws_access_key_id")
+	secret := q.Get("aws_secret_access_key")
+	if secret == "" {
+		secret = q.Get("aws_access_key_secret")
+	}
+	token := q.Get("aws_session_token")
+	if token == "" {
+		token = q.Get("aws_access_token")
+	}
+	if id != "" || secret != "" || token != "" {
+		if id == "" || secret == "" {
+			err = fmt.Errorf("both aws_access_key_id and aws_secret_access_key must be given")
+			return
+		}
+		creds = credentials.NewStaticCredentials(id, secret, token)
 	}
 
 	return
 }
+
+// s3Endpoint returns the host to use as the S3 endpoint for the given URL.
+// Virtual hosted style AWS URLs have the bucket stripped from the host since
+// requests are always made in path style.
+func s3Endpoint(u *url.URL) string {
+	if !strings.Contains(u.Host, "amazonaws.com") {
+		return u.Host
+	}
+
+	hostParts := strings.Split(u.Host, ".")
+	if len(hostParts) == 5 || (len(hostParts) == 4 && hostParts[0] != "s3") {
+		return strings.Join(hostParts[1:], ".")
+	}
+
+	return u.Host
+}
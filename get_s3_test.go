@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 )
 
 func init() {
@@ -186,6 +187,30 @@ func TestS3Getter_Url(t *testing.T) {
 			path:    "foo/bar.baz",
 			version: "1234",
 		},
+		{
+			name:    "AWSDottedRegion",
+			url:     "s3::https://s3.eu-west-1.amazonaws.com/bucket/foo/bar.baz",
+			region:  "eu-west-1",
+			bucket:  "bucket",
+			path:    "foo/bar.baz",
+			version: "",
+		},
+		{
+			name:    "AWSVhost",
+			url:     "s3::https://bucket.s3.amazonaws.com/foo/bar.baz",
+			region:  "us-east-1",
+			bucket:  "bucket",
+			path:    "foo/bar.baz",
+			version: "",
+		},
+		{
+			name:    "AWSVhostRegion",
+			url:     "s3::https://bucket.s3.eu-west-1.amazonaws.com/foo/bar.baz?aws_access_key_id=TESTID&aws_secret_access_key=TestSecret&aws_session_token=TestToken",
+			region:  "eu-west-1",
+			bucket:  "bucket",
+			path:    "foo/bar.baz",
+			version: "",
+		},
 		{
 			name:    "localhost-1",
 			url:     "s3::http://127.0.0.1:9000/test-bucket/hello.txt?aws_access_key_id=TESTID&aws_access_key_secret=TestSecret&region=us-east-2&version=1",
@@ -248,3 +273,87 @@ func TestS3Getter_Url(t *testing.T) {
 		})
 	}
 }
+
+func TestS3Getter_UrlCredentials(t *testing.T) {
+	g := new(S3Getter)
+	u := testURL("https://bucket.s3.eu-west-1.amazonaws.com/foo?aws_access_key_id=TESTID&aws_secret_access_key=TestSecret&aws_session_token=TestToken")
+
+	_, _, _, _, creds, err := g.parseUrl(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if creds == nil {
+		t.Fatal("expected credentials")
+	}
+
+	v, err := creds.Get()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v.AccessKeyID != "TESTID" || v.SecretAccessKey != "TestSecret" || v.SessionToken != "TestToken" {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
+func TestS3Getter_UrlCredentialsPartial(t *testing.T) {
+	g := new(S3Getter)
+	for _, q := range []string{
+		"aws_access_key_id=TESTID",
+		"aws_secret_access_key=TestSecret",
+		"aws_access_key_id=TESTID&aws_session_token=TestToken",
+	} {
+		u := testURL("https://bucket.s3.eu-west-1.amazonaws.com/foo?" + q)
+		if _, _, _, _, _, err := g.parseUrl(u); err == nil {
+			t.Fatalf("%s: should error", q)
+		}
+	}
+}
+
+func TestS3Getter_anonymous(t *testing.T) {
+	keys := []string{
+		"AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY", "AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY", "AWS_PROFILE",
+		"AWS_SHARED_CREDENTIALS_FILE", "AWS_METADATA_URL",
+	}
+	for _, k := range keys {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Unsetenv(k)
+	}
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(tempDir(t), "credentials"))
+	os.Setenv("AWS_METADATA_URL", "http://127.0.0.1:1/latest")
+
+	// Without any credentials configured, buckets are accessed anonymously
+	g := new(S3Getter)
+	u := testURL("https://s3.amazonaws.com/bucket/foo")
+	config, err := g.getAWSConfig("us-east-1", u, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if config.Credentials != credentials.AnonymousCredentials {
+		t.Fatalf("bad credentials: %#v", config.Credentials)
+	}
+
+	// Credentials that are configured but can't be used are an error
+	os.Setenv("AWS_ACCESS_KEY_ID", "TESTID")
+	if _, err := g.getAWSConfig("us-east-1", u, nil); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestS3Getter_Endpoint(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Output string
+	}{
+		{"https://s3.amazonaws.com/bucket/foo", "s3.amazonaws.com"},
+		{"https://s3.eu-west-1.amazonaws.com/bucket/foo", "s3.eu-west-1.amazonaws.com"},
+		{"https://bucket.s3-eu-west-1.amazonaws.com/foo", "s3-eu-west-1.amazonaws.com"},
+		{"https://bucket.s3.eu-west-1.amazonaws.com/foo", "s3.eu-west-1.amazonaws.com"},
+		{"http://127.0.0.1:9000/bucket/foo", "127.0.0.1:9000"},
+	}
+
+	for _, tc := range cases {
+		if actual := s3Endpoint(testURL(tc.Input)); actual != tc.Output {
+			t.Fatalf("%s: expected %s, got %s", tc.Input, tc.Output, actual)
+		}
+	}
+}
@@ -4,28 +4,45 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"net"
 	"net/url"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/cheggaaa/pb"
 	"github.com/mitchellh/go-homedir"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // SftpGetter is a Getter implementation that will download a file through sftp
-// uri format: sftp://[username@]hostname[:port]/directoryname[?options]
+// uri format: sftp://[username@]hostname[:port]/directoryname[?options], or ssh://...
 // see also: http://camel.apache.org/ftp2.html
-type SftpGetter struct{}
+//
+// Authentication uses, in order, the key of the privateKeyFile query parameter,
+// decrypted with the passphrase query parameter if it is encrypted,
+// the keys of the ssh agent listening on SSH_AUTH_SOCK, the default keys in ~/.ssh
+// and a password given in the URL or the password query parameter.
+//
+// The host key of the server is verified against the known_hosts file given by the
+// knownHostsFile query parameter, or ~/.ssh/known_hosts. Set insecureIgnoreHostKey
+// to true to skip the verification, e.g. for test servers.
+type SftpGetter struct {
+	getter
+}
 
 func (g *SftpGetter) ClientMode(u *url.URL) (ClientMode, error) {
-	sftp, err := g.createSftpClient(u)
+	sftp, conn, err := g.createSftpClient(u)
 	if err != nil {
 		return ClientModeInvalid, err
 	}
+	defer conn.Close()
 	defer sftp.Close()
 
 	fileInfo, err := sftp.Stat(u.Path)
@@ -43,40 +60,61 @@ func (g *SftpGetter) GetFilename(u *url.URL) (string, error) {
 }
 
 // Get the files under the remote dir.
-// Note: recursively download is not supported at the moment.
 // Query parameters:
 //   - fileName: the name of the file to download, support regex
+//   - recursive: true to also download the files of the sub directories, keeping their layout, default as false
 //   - preservePermissions: true to preserve the file permissions on local file, default as false
 // example url: sftp://username@host/the/remote/dir?fileName=f1.txt&fileName=f2.txt&fileName=.*\.txt
 func (g *SftpGetter) Get(dst string, u *url.URL) error {
-	sftp, err := g.createSftpClient(u)
+	sftp, conn, err := g.createSftpClient(u)
 	if err != nil {
 		return err
 	}
+	defer conn.Close()
 	defer sftp.Close()
 
-	rmtDir := u.Path
-	rmtFiles, err := sftp.ReadDir(rmtDir)
-	if err != nil {
-		return err
+	fileNames, hasFileName := u.Query()["fileName"]
+	var res []*regexp.Regexp
+	for _, fileName := range fileNames {
+		re, err := regexp.Compile(fileName)
+		if err != nil {
+			continue
+		}
+		res = append(res, re)
+	}
+	match := func(name string) bool {
+		if !hasFileName {
+			return true
+		}
+		for _, re := range res {
+			if re.FindString(name) != "" {
+				return true
+			}
+		}
+		return false
 	}
 
+	// the target files, relative to the remote dir
+	rmtDir := u.Path
 	var targetFiles []string
-	if fileNames, hasFileName := u.Query()["fileName"]; hasFileName {
-		for _, fileName := range fileNames {
-			re, err := regexp.Compile(fileName)
-			if err != nil {
-				continue
+	if recursive, _ := strconv.ParseBool(u.Query().Get("recursive")); recursive {
+		walker := sftp.Walk(rmtDir)
+		for walker.Step() {
+			if err := walker.Err(); err != nil {
+				return err
 			}
-			for _, rmtFile := range rmtFiles {
-				if !rmtFile.IsDir() && re.FindString(rmtFile.Name()) != "" {
-					targetFiles = append(targetFiles, rmtFile.Name())
-				}
+			if !walker.Stat().IsDir() && match(path.Base(walker.Path())) {
+				rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), rmtDir), "/")
+				targetFiles = append(targetFiles, rel)
 			}
 		}
 	} else {
+		rmtFiles, err := sftp.ReadDir(rmtDir)
+		if err != nil {
+			return err
+		}
 		for _, rmtFile := range rmtFiles {
-			if !rmtFile.IsDir() {
+			if !rmtFile.IsDir() && match(rmtFile.Name()) {
 				targetFiles = append(targetFiles, rmtFile.Name())
 			}
 		}
@@ -84,7 +122,11 @@ func (g *SftpGetter) Get(dst string, u *url.URL) error {
 
 	preservePerm, _ := strconv.ParseBool(u.Query().Get("preservePermissions"))
 	for _, file := range targetFiles {
-		if err := g.getFile(sftp, dst+"/"+file, rmtDir+"/"+file, preservePerm); err != nil {
+		local := filepath.Join(dst, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+			return err
+		}
+		if err := g.getFile(sftp, local, rmtDir+"/"+file, preservePerm); err != nil {
 			return err
 		}
 	}
@@ -97,34 +139,54 @@ func (g *SftpGetter) Get(dst string, u *url.URL) error {
 //   - preservePermissions: true to preserve the file permissions on local file, default as false
 // example url: sftp://username@host/the/remote/file.txt
 func (g *SftpGetter) GetFile(dst string, u *url.URL) error {
-	sftp, err := g.createSftpClient(u)
+	sftp, conn, err := g.createSftpClient(u)
 	if err != nil {
 		return err
 	}
+	defer conn.Close()
 	defer sftp.Close()
 
 	preservePerm, _ := strconv.ParseBool(u.Query().Get("preservePermissions"))
 	return g.getFile(sftp, dst, u.Path, preservePerm)
 }
 
-func (g *SftpGetter) createSftpClient(u *url.URL) (*sftp.Client, error) {
+// createSftpClient connects to the host of the url, returning the sftp client and the
+// ssh connection it runs on, which must both be closed.
+func (g *SftpGetter) createSftpClient(u *url.URL) (*sftp.Client, *ssh.Client, error) {
 	if u.User == nil {
-		return nil, fmt.Errorf("user name is required in url.")
+		return nil, nil, fmt.Errorf("user name is required in url.")
 	}
 	user := u.User.Username()
 	if user == "" {
-		return nil, fmt.Errorf("user name is required in url.")
+		return nil, nil, fmt.Errorf("user name is required in url.")
 	}
 
 	var authMethods []ssh.AuthMethod
-	idRsaFile, _ := homedir.Expand("~/.ssh/id_rsa")
-	idDsaFile, _ := homedir.Expand("~/.ssh/id_dsa")
-	potentialKeyFiles := []string{u.Query().Get("privateKeyFile"), idRsaFile, idDsaFile}
-	for _, keyFile := range potentialKeyFiles {
+	if keyFile := u.Query().Get("privateKeyFile"); keyFile != "" {
+		key, err := g.getKeyFile(keyFile, u.Query().Get("passphrase"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse private key [%s]: %v", keyFile, err)
+		}
+		authMethods = append(authMethods, ssh.PublicKeys(key))
+	}
+
+	// the keys of the ssh agent, the agent is only used while connecting
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		agentConn, err := net.Dial("unix", sock)
+		if err != nil {
+			g.logger().Printf("failed to connect to ssh agent [%s]: %v", sock, err)
+		} else {
+			defer agentConn.Close()
+			authMethods = append(authMethods, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+		}
+	}
+
+	for _, name := range []string{"id_rsa", "id_ecdsa", "id_ed25519", "id_dsa"} {
+		keyFile, _ := homedir.Expand("~/.ssh/" + name)
 		if keyFile != "" && exists(keyFile) {
-			key, err := g.getKeyFile(keyFile)
+			key, err := g.getKeyFile(keyFile, "")
 			if err != nil {
-				log.Printf("failed to parse private key [%s]: %v", keyFile, err)
+				g.logger().Printf("failed to parse private key [%s]: %v", keyFile, err)
 			} else {
 				authMethods = append(authMethods, ssh.PublicKeys(key))
 			}
@@ -137,12 +199,18 @@ func (g *SftpGetter) createSftpClient(u *url.URL) (*sftp.Client, error) {
 	}
 
 	if len(authMethods) == 0 {
-		return nil, fmt.Errorf("either password or private key is required for ssh auth.")
+		return nil, nil, fmt.Errorf("either password, private key or ssh agent is required for ssh auth.")
+	}
+
+	hostKeyCallback, err := g.hostKeyCallback(u)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	config := &ssh.ClientConfig{
-		User: user,
-		Auth: authMethods,
+		User:            user,
+		Auth:            authMethods,
+		HostKeyCallback: hostKeyCallback,
 	}
 	var port = u.Port()
 	if port == "" {
@@ -150,19 +218,52 @@ func (g *SftpGetter) createSftpClient(u *url.URL) (*sftp.Client, error) {
 	}
 	client, err := ssh.Dial("tcp", u.Hostname()+":"+port, config)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	sftp, err := sftp.NewClient(client)
-	return sftp, err
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	return sftp, client, nil
 }
 
-func (g *SftpGetter) getKeyFile(file string) (key ssh.Signer, err error) {
+// hostKeyCallback returns the callback verifying the host key of the server against the
+// known_hosts file given by the knownHostsFile query parameter, or ~/.ssh/known_hosts.
+func (g *SftpGetter) hostKeyCallback(u *url.URL) (ssh.HostKeyCallback, error) {
+	if insecure, _ := strconv.ParseBool(u.Query().Get("insecureIgnoreHostKey")); insecure {
+		g.logger().Printf("not verifying the host key of %s", u.Host)
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	knownHostsFile := u.Query().Get("knownHostsFile")
+	if knownHostsFile == "" {
+		knownHostsFile, _ = homedir.Expand("~/.ssh/known_hosts")
+	}
+	if knownHostsFile == "" || !exists(knownHostsFile) {
+		return nil, fmt.Errorf(
+			"known_hosts file [%s] not found, it is required to verify the host key of %s", knownHostsFile, u.Host)
+	}
+
+	callback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse known_hosts file [%s]: %v", knownHostsFile, err)
+	}
+	return callback, nil
+}
+
+// getKeyFile parses the private key in file, decrypting it with the
+// passphrase if it isn't empty.
+func (g *SftpGetter) getKeyFile(file, passphrase string) (key ssh.Signer, err error) {
 	buffer, err := ioutil.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
 
+	if passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(buffer, []byte(passphrase))
+	}
 	key, err = ssh.ParsePrivateKey(buffer)
 	return key, err
 }
@@ -179,7 +280,7 @@ func (g *SftpGetter) getFile(sftp *sftp.Client, dst, src string, preservePerm bo
 		return err
 	}
 
-	log.Printf("Downloading remote %s to local %s", src, dst)
+	g.logger().Printf("Downloading remote %s to local %s", src, dst)
 	rmtFileInfo, err := rmtFile.Stat()
 	if err != nil {
 		return err
@@ -187,7 +288,12 @@ func (g *SftpGetter) getFile(sftp *sftp.Client, dst, src string, preservePerm bo
 	bar := pb.New64(rmtFileInfo.Size()).SetUnits(pb.U_BYTES)
 	bar.Start()
 	writer := io.MultiWriter(dstFile, bar)
-	_, err = rmtFile.WriteTo(writer)
+	if g.client != nil && g.client.RateLimit > 0 {
+		_, err = io.Copy(writer, g.rateLimitReader(rmtFile))
+	} else {
+		// WriteTo reads the file with concurrent requests
+		_, err = rmtFile.WriteTo(writer)
+	}
 	bar.Finish()
 	dstFile.Close()
 	if err != nil {
@@ -0,0 +1,185 @@
+package getter
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// SmbGetter is a Getter implementation that will download a file, or all
+// the files below a directory, from an SMB/CIFS share using the smbclient
+// command, without mounting the share.
+//
+// URLs take the form smb://[user[:password]@]host[:port]/share/path. The
+// user authenticates with NTLM, or with Kerberos if the auth query parameter
+// is "kerberos", in which case a ticket must have been obtained with kinit.
+// Without a user, the share is accessed as a guest.
+//
+// Query parameters:
+//   - domain: the domain, or workgroup, of the user
+//   - auth: "ntlm", the default, or "kerberos"
+type SmbGetter struct {
+	getter
+}
+
+func (g *SmbGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	share, p, err := smbPath(u)
+	if err != nil {
+		return 0, err
+	}
+	if p == "" {
+		return ClientModeDir, nil
+	}
+
+	// allinfo prints the attributes of the file, Ex. "attributes: D (10)"
+	// for a directory
+	out, err := g.run(u, share, "", fmt.Sprintf(`allinfo "%s"`, p))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "attributes:") {
+			continue
+		}
+		attrs := strings.Fields(strings.TrimPrefix(line, "attributes:"))
+		if len(attrs) > 0 && strings.Contains(attrs[0], "D") {
+			return ClientModeDir, nil
+		}
+		return ClientModeFile, nil
+	}
+	return 0, fmt.Errorf("error reading the attributes of %s: %s", p, out)
+}
+
+func (g *SmbGetter) GetFilename(u *url.URL) (string, error) {
+	return "", nil
+}
+
+func (g *SmbGetter) Get(dst string, u *url.URL) error {
+	share, p, err := smbPath(u)
+	if err != nil {
+		return err
+	}
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	if strings.Contains(dst, `"`) {
+		return fmt.Errorf("unsupported destination for SMB: %s", dst)
+	}
+	_, err = g.run(u, share, p, fmt.Sprintf(`prompt OFF; recurse ON; lcd "%s"; mget *`, dst))
+	return err
+}
+
+func (g *SmbGetter) GetFile(dst string, u *url.URL) error {
+	share, p, err := smbPath(u)
+	if err != nil {
+		return err
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if strings.Contains(dst, `"`) {
+		return fmt.Errorf("unsupported destination for SMB: %s", dst)
+	}
+	_, err = g.run(u, share, "", fmt.Sprintf(`get "%s" "%s"`, p, dst))
+	return err
+}
+
+// run runs the smbclient commands on the share, in the directory dir if it
+// isn't empty, authenticating as the user of u, and returns its output.
+func (g *SmbGetter) run(u *url.URL, share, dir, commands string) (string, error) {
+	if _, err := exec.LookPath("smbclient"); err != nil {
+		return "", fmt.Errorf("smbclient must be available and on the PATH")
+	}
+
+	args := []string{fmt.Sprintf("//%s/%s", u.Hostname(), share)}
+	if port := u.Port(); port != "" {
+		args = append(args, "--port="+port)
+	}
+	if dir != "" {
+		args = append(args, "--directory="+dir)
+	}
+
+	q := u.Query()
+	if domain := q.Get("domain"); domain != "" {
+		args = append(args, "--workgroup="+domain)
+	}
+
+	// The credentials are passed in the environment, to keep the password
+	// out of the command line
+	env := os.Environ()
+	switch q.Get("auth") {
+	case "", "ntlm":
+		if u.User == nil {
+			args = append(args, "--no-pass")
+		} else {
+			env = append(env, "USER="+u.User.Username())
+			if password, ok := u.User.Password(); ok {
+				env = append(env, "PASSWD="+password)
+			}
+		}
+	case "kerberos":
+		args = append(args, "--use-kerberos=required")
+		if u.User != nil {
+			env = append(env, "USER="+u.User.Username())
+		}
+	default:
+		return "", fmt.Errorf("unsupported SMB auth: %s", q.Get("auth"))
+	}
+
+	cmd := exec.CommandContext(g.ctx(), "smbclient", append(args, "--command="+commands)...)
+	cmd.Env = env
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		out := strings.TrimSpace(stderr.String() + stdout.String())
+		return "", fmt.Errorf("error running smbclient: %s: %s", err, out)
+	}
+	return stdout.String(), nil
+}
+
+// smbPath returns the share and the path on the share of u, with the
+// backslashes smbclient expects.
+func smbPath(u *url.URL) (string, string, error) {
+	if u.Scheme != "smb" {
+		return "", "", fmt.Errorf("unsupported scheme for SMB: %s", u.Scheme)
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+	if u.Host == "" || parts[0] == "" {
+		return "", "", fmt.Errorf("URL is not a valid SMB URL, it has no share: %s", u)
+	}
+
+	var p string
+	if len(parts) == 2 {
+		p = strings.Trim(parts[1], "/")
+	}
+	if containsDotDot(p) || strings.ContainsAny(p, `"\`) {
+		return "", "", fmt.Errorf("unsupported path for SMB: %s", p)
+	}
+	return parts[0], strings.Replace(p, "/", `\`, -1), nil
+}
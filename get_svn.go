@@ -0,0 +1,167 @@
+package getter
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SvnGetter is a Getter implementation that will check out, or export, a
+// directory from a Subversion repository using the svn command.
+//
+// URLs take the form svn::https://[user[:password]@]host/repo/path, or
+// svn://host/repo/path for the svnserve protocol. The user and password are
+// passed to svn with --username and --password-from-stdin, and aren't
+// cached.
+//
+// Query parameters:
+//   - rev: the revision to check out, a number or one of svn's keywords
+//   - tag: a tag to check out instead of the path, which is the root of a
+//     repository with the standard trunk/branches/tags layout
+//   - export: true to export the files without the .svn metadata
+type SvnGetter struct {
+	getter
+}
+
+func (g *SvnGetter) ClientMode(_ *url.URL) (ClientMode, error) {
+	return ClientModeDir, nil
+}
+
+func (g *SvnGetter) GetFilename(u *url.URL) (string, error) {
+	return "", nil
+}
+
+func (g *SvnGetter) Get(dst string, u *url.URL) error {
+	src, rev, export, err := g.parse(u)
+	if err != nil {
+		return err
+	}
+
+	if export {
+		// Remove destination if it already exists
+		_, err := os.Stat(dst)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil {
+			// Remove the destination
+			if err := os.RemoveAll(dst); err != nil {
+				return err
+			}
+		}
+
+		// Create all the parent directories
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+
+		return g.run(src, "export", "--force", "-r", rev, src.String(), dst)
+	}
+
+	// An existing working copy is switched to the URL and revision, which
+	// only transfers the changes
+	_, err = os.Stat(filepath.Join(dst, ".svn"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		return g.run(src, "switch", "--ignore-ancestry", "-r", rev, src.String(), dst)
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	return g.run(src, "checkout", "-r", rev, src.String(), dst)
+}
+
+// GetFile exports the single file, without checking out its directory.
+func (g *SvnGetter) GetFile(dst string, u *url.URL) error {
+	src, rev, _, err := g.parse(u)
+	if err != nil {
+		return err
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	return g.run(src, "export", "--force", "-r", rev, src.String(), dst)
+}
+
+// parse returns the URL of the repository path to get, without the query,
+// the revision and whether to export it, from the query of u.
+func (g *SvnGetter) parse(u *url.URL) (*url.URL, string, bool, error) {
+	src := *u
+	q := src.Query()
+	src.RawQuery = ""
+
+	rev := q.Get("rev")
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	if tag := q.Get("tag"); tag != "" {
+		if containsDotDot(tag) || strings.Contains(tag, "/") {
+			return nil, "", false, fmt.Errorf("invalid tag: %s", tag)
+		}
+		src.Path = path.Join(src.Path, "tags", tag)
+	}
+
+	var export bool
+	if v := q.Get("export"); v != "" {
+		var err error
+		if export, err = strconv.ParseBool(v); err != nil {
+			return nil, "", false, fmt.Errorf("invalid export: %s", v)
+		}
+	}
+
+	return &src, rev, export, nil
+}
+
+// run runs svn non-interactively with the arguments, authenticated with the
+// user and password of u, which are removed from the URL in the arguments.
+// Tunnels, such as svn+ssh, authenticate the user of the URL themselves.
+func (g *SvnGetter) run(u *url.URL, args ...string) error {
+	if _, err := exec.LookPath("svn"); err != nil {
+		return fmt.Errorf("svn must be available and on the PATH")
+	}
+
+	opts := []string{"--non-interactive", "--no-auth-cache"}
+	var password string
+	var hasPassword bool
+	if u.User != nil && !strings.HasPrefix(u.Scheme, "svn+") {
+		opts = append(opts, "--username", u.User.Username())
+		if password, hasPassword = u.User.Password(); hasPassword {
+			opts = append(opts, "--password-from-stdin")
+		}
+
+		anon := *u
+		anon.User = nil
+		for i, arg := range args {
+			if arg == u.String() {
+				args[i] = anon.String()
+			}
+		}
+	}
+
+	cmd := exec.CommandContext(g.ctx(), "svn", append(opts, args...)...)
+	if hasPassword {
+		cmd.Stdin = strings.NewReader(password)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error running svn: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
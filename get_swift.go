@@ -0,0 +1,411 @@
+package getter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SwiftGetter is a Getter implementation that will download an object, or
+// all the objects below a pseudo-directory prefix, from OpenStack Swift.
+//
+// URLs take the form swift://container/path. The storage URL and token are
+// obtained from Keystone v3, at the auth_url query parameter or OS_AUTH_URL,
+// with the first of, if any:
+//   - an application credential, from the OS_APPLICATION_CREDENTIAL_ID and
+//     OS_APPLICATION_CREDENTIAL_SECRET environment variables
+//   - a user name and password, given in the URL or from the OS_USERNAME and
+//     OS_PASSWORD environment variables, scoped to the project of the project
+//     query parameter, OS_PROJECT_NAME or OS_PROJECT_ID
+//
+// The object-store endpoint of the region query parameter or OS_REGION_NAME
+// is used, if set. Alternatively, OS_AUTH_TOKEN and OS_STORAGE_URL give a
+// token and storage URL to use as is.
+type SwiftGetter struct {
+	getter
+
+	// Client is the http.Client to use for requests to Keystone and Swift.
+	// This defaults to the client shared with the HttpGetter if left unset.
+	Client *http.Client
+}
+
+// swiftObject is a parsed reference to an object, or a prefix of objects,
+// in a container, with the storage URL and token to access it.
+type swiftObject struct {
+	StorageURL string
+	Token      string
+	Container  string
+	Path       string
+}
+
+// swiftListing is an object of the JSON listing of a container.
+type swiftListing struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+}
+
+func (g *SwiftGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	obj, err := g.parseUrl(u)
+	if err != nil {
+		return 0, err
+	}
+	if obj.Path == "" || strings.HasSuffix(obj.Path, "/") {
+		return ClientModeDir, nil
+	}
+
+	// An object exists at exactly the path for single files, otherwise the
+	// path is a pseudo-directory.
+	resp, err := g.request(obj, "HEAD", obj.Path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case 200, 204:
+		return ClientModeFile, nil
+	case 404:
+		return ClientModeDir, nil
+	default:
+		return 0, fmt.Errorf("bad response code checking object %s: %d", obj.Path, resp.StatusCode)
+	}
+}
+
+func (g *SwiftGetter) GetFilename(u *url.URL) (string, error) {
+	return "", nil
+}
+
+func (g *SwiftGetter) Get(dst string, u *url.URL) error {
+	obj, err := g.parseUrl(u)
+	if err != nil {
+		return err
+	}
+
+	prefix := obj.Path
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// List the objects below the prefix, page after page, until a page is
+	// empty
+	found := false
+	marker := ""
+	for {
+		list, err := g.list(obj, prefix, marker)
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			break
+		}
+
+		for _, o := range list {
+			marker = o.Name
+
+			// Pseudo-directory markers are empty objects, skip them
+			if strings.HasSuffix(o.Name, "/") || o.ContentType == "application/directory" {
+				continue
+			}
+
+			rel := strings.TrimPrefix(o.Name, prefix)
+			if containsDotDot(rel) {
+				return fmt.Errorf("object name contains '..': %s", o.Name)
+			}
+
+			found = true
+			if err := g.getObject(filepath.Join(dst, filepath.FromSlash(rel)), obj, o.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("no objects found below %s", u)
+	}
+
+	return nil
+}
+
+func (g *SwiftGetter) GetFile(dst string, u *url.URL) error {
+	obj, err := g.parseUrl(u)
+	if err != nil {
+		return err
+	}
+
+	return g.getObject(dst, obj, obj.Path)
+}
+
+// getObject downloads the object with the given name to dst.
+func (g *SwiftGetter) getObject(dst string, obj *swiftObject, name string) error {
+	resp, err := g.request(obj, "GET", name, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("bad response code downloading object %s: %d", name, resp.StatusCode)
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if h := g.outputHash(dst); h != nil {
+		w = io.MultiWriter(f, h)
+	}
+
+	_, err = io.Copy(w, g.rateLimitReader(resp.Body))
+	return err
+}
+
+// list returns the page of objects below prefix after marker.
+func (g *SwiftGetter) list(obj *swiftObject, prefix, marker string) ([]swiftListing, error) {
+	q := url.Values{}
+	q.Set("format", "json")
+	if prefix != "" {
+		q.Set("prefix", prefix)
+	}
+	if marker != "" {
+		q.Set("marker", marker)
+	}
+
+	resp, err := g.request(obj, "GET", "", q)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+	case 204:
+		// Nothing listed
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("bad response code listing objects of %s: %d", obj.Container, resp.StatusCode)
+	}
+
+	var list []swiftListing
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("error parsing object list of %s: %s", obj.Container, err)
+	}
+
+	return list, nil
+}
+
+// request issues a request for the object with the given name, or for the
+// container if name is empty.
+func (g *SwiftGetter) request(obj *swiftObject, method, name string, query url.Values) (*http.Response, error) {
+	if g.Client == nil {
+		g.Client = httpClient
+	}
+
+	rawURL := obj.StorageURL + "/" + url.PathEscape(obj.Container)
+	if name != "" {
+		rawURL += "/" + strings.Replace(url.PathEscape(name), "%2F", "/", -1)
+	}
+	if len(query) > 0 {
+		rawURL += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(g.ctx())
+	req.Header.Set("X-Auth-Token", obj.Token)
+
+	return g.Client.Do(req)
+}
+
+func (g *SwiftGetter) parseUrl(u *url.URL) (*swiftObject, error) {
+	if u.Scheme != "swift" {
+		return nil, fmt.Errorf("unsupported scheme for Swift: %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("URL is not a valid Swift URL, it has no container: %s", u)
+	}
+
+	obj := &swiftObject{
+		Container: u.Host,
+		Path:      strings.TrimPrefix(u.Path, "/"),
+	}
+
+	// A token and storage URL given as is don't need Keystone
+	if token, storageURL := os.Getenv("OS_AUTH_TOKEN"), os.Getenv("OS_STORAGE_URL"); token != "" && storageURL != "" {
+		obj.Token = token
+		obj.StorageURL = strings.TrimSuffix(storageURL, "/")
+		return obj, nil
+	}
+
+	var err error
+	obj.StorageURL, obj.Token, err = g.authenticate(u)
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// authenticate requests a token from Keystone v3, and returns the URL of
+// the object-store endpoint of its catalog with the token.
+func (g *SwiftGetter) authenticate(u *url.URL) (string, string, error) {
+	if g.Client == nil {
+		g.Client = httpClient
+	}
+
+	q := u.Query()
+	// getenv returns the query parameter, or else the environment variable,
+	// or else def
+	getenv := func(param, env, def string) string {
+		if v := q.Get(param); v != "" {
+			return v
+		}
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+		return def
+	}
+
+	authURL := strings.TrimSuffix(getenv("auth_url", "OS_AUTH_URL", ""), "/")
+	if authURL == "" {
+		return "", "", fmt.Errorf("no Keystone URL given for %s, set auth_url or OS_AUTH_URL", u)
+	}
+	if !strings.HasSuffix(authURL, "/v3") {
+		authURL += "/v3"
+	}
+
+	var identity, scope map[string]interface{}
+	if id := os.Getenv("OS_APPLICATION_CREDENTIAL_ID"); id != "" {
+		identity = map[string]interface{}{
+			"methods": []string{"application_credential"},
+			"application_credential": map[string]string{
+				"id":     id,
+				"secret": os.Getenv("OS_APPLICATION_CREDENTIAL_SECRET"),
+			},
+		}
+	} else {
+		username, password := os.Getenv("OS_USERNAME"), os.Getenv("OS_PASSWORD")
+		if u.User != nil {
+			username = u.User.Username()
+			password, _ = u.User.Password()
+		}
+		if username == "" {
+			return "", "", fmt.Errorf("no OpenStack credentials given for %s", u)
+		}
+		identity = map[string]interface{}{
+			"methods": []string{"password"},
+			"password": map[string]interface{}{
+				"user": map[string]interface{}{
+					"name":     username,
+					"password": password,
+					"domain":   map[string]string{"name": getenv("", "OS_USER_DOMAIN_NAME", "Default")},
+				},
+			},
+		}
+
+		if project := getenv("project", "OS_PROJECT_NAME", ""); project != "" {
+			scope = map[string]interface{}{
+				"project": map[string]interface{}{
+					"name":   project,
+					"domain": map[string]string{"name": getenv("", "OS_PROJECT_DOMAIN_NAME", "Default")},
+				},
+			}
+		} else if id := os.Getenv("OS_PROJECT_ID"); id != "" {
+			scope = map[string]interface{}{
+				"project": map[string]string{"id": id},
+			}
+		}
+	}
+
+	auth := map[string]interface{}{"identity": identity}
+	if scope != nil {
+		auth["scope"] = scope
+	}
+	body, err := json.Marshal(map[string]interface{}{"auth": auth})
+	if err != nil {
+		return "", "", err
+	}
+
+	req, err := http.NewRequest("POST", authURL+"/auth/tokens", bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	req = req.WithContext(g.ctx())
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 201 {
+		return "", "", fmt.Errorf("bad response code authenticating with %s: %d", authURL, resp.StatusCode)
+	}
+
+	var result struct {
+		Token struct {
+			Catalog []struct {
+				Type      string `json:"type"`
+				Endpoints []struct {
+					Interface string `json:"interface"`
+					Region    string `json:"region"`
+					RegionID  string `json:"region_id"`
+					URL       string `json:"url"`
+				} `json:"endpoints"`
+			} `json:"catalog"`
+		} `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", "", fmt.Errorf("error parsing token from %s: %s", authURL, err)
+	}
+	token := resp.Header.Get("X-Subject-Token")
+	if token == "" {
+		return "", "", fmt.Errorf("no token returned by %s", authURL)
+	}
+
+	region := getenv("region", "OS_REGION_NAME", "")
+	iface := getenv("", "OS_INTERFACE", "public")
+	for _, service := range result.Token.Catalog {
+		if service.Type != "object-store" {
+			continue
+		}
+		for _, e := range service.Endpoints {
+			if e.Interface != iface || region != "" && e.Region != region && e.RegionID != region {
+				continue
+			}
+			return strings.TrimSuffix(e.URL, "/"), token, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("no %s object-store endpoint found in the catalog of %s", iface, authURL)
+}
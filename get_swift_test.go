@@ -0,0 +1,275 @@
+package getter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSwiftGetter_impl(t *testing.T) {
+	var _ Getter = new(SwiftGetter)
+}
+
+func TestSwiftGetter_parseUrl(t *testing.T) {
+	g := new(SwiftGetter)
+	for _, input := range []string{
+		"https://container/file.txt",
+		"swift:///file.txt",
+		"swift://container/file.txt",
+	} {
+		if _, err := g.parseUrl(testURL(input)); err == nil {
+			t.Fatalf("%s: should error", input)
+		}
+	}
+}
+
+func TestSwiftGetter_file(t *testing.T) {
+	ln := testSwiftServer(t)
+	defer ln.Close()
+
+	g := new(SwiftGetter)
+	u := testSwiftURL(ln, "file.txt", "")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeFile {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempFile(t)
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestSwiftGetter_dir(t *testing.T) {
+	ln := testSwiftServer(t)
+	defer ln.Close()
+
+	g := new(SwiftGetter)
+	u := testSwiftURL(ln, "module", "")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempDir(t)
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "# Hello\n")
+	assertContents(t, filepath.Join(dst, "sub", "foo.txt"), "foo\n")
+
+	if err := g.Get(tempDir(t), testSwiftURL(ln, "missing", "")); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestSwiftGetter_auth(t *testing.T) {
+	ln := testSwiftServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Name  string
+		User  *url.Userinfo
+		Query string
+		Env   map[string]string
+		Err   bool
+	}{
+		{"password", url.UserPassword("user", "secret"), "", nil, false},
+		{"bad password", url.UserPassword("user", "wrong"), "", nil, true},
+		{"password env", nil, "", map[string]string{"OS_USERNAME": "user", "OS_PASSWORD": "secret"}, false},
+		{"no credentials", nil, "", nil, true},
+		{"region", url.UserPassword("user", "secret"), "&region=RegionOne", nil, false},
+		{"other region", url.UserPassword("user", "secret"), "&region=RegionTwo", nil, true},
+		{
+			"application credential", nil, "",
+			map[string]string{"OS_APPLICATION_CREDENTIAL_ID": "app", "OS_APPLICATION_CREDENTIAL_SECRET": "secret"},
+			false,
+		},
+		{
+			"token", nil, "",
+			map[string]string{"OS_AUTH_TOKEN": "token", "OS_STORAGE_URL": fmt.Sprintf("http://%s/v1/AUTH_one", ln.Addr())},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			for k, v := range tc.Env {
+				old := os.Getenv(k)
+				os.Setenv(k, v)
+				defer os.Setenv(k, old)
+			}
+
+			g := new(SwiftGetter)
+			u := testSwiftURL(ln, "file.txt", tc.Query)
+			u.User = tc.User
+
+			dst := tempFile(t)
+			err := g.GetFile(dst, u)
+			if (err != nil) != tc.Err {
+				t.Fatalf("err: %v", err)
+			}
+			if !tc.Err {
+				assertContents(t, dst, "Hello\n")
+			}
+		})
+	}
+}
+
+func TestSwiftGetter_client(t *testing.T) {
+	ln := testSwiftServer(t)
+	defer ln.Close()
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:  testSwiftURL(ln, "module", "").String(),
+		Dst:  dst,
+		Mode: ClientModeAny,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "# Hello\n")
+}
+
+// testSwiftURL returns the URL of the object path in the container of
+// testSwiftServer, with the user "user" and Keystone of the server.
+func testSwiftURL(ln net.Listener, path, query string) *url.URL {
+	authURL := url.QueryEscape(fmt.Sprintf("http://%s/v3", ln.Addr()))
+	u := testURL(fmt.Sprintf("swift://container/%s?auth_url=%s%s", path, authURL, query))
+	u.User = url.UserPassword("user", "secret")
+	return u
+}
+
+// testSwiftServer starts a minimal Keystone and Swift. Keystone issues the
+// token "token" to the user "user" with the password "secret", and the
+// application credential "app" with the secret "secret". Its catalog has
+// the object-store endpoints of RegionOne, which has the container
+// "container", and RegionTwo, which has no containers. Objects are listed
+// one per page, to test listing with markers.
+func testSwiftServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	objects := map[string]string{
+		"file.txt":           "Hello\n",
+		"module/main.tf":     "# Hello\n",
+		"module/sub":         "",
+		"module/sub/foo.txt": "foo\n",
+		"other/bar.txt":      "bar\n",
+	}
+	var names []string
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v3/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Auth struct {
+				Identity struct {
+					Password struct {
+						User struct {
+							Name     string `json:"name"`
+							Password string `json:"password"`
+						} `json:"user"`
+					} `json:"password"`
+					ApplicationCredential struct {
+						ID     string `json:"id"`
+						Secret string `json:"secret"`
+					} `json:"application_credential"`
+				} `json:"identity"`
+			} `json:"auth"`
+		}
+		if r.Method != "POST" || json.NewDecoder(r.Body).Decode(&body) != nil {
+			w.WriteHeader(400)
+			return
+		}
+		identity := body.Auth.Identity
+		if !(identity.Password.User.Name == "user" && identity.Password.User.Password == "secret") &&
+			!(identity.ApplicationCredential.ID == "app" && identity.ApplicationCredential.Secret == "secret") {
+			w.WriteHeader(401)
+			return
+		}
+
+		w.Header().Set("X-Subject-Token", "token")
+		w.WriteHeader(201)
+		fmt.Fprintf(w, `{"token": {"catalog": [
+			{"type": "identity", "endpoints": [{"interface": "public", "region": "RegionOne", "url": "http://%[1]s/v3"}]},
+			{"type": "object-store", "endpoints": [
+				{"interface": "internal", "region": "RegionOne", "url": "http://%[1]s/internal"},
+				{"interface": "public", "region": "RegionOne", "url": "http://%[1]s/v1/AUTH_one"},
+				{"interface": "public", "region": "RegionTwo", "url": "http://%[1]s/v1/AUTH_two/"}
+			]}
+		]}}`, ln.Addr())
+	})
+	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "token" {
+			w.WriteHeader(401)
+			return
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v1/AUTH_one/"), "/", 2)
+		if !strings.HasPrefix(r.URL.Path, "/v1/AUTH_one/") || parts[0] != "container" {
+			w.WriteHeader(404)
+			return
+		}
+
+		q := r.URL.Query()
+		if len(parts) == 1 {
+			var list []map[string]string
+			for _, name := range names {
+				if strings.HasPrefix(name, q.Get("prefix")) && name > q.Get("marker") {
+					contentType := "text/plain"
+					if objects[name] == "" {
+						contentType = "application/directory"
+					}
+					list = append(list, map[string]string{"name": name, "content_type": contentType})
+					break
+				}
+			}
+			if len(list) == 0 {
+				w.WriteHeader(204)
+				return
+			}
+			json.NewEncoder(w).Encode(list)
+			return
+		}
+
+		content, ok := objects[parts[1]]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if r.Method != "HEAD" {
+			fmt.Fprint(w, content)
+		}
+	})
+
+	var server http.Server
+	server.Handler = mux
+	go server.Serve(ln)
+
+	return ln
+}
@@ -0,0 +1,224 @@
+package getter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WebDAVGetter is a Getter implementation that will download a file, or
+// all the files of a collection, from a WebDAV server, such as Nextcloud or
+// the WebDAV interface of Artifactory.
+//
+// URLs take the form dav://host/path or davs://host/path, for WebDAV over
+// HTTP and HTTPS respectively. The getter can also be forced on HTTP URLs:
+// webdav::https://host/path. Whether the path is a collection, and its
+// members, are found with PROPFIND requests, and files are downloaded with
+// HttpGet.
+type WebDAVGetter struct {
+	httpBackedGetter
+}
+
+// davPropfindBody asks for the resource type only, to tell collections and
+// files apart.
+const davPropfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`
+
+// davMultistatus is the response to a PROPFIND request.
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+			} `xml:"DAV: prop"`
+			Status string `xml:"DAV: status"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// davResource is a file or collection listed by a PROPFIND request.
+type davResource struct {
+	URL        *url.URL
+	Collection bool
+}
+
+func (g *WebDAVGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	u, err := davHttpURL(u)
+	if err != nil {
+		return 0, err
+	}
+
+	resources, err := g.propfind(u, "0")
+	if err != nil {
+		return 0, err
+	}
+	if len(resources) > 0 && resources[0].Collection {
+		return ClientModeDir, nil
+	}
+	return ClientModeFile, nil
+}
+
+func (g *WebDAVGetter) GetFilename(u *url.URL) (string, error) {
+	u, err := davHttpURL(u)
+	if err != nil {
+		return "", err
+	}
+	return g.http().GetFilename(u)
+}
+
+func (g *WebDAVGetter) Get(dst string, u *url.URL) error {
+	u, err := davHttpURL(u)
+	if err != nil {
+		return err
+	}
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	return g.getCollection(dst, u)
+}
+
+func (g *WebDAVGetter) GetFile(dst string, u *url.URL) error {
+	u, err := davHttpURL(u)
+	if err != nil {
+		return err
+	}
+	return g.http().GetFile(dst, u)
+}
+
+// getCollection downloads the members of the collection at u to dst,
+// descending into the collections it contains.
+func (g *WebDAVGetter) getCollection(dst string, u *url.URL) error {
+	if !strings.HasSuffix(u.Path, "/") {
+		u2 := *u
+		u2.Path += "/"
+		u2.RawPath = ""
+		u = &u2
+	}
+
+	resources, err := g.propfind(u, "1")
+	if err != nil {
+		return err
+	}
+
+	for _, r := range resources {
+		// Only the members directly in the collection are downloaded, the
+		// collection itself is listed as well
+		p, dir := path.Clean(r.URL.Path), path.Clean(u.Path)
+		if p == dir || path.Dir(p) != dir {
+			continue
+		}
+		name := path.Base(p)
+		if name == "." || name == ".." || strings.Contains(name, `\`) {
+			return fmt.Errorf("invalid member name in %s: %q", u, name)
+		}
+
+		local := filepath.Join(dst, name)
+		if r.Collection {
+			if err := os.MkdirAll(local, 0755); err != nil {
+				return err
+			}
+			err = g.getCollection(local, r.URL)
+		} else {
+			err = g.http().GetFile(local, r.URL)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// propfind returns the resource at u, and its members if depth is "1".
+func (g *WebDAVGetter) propfind(u *url.URL, depth string) ([]davResource, error) {
+	reqURL := *u
+	httpGet := g.http()
+	if httpGet.Netrc {
+		if err := addAuthFromNetrc(&reqURL); err != nil {
+			return nil, err
+		}
+	}
+
+	httpGet.initClient()
+	req, err := httpGet.newRequest("PROPFIND", &reqURL)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", `application/xml; charset="utf-8"`)
+	req.Body = ioutil.NopCloser(strings.NewReader(davPropfindBody))
+	req.ContentLength = int64(len(davPropfindBody))
+
+	resp, err := httpGet.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 207 {
+		return nil, fmt.Errorf("bad response code listing %s: %d", redactURL(u.String()), resp.StatusCode)
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("error parsing the PROPFIND response of %s: %s", redactURL(u.String()), err)
+	}
+
+	var resources []davResource
+	for _, r := range ms.Responses {
+		href, err := url.Parse(strings.TrimSpace(r.Href))
+		if err != nil {
+			return nil, fmt.Errorf("invalid href in the PROPFIND response of %s: %s", redactURL(u.String()), err)
+		}
+
+		// Hrefs are usually absolute paths, resolve them keeping the
+		// credentials of u
+		resource := davResource{URL: u.ResolveReference(href)}
+		resource.URL.User = u.User
+		for _, ps := range r.Propstat {
+			if ps.Prop.ResourceType.Collection != nil && strings.Contains(ps.Status, " 200 ") {
+				resource.Collection = true
+			}
+		}
+		resources = append(resources, resource)
+	}
+
+	return resources, nil
+}
+
+// davHttpURL returns u with its dav or davs scheme replaced by http or https.
+func davHttpURL(u *url.URL) (*url.URL, error) {
+	u2 := *u
+	switch u.Scheme {
+	case "dav":
+		u2.Scheme = "http"
+	case "davs":
+		u2.Scheme = "https"
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported scheme for WebDAV: %s", u.Scheme)
+	}
+	return &u2, nil
+}
@@ -0,0 +1,189 @@
+package getter
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWebDAVGetter_impl(t *testing.T) {
+	var _ Getter = new(WebDAVGetter)
+}
+
+func TestWebDAVGetter_file(t *testing.T) {
+	ln := testWebDAVServer(t)
+	defer ln.Close()
+
+	g := new(WebDAVGetter)
+	u := testWebDAVURL(ln, "file.txt")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeFile {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempFile(t)
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestWebDAVGetter_dir(t *testing.T) {
+	ln := testWebDAVServer(t)
+	defer ln.Close()
+
+	g := new(WebDAVGetter)
+	u := testWebDAVURL(ln, "module")
+
+	mode, err := g.ClientMode(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+
+	dst := tempDir(t)
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "# Hello\n")
+	assertContents(t, filepath.Join(dst, "hello world.txt"), "Hello, world\n")
+	assertContents(t, filepath.Join(dst, "sub", "foo.txt"), "foo\n")
+}
+
+func TestWebDAVGetter_auth(t *testing.T) {
+	ln := testWebDAVServer(t)
+	defer ln.Close()
+
+	g := new(WebDAVGetter)
+	u := testWebDAVURL(ln, "private/module")
+	if err := g.Get(tempDir(t), u); err == nil {
+		t.Fatal("should error")
+	}
+
+	u.User = url.UserPassword("user", "secret")
+	dst := tempDir(t)
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "# Hello\n")
+}
+
+func TestWebDAVGetter_client(t *testing.T) {
+	ln := testWebDAVServer(t)
+	defer ln.Close()
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:  "webdav::" + fmt.Sprintf("http://%s/dav/module", ln.Addr()),
+		Dst:  dst,
+		Mode: ClientModeAny,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "sub", "foo.txt"), "foo\n")
+}
+
+func testWebDAVURL(ln net.Listener, path string) *url.URL {
+	return testURL(fmt.Sprintf("dav://%s/dav/%s", ln.Addr().String(), path))
+}
+
+// testWebDAVServer starts a minimal WebDAV server below /dav/, answering
+// PROPFIND requests of depth 0 and 1. The collections below /dav/private/
+// require basic auth as user:secret.
+func testWebDAVServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	files := map[string]string{
+		"/dav/file.txt":                "Hello\n",
+		"/dav/module/main.tf":          "# Hello\n",
+		"/dav/module/hello world.txt":  "Hello, world\n",
+		"/dav/module/sub/foo.txt":      "foo\n",
+		"/dav/private/module/main.tf":  "# Hello\n",
+		"/dav/private/module/.hidden/": "",
+	}
+	isCollection := func(p string) bool {
+		for f := range files {
+			if strings.HasPrefix(f, strings.TrimSuffix(p, "/")+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dav/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/dav/private/") {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+				w.WriteHeader(401)
+				return
+			}
+		}
+
+		p := path.Clean(r.URL.Path)
+		content, isFile := files[p]
+		if !isFile && !isCollection(p) {
+			w.WriteHeader(404)
+			return
+		}
+
+		if r.Method != "PROPFIND" {
+			fmt.Fprint(w, content)
+			return
+		}
+
+		// The resource itself is listed first, then its members with depth 1
+		members := []string{p}
+		if !isFile && r.Header.Get("Depth") == "1" {
+			seen := map[string]bool{}
+			for f := range files {
+				if !strings.HasPrefix(f, p+"/") {
+					continue
+				}
+				member := p + "/" + strings.SplitN(strings.TrimPrefix(f, p+"/"), "/", 2)[0]
+				if !seen[member] {
+					seen[member] = true
+					members = append(members, member)
+				}
+			}
+			sort.Strings(members[1:])
+		}
+
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(207)
+		fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`)
+		for _, m := range members {
+			href := (&url.URL{Path: m}).EscapedPath()
+			resourceType := ""
+			if _, ok := files[m]; !ok && isCollection(m) {
+				href += "/"
+				resourceType = "<d:collection/>"
+			}
+			fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:propstat><d:prop>`+
+				`<d:resourcetype>%s</d:resourcetype></d:prop>`+
+				`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, href, resourceType)
+		}
+		fmt.Fprint(w, `</d:multistatus>`)
+	})
+
+	var server http.Server
+	server.Handler = mux
+	go server.Serve(ln)
+
+	return ln
+}
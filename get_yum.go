@@ -0,0 +1,591 @@
+package getter
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// YumGetter is a Getter implementation that will download an RPM from a
+// yum or DNF repository, resolving the package by name, version and
+// architecture from the repomd.xml and primary metadata of the repository.
+//
+// URLs take the form yum::https://host/path?package=name, where the URL is
+// the baseurl of the repository. The primary metadata is verified with its
+// checksum in repomd.xml, and the RPM with its checksum in the primary
+// metadata. With a GPG key, from the gpgkey query parameter or GpgKey, the
+// signature of repomd.xml in repomd.xml.asc is verified too, like
+// repo_gpgcheck does.
+//
+// Query parameters:
+//   - package: the name of the package
+//   - version: the version, which is matched exactly, or constraints on it,
+//     Ex., '>= 7.76, < 8', of the form '[epoch:]version[-release]', default
+//     as the newest version
+//   - arch: the architecture, default as that of the running system
+//   - gpgkey: the URLs of the GPG keys the signature of repomd.xml is
+//     verified with, which can be relative to the repository
+type YumGetter struct {
+	httpBackedGetter
+
+	// GpgKey are ASCII-armored GPG public keys the signature of repomd.xml
+	// is verified with, in addition to those of the gpgkey query parameter.
+	GpgKey string
+}
+
+// yumPackage is a package listed by the primary metadata.
+type yumPackage struct {
+	Name    string `xml:"name"`
+	Arch    string `xml:"arch"`
+	Version struct {
+		Epoch string `xml:"epoch,attr"`
+		Ver   string `xml:"ver,attr"`
+		Rel   string `xml:"rel,attr"`
+	} `xml:"version"`
+	Checksum yumChecksum `xml:"checksum"`
+	Location yumLocation `xml:"location"`
+}
+
+type yumChecksum struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type yumLocation struct {
+	Href string `xml:"href,attr"`
+	Base string `xml:"base,attr"`
+}
+
+// evr returns the '[epoch:]version-release' of the package.
+func (p *yumPackage) evr() string {
+	evr := p.Version.Ver + "-" + p.Version.Rel
+	if p.Version.Epoch != "" && p.Version.Epoch != "0" {
+		evr = p.Version.Epoch + ":" + evr
+	}
+	return evr
+}
+
+// yumQueryParams are the query parameters of the YumGetter, which aren't
+// part of the URL of the repository.
+var yumQueryParams = []string{"package", "version", "arch", "gpgkey"}
+
+// yumArchs maps the GOARCH of the running system to the name of its
+// architecture in RPM.
+var yumArchs = map[string]string{
+	"386":   "i686",
+	"amd64": "x86_64",
+	"arm":   "armv7hl",
+	"arm64": "aarch64",
+}
+
+func (g *YumGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	return ClientModeFile, nil
+}
+
+// GetFilename returns the name of the RPM of the resolved version, Ex.,
+// 'curl-7.76.1-26.el9.x86_64.rpm'.
+func (g *YumGetter) GetFilename(u *url.URL) (string, error) {
+	rpmURL, _, err := g.resolve(u)
+	if err != nil {
+		return "", err
+	}
+	return path.Base(rpmURL.Path), nil
+}
+
+// Get downloads the RPM into the directory dst.
+func (g *YumGetter) Get(dst string, u *url.URL) error {
+	rpmURL, p, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+	return g.getVerified(filepath.Join(dst, path.Base(rpmURL.Path)), rpmURL, p.Checksum)
+}
+
+func (g *YumGetter) GetFile(dst string, u *url.URL) error {
+	rpmURL, p, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+	return g.getVerified(dst, rpmURL, p.Checksum)
+}
+
+// getVerified downloads u to dst and verifies its checksum.
+func (g *YumGetter) getVerified(dst string, u *url.URL, checksum yumChecksum) error {
+	if err := g.http().GetFile(dst, u); err != nil {
+		return err
+	}
+
+	var h hash.Hash
+	switch checksum.Type {
+	case "sha", "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported checksum type of %s: %q", path.Base(u.Path), checksum.Type)
+	}
+
+	f, err := os.Open(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	expected := strings.ToLower(strings.TrimSpace(checksum.Value))
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != expected {
+		return fmt.Errorf("%s of %s doesn't match, expected %s, got %s", checksum.Type, path.Base(u.Path), expected, actual)
+	}
+	return nil
+}
+
+// resolve returns the URL of the RPM of the newest version of the package
+// of u matching the version and arch query parameters, and the package.
+func (g *YumGetter) resolve(u *url.URL) (*url.URL, *yumPackage, error) {
+	q := u.Query()
+	name := q.Get("package")
+	if name == "" {
+		return nil, nil, fmt.Errorf("query parameter 'package' is required")
+	}
+	constraints, err := parseRpmConstraints(q.Get("version"))
+	if err != nil {
+		return nil, nil, err
+	}
+	arch := q.Get("arch")
+	if arch == "" {
+		if arch = yumArchs[runtime.GOARCH]; arch == "" {
+			arch = runtime.GOARCH
+		}
+	}
+	gpgKeys := strings.FieldsFunc(q.Get("gpgkey"), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	repo := *u
+	for _, k := range yumQueryParams {
+		q.Del(k)
+	}
+	repo.RawQuery = q.Encode()
+	repo.Path = strings.TrimSuffix(repo.Path, "/") + "/"
+	repo.RawPath = ""
+
+	repomdURL, _ := repo.Parse("repodata/repomd.xml")
+	repomd, err := g.get(repomdURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := g.verifyRepomd(&repo, repomdURL, repomd, gpgKeys); err != nil {
+		return nil, nil, err
+	}
+
+	var md struct {
+		Data []struct {
+			Type     string      `xml:"type,attr"`
+			Checksum yumChecksum `xml:"checksum"`
+			Location yumLocation `xml:"location"`
+		} `xml:"data"`
+	}
+	if err := xml.Unmarshal(repomd, &md); err != nil {
+		return nil, nil, fmt.Errorf("error parsing %s: %s", redactURL(repomdURL.String()), err)
+	}
+	var primaryURL *url.URL
+	var primaryChecksum yumChecksum
+	for _, data := range md.Data {
+		if data.Type != "primary" {
+			continue
+		}
+		if primaryURL, err = yumLocationURL(&repo, data.Location); err != nil {
+			return nil, nil, err
+		}
+		primaryChecksum = data.Checksum
+	}
+	if primaryURL == nil {
+		return nil, nil, fmt.Errorf("%s has no primary metadata", redactURL(repomdURL.String()))
+	}
+
+	td, err := ioutil.TempDir(g.tempDir(), g.tempPattern("yum"))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer g.removeTemp(td)
+
+	// The primary metadata is usually compressed
+	primaryPath := filepath.Join(td, "primary"+path.Ext(primaryURL.Path))
+	if err := g.getVerified(primaryPath, primaryURL, primaryChecksum); err != nil {
+		return nil, nil, err
+	}
+	switch ext := strings.TrimPrefix(path.Ext(primaryURL.Path), "."); ext {
+	case "xml":
+	case "gz", "xz", "bz2":
+		xmlPath := filepath.Join(td, "primary.xml")
+		if err := g.decompressors()[ext].Decompress(xmlPath, primaryPath, false); err != nil {
+			return nil, nil, err
+		}
+		primaryPath = xmlPath
+	default:
+		return nil, nil, fmt.Errorf("unsupported compression of %s", path.Base(primaryURL.Path))
+	}
+
+	packages, err := parseYumPrimary(primaryPath, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing %s: %s", redactURL(primaryURL.String()), err)
+	}
+	var latest *yumPackage
+	for i, p := range packages {
+		if p.Arch != arch && p.Arch != "noarch" {
+			continue
+		}
+		if !constraints.Check(p.evr()) {
+			continue
+		}
+		if latest == nil || compareRpmEVRs(p.evr(), latest.evr()) > 0 {
+			latest = &packages[i]
+		}
+	}
+	if latest == nil {
+		return nil, nil, fmt.Errorf("no version of package %s for %s matches %q", name, arch, u.Query().Get("version"))
+	}
+
+	rpmURL, err := yumLocationURL(&repo, latest.Location)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rpmURL, latest, nil
+}
+
+// verifyRepomd verifies the signature of repomd.xml with the keys of the
+// getter and the keyURLs, relative to repo, if any.
+func (g *YumGetter) verifyRepomd(repo, repomdURL *url.URL, repomd []byte, keyURLs []string) error {
+	var keyring openpgp.EntityList
+	if g.GpgKey != "" {
+		keys, err := openpgp.ReadArmoredKeyRing(strings.NewReader(g.GpgKey))
+		if err != nil {
+			return fmt.Errorf("error reading GpgKey: %s", err)
+		}
+		keyring = append(keyring, keys...)
+	}
+	for _, k := range keyURLs {
+		keyURL, err := repo.Parse(k)
+		if err != nil {
+			return fmt.Errorf("invalid gpgkey %q: %s", k, err)
+		}
+		key, err := g.get(keyURL)
+		if err != nil {
+			return err
+		}
+		keys, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+		if err != nil {
+			return fmt.Errorf("error reading the GPG key %s: %s", redactURL(keyURL.String()), err)
+		}
+		keyring = append(keyring, keys...)
+	}
+	if len(keyring) == 0 {
+		return nil
+	}
+
+	sigURL, _ := repomdURL.Parse("repomd.xml.asc")
+	sig, err := g.get(sigURL)
+	if err != nil {
+		return err
+	}
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(repomd), bytes.NewReader(sig), nil); err != nil {
+		return fmt.Errorf("error verifying the signature of %s: %s", redactURL(repomdURL.String()), err)
+	}
+	return nil
+}
+
+// get downloads u.
+func (g *YumGetter) get(u *url.URL) ([]byte, error) {
+	reqURL := *u
+	httpGet := g.http()
+	if httpGet.Netrc {
+		if err := addAuthFromNetrc(&reqURL); err != nil {
+			return nil, err
+		}
+	}
+
+	httpGet.initClient()
+	resp, err := httpGet.do("GET", &reqURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("bad response code getting %s: %d", redactURL(u.String()), resp.StatusCode)
+	}
+	return ioutil.ReadAll(g.rateLimitReader(resp.Body))
+}
+
+// yumLocationURL returns the URL of the location in the metadata of the
+// repository, which is relative to its xml:base, if any, or to repo.
+func yumLocationURL(repo *url.URL, loc yumLocation) (*url.URL, error) {
+	if loc.Href == "" || containsDotDot(loc.Href) {
+		return nil, fmt.Errorf("invalid location %q in the metadata of %s", loc.Href, redactURL(repo.String()))
+	}
+	base := repo
+	if loc.Base != "" {
+		var err error
+		if base, err = repo.Parse(strings.TrimSuffix(loc.Base, "/") + "/"); err != nil {
+			return nil, fmt.Errorf("invalid xml:base %q in the metadata of %s: %s", loc.Base, redactURL(repo.String()), err)
+		}
+	}
+	return base.Parse(strings.TrimPrefix(loc.Href, "/"))
+}
+
+// parseYumPrimary returns the packages named name in the primary metadata
+// at path, decoding one package at a time, as the metadata can be large.
+func parseYumPrimary(primaryPath, name string) ([]yumPackage, error) {
+	f, err := os.Open(primaryPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var packages []yumPackage
+	found := false
+	d := xml.NewDecoder(f)
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "metadata":
+			found = true
+		case "package":
+			var p yumPackage
+			if err := d.DecodeElement(&p, &start); err != nil {
+				return nil, err
+			}
+			if p.Name == name {
+				packages = append(packages, p)
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no metadata element")
+	}
+	return packages, nil
+}
+
+// rpmConstraints are constraints on RPM versions, which all have to match.
+type rpmConstraints []struct {
+	op, evr string
+}
+
+// parseRpmConstraints parses the comma-separated constraints in expr, Ex.,
+// '>= 7.76, < 8'. A version without an operator is matched exactly.
+func parseRpmConstraints(expr string) (rpmConstraints, error) {
+	var constraints rpmConstraints
+	for _, c := range strings.Split(expr, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		evr := strings.TrimSpace(strings.TrimLeft(c, "<>="))
+		op := strings.TrimSpace(c[:len(c)-len(strings.TrimLeft(c, "<>="))])
+		switch op {
+		case "", "==":
+			op = "="
+		case "<", "<=", "=", ">=", ">":
+		default:
+			return nil, fmt.Errorf("invalid version constraint %q", c)
+		}
+		if evr == "" || evr[0] < '0' || evr[0] > '9' {
+			return nil, fmt.Errorf("invalid version constraint %q", c)
+		}
+		constraints = append(constraints, struct{ op, evr string }{op, evr})
+	}
+	return constraints, nil
+}
+
+// Check returns whether the version evr, with a release, matches the
+// constraints. Constraints without a release match any release.
+func (cs rpmConstraints) Check(evr string) bool {
+	for _, c := range cs {
+		v := evr
+		if _, _, rel := splitRpmEVR(c.evr); rel == "" {
+			epoch, ver, _ := splitRpmEVR(evr)
+			v = epoch + ":" + ver
+		}
+		cmp := compareRpmEVRs(v, c.evr)
+		var ok bool
+		switch c.op {
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case "=":
+			ok = cmp == 0
+		case ">=":
+			ok = cmp >= 0
+		case ">":
+			ok = cmp > 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// splitRpmEVR splits the '[epoch:]version[-release]' evr, with an epoch of
+// 0 by default.
+func splitRpmEVR(evr string) (string, string, string) {
+	epoch := "0"
+	if i := strings.Index(evr, ":"); i >= 0 {
+		epoch, evr = evr[:i], evr[i+1:]
+	}
+	rel := ""
+	if i := strings.LastIndex(evr, "-"); i >= 0 {
+		evr, rel = evr[:i], evr[i+1:]
+	}
+	return epoch, evr, rel
+}
+
+// compareRpmEVRs compares the versions a and b, of the form
+// '[epoch:]version[-release]', as rpm does, returning a negative number if
+// a is older, zero if they're equal, and a positive number if a is newer.
+func compareRpmEVRs(a, b string) int {
+	aEpoch, aVer, aRel := splitRpmEVR(a)
+	bEpoch, bVer, bRel := splitRpmEVR(b)
+	ae, _ := strconv.Atoi(aEpoch)
+	be, _ := strconv.Atoi(bEpoch)
+	if ae != be {
+		if ae < be {
+			return -1
+		}
+		return 1
+	}
+	if cmp := compareRpmVersions(aVer, bVer); cmp != 0 {
+		return cmp
+	}
+	return compareRpmVersions(aRel, bRel)
+}
+
+// compareRpmVersions compares the versions or releases a and b as
+// rpmvercmp does: they're split into segments of digits and letters,
+// compared numerically and lexically, where '~' sorts before anything,
+// even the end, and '^' after the end but before anything else.
+func compareRpmVersions(a, b string) int {
+	if a == b {
+		return 0
+	}
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	isAlpha := func(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+	isAlnum := func(c byte) bool { return isDigit(c) || isAlpha(c) }
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isAlnum(a[i]) && a[i] != '~' && a[i] != '^' {
+			i++
+		}
+		for j < len(b) && !isAlnum(b[j]) && b[j] != '~' && b[j] != '^' {
+			j++
+		}
+
+		if (i < len(a) && a[i] == '~') || (j < len(b) && b[j] == '~') {
+			if i >= len(a) || a[i] != '~' {
+				return 1
+			}
+			if j >= len(b) || b[j] != '~' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		if (i < len(a) && a[i] == '^') || (j < len(b) && b[j] == '^') {
+			if i >= len(a) {
+				return -1
+			}
+			if j >= len(b) {
+				return 1
+			}
+			if a[i] != '^' {
+				return 1
+			}
+			if b[j] != '^' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		if i >= len(a) || j >= len(b) {
+			break
+		}
+
+		// The segments are of the kind of the segment of a
+		si, sj := i, j
+		numeric := isDigit(a[i])
+		kind := isAlpha
+		if numeric {
+			kind = isDigit
+		}
+		for i < len(a) && kind(a[i]) {
+			i++
+		}
+		for j < len(b) && kind(b[j]) {
+			j++
+		}
+		if j == sj {
+			// Numeric segments are newer than alphabetic ones
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+
+		segA, segB := a[si:i], b[sj:j]
+		if numeric {
+			segA, segB = strings.TrimLeft(segA, "0"), strings.TrimLeft(segB, "0")
+			if len(segA) != len(segB) {
+				if len(segA) > len(segB) {
+					return 1
+				}
+				return -1
+			}
+		}
+		if segA != segB {
+			if segA < segB {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case i >= len(a) && j >= len(b):
+		return 0
+	case i < len(a):
+		return 1
+	}
+	return -1
+}
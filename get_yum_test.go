@@ -0,0 +1,305 @@
+package getter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+func TestYumGetter_impl(t *testing.T) {
+	var _ Getter = new(YumGetter)
+}
+
+func TestCompareRpmEVRs(t *testing.T) {
+	cases := []struct {
+		A, B string
+		Cmp  int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.00", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1.0a", "1.0", 1},
+		{"1.0", "1.0.1", -1},
+		{"1.0a", "1.0.1", -1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0^git1", "1.0", 1},
+		{"1.0^git1", "1.0.1", -1},
+		{"7.76.1-26.el9", "7.76.1-29.el9", -1},
+		{"1:7.0-1", "8.0.0-1.el9", 1},
+		{"0:1.0-1", "1.0-1", 0},
+		{"1.0_1", "1.0.1", 0},
+	}
+	for _, tc := range cases {
+		cmp := compareRpmEVRs(tc.A, tc.B)
+		if (cmp < 0 && tc.Cmp >= 0) || (cmp > 0 && tc.Cmp <= 0) || (cmp == 0 && tc.Cmp != 0) {
+			t.Fatalf("%s %s: bad comparison: %d", tc.A, tc.B, cmp)
+		}
+	}
+}
+
+func TestParseRpmConstraints(t *testing.T) {
+	cases := []struct {
+		Expr  string
+		EVR   string
+		Match bool
+	}{
+		{"", "1.0-1", true},
+		{"7.76.1", "7.76.1-26.el9", true},
+		{"7.76.1-26.el9", "7.76.1-29.el9", false},
+		{">= 7.76, < 8", "7.76.1-29.el9", true},
+		{">=7.76,<8", "8.0.0-1.el9", false},
+		{"> 7.76.1", "7.76.1-29.el9", false},
+		{"> 7.76.1-26.el9", "7.76.1-29.el9", true},
+		{"<= 1:7.0", "8.0.0-1", true},
+	}
+	for _, tc := range cases {
+		c, err := parseRpmConstraints(tc.Expr)
+		if err != nil {
+			t.Fatalf("%q: err: %s", tc.Expr, err)
+		}
+		if c.Check(tc.EVR) != tc.Match {
+			t.Fatalf("%q %s: bad match", tc.Expr, tc.EVR)
+		}
+	}
+
+	for _, expr := range []string{">=", "=> 1.0", "~1.0", "!= 1.0"} {
+		if _, err := parseRpmConstraints(expr); err == nil {
+			t.Fatalf("%q: should error", expr)
+		}
+	}
+}
+
+func TestYumGetter(t *testing.T) {
+	ln, key := testYumServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Query    string
+		Filename string
+	}{
+		{"package=curl", "curl-8.0.0-1.el9.x86_64.rpm"},
+		{"package=curl&version=>=%207.76,%20<%208", "curl-7.76.1-29.el9.x86_64.rpm"},
+		{"package=curl&version=7.76.1-26.el9", "curl-7.76.1-26.el9.x86_64.rpm"},
+		{"package=curl&arch=aarch64", "curl-7.76.1-29.el9.aarch64.rpm"},
+		{"package=bash-completion", "bash-completion-2.11-5.el9.noarch.rpm"},
+		{"package=curl&gpgkey=RPM-GPG-KEY", "curl-8.0.0-1.el9.x86_64.rpm"},
+	}
+	for _, tc := range cases {
+		g := new(YumGetter)
+		u := testURL(fmt.Sprintf("http://%s/repo?arch=x86_64&%s", ln.Addr(), tc.Query))
+		if strings.Contains(tc.Query, "arch=") {
+			u = testURL(fmt.Sprintf("http://%s/repo/?%s", ln.Addr(), tc.Query))
+		}
+
+		mode, err := g.ClientMode(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		if mode != ClientModeFile {
+			t.Fatalf("%s: bad mode: %d", tc.Query, mode)
+		}
+
+		filename, err := g.GetFilename(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		if filename != tc.Filename {
+			t.Fatalf("%s: bad filename: %s", tc.Query, filename)
+		}
+
+		dst := tempFile(t)
+		if err := g.GetFile(dst, u); err != nil {
+			t.Fatalf("%s: err: %s", tc.Query, err)
+		}
+		assertContents(t, dst, tc.Filename)
+	}
+
+	// The key can be given to the getter
+	g := &YumGetter{GpgKey: key}
+	u := testURL(fmt.Sprintf("http://%s/repo?package=curl&arch=x86_64", ln.Addr()))
+	if err := g.GetFile(tempFile(t), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestYumGetter_bad(t *testing.T) {
+	ln, _ := testYumServer(t)
+	defer ln.Close()
+
+	g := new(YumGetter)
+	for _, query := range []string{
+		"",
+		"package=missing",
+		"package=curl&version=>%209",
+		"package=curl&version=abc",
+		"package=curl&arch=s390x",
+		"package=corrupt",
+		"package=curl&gpgkey=OTHER-GPG-KEY",
+		"package=curl&gpgkey=MISSING-GPG-KEY",
+	} {
+		u := testURL(fmt.Sprintf("http://%s/repo?%s", ln.Addr(), query))
+		if err := g.GetFile(tempFile(t), u); err == nil {
+			t.Fatalf("%s: should error", query)
+		}
+	}
+
+	// The repository isn't signed
+	u := testURL(fmt.Sprintf("http://%s/unsigned?package=curl&arch=x86_64&gpgkey=/repo/RPM-GPG-KEY", ln.Addr()))
+	if err := g.GetFile(tempFile(t), u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestYumGetter_client(t *testing.T) {
+	ln, _ := testYumServer(t)
+	defer ln.Close()
+
+	dst := tempDir(t)
+	client := &Client{
+		Src:  fmt.Sprintf("yum::http://%s/repo?package=curl&arch=aarch64&gpgkey=RPM-GPG-KEY", ln.Addr()),
+		Dst:  filepath.Join(dst, "curl.rpm"),
+		Mode: ClientModeFile,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "curl.rpm"), "curl-7.76.1-29.el9.aarch64.rpm")
+}
+
+// testYumServer starts a repository at /repo, with repomd.xml signed by the
+// key at /repo/RPM-GPG-KEY, which is returned, and the same repository
+// without the signature at /unsigned. /repo/OTHER-GPG-KEY is a key that
+// didn't sign it. The repository has the versions 7.76.1-26.el9,
+// 7.76.1-29.el9 and 8.0.0-1.el9 of curl for x86_64, 7.76.1-29.el9 for
+// aarch64, bash-completion for noarch and corrupt, whose checksum doesn't
+// match. The content of each RPM is its name.
+func testYumServer(t *testing.T) (net.Listener, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	files := map[string][]byte{}
+	var primary bytes.Buffer
+	fmt.Fprint(&primary, `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="7">
+`)
+	add := func(name, epoch, ver, rel, arch string) {
+		filename := fmt.Sprintf("Packages/%c/%s-%s-%s.%s.rpm", name[0], name, ver, rel, arch)
+		content := []byte(filepath.Base(filename))
+		files[filename] = content
+		sum := sha256.Sum256(content)
+		sha := hex.EncodeToString(sum[:])
+		if name == "corrupt" {
+			sha = strings.Repeat("0", 64)
+		}
+		fmt.Fprintf(&primary, `<package type="rpm">
+  <name>%s</name>
+  <arch>%s</arch>
+  <version epoch="%s" ver="%s" rel="%s"/>
+  <checksum type="sha256" pkgid="YES">%s</checksum>
+  <summary>A package</summary>
+  <format>
+    <rpm:license>MIT</rpm:license>
+    <rpm:provides><rpm:entry name="%s" flags="EQ" epoch="%s" ver="%s" rel="%s"/></rpm:provides>
+  </format>
+  <location href="%s"/>
+</package>
+`, name, arch, epoch, ver, rel, sha, name, epoch, ver, rel, filename)
+	}
+	add("curl", "0", "7.76.1", "26.el9", "x86_64")
+	add("curl", "0", "8.0.0", "1.el9", "x86_64")
+	add("curl", "0", "7.76.1", "29.el9", "x86_64")
+	add("curl", "0", "7.76.1", "29.el9", "aarch64")
+	add("curl", "0", "7.76.1", "29.el9", "src")
+	add("bash-completion", "1", "2.11", "5.el9", "noarch")
+	add("corrupt", "0", "1.0", "1", "x86_64")
+	fmt.Fprint(&primary, "</metadata>\n")
+
+	var primaryGz bytes.Buffer
+	gzipW := gzip.NewWriter(&primaryGz)
+	gzipW.Write(primary.Bytes())
+	gzipW.Close()
+	sum := sha256.Sum256(primaryGz.Bytes())
+	primaryPath := fmt.Sprintf("repodata/%x-primary.xml.gz", sum)
+	files[primaryPath] = primaryGz.Bytes()
+
+	repomd := []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo" xmlns:rpm="http://linux.duke.edu/metadata/rpm">
+  <revision>1700000000</revision>
+  <data type="filelists">
+    <checksum type="sha256">%s</checksum>
+    <location href="repodata/filelists.xml.gz"/>
+  </data>
+  <data type="primary">
+    <checksum type="sha256">%x</checksum>
+    <open-checksum type="sha256">%s</open-checksum>
+    <location href="%s"/>
+  </data>
+</repomd>
+`, strings.Repeat("0", 64), sum, strings.Repeat("0", 64), primaryPath))
+	files["repodata/repomd.xml"] = repomd
+
+	// The repository is signed with one of two keys
+	var keys []string
+	for _, name := range []string{"Test", "Other"} {
+		entity, err := openpgp.NewEntity(name, "", strings.ToLower(name)+"@example.com", nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		var key bytes.Buffer
+		w, err := armor.Encode(&key, openpgp.PublicKeyType, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := entity.Serialize(w); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		w.Close()
+		keys = append(keys, key.String())
+
+		if name == "Test" {
+			var sig bytes.Buffer
+			if err := openpgp.ArmoredDetachSign(&sig, entity, bytes.NewReader(repomd), nil); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			files["repodata/repomd.xml.asc"] = sig.Bytes()
+		}
+	}
+	files["RPM-GPG-KEY"] = []byte(keys[0])
+	files["OTHER-GPG-KEY"] = []byte(keys[1])
+
+	mux := http.NewServeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[strings.Index(r.URL.Path[1:], "/")+2:]
+		if strings.HasPrefix(r.URL.Path, "/unsigned/") && strings.HasSuffix(name, ".asc") {
+			w.WriteHeader(404)
+			return
+		}
+		content, ok := files[name]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write(content)
+	}
+	mux.HandleFunc("/repo/", handler)
+	mux.HandleFunc("/unsigned/", handler)
+
+	var server http.Server
+	server.Handler = mux
+	go server.Serve(ln)
+
+	return ln, keys[0]
+}
@@ -0,0 +1,151 @@
+package getter
+
+import (
+	"io"
+	"net/url"
+)
+
+// Hooks are callbacks invoked by the Client and its getters at key stages of
+// a download, for tools tracing complex fetches or building dashboards. Any
+// of them can be nil. Sources and URLs are passed with their passwords, and
+// credentials in their query, redacted.
+type Hooks struct {
+	// Detected is called with the source and what it was detected as, Ex.,
+	// "github.com/foo/bar" and "git::https://github.com/foo/bar.git".
+	Detected func(src, detected string)
+
+	// GetterSelected is called with the key of the getter selected for the
+	// source, Ex., "git", and the getter itself.
+	GetterSelected func(key string, g Getter)
+
+	// RequestStarted is called when a getter starts a request, by getters
+	// supporting it, such as the HTTP and Maven getters.
+	RequestStarted func(method string, u *url.URL)
+
+	// Progress is called as a file is downloaded, with the number of bytes
+	// read so far and the total, or -1 if unknown, by getters supporting
+	// it, such as the HTTP and Maven getters.
+	Progress func(u *url.URL, read, total int64)
+
+	// DecompressStarted and DecompressFinished are called before and after
+	// an archive of the given type, Ex., "tar.gz", is decompressed into dst.
+	DecompressStarted  func(archive, dst string)
+	DecompressFinished func(archive, dst string, err error)
+
+	// SnapshotResolved is called when the MvnGetter resolves a snapshot
+	// version to its latest build, Ex., "6.13-SNAPSHOT" to
+	// "6.13-20171126.202552-6".
+	SnapshotResolved func(version, build string)
+}
+
+// ProgressTracker is notified of the progress of the files downloaded by
+// getters supporting it, such as the HTTP and Maven getters, e.g. to render
+// progress bars with an ETA. See Client.ProgressTracker.
+type ProgressTracker interface {
+	// TrackProgress is called as the file at src is downloaded, with the
+	// number of bytes read so far and the total, or -1 if unknown. The
+	// password and query credentials of src are redacted, see redactURL.
+	TrackProgress(src string, read, total int64)
+}
+
+// The methods below call the hooks, and are no-ops if h or the hook is nil.
+
+func (h *Hooks) detected(src, detected string) {
+	if h != nil && h.Detected != nil {
+		h.Detected(redactURL(src), redactURL(detected))
+	}
+}
+
+func (h *Hooks) getterSelected(key string, g Getter) {
+	if h != nil && h.GetterSelected != nil {
+		h.GetterSelected(key, g)
+	}
+}
+
+func (h *Hooks) requestStarted(method string, u *url.URL) {
+	if h != nil && h.RequestStarted != nil {
+		redacted, _ := url.Parse(redactURL(u.String()))
+		h.RequestStarted(method, redacted)
+	}
+}
+
+func (h *Hooks) decompressStarted(archive, dst string) {
+	if h != nil && h.DecompressStarted != nil {
+		h.DecompressStarted(archive, dst)
+	}
+}
+
+func (h *Hooks) decompressFinished(archive, dst string, err error) {
+	if h != nil && h.DecompressFinished != nil {
+		h.DecompressFinished(archive, dst, err)
+	}
+}
+
+func (h *Hooks) progress(u *url.URL, read, total int64) {
+	if h != nil && h.Progress != nil {
+		redacted, _ := url.Parse(redactURL(u.String()))
+		h.Progress(redacted, read, total)
+	}
+}
+
+func (h *Hooks) snapshotResolved(version, build string) {
+	if h != nil && h.SnapshotResolved != nil {
+		h.SnapshotResolved(version, build)
+	}
+}
+
+// progressReader returns r reporting the progress of reading the file at u
+// of the given total size to the Progress hook, or r itself if there is no
+// such hook.
+func (h *Hooks) progressReader(u *url.URL, r io.Reader, total int64) io.Reader {
+	if h == nil || h.Progress == nil {
+		return r
+	}
+	redacted, _ := url.Parse(redactURL(u.String()))
+	return &progressReader{Reader: r, progress: h.Progress, u: redacted, total: total}
+}
+
+// progressReader returns r reporting the progress of reading the file at u
+// of the given total size to the Progress hook and the ProgressTracker of the
+// client, or r itself if there are none.
+func (c *Client) progressReader(u *url.URL, r io.Reader, total int64) io.Reader {
+	r = c.Hooks.progressReader(u, r, total)
+	if c.ProgressTracker == nil {
+		return r
+	}
+
+	track := func(u *url.URL, read, total int64) {
+		c.ProgressTracker.TrackProgress(u.String(), read, total)
+	}
+	redacted, _ := url.Parse(redactURL(u.String()))
+	return &progressReader{Reader: r, progress: track, u: redacted, total: total}
+}
+
+// progress reports the progress of downloading the file at u to the
+// Progress hook and the ProgressTracker of the client, for downloads that
+// aren't read through a progressReader.
+func (c *Client) progress(u *url.URL, read, total int64) {
+	c.Hooks.progress(u, read, total)
+	if c.ProgressTracker != nil {
+		c.ProgressTracker.TrackProgress(redactURL(u.String()), read, total)
+	}
+}
+
+// progressReader calls progress with the number of bytes read so far after
+// every read from the Reader.
+type progressReader struct {
+	io.Reader
+	progress func(u *url.URL, read, total int64)
+	u        *url.URL
+	read     int64
+	total    int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		r.progress(r.u, r.read, r.total)
+	}
+	return n, err
+}
@@ -0,0 +1,145 @@
+package getter
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testHooks returns Hooks recording the stages they are called at in events.
+func testHooks(events *[]string) *Hooks {
+	record := func(format string, v ...interface{}) {
+		*events = append(*events, fmt.Sprintf(format, v...))
+	}
+
+	return &Hooks{
+		Detected: func(src, detected string) {
+			record("detected")
+		},
+		GetterSelected: func(key string, g Getter) {
+			record("getter %s", key)
+		},
+		RequestStarted: func(method string, u *url.URL) {
+			record("request %s %s", method, filepath.Base(u.Path))
+		},
+		Progress: func(u *url.URL, read, total int64) {
+			if read == total {
+				record("progress %s %d", filepath.Base(u.Path), read)
+			}
+		},
+		DecompressStarted: func(archive, dst string) {
+			record("decompress %s", archive)
+		},
+		DecompressFinished: func(archive, dst string, err error) {
+			record("decompressed %s %v", archive, err)
+		},
+		SnapshotResolved: func(version, build string) {
+			record("snapshot %s %s", version, build)
+		},
+	}
+}
+
+func TestHooks_mvn(t *testing.T) {
+	ln := testMvnServer(t)
+	defer ln.Close()
+
+	var events []string
+	client := &Client{
+		Src:   "mvn::" + testMvnURL(ln, "groupId=org.example&artifactId=test&version=1.1.0-SNAPSHOT").String(),
+		Dst:   tempFile(t),
+		Mode:  ClientModeFile,
+		Hooks: testHooks(&events),
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		"detected",
+		"getter mvn",
+		"request GET maven-metadata.xml",
+		"snapshot 1.1.0-SNAPSHOT 1.1.0-20171126.202552-2",
+		"request GET test-1.1.0-20171126.202552-2.jar",
+		"progress test-1.1.0-20171126.202552-2.jar 24",
+		"request GET test-1.1.0-20171126.202552-2.jar.sha256",
+		"request GET test-1.1.0-20171126.202552-2.jar.sha1",
+		"request GET test-1.1.0-20171126.202552-2.jar.md5",
+	}
+	var actual []string
+	for _, e := range events {
+		// The metadata is downloaded to a temporary file, with progress
+		if !strings.HasPrefix(e, "progress maven-metadata") {
+			actual = append(actual, e)
+		}
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad events:\n%s", strings.Join(events, "\n"))
+	}
+}
+
+func TestHooks_decompress(t *testing.T) {
+	var events []string
+	client := &Client{
+		Src:   testModule("archive-rooted/archive.tar.gz"),
+		Dst:   tempDir(t),
+		Mode:  ClientModeDir,
+		Hooks: testHooks(&events),
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		"detected",
+		"getter file",
+		"decompress tar.gz",
+		"decompressed tar.gz <nil>",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("bad events:\n%s", strings.Join(events, "\n"))
+	}
+}
+
+// testProgressTracker records the last progress of every file.
+type testProgressTracker map[string][2]int64
+
+func (p testProgressTracker) TrackProgress(src string, read, total int64) {
+	p[src] = [2]int64{read, total}
+}
+
+func TestProgressTracker(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	tracker := testProgressTracker{}
+	src := fmt.Sprintf("http://user:secret@%s/file", ln.Addr().String())
+	client := &Client{
+		Src:             src,
+		Dst:             tempFile(t),
+		Mode:            ClientModeFile,
+		ProgressTracker: tracker,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := testProgressTracker{
+		redactURL(src): {6, 6},
+	}
+	if !reflect.DeepEqual(tracker, expected) {
+		t.Fatalf("bad: %v", tracker)
+	}
+}
+
+func TestHooks_nil(t *testing.T) {
+	// Calling the hooks of a client without them is a no-op
+	var h *Hooks
+	h.detected("foo", "bar")
+	h.snapshotResolved("1.0-SNAPSHOT", "1.0-20180101.000000-1")
+	if r := h.progressReader(testURL("http://example.com"), nil, 0); r != nil {
+		t.Fatalf("bad: %#v", r)
+	}
+}
@@ -0,0 +1,13 @@
+package getter
+
+// Logger is the interface used by the Client and the getters for logging.
+// It is satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// discardLogger is a Logger that logs nothing. It is used when no Logger
+// is configured so that library consumers don't get unsolicited output.
+type discardLogger struct{}
+
+func (discardLogger) Printf(string, ...interface{}) {}
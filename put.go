@@ -0,0 +1,14 @@
+package getter
+
+import (
+	"net/url"
+)
+
+// Putter is implemented by Getters that can also upload a file to a URL,
+// so that tools constructing getters can push artifacts back reusing the
+// same URL and auth handling. It is separate from Getter, so getters only
+// implement it if their protocol supports uploads.
+type Putter interface {
+	// PutFile uploads the single file src to the URL.
+	PutFile(src string, u *url.URL) error
+}
@@ -0,0 +1,77 @@
+package getter
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// rateLimitReader throttles reading from the ReadCloser to rate bytes per
+// second, using a token bucket holding up to a second worth of bytes so that
+// short bursts aren't slowed down.
+type rateLimitReader struct {
+	io.ReadCloser
+
+	// ctx aborts waiting for the bucket to refill when it is done.
+	ctx context.Context
+
+	rate   int64
+	tokens int64
+	last   time.Time
+}
+
+// newRateLimitReader returns rc throttled to rate bytes per second, or rc
+// itself if rate isn't positive. Reads waiting for the bucket to refill
+// return the error of ctx when it is done.
+func newRateLimitReader(ctx context.Context, rc io.ReadCloser, rate int64) io.ReadCloser {
+	if rate <= 0 {
+		return rc
+	}
+	return &rateLimitReader{ReadCloser: rc, ctx: ctx, rate: rate, tokens: rate, last: time.Now()}
+}
+
+func (r *rateLimitReader) Read(p []byte) (int, error) {
+	// Never read more than the bucket holds, so that the wait per read is
+	// bounded and progress stays smooth
+	if int64(len(p)) > r.rate {
+		p = p[:r.rate]
+	}
+
+	n, err := r.ReadCloser.Read(p)
+	if terr := r.take(int64(n)); terr != nil && err == nil {
+		err = terr
+	}
+	return n, err
+}
+
+// take removes n tokens from the bucket, waiting until the bucket is no
+// longer in debt if there weren't enough, or until the context is done, in
+// which case its error is returned.
+func (r *rateLimitReader) take(n int64) error {
+	now := time.Now()
+	elapsed := now.Sub(r.last)
+	if elapsed > time.Second {
+		elapsed = time.Second
+	}
+	r.last = now
+
+	r.tokens += int64(elapsed) * r.rate / int64(time.Second)
+	if r.tokens > r.rate {
+		r.tokens = r.rate
+	}
+
+	r.tokens -= n
+	if r.tokens >= 0 {
+		return nil
+	}
+
+	// The tokens refilled while waiting are added by the next take
+	t := time.NewTimer(time.Duration(-r.tokens * int64(time.Second) / r.rate))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	}
+}
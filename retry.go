@@ -0,0 +1,94 @@
+package getter
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// defaultRetryBackoff is the wait before the first retry if the Client has
+// no RetryBackoff, and maxRetryBackoff the longest wait between retries.
+const (
+	defaultRetryBackoff = time.Second
+	maxRetryBackoff     = time.Minute
+)
+
+// retryable returns whether the download failing with err is retried.
+func (c *Client) retryable(err error) bool {
+	if c.Ctx != nil && c.Ctx.Err() != nil {
+		return false
+	}
+	if c.Retryable != nil {
+		return c.Retryable(err)
+	}
+	return isTransientError(err)
+}
+
+// isTransientError returns whether err is a transient failure that may not
+// happen again: network errors, 5xx and 429 responses of the HTTP and Maven
+// getters, and throttling or 5xx errors of S3.
+func isTransientError(err error) bool {
+	if err == io.ErrUnexpectedEOF {
+		return true
+	}
+
+	switch e := err.(type) {
+	case *TimeoutError:
+		return false
+	case *badResponseError:
+		return e.StatusCode >= 500 || e.StatusCode == 429
+	case net.Error:
+		return true
+	}
+
+	// Errors of the AWS SDK, without depending on its types here
+	if e, ok := err.(interface {
+		StatusCode() int
+	}); ok && (e.StatusCode() >= 500 || e.StatusCode() == 429) {
+		return true
+	}
+	if e, ok := err.(interface {
+		Code() string
+	}); ok {
+		switch e.Code() {
+		case "Throttling", "ThrottlingException", "ThrottledException", "RequestThrottled",
+			"RequestThrottledException", "SlowDown", "RequestLimitExceeded", "RequestTimeout":
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryBackoff returns the time to wait before the given retry, starting at
+// 1, doubling the wait before each retry.
+func (c *Client) retryBackoff(retry int) time.Duration {
+	backoff := c.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	for i := 1; i < retry && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return backoff
+}
+
+// sleep waits for d, returning early with the error of Ctx if it is done.
+func (c *Client) sleep(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	if c.Ctx == nil {
+		<-timer.C
+		return nil
+	}
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.Ctx.Done():
+		return c.Ctx.Err()
+	}
+}
@@ -0,0 +1,144 @@
+package getter
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pierrec/lz4"
+	"github.com/ulikunitz/xz"
+)
+
+// Streamer is implemented by Getters that can return the source as a
+// stream, instead of writing it to a destination.
+type Streamer interface {
+	// GetReader returns a reader of the single file the URL references.
+	// The caller must close it.
+	GetReader(*url.URL) (io.ReadCloser, error)
+}
+
+// streamDecompressors maps the keys of the single file Decompressors to a
+// function wrapping a reader so that it reads the decompressed data.
+var streamDecompressors = map[string]func(io.Reader) (io.Reader, error){
+	"gz": func(r io.Reader) (io.Reader, error) {
+		return gzip.NewReader(r)
+	},
+	"bz2": func(r io.Reader) (io.Reader, error) {
+		return bzip2.NewReader(r), nil
+	},
+	"xz": func(r io.Reader) (io.Reader, error) {
+		return xz.NewReader(r)
+	},
+	"lz4": func(r io.Reader) (io.Reader, error) {
+		return lz4.NewReader(r), nil
+	},
+}
+
+// GetStream returns a reader of the configured source instead of
+// downloading it to Dst, for callers that process the data in memory. The
+// caller must close it. Only file sources of getters implementing Streamer
+// can be streamed.
+//
+// If a checksum is given, reading the end of the stream returns an error
+// if the data read doesn't match it. If DecompressStream is set, gzip,
+// bzip2, xz and lz4 sources are decompressed while being read.
+func (c *Client) GetStream() (io.ReadCloser, error) {
+	g, force, u, err := c.resolveGetter()
+	if err != nil {
+		return nil, err
+	}
+	s, ok := g.(Streamer)
+	if !ok {
+		return nil, fmt.Errorf("streaming not supported for scheme '%s'", force)
+	}
+
+	c.logger().Printf("streaming with %s getter from '%s://%s%s'", force, u.Scheme, u.Host, u.Path)
+
+	// Determine if we have an archive type
+	q := u.Query()
+	archiveV := q.Get("archive")
+	if archiveV != "" {
+		q.Del("archive")
+		u.RawQuery = q.Encode()
+
+		if b, err := strconv.ParseBool(archiveV); err == nil && !b {
+			archiveV = "-"
+		}
+	}
+	if archiveV == "" {
+		for k := range streamDecompressors {
+			if strings.HasSuffix(u.Path, "."+k) {
+				archiveV = k
+			}
+		}
+	}
+
+	checksumHash, checksumValue, err := c.checksumParam(u)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := s.GetReader(u)
+	if err != nil {
+		return nil, err
+	}
+
+	// The checksum is of the source as downloaded, so it wraps the reader
+	// before decompression
+	if checksumHash != nil {
+		rc = &checksumReader{ReadCloser: rc, hash: checksumHash, value: checksumValue}
+	}
+
+	if c.DecompressStream {
+		if newReader := streamDecompressors[archiveV]; newReader != nil {
+			c.logger().Printf("decompressing %s stream", archiveV)
+			if rc, err = decompressReader(rc, newReader); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return rc, nil
+}
+
+// decompressReader wraps rc so that it reads the data decompressed by the
+// reader newReader returns, closing rc on error.
+func decompressReader(rc io.ReadCloser, newReader func(io.Reader) (io.Reader, error)) (io.ReadCloser, error) {
+	r, err := newReader(rc)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, rc}, nil
+}
+
+// checksumReader hashes the data read from the ReadCloser, and returns an
+// error instead of io.EOF if it doesn't match the expected value.
+type checksumReader struct {
+	io.ReadCloser
+	hash  hash.Hash
+	value []byte
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		if actual := r.hash.Sum(nil); !bytes.Equal(actual, r.value) {
+			return n, fmt.Errorf(
+				"Checksums did not match.\nExpected: %x\nGot: %x",
+				r.value, actual)
+		}
+	}
+	return n, err
+}
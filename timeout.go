@@ -0,0 +1,50 @@
+package getter
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TimeoutError is returned by the Client when the getter for Scheme didn't
+// download the source within its Timeout, see Client.Timeouts.
+type TimeoutError struct {
+	Scheme  string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s getter timed out after %s", e.Scheme, e.Timeout)
+}
+
+// timeout returns the time the getter for scheme has to download the
+// source, or zero if there is no limit.
+func (c *Client) timeout(scheme string) time.Duration {
+	if timeout, ok := c.Timeouts[scheme]; ok {
+		return timeout
+	}
+	return c.Timeout
+}
+
+// startTimeout bounds the context getters download with by timeout, until
+// the returned function is called with the error of the download. That
+// function returns the error, or a *TimeoutError if the timeout expired.
+func (c *Client) startTimeout(scheme string, timeout time.Duration) func(error) error {
+	parent := c.Ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	c.timeoutCtx = ctx
+
+	return func(err error) error {
+		c.timeoutCtx = nil
+		expired := ctx.Err() == context.DeadlineExceeded && parent.Err() == nil
+		cancel()
+
+		if err != nil && expired {
+			return &TimeoutError{Scheme: scheme, Timeout: timeout}
+		}
+		return err
+	}
+}
@@ -0,0 +1,45 @@
+package getter
+
+import (
+	"net/url"
+)
+
+// Validator is implemented by Getters that can cheaply check that a source
+// is reachable and valid, without downloading it.
+type Validator interface {
+	// Validate returns an error if the URL can't be downloaded.
+	Validate(*url.URL) error
+}
+
+// Validate checks the configured source can be downloaded, without
+// downloading it, so that configurations can be validated before starting
+// long running jobs. The source is detected and its query parameters are
+// checked like with Get, and getters implementing Validator check the
+// source itself, e.g. with a HEAD request for HTTP or git ls-remote for git.
+// Sources of other getters are only checked as far as the client can.
+func (c *Client) Validate() error {
+	g, force, u, err := c.resolveGetter()
+	if err != nil {
+		return err
+	}
+
+	// Check the magic query parameters, and remove them so that they aren't
+	// passed on to the getter
+	if _, _, err := c.checksumParam(u); err != nil {
+		return err
+	}
+	q := u.Query()
+	for _, k := range []string{"archive", "archive_entry", "filename", "mirror"} {
+		q.Del(k)
+	}
+	u.RawQuery = q.Encode()
+
+	v, ok := g.(Validator)
+	if !ok {
+		c.logger().Printf("%s getter can't validate sources, skipping", force)
+		return nil
+	}
+
+	c.logger().Printf("validating '%s://%s%s' with %s getter", u.Scheme, u.Host, u.Path, force)
+	return v.Validate(u)
+}
@@ -0,0 +1,252 @@
+package getter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// versionRange is a range of versions in the syntax of npm and Helm, Ex.,
+// "^1.2.3", "~1.2", "1.x", ">=1.0 <2.0", "1.0.0 - 2.0.0" or "1.2 || 2.x",
+// as alternative sets of comparators a version must match all of. As with
+// npm, a pre-release version is only in a set with a comparator against a
+// pre-release of the same version, Ex., 1.2.3-beta.2 is in "^1.2.3-beta.1"
+// but not in "^1.2.0".
+type versionRange [][]versionComparator
+
+// versionComparator compares versions to v with the operator op, one of
+// "=", "!=", ">", ">=", "<" and "<=".
+type versionComparator struct {
+	op string
+	v  *version.Version
+}
+
+// parseVersionRange parses the range expr. An empty range, "*" or "x",
+// matches all the versions that aren't pre-releases.
+func parseVersionRange(expr string) (versionRange, error) {
+	var r versionRange
+	for _, alt := range strings.Split(expr, "||") {
+		comparators, err := rangeComparators(strings.TrimSpace(alt))
+		if err != nil {
+			return nil, fmt.Errorf("invalid version range %q: %s", expr, err)
+		}
+		r = append(r, comparators)
+	}
+	return r, nil
+}
+
+// Check returns whether v is in the range.
+func (r versionRange) Check(v *version.Version) bool {
+	for _, comparators := range r {
+		match := true
+		preOK := v.Prerelease() == ""
+		for _, c := range comparators {
+			n := v.Compare(c.v)
+			switch c.op {
+			case "=":
+				match = match && n == 0
+			case "!=":
+				match = match && n != 0
+			case ">":
+				match = match && n > 0
+			case ">=":
+				match = match && n >= 0
+			case "<":
+				match = match && n < 0
+			case "<=":
+				match = match && n <= 0
+			}
+			if c.v.Prerelease() != "" && equalSegments(c.v, v) {
+				preOK = true
+			}
+		}
+		if match && preOK {
+			return true
+		}
+	}
+	return false
+}
+
+// latest returns the highest of the versions in the range, and false if
+// none is. Versions which don't parse are skipped.
+func (r versionRange) latest(versions []string) (string, bool) {
+	var latest *version.Version
+	var latestStr string
+	for _, s := range versions {
+		v, err := version.NewVersion(s)
+		if err != nil || !r.Check(v) {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest, latestStr = v, s
+		}
+	}
+	return latestStr, latest != nil
+}
+
+// equalSegments returns whether the versions are equal, ignoring their
+// pre-releases.
+func equalSegments(a, b *version.Version) bool {
+	as, bs := a.Segments(), b.Segments()
+	if len(as) != len(bs) {
+		return false
+	}
+	for i := range as {
+		if as[i] != bs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// rangeComparators returns the comparators of a range without
+// alternatives.
+func rangeComparators(expr string) ([]versionComparator, error) {
+	// A hyphen range, Ex., "1.2 - 2.3.4", includes both ends
+	if parts := strings.Split(expr, " - "); len(parts) == 2 {
+		from, err := parseComparator(">=", strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, err
+		}
+		to, err := parseComparator("<=", strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+		return append(from, to...), nil
+	}
+
+	// Comparators are separated by spaces, or commas as in Helm, and
+	// operators may be separated from their version, Ex., ">= 1.2, < 2"
+	var tokens []string
+	pending := ""
+	for _, f := range strings.Fields(strings.Replace(expr, ",", " ", -1)) {
+		if strings.Trim(f, "<>=^~!") == "" {
+			pending += f
+			continue
+		}
+		tokens = append(tokens, pending+f)
+		pending = ""
+	}
+	if pending != "" {
+		return nil, fmt.Errorf("operator %q without a version", pending)
+	}
+
+	var comparators []versionComparator
+	for _, t := range tokens {
+		v := strings.TrimLeft(t, "<>=^~!")
+		c, err := parseComparator(t[:len(t)-len(v)], v)
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, c...)
+	}
+	return comparators, nil
+}
+
+// parseComparator returns the comparators of the operator applied to the
+// version v, which can be partial, Ex., "1", "1.2" or "1.x".
+func parseComparator(op, v string) ([]versionComparator, error) {
+	v = strings.TrimPrefix(v, "v")
+	pre := ""
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v, pre = v[:i], v[i:]
+	}
+
+	// The parts given, up to the first wildcard
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		if p == "x" || p == "X" || p == "*" || p == "" {
+			break
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", v)
+		}
+		parts = append(parts, n)
+	}
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid version %q", v)
+	}
+	if len(parts) < 3 {
+		// Only complete versions have pre-releases
+		pre = ""
+	}
+	full := make([]int, 3)
+	copy(full, parts)
+	lower, err := version.NewVersion(fmt.Sprintf("%d.%d.%d%s", full[0], full[1], full[2], pre))
+	if err != nil {
+		return nil, err
+	}
+
+	// next returns the version after the given number of leading parts,
+	// Ex., 1.3.0 for 1.2.3 and 2
+	next := func(n int) *version.Version {
+		bumped := make([]int, 3)
+		copy(bumped, full[:n])
+		bumped[n-1]++
+		return version.Must(version.NewVersion(fmt.Sprintf("%d.%d.%d", bumped[0], bumped[1], bumped[2])))
+	}
+	between := func(n int) []versionComparator {
+		return []versionComparator{{">=", lower}, {"<", next(n)}}
+	}
+
+	switch op {
+	case "", "=":
+		if len(parts) == 0 {
+			return nil, nil
+		}
+		if len(parts) == 3 {
+			return []versionComparator{{"=", lower}}, nil
+		}
+		return between(len(parts)), nil
+	case "^":
+		if len(parts) == 0 {
+			return nil, nil
+		}
+		// The first non-zero part is fixed, Ex., ^0.2.3 is below 0.3.0
+		n := 1
+		for n < len(parts) && full[n-1] == 0 {
+			n++
+		}
+		return between(n), nil
+	case "~":
+		if len(parts) == 0 {
+			return nil, nil
+		}
+		if len(parts) == 1 {
+			return between(1), nil
+		}
+		return between(2), nil
+	case ">", "<=":
+		if len(parts) == 0 {
+			if op == ">" {
+				return nil, fmt.Errorf("no version is above %q", v)
+			}
+			return nil, nil
+		}
+		if len(parts) == 3 {
+			return []versionComparator{{op, lower}}, nil
+		}
+		// Ex., >1.2 is >=1.3.0, and <=1.2 is <1.3.0
+		if op == ">" {
+			return []versionComparator{{">=", next(len(parts))}}, nil
+		}
+		return []versionComparator{{"<", next(len(parts))}}, nil
+	case ">=", "<":
+		if len(parts) == 0 {
+			if op == "<" {
+				return nil, fmt.Errorf("no version is below %q", v)
+			}
+			return nil, nil
+		}
+		return []versionComparator{{op, lower}}, nil
+	case "!=":
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("!= requires a complete version, got %q", v)
+		}
+		return []versionComparator{{"!=", lower}}, nil
+	}
+	return nil, fmt.Errorf("unsupported operator %q", op)
+}
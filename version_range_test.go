@@ -0,0 +1,61 @@
+package getter
+
+import (
+	"testing"
+)
+
+func TestVersionRange(t *testing.T) {
+	versions := []string{
+		"0.1.0", "0.1.5", "0.2.0", "1.0.0", "1.2.0", "1.2.3", "1.2.4-beta.1",
+		"1.2.9", "1.3.0", "2.0.0-rc.1", "2.0.0", "2.1.0", "v3.0.0", "invalid",
+	}
+
+	cases := []struct {
+		Range  string
+		Latest string
+	}{
+		{"", "v3.0.0"},
+		{"*", "v3.0.0"},
+		{"1.2.3", "1.2.3"},
+		{"=1.2.3", "1.2.3"},
+		{"v1.2.3", "1.2.3"},
+		{"1.2", "1.2.9"},
+		{"1.2.x", "1.2.9"},
+		{"1", "1.3.0"},
+		{"1.x", "1.3.0"},
+		{"^1.2.3", "1.3.0"},
+		{"^0.1.0", "0.1.5"},
+		{"^0.0.1", ""},
+		{"~1.2.3", "1.2.9"},
+		{"~1", "1.3.0"},
+		{">=1.0 <2", "1.3.0"},
+		{">= 1.0.0, < 2.0.0", "1.3.0"},
+		{">1.2", "v3.0.0"},
+		{"<=1.2", "1.2.9"},
+		{"<1.2.3", "1.2.0"},
+		{"1.0.0 - 1.2", "1.2.9"},
+		{"0.1 || 1.2", "1.2.9"},
+		{"^1.2.0 !=1.3.0", "1.2.9"},
+		{"^1.2.4-beta.0", "1.3.0"},
+		{"1.2.4-beta.1", "1.2.4-beta.1"},
+		{">=2.0.0-rc.0 <2.0.0", "2.0.0-rc.1"},
+		{"^4", ""},
+	}
+
+	for _, tc := range cases {
+		r, err := parseVersionRange(tc.Range)
+		if err != nil {
+			t.Fatalf("%q: err: %s", tc.Range, err)
+		}
+		latest, ok := r.latest(versions)
+		if latest != tc.Latest || ok != (tc.Latest != "") {
+			t.Fatalf("%q: bad latest: %q", tc.Range, latest)
+		}
+	}
+
+	for _, expr := range []string{"abc", ">=", "1.2.3.4", "<*", "~>1.2", "!=1.2"} {
+		if _, err := parseVersionRange(expr); err == nil {
+			t.Fatalf("%q: should error", expr)
+		}
+	}
+}